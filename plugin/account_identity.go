@@ -0,0 +1,167 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// resolveAccountIdentity - unmarshal account parameters. Account parameters can contain:
+//   - DerivationAddressIdentity - derivation path of account
+//   - StringValue - hex address of account. Address resolved to derivation path by in-memory address index.
+//     Index contains addresses of loaded accounts, addresses registered by host and
+//     addresses found by FindAccountByAddress method
+func (u *mnemonicWalletUnit) resolveAccountIdentity(ctx context.Context,
+	accountParameters *anypb.Any,
+) (*pbCommon.DerivationAddressIdentity, error) {
+	addressIdentity := &wrapperspb.StringValue{}
+	if !accountParameters.MessageIs(addressIdentity) {
+		accIdentity := &pbCommon.DerivationAddressIdentity{}
+		err := accountParameters.UnmarshalTo(accIdentity)
+		if err != nil {
+			return nil, err
+		}
+
+		return accIdentity, nil
+	}
+
+	err := accountParameters.UnmarshalTo(addressIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := u.resolveAddress(ctx, addressIdentity.GetValue())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbCommon.DerivationAddressIdentity{
+		AccountIndex:  path.AccountIndex,
+		InternalIndex: path.InternalIndex,
+		AddressIndex:  path.AddressIndex,
+	}, nil
+}
+
+func (u *mnemonicWalletUnit) resolveAddress(_ context.Context,
+	address string,
+) (derivationPath, error) {
+	if !common.IsHexAddress(address) {
+		return derivationPath{}, fmt.Errorf("%w: %s", ErrAddressInvalid, address)
+	}
+
+	addr := common.HexToAddress(address)
+
+	path, isExists := u.addrIndex.get(addr)
+	if isExists {
+		return path, nil
+	}
+
+	// registered by host addresses verified by derivation on registration
+	path, isExists = u.addrIndex.getRegistered(addr)
+	if !isExists {
+		return derivationPath{}, fmt.Errorf("%w: %s", ErrAddressNotOwned, addr.Hex())
+	}
+
+	return path, nil
+}
+
+// RegisterAccountAddresses - register address to derivation path mapping, e.g. from host storage.
+// Registered addresses can be used as address identity in account parameters. Every address is
+// verified by derivation from public change node before registration, nothing registered if any address
+// not belongs to its derivation path
+func (u *mnemonicWalletUnit) RegisterAccountAddresses(_ context.Context,
+	accounts []*pbCommon.AccountIdentity,
+) error {
+	paths := make([]*pbCommon.DerivationAddressIdentity, len(accounts))
+	for i, account := range accounts {
+		if account == nil || !common.IsHexAddress(account.Address) {
+			return fmt.Errorf("%w: position %d", ErrAddressInvalid, i)
+		}
+
+		accIdentity := &pbCommon.DerivationAddressIdentity{}
+		err := account.Parameters.UnmarshalTo(accIdentity)
+		if err != nil {
+			return err
+		}
+
+		derivedAddress, err := u.deriveAddressByPublicNode(accIdentity.AccountIndex,
+			accIdentity.InternalIndex,
+			accIdentity.AddressIndex)
+		if err != nil {
+			return err
+		}
+
+		if derivedAddress != common.HexToAddress(account.Address) {
+			return fmt.Errorf("%w: %s - derivation path belongs to %s", ErrAddressNotOwned,
+				common.HexToAddress(account.Address).Hex(), derivedAddress.Hex())
+		}
+
+		paths[i] = accIdentity
+	}
+
+	for i, account := range accounts {
+		u.addrIndex.register(common.HexToAddress(account.Address), paths[i].AccountIndex,
+			paths[i].InternalIndex,
+			paths[i].AddressIndex)
+	}
+
+	return nil
+}
+
+// deriveAddressByPublicNode - derive address by cached public change node, without private keys derivation
+func (u *mnemonicWalletUnit) deriveAddressByPublicNode(account, change, index uint32) (common.Address, error) {
+	node, err := u.changePublicNode(account, change)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	childNode, err := node.Derive(index)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pubKey, err := childNode.ECPubKey()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pubKey.ToECDSA()), nil
+}
@@ -0,0 +1,181 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMnemonicWalletUnit_SignData_ByAddress(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	// derivation path 7'/8/9
+	loadedAddress := "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	recipient := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+	dataForSign, _ := types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(10000000000),
+		Gas:      21000,
+		To:       &recipient,
+		Value:    big.NewInt(1500000),
+	}).MarshalBinary()
+
+	signByAddress := func(address string) (*string, error) {
+		addressIdentity, marshalErr := anypb.New(wrapperspb.String(address))
+		if marshalErr != nil {
+			t.Fatalf("%s: %e", "unable to marshal address identity", marshalErr)
+		}
+
+		addr, signedData, signErr := poolUnit.SignData(context.Background(), addressIdentity, dataForSign)
+		if signErr != nil {
+			return nil, signErr
+		}
+
+		signedTx := &types.Transaction{}
+		_ = signedTx.UnmarshalBinary(signedData)
+
+		sender, signErr := types.Sender(poolUnit.dataSigner, signedTx)
+		if signErr != nil || sender.Hex() != *addr {
+			t.Fatalf("%s", "sender not equal with expected")
+		}
+
+		return addr, nil
+	}
+
+	_, err = signByAddress(loadedAddress)
+	if !errors.Is(err, ErrAddressNotOwned) {
+		t.Fatalf("%s", "unknown address must not be resolved")
+	}
+
+	pathIdentity := &anypb.Any{}
+	_ = pathIdentity.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	_, err = poolUnit.LoadAccount(context.Background(), pathIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to load account", err)
+	}
+
+	addr, err := signByAddress(loadedAddress)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data by loaded address", err)
+	}
+
+	if *addr != loadedAddress {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	// registered by host address
+	hdWalletAccount, err := poolUnit.hdWalletSvc.NewAccount(3, 0, 1001)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive account", err)
+	}
+
+	registeredAddress, _ := hdWalletAccount.GetAddress()
+	hdWalletAccount.ClearSecrets()
+
+	registeredPath := &anypb.Any{}
+	_ = registeredPath.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  3,
+		InternalIndex: 0,
+		AddressIndex:  1001,
+	})
+
+	foreignAddress := "0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489"
+	err = poolUnit.RegisterAccountAddresses(context.Background(), []*pbCommon.AccountIdentity{
+		{Parameters: registeredPath, Address: registeredAddress},
+		{Parameters: registeredPath, Address: foreignAddress},
+	})
+	if !errors.Is(err, ErrAddressNotOwned) {
+		t.Fatalf("%s", "address with wrong derivation path must not be registered")
+	}
+
+	_, err = signByAddress(registeredAddress)
+	if !errors.Is(err, ErrAddressNotOwned) {
+		t.Fatalf("%s", "addresses of rejected registration batch must not be registered")
+	}
+
+	// repeated registration of same address stored once
+	for i := 0; i < 2; i++ {
+		err = poolUnit.RegisterAccountAddresses(context.Background(), []*pbCommon.AccountIdentity{
+			{Parameters: registeredPath, Address: registeredAddress},
+		})
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to register addresses", err)
+		}
+	}
+
+	registeredPair := poolUnit.addrIndex.pairs[changeNodeKey(3, 0)]
+	if len(registeredPair.addresses) != 1 {
+		t.Fatalf("%s: %d", "count of registered pair addresses not equal with expected",
+			len(registeredPair.addresses))
+	}
+
+	addr, err = signByAddress(registeredAddress)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data by registered address", err)
+	}
+
+	if *addr != registeredAddress {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	_, err = signByAddress(foreignAddress)
+	if !errors.Is(err, ErrAddressNotOwned) {
+		t.Fatalf("%s", "address with wrong registered derivation path must not be resolved")
+	}
+}
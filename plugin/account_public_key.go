@@ -0,0 +1,260 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// publicKeysMaxBatchSize - max count of public keys in one batch request
+const publicKeysMaxBatchSize = 100_000
+
+var ErrPublicKeysWrongRange = errors.New("wrong public keys range")
+
+// accountPublicKey - secp256k1 public key of derived account, JSON format
+type accountPublicKey struct {
+	derivationPath
+
+	Address string `json:"address"`
+	// CompressedPublicKey - 33 bytes, SEC1 compressed form
+	CompressedPublicKey hexutil.Bytes `json:"compressedPublicKey"`
+	// UncompressedPublicKey - 65 bytes, SEC1 uncompressed form with 0x04 prefix
+	UncompressedPublicKey hexutil.Bytes `json:"uncompressedPublicKey"`
+}
+
+// GetAccountPublicKey - returns address and JSON-encoded public key of account.
+// Public key derived by public change level node, private keys not loaded to address pool.
+// Public key of hardened address index derived by private key of unlocked wallet
+func (u *mnemonicWalletUnit) GetAccountPublicKey(ctx context.Context,
+	accountParameters *anypb.Any,
+) (*string, []byte, error) {
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pubKey *accountPublicKey
+	if accIdentity.AddressIndex >= hdkeychain.HardenedKeyStart {
+		pubKey, err = u.deriveHardenedPublicKey(accIdentity.AccountIndex,
+			accIdentity.InternalIndex,
+			accIdentity.AddressIndex)
+	} else {
+		var node *hdkeychain.ExtendedKey
+		node, err = u.getChangePublicNode(accIdentity.AccountIndex, accIdentity.InternalIndex)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pubKey, err = u.derivePublicKey(node, accIdentity.AccountIndex,
+			accIdentity.InternalIndex,
+			accIdentity.AddressIndex)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubKeyData, err := json.Marshal(pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &pubKey.Address, pubKeyData, nil
+}
+
+// GetMultipleAccountPublicKeys - same as GetAccountPublicKey for ranges of accounts, like GetMultipleAccounts.
+// Returns count and JSON-encoded list of public keys
+func (u *mnemonicWalletUnit) GetMultipleAccountPublicKeys(ctx context.Context,
+	multipleAccountsParameters *anypb.Any,
+) (uint, []byte, error) {
+	rangeList := &pbCommon.RangeUnitsList{}
+	err := multipleAccountsParameters.UnmarshalTo(rangeList)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var size uint64
+	for _, rangeUnit := range rangeList.RangeUnits {
+		if rangeUnit.AddressIndexFrom > rangeUnit.AddressIndexTo {
+			return 0, nil, fmt.Errorf("%w: address index from %d greater than address index to %d",
+				ErrPublicKeysWrongRange, rangeUnit.AddressIndexFrom, rangeUnit.AddressIndexTo)
+		}
+
+		size += uint64(rangeUnit.AddressIndexTo-rangeUnit.AddressIndexFrom) + 1
+	}
+
+	if size > publicKeysMaxBatchSize {
+		return 0, nil, fmt.Errorf("%w: batch size %d greater than %d",
+			ErrPublicKeysWrongRange, size, publicKeysMaxBatchSize)
+	}
+
+	result := make([]*accountPublicKey, 0, size)
+
+	for _, rangeUnit := range rangeList.RangeUnits {
+		// public change node not needed for fully hardened range
+		var node *hdkeychain.ExtendedKey
+
+		for index := rangeUnit.AddressIndexFrom; ; index++ {
+			if ctx.Err() != nil {
+				return 0, nil, ctx.Err()
+			}
+
+			var (
+				pubKey  *accountPublicKey
+				loopErr error
+			)
+
+			if index >= hdkeychain.HardenedKeyStart {
+				pubKey, loopErr = u.deriveHardenedPublicKey(rangeUnit.AccountIndex, rangeUnit.InternalIndex, index)
+			} else {
+				if node == nil {
+					node, loopErr = u.getChangePublicNode(rangeUnit.AccountIndex, rangeUnit.InternalIndex)
+					if loopErr != nil {
+						return 0, nil, loopErr
+					}
+				}
+
+				pubKey, loopErr = u.derivePublicKey(node, rangeUnit.AccountIndex, rangeUnit.InternalIndex, index)
+			}
+			if loopErr != nil {
+				return 0, nil, loopErr
+			}
+
+			result = append(result, pubKey)
+
+			if index == rangeUnit.AddressIndexTo {
+				break
+			}
+		}
+	}
+
+	pubKeysData, err := json.Marshal(result)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uint(len(result)), pubKeysData, nil
+}
+
+// getChangePublicNode - returns cached in address index public change node or derive new one
+func (u *mnemonicWalletUnit) getChangePublicNode(account, change uint32) (*hdkeychain.ExtendedKey, error) {
+	node, isExists := u.addrIndex.getChangeNode(account, change)
+	if isExists {
+		return node, nil
+	}
+
+	node, err := u.newChangePublicNode(account, change)
+	if err != nil {
+		return nil, err
+	}
+
+	u.addrIndex.putChangeNode(account, change, node)
+
+	return node, nil
+}
+
+func (u *mnemonicWalletUnit) derivePublicKey(node *hdkeychain.ExtendedKey,
+	account, change, index uint32,
+) (*accountPublicKey, error) {
+	childNode, err := node.Derive(index)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := childNode.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+
+	address := crypto.PubkeyToAddress(*pubKey.ToECDSA())
+	u.addrIndex.put(address, account, change, index)
+
+	return &accountPublicKey{
+		derivationPath: derivationPath{
+			AccountIndex:  account,
+			InternalIndex: change,
+			AddressIndex:  index,
+		},
+		Address:               address.Hex(),
+		CompressedPublicKey:   pubKey.SerializeCompressed(),
+		UncompressedPublicKey: pubKey.SerializeUncompressed(),
+	}, nil
+}
+
+// deriveHardenedPublicKey - derive public key of hardened address index, private key required
+func (u *mnemonicWalletUnit) deriveHardenedPublicKey(account, change, index uint32) (*accountPublicKey, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	hdWalletSvc, err := u.unlockedWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	hdWalletAccount, err := hdWalletSvc.NewAccount(account, change, index)
+	if err != nil {
+		return nil, err
+	}
+
+	privKey := hdWalletAccount.CloneECDSAPrivateKey()
+	hdWalletAccount.ClearSecrets()
+	hdWalletAccount = nil
+
+	// public key shares coordinates with private key, so it serialized before private key wipe
+	address := crypto.PubkeyToAddress(privKey.PublicKey)
+	compressedPubKey := crypto.CompressPubkey(&privKey.PublicKey)
+	uncompressedPubKey := crypto.FromECDSAPub(&privKey.PublicKey)
+	zeroKey(privKey)
+
+	u.addrIndex.put(address, account, change, index)
+
+	return &accountPublicKey{
+		derivationPath: derivationPath{
+			AccountIndex:  account,
+			InternalIndex: change,
+			AddressIndex:  index,
+		},
+		Address:               address.Hex(),
+		CompressedPublicKey:   compressedPubKey,
+		UncompressedPublicKey: uncompressedPubKey,
+	}, nil
+}
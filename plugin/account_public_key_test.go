@@ -0,0 +1,264 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMnemonicWalletUnit_GetAccountPublicKey(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	addr, pubKeyData, err := poolUnit.GetAccountPublicKey(context.Background(), accountIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get account public key", err)
+	}
+
+	if *addr != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	pubKey := &accountPublicKey{}
+	err = json.Unmarshal(pubKeyData, pubKey)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal public key", err)
+	}
+
+	hdWalletAccount, err := poolUnit.hdWalletSvc.NewAccount(7, 8, 9)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive account", err)
+	}
+
+	expectedCompressedPubKey := hdWalletAccount.GetPubKey()
+	hdWalletAccount.ClearSecrets()
+
+	if pubKey.CompressedPublicKey.String() != expectedCompressedPubKey {
+		t.Fatalf("%s", "compressed public key not equal with expected")
+	}
+
+	uncompressedPubKey, err := crypto.UnmarshalPubkey(pubKey.UncompressedPublicKey)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal uncompressed public key", err)
+	}
+
+	decompressedPubKey, err := crypto.DecompressPubkey(pubKey.CompressedPublicKey)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decompress public key", err)
+	}
+
+	if !decompressedPubKey.Equal(uncompressedPubKey) ||
+		crypto.PubkeyToAddress(*uncompressedPubKey).Hex() != pubKey.Address ||
+		pubKey.AccountIndex != 7 || pubKey.InternalIndex != 8 || pubKey.AddressIndex != 9 {
+		t.Fatalf("%s", "public key forms not equal with each other")
+	}
+
+	if len(poolUnit.addressPool) != 0 {
+		t.Fatalf("%s", "private keys must not be loaded to address pool")
+	}
+}
+
+func TestMnemonicWalletUnit_GetMultipleAccountPublicKeys(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	rangeParams, _ := anypb.New(&pbCommon.RangeUnitsList{
+		RangeUnits: []*pbCommon.RangeRequestUnit{
+			{AccountIndex: 3, InternalIndex: 0, AddressIndexFrom: 0, AddressIndexTo: 4},
+			{AccountIndex: 7, InternalIndex: 8, AddressIndexFrom: 9, AddressIndexTo: 9},
+		},
+	})
+
+	count, pubKeysData, err := poolUnit.GetMultipleAccountPublicKeys(context.Background(), rangeParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get multiple public keys", err)
+	}
+
+	expectedCount, accounts, err := poolUnit.GetMultipleAccounts(context.Background(), rangeParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get multiple accounts", err)
+	}
+
+	pubKeys := make([]*accountPublicKey, 0)
+	err = json.Unmarshal(pubKeysData, &pubKeys)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal public keys", err)
+	}
+
+	if count != expectedCount || len(pubKeys) != len(accounts) {
+		t.Fatalf("%s", "public keys count not equal with expected")
+	}
+
+	for i, pubKey := range pubKeys {
+		if pubKey.Address != accounts[i].Address {
+			t.Fatalf("%s", "public key address not equal with expected")
+		}
+
+		uncompressedPubKey, err := crypto.UnmarshalPubkey(pubKey.UncompressedPublicKey)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to unmarshal uncompressed public key", err)
+		}
+
+		if hexutil.Encode(crypto.CompressPubkey(uncompressedPubKey)) != pubKey.CompressedPublicKey.String() {
+			t.Fatalf("%s", "compressed public key not equal with expected")
+		}
+	}
+
+	if len(poolUnit.addressPool) != 0 {
+		t.Fatalf("%s", "private keys must not be loaded to address pool")
+	}
+
+	wrongRangeParams, _ := anypb.New(&pbCommon.RangeUnitsList{
+		RangeUnits: []*pbCommon.RangeRequestUnit{
+			{AccountIndex: 3, InternalIndex: 0, AddressIndexFrom: 5, AddressIndexTo: 4},
+		},
+	})
+
+	_, _, err = poolUnit.GetMultipleAccountPublicKeys(context.Background(), wrongRangeParams)
+	if err == nil {
+		t.Fatalf("%s", "wrong range must not be accepted")
+	}
+}
+
+func TestMnemonicWalletUnit_GetAccountPublicKey_HardenedIndex(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	hardenedIndex := uint32(hdkeychain.HardenedKeyStart + 9)
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  hardenedIndex,
+	})
+
+	addr, pubKeyData, err := poolUnit.GetAccountPublicKey(context.Background(), accountIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get account public key of hardened index", err)
+	}
+
+	hdWalletAccount, err := poolUnit.hdWalletSvc.NewAccount(7, 8, hardenedIndex)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive account", err)
+	}
+
+	expectedAddress, _ := hdWalletAccount.GetAddress()
+	expectedCompressedPubKey := hdWalletAccount.GetPubKey()
+	hdWalletAccount.ClearSecrets()
+
+	pubKey := &accountPublicKey{}
+	err = json.Unmarshal(pubKeyData, pubKey)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal public key", err)
+	}
+
+	if *addr != expectedAddress || pubKey.CompressedPublicKey.String() != expectedCompressedPubKey {
+		t.Fatalf("%s", "public key of hardened index not equal with expected")
+	}
+
+	rangeList, _ := anypb.New(&pbCommon.RangeUnitsList{
+		RangeUnits: []*pbCommon.RangeRequestUnit{
+			{
+				AccountIndex:     7,
+				InternalIndex:    8,
+				AddressIndexFrom: hardenedIndex - 1,
+				AddressIndexTo:   hardenedIndex,
+			},
+		},
+	})
+
+	count, pubKeysData, err := poolUnit.GetMultipleAccountPublicKeys(context.Background(), rangeList)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get public keys of range with hardened indexes", err)
+	}
+
+	pubKeys := make([]*accountPublicKey, 0)
+	err = json.Unmarshal(pubKeysData, &pubKeys)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal public keys", err)
+	}
+
+	if count != 2 || len(pubKeys) != 2 || pubKeys[1].Address != expectedAddress {
+		t.Fatalf("%s", "public keys of range not equal with expected")
+	}
+
+	if len(poolUnit.addressPool) != 0 {
+		t.Fatalf("%s", "private keys must not be loaded to address pool")
+	}
+}
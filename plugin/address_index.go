@@ -0,0 +1,625 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	addressSearchDefaultIndexGap     = 1000
+	addressSearchDefaultWorkersCount = 4
+	addressSearchMaxWorkersCount     = 64
+	// addressSearchMaxScanSize - max count of derived addresses for one search request
+	addressSearchMaxScanSize = 1_000_000
+
+	// addressIndexMaxSize - max count of indexed and registered addresses. Addresses of least recently used
+	// account and change pairs evicted on overflow
+	addressIndexMaxSize = 2 * addressSearchMaxScanSize
+	// addressIndexMaxPairs - max count of cached account and change pairs with public change nodes
+	addressIndexMaxPairs = 4096
+)
+
+var (
+	ErrAddressNotFound          = errors.New("address not found in wallet derivation paths")
+	ErrAddressSearchWrongBounds = errors.New("wrong address search bounds")
+	ErrAddressInvalid           = errors.New("invalid address")
+	ErrAddressNotOwned          = errors.New("address is not owned by wallet or not loaded to address index")
+)
+
+// addressSearchBounds - bounds of derivation paths for address reverse lookup
+type addressSearchBounds struct {
+	AccountFrom uint32 `json:"accountFrom"`
+	AccountTo   uint32 `json:"accountTo"`
+	ChangeFrom  uint32 `json:"changeFrom"`
+	ChangeTo    uint32 `json:"changeTo"`
+	// IndexGap - gap limit, count of consecutive unused address indexes of every account and change pair
+	// after which scan of pair stops. Address is used if it was issued by wallet or registered by host. Default 1000
+	IndexGap uint32 `json:"indexGap"`
+	// Workers - size of derivation worker pool, default 4, max 64
+	Workers uint `json:"workers,omitempty"`
+}
+
+func (b *addressSearchBounds) validate() error {
+	if b.AccountFrom > b.AccountTo || b.ChangeFrom > b.ChangeTo {
+		return ErrAddressSearchWrongBounds
+	}
+
+	if b.IndexGap == 0 {
+		b.IndexGap = addressSearchDefaultIndexGap
+	}
+
+	scanSize := uint64(b.AccountTo-b.AccountFrom+1) * uint64(b.ChangeTo-b.ChangeFrom+1) * uint64(b.IndexGap)
+	if scanSize > addressSearchMaxScanSize {
+		return fmt.Errorf("%w: scan size %d greater than %d", ErrAddressSearchWrongBounds,
+			scanSize, addressSearchMaxScanSize)
+	}
+
+	if b.Workers == 0 {
+		b.Workers = addressSearchDefaultWorkersCount
+	}
+
+	if b.Workers > addressSearchMaxWorkersCount {
+		b.Workers = addressSearchMaxWorkersCount
+	}
+
+	return nil
+}
+
+// contains - path account and change in bounds
+func (b *addressSearchBounds) contains(path derivationPath) bool {
+	return path.AccountIndex >= b.AccountFrom && path.AccountIndex <= b.AccountTo &&
+		path.InternalIndex >= b.ChangeFrom && path.InternalIndex <= b.ChangeTo
+}
+
+// indexPair - cached data of account and change pair m/44'/coinType'/account'/change
+type indexPair struct {
+	// node - cached public extended key of change level, nil if not cached yet
+	node *hdkeychain.ExtendedKey
+	// scannedTo - count of indexed address indexes from zero index
+	scannedTo uint32
+	// used - address indexes issued by wallet or registered by host
+	used map[uint32]struct{}
+	// addresses - indexed and registered addresses of pair, used for eviction
+	addresses []common.Address
+	// lastUsage - value of index usage counter on last access to pair
+	lastUsage atomic.Uint64
+}
+
+// gapLimitEnd - returns end of scan window of pair: scan stops after gap consecutive unused indexes
+func (p *indexPair) gapLimitEnd(gap uint32) uint32 {
+	usedIndexes := make([]uint32, 0, len(p.used))
+	for index := range p.used {
+		usedIndexes = append(usedIndexes, index)
+	}
+
+	sort.Slice(usedIndexes, func(i, j int) bool {
+		return usedIndexes[i] < usedIndexes[j]
+	})
+
+	end := uint64(gap)
+	for _, index := range usedIndexes {
+		if uint64(index) >= end {
+			break
+		}
+
+		end = uint64(index) + 1 + uint64(gap)
+	}
+
+	return uint32(min(end, hdkeychain.HardenedKeyStart))
+}
+
+// addressIndex - incrementally built in-memory index of wallet addresses. Contains only public data.
+// Size of index bounded by addressIndexMaxSize addresses and addressIndexMaxPairs account and change pairs,
+// data of least recently used pairs evicted on overflow
+type addressIndex struct {
+	mu *sync.RWMutex
+
+	// addresses - map key - address, map value - derivation path
+	addresses map[common.Address]derivationPath
+	// registered - addresses registered by host, map key - address, map value - derivation path.
+	// Verified by derivation on registration
+	registered map[common.Address]derivationPath
+	// pairs - map key - account and change pair
+	pairs map[uint64]*indexPair
+	// usageCounter - logical clock of pairs usage
+	usageCounter atomic.Uint64
+}
+
+func newAddressIndex() *addressIndex {
+	return &addressIndex{
+		mu:         &sync.RWMutex{},
+		addresses:  make(map[common.Address]derivationPath),
+		registered: make(map[common.Address]derivationPath),
+		pairs:      make(map[uint64]*indexPair),
+	}
+}
+
+func changeNodeKey(account, change uint32) uint64 {
+	return uint64(account)<<32 | uint64(change)
+}
+
+// touch - marks pair as recently used. Safe for call under read lock
+func (i *addressIndex) touch(pair *indexPair) {
+	pair.lastUsage.Store(i.usageCounter.Add(1))
+}
+
+// getPair - returns existing or new pair. Must be called under write lock
+func (i *addressIndex) getPair(account, change uint32) *indexPair {
+	key := changeNodeKey(account, change)
+
+	pair, isExists := i.pairs[key]
+	if !isExists {
+		pair = &indexPair{
+			used: make(map[uint32]struct{}),
+		}
+		i.pairs[key] = pair
+	}
+
+	i.touch(pair)
+
+	return pair
+}
+
+// isIndexed - address already stored in pair addresses. Must be called under lock
+func (i *addressIndex) isIndexed(address common.Address) bool {
+	_, isExists := i.addresses[address]
+	if isExists {
+		return true
+	}
+
+	_, isExists = i.registered[address]
+
+	return isExists
+}
+
+// evict - removes data of least recently used pairs until index fit to size bounds.
+// Pair of current operation never evicted. Must be called under write lock
+func (i *addressIndex) evict(currentKey uint64) {
+	for len(i.addresses)+len(i.registered) > addressIndexMaxSize || len(i.pairs) > addressIndexMaxPairs {
+		var (
+			lruKey   uint64
+			lruPair  *indexPair
+			lruUsage uint64
+		)
+
+		for key, pair := range i.pairs {
+			if key == currentKey {
+				continue
+			}
+
+			usage := pair.lastUsage.Load()
+			if lruPair == nil || usage < lruUsage {
+				lruKey, lruPair, lruUsage = key, pair, usage
+			}
+		}
+
+		if lruPair == nil {
+			return
+		}
+
+		for _, address := range lruPair.addresses {
+			path, isExists := i.addresses[address]
+			if isExists && changeNodeKey(path.AccountIndex, path.InternalIndex) == lruKey {
+				delete(i.addresses, address)
+			}
+
+			path, isExists = i.registered[address]
+			if isExists && changeNodeKey(path.AccountIndex, path.InternalIndex) == lruKey {
+				delete(i.registered, address)
+			}
+		}
+
+		// evicted public node not zeroed - it can be used by concurrent scan
+		delete(i.pairs, lruKey)
+	}
+}
+
+func (i *addressIndex) get(address common.Address) (derivationPath, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	path, isExists := i.addresses[address]
+	if isExists {
+		pair, isPairExists := i.pairs[changeNodeKey(path.AccountIndex, path.InternalIndex)]
+		if isPairExists {
+			i.touch(pair)
+		}
+	}
+
+	return path, isExists
+}
+
+// put - puts issued by wallet address to index and marks it as used
+func (i *addressIndex) put(address common.Address, account, change, index uint32) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pair := i.getPair(account, change)
+	pair.used[index] = struct{}{}
+
+	if !i.isIndexed(address) {
+		pair.addresses = append(pair.addresses, address)
+	}
+
+	i.addresses[address] = derivationPath{
+		AccountIndex:  account,
+		InternalIndex: change,
+		AddressIndex:  index,
+	}
+
+	i.evict(changeNodeKey(account, change))
+}
+
+func (i *addressIndex) getRegistered(address common.Address) (derivationPath, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	path, isExists := i.registered[address]
+
+	return path, isExists
+}
+
+// register - puts address registered by host to index and marks it as used.
+// Address must be verified by derivation before registration
+func (i *addressIndex) register(address common.Address, account, change, index uint32) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pair := i.getPair(account, change)
+	pair.used[index] = struct{}{}
+
+	if !i.isIndexed(address) {
+		pair.addresses = append(pair.addresses, address)
+	}
+
+	i.registered[address] = derivationPath{
+		AccountIndex:  account,
+		InternalIndex: change,
+		AddressIndex:  index,
+	}
+
+	i.evict(changeNodeKey(account, change))
+}
+
+func (i *addressIndex) putHex(address string, account, change, index uint32) {
+	if !common.IsHexAddress(address) {
+		return
+	}
+
+	i.put(common.HexToAddress(address), account, change, index)
+}
+
+func (i *addressIndex) clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key, pair := range i.pairs {
+		if pair.node != nil {
+			pair.node.Zero()
+		}
+
+		delete(i.pairs, key)
+	}
+
+	i.addresses = make(map[common.Address]derivationPath)
+	i.registered = make(map[common.Address]derivationPath)
+}
+
+func (i *addressIndex) getChangeNode(account, change uint32) (*hdkeychain.ExtendedKey, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	pair, isExists := i.pairs[changeNodeKey(account, change)]
+	if !isExists || pair.node == nil {
+		return nil, false
+	}
+
+	i.touch(pair)
+
+	return pair.node, true
+}
+
+func (i *addressIndex) putChangeNode(account, change uint32, node *hdkeychain.ExtendedKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pair := i.getPair(account, change)
+	pair.node = node
+
+	i.evict(changeNodeKey(account, change))
+}
+
+// scanWindow - returns range [from, to) of not scanned yet address indexes of pair in gap limit
+func (i *addressIndex) scanWindow(account, change, gap uint32) (from, to uint32) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	pair, isExists := i.pairs[changeNodeKey(account, change)]
+	if !isExists {
+		return 0, gap
+	}
+
+	return pair.scannedTo, max(pair.scannedTo, pair.gapLimitEnd(gap))
+}
+
+// uncachedChangeNodes - returns one path of every account and change pair of indexed addresses
+// without cached public change node
+func (i *addressIndex) uncachedChangeNodes() []derivationPath {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	result := make([]derivationPath, 0)
+
+	for key, pair := range i.pairs {
+		if pair.node != nil || len(pair.addresses) == 0 {
+			continue
+		}
+
+		result = append(result, derivationPath{
+			AccountIndex:  uint32(key >> 32),
+			InternalIndex: uint32(key),
+		})
+	}
+
+	return result
+}
+
+// scanRange - derive addresses of range [from, to) by public change node and put them into index
+func (i *addressIndex) scanRange(ctx context.Context,
+	node *hdkeychain.ExtendedKey,
+	account, change uint32,
+	from, to uint32,
+	workersCount uint,
+) error {
+	count := to - from
+	addresses := make([]common.Address, count)
+	errs := make([]error, workersCount)
+
+	chunkSize := count / uint32(workersCount)
+	if count%uint32(workersCount) != 0 {
+		chunkSize++
+	}
+
+	wg := sync.WaitGroup{}
+
+	for worker := uint32(0); worker != uint32(workersCount); worker++ {
+		chunkFrom := worker * chunkSize
+		if chunkFrom >= count {
+			break
+		}
+
+		chunkTo := min(chunkFrom+chunkSize, count)
+
+		wg.Add(1)
+		go func(worker, chunkFrom, chunkTo uint32) {
+			defer wg.Done()
+
+			for position := chunkFrom; position != chunkTo; position++ {
+				if ctx.Err() != nil {
+					errs[worker] = ctx.Err()
+
+					return
+				}
+
+				childNode, err := node.Derive(from + position)
+				if err != nil {
+					errs[worker] = err
+
+					return
+				}
+
+				pubKey, err := childNode.ECPubKey()
+				if err != nil {
+					errs[worker] = err
+
+					return
+				}
+
+				addresses[position] = crypto.PubkeyToAddress(*pubKey.ToECDSA())
+			}
+		}(worker, chunkFrom, chunkTo)
+	}
+
+	wg.Wait()
+
+	err := errors.Join(errs...)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pair := i.getPair(account, change)
+
+	for position, address := range addresses {
+		if !i.isIndexed(address) {
+			pair.addresses = append(pair.addresses, address)
+		}
+
+		i.addresses[address] = derivationPath{
+			AccountIndex:  account,
+			InternalIndex: change,
+			AddressIndex:  from + uint32(position),
+		}
+	}
+
+	if pair.scannedTo < to {
+		pair.scannedTo = to
+	}
+
+	i.evict(changeNodeKey(account, change))
+
+	return nil
+}
+
+// FindAccountByAddress - reverse lookup of derivation path by address. Search executed in bounds,
+// every found address stored in in-memory index, so repeated lookups are O(1).
+// Indexed address with derivation path outside bounds is not found.
+// searchBoundsData - JSON-encoded addressSearchBounds
+func (u *mnemonicWalletUnit) FindAccountByAddress(ctx context.Context,
+	address string,
+	searchBoundsData []byte,
+) (*pbCommon.AccountIdentity, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("%w: %s", ErrAddressInvalid, address)
+	}
+
+	bounds := &addressSearchBounds{}
+	err := json.Unmarshal(searchBoundsData, bounds)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal address search bounds: %w", err)
+	}
+
+	err = bounds.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := u.findAccountByAddress(ctx, common.HexToAddress(address), bounds)
+	if err != nil {
+		return nil, err
+	}
+
+	addrParams := &anypb.Any{}
+	err = addrParams.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  path.AccountIndex,
+		InternalIndex: path.InternalIndex,
+		AddressIndex:  path.AddressIndex,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbCommon.AccountIdentity{
+		Parameters: addrParams,
+		Address:    common.HexToAddress(address).Hex(),
+	}, nil
+}
+
+func (u *mnemonicWalletUnit) findAccountByAddress(ctx context.Context,
+	address common.Address,
+	bounds *addressSearchBounds,
+) (derivationPath, error) {
+	// indexed address is unique - if its path outside bounds, bounded scan can not find it
+	path, isExists := u.addrIndex.get(address)
+	if isExists {
+		if !bounds.contains(path) {
+			return derivationPath{}, fmt.Errorf("%w: %s", ErrAddressNotFound, address.Hex())
+		}
+
+		return path, nil
+	}
+
+	// scan window of pair can be extended by used addresses, so total scan size bounded separately
+	scanBudget := uint32(addressSearchMaxScanSize)
+
+	for account := bounds.AccountFrom; ; account++ {
+		for change := bounds.ChangeFrom; ; change++ {
+			node, err := u.changePublicNode(account, change)
+			if err != nil {
+				return derivationPath{}, err
+			}
+
+			from, to := u.addrIndex.scanWindow(account, change, bounds.IndexGap)
+			to = min(to, from+scanBudget)
+
+			if from < to {
+				err := u.addrIndex.scanRange(ctx, node, account, change, from, to, bounds.Workers)
+				if err != nil {
+					return derivationPath{}, err
+				}
+
+				scanBudget -= to - from
+
+				path, isExists = u.addrIndex.get(address)
+				if isExists && bounds.contains(path) {
+					return path, nil
+				}
+			}
+
+			if change == bounds.ChangeTo || scanBudget == 0 {
+				break
+			}
+		}
+
+		if account == bounds.AccountTo || scanBudget == 0 {
+			break
+		}
+	}
+
+	return derivationPath{}, fmt.Errorf("%w: %s", ErrAddressNotFound, address.Hex())
+}
+
+// changePublicNode - returns cached or new public node of account and change pair
+func (u *mnemonicWalletUnit) changePublicNode(account, change uint32) (*hdkeychain.ExtendedKey, error) {
+	node, isExists := u.addrIndex.getChangeNode(account, change)
+	if isExists {
+		return node, nil
+	}
+
+	node, err := u.newChangePublicNode(account, change)
+	if err != nil {
+		return nil, err
+	}
+
+	u.addrIndex.putChangeNode(account, change, node)
+
+	return node, nil
+}
+
+func (u *mnemonicWalletUnit) newChangePublicNode(account, change uint32) (*hdkeychain.ExtendedKey, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	hdWalletSvc, err := u.unlockedWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	return hdWalletSvc.NewChangePublicNode(account, change)
+}
@@ -0,0 +1,230 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMnemonicWalletUnit_FindAccountByAddress(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	// derived by private derivation flow, without address index
+	hdWalletAccount, err := poolUnit.hdWalletSvc.NewAccount(2, 1, 250)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive account", err)
+	}
+
+	targetAddress, _ := hdWalletAccount.GetAddress()
+	hdWalletAccount.ClearSecrets()
+
+	bounds := []byte(`{"accountFrom":0,"accountTo":3,"changeFrom":0,"changeTo":1,"indexGap":300,"workers":3}`)
+
+	accountIdentity, err := poolUnit.FindAccountByAddress(context.Background(), targetAddress, bounds)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to find account by address", err)
+	}
+
+	accIdentity := &pbCommon.DerivationAddressIdentity{}
+	err = accountIdentity.Parameters.UnmarshalTo(accIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal account parameters", err)
+	}
+
+	if accIdentity.AccountIndex != 2 || accIdentity.InternalIndex != 1 || accIdentity.AddressIndex != 250 {
+		t.Fatalf("%s", "derivation path not equal with expected")
+	}
+
+	if accountIdentity.Address != targetAddress {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	// 3 accounts by 2 changes, search stopped on third account
+	indexedCount := len(poolUnit.addrIndex.addresses)
+	if indexedCount != 6*300 {
+		t.Fatalf("%s: %d", "count of indexed addresses not equal with expected", indexedCount)
+	}
+
+	// repeated lookup served by index, without new derivations
+	_, err = poolUnit.FindAccountByAddress(context.Background(), targetAddress, bounds)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to find account by address", err)
+	}
+
+	if indexedCount != len(poolUnit.addrIndex.addresses) {
+		t.Fatalf("%s", "repeated lookup must not derive new addresses")
+	}
+
+	// address outside bounds, but already known by GetAccountAddress call
+	farAccountIdentity := &anypb.Any{}
+	_ = farAccountIdentity.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  100,
+		InternalIndex: 0,
+		AddressIndex:  50000,
+	})
+
+	farAddress, err := poolUnit.GetAccountAddress(context.Background(), farAccountIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get address from pool unit", err)
+	}
+
+	_, err = poolUnit.FindAccountByAddress(context.Background(), *farAddress,
+		[]byte(`{"accountFrom":0,"accountTo":0,"changeFrom":0,"changeTo":0,"indexGap":10}`))
+	if !errors.Is(err, ErrAddressNotFound) {
+		t.Fatalf("%s", "previously derived address outside bounds must not be found")
+	}
+
+	_, err = poolUnit.FindAccountByAddress(context.Background(), *farAddress,
+		[]byte(`{"accountFrom":100,"accountTo":100,"changeFrom":0,"changeTo":0,"indexGap":10}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to find previously derived account by address", err)
+	}
+
+	_, err = poolUnit.FindAccountByAddress(context.Background(),
+		common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8").Hex(),
+		[]byte(`{"accountFrom":0,"accountTo":1,"changeFrom":0,"changeTo":0,"indexGap":20}`))
+	if !errors.Is(err, ErrAddressNotFound) {
+		t.Fatalf("%s", "foreign address must not be found")
+	}
+}
+
+func TestMnemonicWalletUnit_FindAccountByAddress_GapLimit(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	hdWalletAccount, err := poolUnit.hdWalletSvc.NewAccount(0, 0, 150)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive account", err)
+	}
+
+	targetAddress, _ := hdWalletAccount.GetAddress()
+	hdWalletAccount.ClearSecrets()
+
+	bounds := []byte(`{"accountFrom":0,"accountTo":0,"changeFrom":0,"changeTo":0,"indexGap":100}`)
+
+	// no used addresses - only first 100 indexes scanned
+	_, err = poolUnit.FindAccountByAddress(context.Background(), targetAddress, bounds)
+	if !errors.Is(err, ErrAddressNotFound) {
+		t.Fatalf("%s", "address outside of gap limit must not be found")
+	}
+
+	if len(poolUnit.addrIndex.addresses) != 100 {
+		t.Fatalf("%s: %d", "count of indexed addresses not equal with expected",
+			len(poolUnit.addrIndex.addresses))
+	}
+
+	// issued address at index 90 extends scan window to index 190
+	usedAccountIdentity := &anypb.Any{}
+	_ = usedAccountIdentity.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  0,
+		InternalIndex: 0,
+		AddressIndex:  90,
+	})
+
+	_, err = poolUnit.GetAccountAddress(context.Background(), usedAccountIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get address from pool unit", err)
+	}
+
+	accountIdentity, err := poolUnit.FindAccountByAddress(context.Background(), targetAddress, bounds)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to find account by address", err)
+	}
+
+	accIdentity := &pbCommon.DerivationAddressIdentity{}
+	err = accountIdentity.Parameters.UnmarshalTo(accIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal account parameters", err)
+	}
+
+	if accIdentity.AddressIndex != 150 {
+		t.Fatalf("%s", "derivation path not equal with expected")
+	}
+
+	if len(poolUnit.addrIndex.addresses) != 191 {
+		t.Fatalf("%s: %d", "count of indexed addresses not equal with expected",
+			len(poolUnit.addrIndex.addresses))
+	}
+}
+
+func TestAddressIndex_Eviction(t *testing.T) {
+	index := newAddressIndex()
+
+	index.put(common.HexToAddress("0x01"), 0, 0, 0)
+
+	for account := uint32(1); account != addressIndexMaxPairs+1; account++ {
+		index.putChangeNode(account, 0, nil)
+	}
+
+	if len(index.pairs) != addressIndexMaxPairs {
+		t.Fatalf("%s: %d", "count of cached pairs not equal with expected", len(index.pairs))
+	}
+
+	// least recently used pair evicted with addresses
+	_, isExists := index.get(common.HexToAddress("0x01"))
+	if isExists {
+		t.Fatalf("%s", "address of evicted pair must not be found")
+	}
+
+	if _, isExists = index.pairs[changeNodeKey(addressIndexMaxPairs, 0)]; !isExists {
+		t.Fatalf("%s", "recently used pair must not be evicted")
+	}
+}
@@ -0,0 +1,293 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	domainBeaconProposer = [4]byte{0x00, 0x00, 0x00, 0x00}
+	domainBeaconAttester = [4]byte{0x01, 0x00, 0x00, 0x00}
+	domainVoluntaryExit  = [4]byte{0x04, 0x00, 0x00, 0x00}
+
+	ErrBeaconSigningWrongParams = errors.New("wrong beacon signing params")
+)
+
+// beaconSigningParams - common params of beacon chain signing, JSON format
+type beaconSigningParams struct {
+	// ValidatorIndex - EIP-2334 index of validator signing key m/12381/3600/i/0/0
+	ValidatorIndex uint32 `json:"validatorIndex"`
+	// ForkVersion - fork version of signing epoch, 4 bytes
+	ForkVersion hexutil.Bytes `json:"forkVersion"`
+	// GenesisValidatorsRoot - genesis validators root of network
+	GenesisValidatorsRoot common.Hash `json:"genesisValidatorsRoot"`
+}
+
+func (p *beaconSigningParams) validate() error {
+	if len(p.ForkVersion) != forkVersionLength {
+		return fmt.Errorf("%w: fork version must be %d bytes", ErrBeaconSigningWrongParams, forkVersionLength)
+	}
+
+	if p.GenesisValidatorsRoot == (common.Hash{}) {
+		return fmt.Errorf("%w: genesis validators root is empty", ErrBeaconSigningWrongParams)
+	}
+
+	return nil
+}
+
+func (p *beaconSigningParams) domain(domainType [4]byte) [sszChunkLength]byte {
+	var forkVersion [forkVersionLength]byte
+	copy(forkVersion[:], p.ForkVersion)
+
+	return computeDomain(domainType, forkVersion, p.GenesisValidatorsRoot)
+}
+
+// beaconBlockHeader - BeaconBlockHeader container. Hash tree root of header same as root of full block
+type beaconBlockHeader struct {
+	Slot          uint64      `json:"slot"`
+	ProposerIndex uint64      `json:"proposerIndex"`
+	ParentRoot    common.Hash `json:"parentRoot"`
+	StateRoot     common.Hash `json:"stateRoot"`
+	BodyRoot      common.Hash `json:"bodyRoot"`
+}
+
+func (h *beaconBlockHeader) hashTreeRoot() [sszChunkLength]byte {
+	return sszMerkleize(sszUint64Root(h.Slot), sszUint64Root(h.ProposerIndex),
+		h.ParentRoot, h.StateRoot, h.BodyRoot)
+}
+
+// beaconCheckpoint - Checkpoint container
+type beaconCheckpoint struct {
+	Epoch uint64      `json:"epoch"`
+	Root  common.Hash `json:"root"`
+}
+
+func (c *beaconCheckpoint) hashTreeRoot() [sszChunkLength]byte {
+	return sszMerkleize(sszUint64Root(c.Epoch), c.Root)
+}
+
+// beaconAttestationData - AttestationData container
+type beaconAttestationData struct {
+	Slot            uint64            `json:"slot"`
+	Index           uint64            `json:"index"`
+	BeaconBlockRoot common.Hash       `json:"beaconBlockRoot"`
+	Source          *beaconCheckpoint `json:"source"`
+	Target          *beaconCheckpoint `json:"target"`
+}
+
+func (a *beaconAttestationData) hashTreeRoot() [sszChunkLength]byte {
+	return sszMerkleize(sszUint64Root(a.Slot), sszUint64Root(a.Index), a.BeaconBlockRoot,
+		a.Source.hashTreeRoot(), a.Target.hashTreeRoot())
+}
+
+// beaconVoluntaryExit - VoluntaryExit container
+type beaconVoluntaryExit struct {
+	Epoch          uint64 `json:"epoch"`
+	ValidatorIndex uint64 `json:"validatorIndex"`
+}
+
+func (e *beaconVoluntaryExit) hashTreeRoot() [sszChunkLength]byte {
+	return sszMerkleize(sszUint64Root(e.Epoch), sszUint64Root(e.ValidatorIndex))
+}
+
+type beaconBlockSigningParams struct {
+	beaconSigningParams
+	Block *beaconBlockHeader `json:"block"`
+}
+
+type beaconAttestationSigningParams struct {
+	beaconSigningParams
+	Attestation *beaconAttestationData `json:"attestation"`
+}
+
+type beaconVoluntaryExitSigningParams struct {
+	beaconSigningParams
+	VoluntaryExit *beaconVoluntaryExit `json:"voluntaryExit"`
+}
+
+// SignBeaconBlock - sign beacon block proposal by validator signing key.
+// Block checked by slashing protection database before signing.
+// Returns validator public key and BLS signature.
+// signingParamsData - JSON-encoded beacon block signing params
+func (u *mnemonicWalletUnit) SignBeaconBlock(ctx context.Context,
+	signingParamsData []byte,
+) (*string, []byte, error) {
+	params := &beaconBlockSigningParams{}
+	err := unmarshalBeaconSigningParams(signingParamsData, params, &params.beaconSigningParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if params.Block == nil {
+		return nil, nil, fmt.Errorf("%w: block is empty", ErrBeaconSigningWrongParams)
+	}
+
+	return u.signBeaconObject(ctx, &params.beaconSigningParams, domainBeaconProposer, params.Block.hashTreeRoot(),
+		signingKindBeaconBlock, params.Block,
+		func(pubKey [blsPublicKeyLength]byte, signingRoot common.Hash) error {
+			return slashingProtection.checkBlock(params.GenesisValidatorsRoot, pubKey,
+				params.Block.Slot, signingRoot)
+		})
+}
+
+// SignBeaconAttestation - sign attestation data by validator signing key.
+// Attestation checked by slashing protection database before signing - double and surround votes refused.
+// Returns validator public key and BLS signature.
+// signingParamsData - JSON-encoded attestation signing params
+func (u *mnemonicWalletUnit) SignBeaconAttestation(ctx context.Context,
+	signingParamsData []byte,
+) (*string, []byte, error) {
+	params := &beaconAttestationSigningParams{}
+	err := unmarshalBeaconSigningParams(signingParamsData, params, &params.beaconSigningParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if params.Attestation == nil || params.Attestation.Source == nil || params.Attestation.Target == nil {
+		return nil, nil, fmt.Errorf("%w: attestation data is empty", ErrBeaconSigningWrongParams)
+	}
+
+	return u.signBeaconObject(ctx, &params.beaconSigningParams, domainBeaconAttester,
+		params.Attestation.hashTreeRoot(), signingKindBeaconAttestation, params.Attestation,
+		func(pubKey [blsPublicKeyLength]byte, signingRoot common.Hash) error {
+			return slashingProtection.checkAttestation(params.GenesisValidatorsRoot, pubKey,
+				params.Attestation.Source.Epoch, params.Attestation.Target.Epoch, signingRoot)
+		})
+}
+
+// SignBeaconVoluntaryExit - sign voluntary exit by validator signing key. Voluntary exit is not slashable.
+// Since Deneb (EIP-7044) caller must use Capella fork version for voluntary exits.
+// Returns validator public key and BLS signature.
+// signingParamsData - JSON-encoded voluntary exit signing params
+func (u *mnemonicWalletUnit) SignBeaconVoluntaryExit(ctx context.Context,
+	signingParamsData []byte,
+) (*string, []byte, error) {
+	params := &beaconVoluntaryExitSigningParams{}
+	err := unmarshalBeaconSigningParams(signingParamsData, params, &params.beaconSigningParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if params.VoluntaryExit == nil {
+		return nil, nil, fmt.Errorf("%w: voluntary exit is empty", ErrBeaconSigningWrongParams)
+	}
+
+	return u.signBeaconObject(ctx, &params.beaconSigningParams, domainVoluntaryExit,
+		params.VoluntaryExit.hashTreeRoot(), signingKindBeaconVoluntaryExit, params.VoluntaryExit, nil)
+}
+
+// signBeaconObject - compute signing root of object and sign it by validator signing key.
+// Slashing protection check called before signing, signature not produced if check failed.
+// Object passed to sign approver and recorded to audit log as summary
+func (u *mnemonicWalletUnit) signBeaconObject(ctx context.Context,
+	params *beaconSigningParams,
+	domainType [4]byte,
+	objectRoot [sszChunkLength]byte,
+	signingKind string,
+	object interface{},
+	protectionCheck func(pubKey [blsPublicKeyLength]byte, signingRoot common.Hash) error,
+) (*string, []byte, error) {
+	if protectionCheck != nil && slashingProtection == nil {
+		return nil, nil, ErrSlashingProtectionNotConfigured
+	}
+
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	secretKey, err := u.deriveBLSKey(blsValidatorSigningKeyPath(params.ValidatorIndex))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroBLSSecretKey(secretKey)
+
+	pubKey := blsPublicKey(secretKey)
+	pubKeyHex := hexutil.Encode(pubKey[:])
+	signingRoot := computeSigningRoot(objectRoot, params.domain(domainType))
+
+	// approval requested before slashing protection check - denied object must not be recorded to database
+	err = approveSignature(ctx, &signApprovalRequest{
+		WalletUUID:     u.mnemonicWalletUUID,
+		DerivationPath: blsValidatorSigningKeyPath(params.ValidatorIndex),
+		Address:        pubKeyHex,
+		Kind:           signingKind,
+		SigningHash:    signingRoot,
+	}, object)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if protectionCheck != nil {
+		err = protectionCheck(pubKey, signingRoot)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	signature, err := blsSign(secretKey, signingRoot[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = auditSignature(&signingAuditRecord{
+		WalletUUID:     u.mnemonicWalletUUID,
+		DerivationPath: blsValidatorSigningKeyPath(params.ValidatorIndex),
+		Address:        pubKeyHex,
+		Kind:           signingKind,
+		Digest:         signingRoot,
+	}, object)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &pubKeyHex, signature[:], nil
+}
+
+func unmarshalBeaconSigningParams(signingParamsData []byte,
+	params interface{},
+	commonParams *beaconSigningParams,
+) error {
+	err := json.Unmarshal(signingParamsData, params)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBeaconSigningWrongParams, err.Error())
+	}
+
+	return commonParams.validate()
+}
@@ -0,0 +1,167 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/google/uuid"
+)
+
+func TestMnemonicWalletUnit_SignBeacon(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	genesisValidatorsRoot := common.HexToHash("0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95")
+	commonParams := fmt.Sprintf(`"validatorIndex":2,"forkVersion":"0x05000000","genesisValidatorsRoot":"%s"`,
+		genesisValidatorsRoot.Hex())
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	blockParams := []byte(`{` + commonParams + `,"block":{"slot":100,"proposerIndex":7,` +
+		`"parentRoot":"0x0000000000000000000000000000000000000000000000000000000000000001",` +
+		`"stateRoot":"0x0000000000000000000000000000000000000000000000000000000000000002",` +
+		`"bodyRoot":"0x0000000000000000000000000000000000000000000000000000000000000003"}}`)
+
+	_, _, err = poolUnit.SignBeaconBlock(context.Background(), blockParams)
+	if !errors.Is(err, ErrSlashingProtectionNotConfigured) {
+		t.Fatalf("%s", "beacon block signing without slashing protection must be refused")
+	}
+
+	slashingProtection, err = newSlashingProtectionDB(filepath.Join(t.TempDir(), "slashing_protection.json"))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create slashing protection database", err)
+	}
+	defer func() {
+		slashingProtection = nil
+	}()
+
+	signingParams := &beaconSigningParams{ForkVersion: hexutil.MustDecode("0x05000000"),
+		GenesisValidatorsRoot: genesisValidatorsRoot}
+
+	pubKeyHex, signature, err := poolUnit.SignBeaconBlock(context.Background(), blockParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign beacon block", err)
+	}
+
+	expectedPubKey, err := poolUnit.getBLSPublicKey(blsValidatorSigningKeyPath(2))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get validator public key", err)
+	}
+
+	if *pubKeyHex != hexutil.Encode(expectedPubKey[:]) {
+		t.Fatalf("%s", "validator public key not equal with expected")
+	}
+
+	block := &beaconBlockHeader{Slot: 100, ProposerIndex: 7, ParentRoot: common.HexToHash("0x01"),
+		StateRoot: common.HexToHash("0x02"), BodyRoot: common.HexToHash("0x03")}
+	signingRoot := computeSigningRoot(block.hashTreeRoot(), signingParams.domain(domainBeaconProposer))
+
+	isValid, err := blsVerify(expectedPubKey[:], signingRoot[:], signature)
+	if err != nil || !isValid {
+		t.Fatalf("%s", "beacon block signature must be valid")
+	}
+
+	doubleBlockParams := []byte(`{` + commonParams + `,"block":{"slot":100,"proposerIndex":7,` +
+		`"parentRoot":"0x0000000000000000000000000000000000000000000000000000000000000005",` +
+		`"stateRoot":"0x0000000000000000000000000000000000000000000000000000000000000002",` +
+		`"bodyRoot":"0x0000000000000000000000000000000000000000000000000000000000000003"}}`)
+
+	_, _, err = poolUnit.SignBeaconBlock(context.Background(), doubleBlockParams)
+	if !errors.Is(err, ErrSlashableBlock) {
+		t.Fatalf("%s", "double proposal must be refused")
+	}
+
+	attestationParams := []byte(`{` + commonParams + `,"attestation":{"slot":100,"index":1,` +
+		`"beaconBlockRoot":"0x0000000000000000000000000000000000000000000000000000000000000001",` +
+		`"source":{"epoch":2,"root":"0x0000000000000000000000000000000000000000000000000000000000000002"},` +
+		`"target":{"epoch":3,"root":"0x0000000000000000000000000000000000000000000000000000000000000003"}}}`)
+
+	_, signature, err = poolUnit.SignBeaconAttestation(context.Background(), attestationParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign attestation", err)
+	}
+
+	attestation := &beaconAttestationData{Slot: 100, Index: 1, BeaconBlockRoot: common.HexToHash("0x01"),
+		Source: &beaconCheckpoint{Epoch: 2, Root: common.HexToHash("0x02")},
+		Target: &beaconCheckpoint{Epoch: 3, Root: common.HexToHash("0x03")}}
+	signingRoot = computeSigningRoot(attestation.hashTreeRoot(), signingParams.domain(domainBeaconAttester))
+
+	isValid, err = blsVerify(expectedPubKey[:], signingRoot[:], signature)
+	if err != nil || !isValid {
+		t.Fatalf("%s", "attestation signature must be valid")
+	}
+
+	surroundParams := []byte(`{` + commonParams + `,"attestation":{"slot":200,"index":1,` +
+		`"beaconBlockRoot":"0x0000000000000000000000000000000000000000000000000000000000000001",` +
+		`"source":{"epoch":1,"root":"0x0000000000000000000000000000000000000000000000000000000000000002"},` +
+		`"target":{"epoch":6,"root":"0x0000000000000000000000000000000000000000000000000000000000000003"}}}`)
+
+	_, _, err = poolUnit.SignBeaconAttestation(context.Background(), surroundParams)
+	if !errors.Is(err, ErrSlashableAttestation) {
+		t.Fatalf("%s", "surround vote must be refused")
+	}
+
+	_, signature, err = poolUnit.SignBeaconVoluntaryExit(context.Background(),
+		[]byte(`{`+commonParams+`,"voluntaryExit":{"epoch":194048,"validatorIndex":7}}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign voluntary exit", err)
+	}
+
+	voluntaryExit := &beaconVoluntaryExit{Epoch: 194048, ValidatorIndex: 7}
+	signingRoot = computeSigningRoot(voluntaryExit.hashTreeRoot(), signingParams.domain(domainVoluntaryExit))
+
+	isValid, err = blsVerify(expectedPubKey[:], signingRoot[:], signature)
+	if err != nil || !isValid {
+		t.Fatalf("%s", "voluntary exit signature must be valid")
+	}
+
+	_, _, err = poolUnit.SignBeaconVoluntaryExit(context.Background(),
+		[]byte(`{"validatorIndex":2,"forkVersion":"0x05","voluntaryExit":{"epoch":1,"validatorIndex":7}}`))
+	if !errors.Is(err, ErrBeaconSigningWrongParams) {
+		t.Fatalf("%s", "wrong signing params must not be accepted")
+	}
+}
@@ -0,0 +1,305 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// depositCLIVersion - version of staking-deposit-cli with same deposit_data.json format
+	depositCLIVersion = "2.7.0"
+
+	depositDefaultAmountGwei = 32_000_000_000
+	depositMinAmountGwei     = 1_000_000_000
+	depositMaxAmountGwei     = 2048_000_000_000
+
+	blsWithdrawalPrefix         = 0x00
+	executionWithdrawalPrefix   = 0x01
+	compoundingWithdrawalPrefix = 0x02
+	withdrawalCredentialsLength = 32
+	forkVersionLength           = 4
+)
+
+var (
+	// domainDeposit - DOMAIN_DEPOSIT domain type
+	domainDeposit = [4]byte{0x03, 0x00, 0x00, 0x00}
+
+	// depositNetworkNames - staking-deposit-cli network names by genesis fork version
+	depositNetworkNames = map[string]string{
+		"00000000": "mainnet",
+		"90000069": "sepolia",
+		"01017000": "holesky",
+		"10000910": "hoodi",
+	}
+
+	ErrDepositWrongParams = errors.New("wrong deposit params")
+)
+
+// depositParams - params of deposit data generation, JSON format
+type depositParams struct {
+	blsValidatorsRange
+
+	// WithdrawalCredentials - optional 32 bytes withdrawal credentials with 0x00, 0x01 or 0x02 prefix
+	WithdrawalCredentials hexutil.Bytes `json:"withdrawalCredentials,omitempty"`
+	// WithdrawalAddress - optional execution layer withdrawal address, 0x01 withdrawal credentials.
+	// If withdrawal credentials and address not set - 0x00 credentials of EIP-2334 withdrawal key used
+	WithdrawalAddress *common.Address `json:"withdrawalAddress,omitempty"`
+	// Amount - deposit amount in Gwei, default 32 ETH
+	Amount uint64 `json:"amount,omitempty"`
+	// ForkVersion - genesis fork version of network, 4 bytes
+	ForkVersion hexutil.Bytes `json:"forkVersion"`
+	// NetworkName - network name, required for networks which are unknown by plugin
+	NetworkName string `json:"networkName,omitempty"`
+}
+
+func (p *depositParams) validate() error {
+	err := p.blsValidatorsRange.validate()
+	if err != nil {
+		return err
+	}
+
+	if len(p.ForkVersion) != forkVersionLength {
+		return fmt.Errorf("%w: fork version must be %d bytes", ErrDepositWrongParams, forkVersionLength)
+	}
+
+	if p.NetworkName == "" {
+		networkName, isExists := depositNetworkNames[hex.EncodeToString(p.ForkVersion)]
+		if !isExists {
+			return fmt.Errorf("%w: network name required for fork version %s",
+				ErrDepositWrongParams, p.ForkVersion.String())
+		}
+
+		p.NetworkName = networkName
+	}
+
+	if p.WithdrawalCredentials != nil && p.WithdrawalAddress != nil {
+		return fmt.Errorf("%w: withdrawal credentials and withdrawal address are mutually exclusive",
+			ErrDepositWrongParams)
+	}
+
+	if p.WithdrawalCredentials != nil {
+		if len(p.WithdrawalCredentials) != withdrawalCredentialsLength {
+			return fmt.Errorf("%w: withdrawal credentials must be %d bytes",
+				ErrDepositWrongParams, withdrawalCredentialsLength)
+		}
+
+		switch p.WithdrawalCredentials[0] {
+		case blsWithdrawalPrefix, executionWithdrawalPrefix, compoundingWithdrawalPrefix:
+		default:
+			return fmt.Errorf("%w: unsupported withdrawal credentials prefix 0x%02x",
+				ErrDepositWrongParams, p.WithdrawalCredentials[0])
+		}
+	}
+
+	if p.Amount == 0 {
+		p.Amount = depositDefaultAmountGwei
+	}
+
+	if p.Amount < depositMinAmountGwei || p.Amount > depositMaxAmountGwei {
+		return fmt.Errorf("%w: amount must be in range %d-%d Gwei",
+			ErrDepositWrongParams, uint64(depositMinAmountGwei), uint64(depositMaxAmountGwei))
+	}
+
+	return nil
+}
+
+// depositData - deposit data entry, same as staking-deposit-cli deposit_data.json entry
+type depositData struct {
+	Pubkey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Amount                uint64 `json:"amount"`
+	Signature             string `json:"signature"`
+	DepositMessageRoot    string `json:"deposit_message_root"`
+	DepositDataRoot       string `json:"deposit_data_root"`
+	ForkVersion           string `json:"fork_version"`
+	NetworkName           string `json:"network_name"`
+	DepositCLIVersion     string `json:"deposit_cli_version"`
+}
+
+// depositMessage - unsigned deposit message with fork version, passed to sign approver
+type depositMessage struct {
+	Pubkey                string `json:"pubkey"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+	Amount                uint64 `json:"amount"`
+	ForkVersion           string `json:"fork_version"`
+}
+
+// blsWithdrawalCredentials - 0x00 withdrawal credentials - prefix and sha256 of withdrawal public key
+func blsWithdrawalCredentials(withdrawalPubKey []byte) []byte {
+	credentials := sha256.Sum256(withdrawalPubKey)
+	credentials[0] = blsWithdrawalPrefix
+
+	return credentials[:]
+}
+
+// executionWithdrawalCredentials - 0x01 withdrawal credentials - prefix, 11 zero bytes and address
+func executionWithdrawalCredentials(address common.Address) []byte {
+	credentials := make([]byte, withdrawalCredentialsLength)
+	credentials[0] = executionWithdrawalPrefix
+	copy(credentials[12:], address[:])
+
+	return credentials
+}
+
+// depositMessageRoot - DepositMessage hash tree root
+func depositMessageRoot(pubKey, withdrawalCredentials []byte, amount uint64) [sszChunkLength]byte {
+	return sszMerkleize(sszBytesRoot(pubKey), sszBytesRoot(withdrawalCredentials), sszUint64Root(amount))
+}
+
+// depositDataRoot - DepositData hash tree root
+func depositDataRoot(pubKey, withdrawalCredentials []byte, amount uint64, signature []byte) [sszChunkLength]byte {
+	return sszMerkleize(sszBytesRoot(pubKey), sszBytesRoot(withdrawalCredentials), sszUint64Root(amount),
+		sszBytesRoot(signature))
+}
+
+// GenerateDepositData - generate deposit data entries of validators range, same as staking-deposit-cli
+// deposit_data.json. Returns count and JSON-encoded list of entries.
+// depositParamsData - JSON-encoded depositParams
+func (u *mnemonicWalletUnit) GenerateDepositData(ctx context.Context,
+	depositParamsData []byte,
+) (uint, []byte, error) {
+	params := &depositParams{}
+	err := json.Unmarshal(depositParamsData, params)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %s", ErrDepositWrongParams, err.Error())
+	}
+
+	err = params.validate()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var forkVersion [forkVersionLength]byte
+	copy(forkVersion[:], params.ForkVersion)
+
+	// deposits domain computed with zero genesis validators root
+	domain := computeDomain(domainDeposit, forkVersion, [sszChunkLength]byte{})
+
+	result := make([]*depositData, 0)
+
+	err = params.forEach(ctx, func(validatorIndex uint32) error {
+		withdrawalCredentials := []byte(params.WithdrawalCredentials)
+
+		switch {
+		case params.WithdrawalAddress != nil:
+			withdrawalCredentials = executionWithdrawalCredentials(*params.WithdrawalAddress)
+
+		case withdrawalCredentials == nil:
+			withdrawalPubKey, loopErr := u.getBLSPublicKey(blsValidatorWithdrawalKeyPath(validatorIndex))
+			if loopErr != nil {
+				return loopErr
+			}
+
+			withdrawalCredentials = blsWithdrawalCredentials(withdrawalPubKey[:])
+		}
+
+		secretKey, loopErr := u.deriveBLSKey(blsValidatorSigningKeyPath(validatorIndex))
+		if loopErr != nil {
+			return loopErr
+		}
+		defer zeroBLSSecretKey(secretKey)
+
+		pubKey := blsPublicKey(secretKey)
+		messageRoot := depositMessageRoot(pubKey[:], withdrawalCredentials, params.Amount)
+		signingRoot := computeSigningRoot(messageRoot, domain)
+
+		loopErr = approveSignature(ctx, &signApprovalRequest{
+			WalletUUID:     u.mnemonicWalletUUID,
+			DerivationPath: blsValidatorSigningKeyPath(validatorIndex),
+			Address:        hex.EncodeToString(pubKey[:]),
+			Kind:           signingKindDepositMessage,
+			SigningHash:    signingRoot,
+		}, &depositMessage{
+			Pubkey:                hex.EncodeToString(pubKey[:]),
+			WithdrawalCredentials: hex.EncodeToString(withdrawalCredentials),
+			Amount:                params.Amount,
+			ForkVersion:           hex.EncodeToString(forkVersion[:]),
+		})
+		if loopErr != nil {
+			return loopErr
+		}
+
+		signature, loopErr := blsSign(secretKey, signingRoot[:])
+		if loopErr != nil {
+			return loopErr
+		}
+
+		dataRoot := depositDataRoot(pubKey[:], withdrawalCredentials, params.Amount, signature[:])
+
+		entry := &depositData{
+			Pubkey:                hex.EncodeToString(pubKey[:]),
+			WithdrawalCredentials: hex.EncodeToString(withdrawalCredentials),
+			Amount:                params.Amount,
+			Signature:             hex.EncodeToString(signature[:]),
+			DepositMessageRoot:    hex.EncodeToString(messageRoot[:]),
+			DepositDataRoot:       hex.EncodeToString(dataRoot[:]),
+			ForkVersion:           hex.EncodeToString(forkVersion[:]),
+			NetworkName:           params.NetworkName,
+			DepositCLIVersion:     depositCLIVersion,
+		}
+
+		loopErr = auditSignature(&signingAuditRecord{
+			WalletUUID:     u.mnemonicWalletUUID,
+			DerivationPath: blsValidatorSigningKeyPath(validatorIndex),
+			Address:        entry.Pubkey,
+			Kind:           signingKindDepositMessage,
+			Digest:         signingRoot,
+		}, entry)
+		if loopErr != nil {
+			return loopErr
+		}
+
+		result = append(result, entry)
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uint(len(result)), resultData, nil
+}
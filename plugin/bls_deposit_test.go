@@ -0,0 +1,282 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tyler-smith/go-bip39"
+)
+
+func TestComputeDomain_Deposit(t *testing.T) {
+	domain := computeDomain(domainDeposit, [4]byte{}, [sszChunkLength]byte{})
+
+	if hex.EncodeToString(domain[:]) != "03000000f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9" {
+		t.Fatalf("%s", "mainnet deposit domain not equal with expected")
+	}
+}
+
+func TestDeriveBLSKeyByPath_MnemonicKnownAnswer(t *testing.T) {
+	// BIP-0039 test vector with "TREZOR" passphrase. Seed of this vector is seed of first EIP-2333 test vector,
+	// so key derivation flow of staking-deposit-cli - mnemonic, BIP-0039 seed and EIP-2333 tree, checked end-to-end
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	seed := bip39.NewSeed(mnemonic, "TREZOR")
+	if hex.EncodeToString(seed) != "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf1"+
+		"41630c7a3c4ab7c81b2f001698e7463b04" {
+		t.Fatalf("%s", "seed not equal with expected")
+	}
+
+	masterKey, err := deriveBLSMasterKey(seed)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive master key", err)
+	}
+
+	if masterKey.String() != "6083874454709270928345386274498605044986640685124978867557563392430687146096" {
+		t.Fatalf("%s", "master key not equal with expected")
+	}
+
+	childKey, err := deriveBLSKeyByPath(seed, "m/0")
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive child key", err)
+	}
+
+	if childKey.String() != "20397789859736650942317412262472558107875392172444076792671091975210932703118" {
+		t.Fatalf("%s", "child key not equal with expected")
+	}
+}
+
+func TestMnemonicWalletUnit_GenerateDepositData(t *testing.T) {
+	type testCase struct {
+		Params                        []byte
+		ExpectedWithdrawalCredentials string
+		ExpectedNetworkName           string
+	}
+
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	count, keysData, err := poolUnit.GetBLSValidatorKeys(context.Background(),
+		[]byte(`{"validatorIndexFrom":0,"validatorIndexTo":1}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get validator keys", err)
+	}
+
+	if count != 2 {
+		t.Fatalf("%s", "count of validator keys not equal with expected")
+	}
+
+	var validatorKeys []*blsValidatorKeys
+	err = json.Unmarshal(keysData, &validatorKeys)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal validator keys", err)
+	}
+
+	testCases := []*testCase{
+		{
+			Params:                        []byte(`{"validatorIndexFrom":0,"validatorIndexTo":1,"forkVersion":"0x00000000"}`),
+			ExpectedWithdrawalCredentials: "",
+			ExpectedNetworkName:           "mainnet",
+		},
+		{
+			Params: []byte(`{"validatorIndexFrom":0,"validatorIndexTo":1,"forkVersion":"0x10000910",` +
+				`"withdrawalAddress":"0xf8A0F16782625B16260D0A4b0Ed107412bd95d56"}`),
+			ExpectedWithdrawalCredentials: "010000000000000000000000f8a0f16782625b16260d0a4b0ed107412bd95d56",
+			ExpectedNetworkName:           "hoodi",
+		},
+		{
+			Params: []byte(`{"validatorIndexFrom":0,"validatorIndexTo":1,"forkVersion":"0x01020304",` +
+				`"networkName":"devnet","amount":64000000000,"withdrawalCredentials":` +
+				`"0x020000000000000000000000f8a0f16782625b16260d0a4b0ed107412bd95d56"}`),
+			ExpectedWithdrawalCredentials: "020000000000000000000000f8a0f16782625b16260d0a4b0ed107412bd95d56",
+			ExpectedNetworkName:           "devnet",
+		},
+	}
+
+	for _, tCase := range testCases {
+		count, depositsData, loopErr := poolUnit.GenerateDepositData(context.Background(), tCase.Params)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to generate deposit data", loopErr)
+		}
+
+		if count != 2 {
+			t.Fatalf("%s", "count of deposits not equal with expected")
+		}
+
+		var deposits []*depositData
+		loopErr = json.Unmarshal(depositsData, &deposits)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to unmarshal deposit data", loopErr)
+		}
+
+		for i, deposit := range deposits {
+			pubKey, _ := hex.DecodeString(deposit.Pubkey)
+			withdrawalCredentials, _ := hex.DecodeString(deposit.WithdrawalCredentials)
+			signature, _ := hex.DecodeString(deposit.Signature)
+			forkVersion, _ := hex.DecodeString(deposit.ForkVersion)
+
+			if deposit.Pubkey != hex.EncodeToString(validatorKeys[i].SigningPublicKey) {
+				t.Fatalf("%s", "deposit public key not equal with validator signing key")
+			}
+
+			expectedWithdrawalCredentials := tCase.ExpectedWithdrawalCredentials
+			if expectedWithdrawalCredentials == "" {
+				expectedWithdrawalCredentials = hex.EncodeToString(
+					blsWithdrawalCredentials(validatorKeys[i].WithdrawalPublicKey))
+			}
+
+			if deposit.WithdrawalCredentials != expectedWithdrawalCredentials {
+				t.Fatalf("%s", "withdrawal credentials not equal with expected")
+			}
+
+			if deposit.NetworkName != tCase.ExpectedNetworkName {
+				t.Fatalf("%s", "network name not equal with expected")
+			}
+
+			var forkVersionArr [forkVersionLength]byte
+			copy(forkVersionArr[:], forkVersion)
+
+			messageRoot := depositMessageRoot(pubKey, withdrawalCredentials, deposit.Amount)
+			if deposit.DepositMessageRoot != hex.EncodeToString(messageRoot[:]) {
+				t.Fatalf("%s", "deposit message root not equal with expected")
+			}
+
+			dataRoot := depositDataRoot(pubKey, withdrawalCredentials, deposit.Amount, signature)
+			if deposit.DepositDataRoot != hex.EncodeToString(dataRoot[:]) {
+				t.Fatalf("%s", "deposit data root not equal with expected")
+			}
+
+			signingRoot := computeSigningRoot(messageRoot,
+				computeDomain(domainDeposit, forkVersionArr, [sszChunkLength]byte{}))
+
+			isValid, verifyErr := blsVerify(pubKey, signingRoot[:], signature)
+			if verifyErr != nil || !isValid {
+				t.Fatalf("%s", "deposit signature must be valid")
+			}
+		}
+	}
+
+	wrongParamsList := [][]byte{
+		[]byte(`{"validatorIndexFrom":0,"validatorIndexTo":0,"forkVersion":"0x0000"}`),
+		[]byte(`{"validatorIndexFrom":0,"validatorIndexTo":0,"forkVersion":"0x01020304"}`),
+		[]byte(`{"validatorIndexFrom":0,"validatorIndexTo":0,"forkVersion":"0x00000000","amount":100}`),
+		[]byte(`{"validatorIndexFrom":0,"validatorIndexTo":0,"forkVersion":"0x00000000",` +
+			`"withdrawalCredentials":"0x03000000000000000000000000000000000000000000000000000000000000ff"}`),
+	}
+
+	for _, params := range wrongParamsList {
+		_, _, loopErr := poolUnit.GenerateDepositData(context.Background(), params)
+		if !errors.Is(loopErr, ErrDepositWrongParams) {
+			t.Fatalf("%s", "wrong deposit params must not be accepted")
+		}
+	}
+
+	_, _, err = poolUnit.GenerateDepositData(context.Background(),
+		[]byte(`{"validatorIndexFrom":2,"validatorIndexTo":1,"forkVersion":"0x00000000"}`))
+	if !errors.Is(err, ErrBLSWrongValidatorRange) {
+		t.Fatalf("%s", "wrong validators range must not be accepted")
+	}
+}
+
+func TestMnemonicWalletUnit_ExportBLSKeystores(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	passphrase := "unit-test-passphrase"
+	validatorsRange := []byte(`{"validatorIndexFrom":3,"validatorIndexTo":3}`)
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	_, _, err = poolUnit.ExportBLSKeystores(context.Background(), validatorsRange, passphrase, nil)
+	if !errors.Is(err, ErrKeystoreExportDisabled) {
+		t.Fatalf("%s", "keystore export must be disabled by default")
+	}
+
+	keystoreExportEnabled = true
+	defer func() {
+		keystoreExportEnabled = false
+	}()
+
+	count, keystoresData, err := poolUnit.ExportBLSKeystores(context.Background(), validatorsRange, passphrase,
+		[]byte(`{"kdf":"scrypt","scryptN":4096}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to export keystores", err)
+	}
+
+	if count != 1 {
+		t.Fatalf("%s", "count of keystores not equal with expected")
+	}
+
+	var keystores []*blsKeystore
+	err = json.Unmarshal(keystoresData, &keystores)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal keystores", err)
+	}
+
+	secretKey, err := poolUnit.deriveBLSKey(blsValidatorSigningKeyPath(3))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive signing key", err)
+	}
+
+	if keystores[0].Path != "m/12381/3600/3/0/0" {
+		t.Fatalf("%s", "keystore path not equal with expected")
+	}
+
+	decryptedKey := decryptBLSKeystore(t, keystores[0], []byte(passphrase))
+	if hex.EncodeToString(decryptedKey) != hex.EncodeToString(secretKey.FillBytes(make([]byte, 32))) {
+		t.Fatalf("%s", "decrypted secret key not equal with expected")
+	}
+}
@@ -0,0 +1,268 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// blsSignatureDST - domain separation tag of Ethereum proof-of-possession BLS signature scheme
+	blsSignatureDST = "BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_"
+	// blsKeyGenSalt - EIP-2333 HKDF_mod_r salt
+	blsKeyGenSalt = "BLS-SIG-KEYGEN-SALT-"
+
+	blsSecretKeyLength = 32
+	blsPublicKeyLength = bls12381.SizeOfG1AffineCompressed
+	blsSignatureLength = bls12381.SizeOfG2AffineCompressed
+
+	blsSeedMinLength        = 32
+	blsHKDFModROutputLength = 48
+	blsLamportChunksCount   = 255
+
+	// EIP-2334 validator keys paths. Purpose - 12381, coin type - 3600
+	blsValidatorWithdrawalKeyPathTemplate = "m/12381/3600/%d/0"
+	blsValidatorSigningKeyPathTemplate    = "m/12381/3600/%d/0/0"
+)
+
+var (
+	ErrBLSSeedTooShort        = errors.New("bls master key seed is too short")
+	ErrBLSWrongKeyPath        = errors.New("wrong bls key path")
+	ErrBLSWrongPublicKey      = errors.New("wrong bls public key")
+	ErrBLSWrongSignature      = errors.New("wrong bls signature")
+	ErrBLSSecretKeyIsZero     = errors.New("bls secret key is zero")
+	ErrBLSWrongValidatorRange = errors.New("wrong validators range")
+)
+
+// hkdfModR - EIP-2333 HKDF_mod_r, derives secret key from input key material
+func hkdfModR(ikm []byte, keyInfo []byte) *big.Int {
+	salt := []byte(blsKeyGenSalt)
+	secretKey := new(big.Int)
+
+	ikmWithZero := make([]byte, len(ikm)+1)
+	copy(ikmWithZero, ikm)
+	defer clear(ikmWithZero)
+
+	info := make([]byte, len(keyInfo)+2)
+	copy(info, keyInfo)
+	binary.BigEndian.PutUint16(info[len(keyInfo):], blsHKDFModROutputLength)
+
+	okm := make([]byte, blsHKDFModROutputLength)
+	defer clear(okm)
+
+	for secretKey.Sign() == 0 {
+		saltHash := sha256.Sum256(salt)
+		salt = saltHash[:]
+
+		prk := hkdf.Extract(sha256.New, ikmWithZero, salt)
+		_, _ = io.ReadFull(hkdf.Expand(sha256.New, prk, info), okm)
+		clear(prk)
+
+		secretKey.SetBytes(okm)
+		secretKey.Mod(secretKey, fr.Modulus())
+	}
+
+	return secretKey
+}
+
+// ikmToLamportSK - EIP-2333 IKM_to_lamport_SK, returns 255 chunks of lamport secret key as single buffer
+func ikmToLamportSK(ikm, salt []byte) []byte {
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	defer clear(prk)
+
+	okm := make([]byte, blsLamportChunksCount*sha256.Size)
+	_, _ = io.ReadFull(hkdf.Expand(sha256.New, prk, nil), okm)
+
+	return okm
+}
+
+// parentSKToLamportPK - EIP-2333 parent_SK_to_lamport_PK, returns compressed lamport public key
+func parentSKToLamportPK(parentSecretKey *big.Int, index uint32) []byte {
+	salt := make([]byte, 4)
+	binary.BigEndian.PutUint32(salt, index)
+
+	ikm := make([]byte, blsSecretKeyLength)
+	parentSecretKey.FillBytes(ikm)
+	defer clear(ikm)
+
+	notIKM := make([]byte, blsSecretKeyLength)
+	for i := range ikm {
+		notIKM[i] = ^ikm[i]
+	}
+	defer clear(notIKM)
+
+	lamportPK := make([]byte, 0, 2*blsLamportChunksCount*sha256.Size)
+	for _, lamportIKM := range [][]byte{ikm, notIKM} {
+		lamportSK := ikmToLamportSK(lamportIKM, salt)
+		for i := 0; i != blsLamportChunksCount; i++ {
+			chunkHash := sha256.Sum256(lamportSK[i*sha256.Size : (i+1)*sha256.Size])
+			lamportPK = append(lamportPK, chunkHash[:]...)
+		}
+		clear(lamportSK)
+	}
+
+	compressedLamportPK := sha256.Sum256(lamportPK)
+
+	return compressedLamportPK[:]
+}
+
+// deriveBLSMasterKey - EIP-2333 derive_master_SK
+func deriveBLSMasterKey(seed []byte) (*big.Int, error) {
+	if len(seed) < blsSeedMinLength {
+		return nil, ErrBLSSeedTooShort
+	}
+
+	return hkdfModR(seed, nil), nil
+}
+
+// deriveBLSChildKey - EIP-2333 derive_child_SK
+func deriveBLSChildKey(parentSecretKey *big.Int, index uint32) *big.Int {
+	return hkdfModR(parentSKToLamportPK(parentSecretKey, index), nil)
+}
+
+// parseBLSKeyPath - parse EIP-2334 path, e.g. m/12381/3600/0/0/0
+func parseBLSKeyPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || parts[0] != "m" {
+		return nil, fmt.Errorf("%w: %s", ErrBLSWrongKeyPath, path)
+	}
+
+	indexes := make([]uint32, len(parts)-1)
+	for i, part := range parts[1:] {
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrBLSWrongKeyPath, path)
+		}
+
+		indexes[i] = uint32(index)
+	}
+
+	return indexes, nil
+}
+
+// deriveBLSKeyByPath - derive BLS secret key of EIP-2334 path from seed
+func deriveBLSKeyByPath(seed []byte, path string) (*big.Int, error) {
+	indexes, err := parseBLSKeyPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	secretKey, err := deriveBLSMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range indexes {
+		childKey := deriveBLSChildKey(secretKey, index)
+		zeroBLSSecretKey(secretKey)
+
+		secretKey = childKey
+	}
+
+	return secretKey, nil
+}
+
+func zeroBLSSecretKey(secretKey *big.Int) {
+	secretKey.SetBits([]big.Word{0x0})
+}
+
+func blsValidatorSigningKeyPath(validatorIndex uint32) string {
+	return fmt.Sprintf(blsValidatorSigningKeyPathTemplate, validatorIndex)
+}
+
+func blsValidatorWithdrawalKeyPath(validatorIndex uint32) string {
+	return fmt.Sprintf(blsValidatorWithdrawalKeyPathTemplate, validatorIndex)
+}
+
+// blsPublicKey - compressed G1 public key of secret key
+func blsPublicKey(secretKey *big.Int) [blsPublicKeyLength]byte {
+	var pubKey bls12381.G1Affine
+	pubKey.ScalarMultiplicationBase(secretKey)
+
+	return pubKey.Bytes()
+}
+
+// blsSign - compressed G2 signature of message by secret key, Ethereum proof-of-possession scheme
+func blsSign(secretKey *big.Int, message []byte) ([blsSignatureLength]byte, error) {
+	if secretKey.Sign() == 0 {
+		return [blsSignatureLength]byte{}, ErrBLSSecretKeyIsZero
+	}
+
+	messagePoint, err := bls12381.HashToG2(message, []byte(blsSignatureDST))
+	if err != nil {
+		return [blsSignatureLength]byte{}, err
+	}
+
+	var signature bls12381.G2Affine
+	signature.ScalarMultiplication(&messagePoint, secretKey)
+
+	return signature.Bytes(), nil
+}
+
+// blsVerify - verify compressed G2 signature of message by compressed G1 public key
+func blsVerify(publicKey []byte, message []byte, signature []byte) (bool, error) {
+	var pubKey bls12381.G1Affine
+	_, err := pubKey.SetBytes(publicKey)
+	if err != nil || pubKey.IsInfinity() {
+		return false, ErrBLSWrongPublicKey
+	}
+
+	var sig bls12381.G2Affine
+	_, err = sig.SetBytes(signature)
+	if err != nil {
+		return false, ErrBLSWrongSignature
+	}
+
+	messagePoint, err := bls12381.HashToG2(message, []byte(blsSignatureDST))
+	if err != nil {
+		return false, err
+	}
+
+	_, _, g1Gen, _ := bls12381.Generators()
+	var negG1Gen bls12381.G1Affine
+	negG1Gen.Neg(&g1Gen)
+
+	// e(pk, H(m)) * e(-g1, sig) == 1
+	return bls12381.PairingCheck([]bls12381.G1Affine{pubKey, negG1Gen}, []bls12381.G2Affine{messagePoint, sig})
+}
@@ -0,0 +1,129 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestDeriveBLSKey_EIP2333(t *testing.T) {
+	type testCase struct {
+		Seed              string
+		ExpectedMasterKey string
+		ChildIndex        uint32
+		ExpectedChildKey  string
+	}
+
+	// EIP-2333 test vectors
+	testCases := []*testCase{
+		{
+			Seed: "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf1" +
+				"41630c7a3c4ab7c81b2f001698e7463b04",
+			ExpectedMasterKey: "6083874454709270928345386274498605044986640685124978867557563392430687146096",
+			ChildIndex:        0,
+			ExpectedChildKey:  "20397789859736650942317412262472558107875392172444076792671091975210932703118",
+		},
+		{
+			Seed:              "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3",
+			ExpectedMasterKey: "19022158461524446591288038168518313374041767046816487870552872741050760015818",
+			ChildIndex:        42,
+			ExpectedChildKey:  "31372231650479070279774297061823572166496564838472787488249775572789064611981",
+		},
+	}
+
+	for _, tCase := range testCases {
+		seed, _ := hex.DecodeString(tCase.Seed)
+
+		masterKey, err := deriveBLSMasterKey(seed)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to derive master key", err)
+		}
+
+		if masterKey.String() != tCase.ExpectedMasterKey {
+			t.Fatalf("%s", "master key not equal with expected")
+		}
+
+		childKey := deriveBLSChildKey(masterKey, tCase.ChildIndex)
+		if childKey.String() != tCase.ExpectedChildKey {
+			t.Fatalf("%s", "child key not equal with expected")
+		}
+	}
+
+	_, err := deriveBLSMasterKey(make([]byte, 16))
+	if !errors.Is(err, ErrBLSSeedTooShort) {
+		t.Fatalf("%s", "short seed must not be accepted")
+	}
+
+	_, err = deriveBLSKeyByPath(make([]byte, 32), "m/12381/3600/x/0/0")
+	if !errors.Is(err, ErrBLSWrongKeyPath) {
+		t.Fatalf("%s", "wrong path must not be accepted")
+	}
+}
+
+func TestBLSSign(t *testing.T) {
+	// Ethereum consensus BLS sign test vector
+	secretKey, _ := new(big.Int).SetString("263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3", 16)
+	message := make([]byte, 32)
+
+	expectedPubKey := "a491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a"
+	expectedSignature := "b6ed936746e01f8ecf281f020953fbf1f01debd5657c4a383940b020b26507f6076334f91e2366c96e9ab279fb5158" +
+		"090352ea1c5b0c9274504f4f0e7053af24802e51e4568d164fe986834f41e55c8e850ce1f98458c0cfc9ab380b55285a55"
+
+	pubKey := blsPublicKey(secretKey)
+	if hex.EncodeToString(pubKey[:]) != expectedPubKey {
+		t.Fatalf("%s", "public key not equal with expected")
+	}
+
+	signature, err := blsSign(secretKey, message)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign message", err)
+	}
+
+	if hex.EncodeToString(signature[:]) != expectedSignature {
+		t.Fatalf("%s", "signature not equal with expected")
+	}
+
+	isValid, err := blsVerify(pubKey[:], message, signature[:])
+	if err != nil || !isValid {
+		t.Fatalf("%s", "signature must be valid")
+	}
+
+	message[0] = 0x1
+	isValid, err = blsVerify(pubKey[:], message, signature[:])
+	if err != nil || isValid {
+		t.Fatalf("%s", "signature of another message must be invalid")
+	}
+}
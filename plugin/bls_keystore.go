@@ -0,0 +1,179 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const blsKeystoreVersion = 4
+
+var ErrBLSKeystoreWrongPassphrase = errors.New("bls keystore passphrase must contain only ASCII characters")
+
+type blsKeystoreModule struct {
+	Function string                 `json:"function"`
+	Params   map[string]interface{} `json:"params"`
+	Message  string                 `json:"message"`
+}
+
+type blsKeystoreCrypto struct {
+	KDF      blsKeystoreModule `json:"kdf"`
+	Checksum blsKeystoreModule `json:"checksum"`
+	Cipher   blsKeystoreModule `json:"cipher"`
+}
+
+// blsKeystore - EIP-2335 BLS12-381 keystore, same as staking-deposit-cli keystore-m_*.json files
+type blsKeystore struct {
+	Crypto      blsKeystoreCrypto `json:"crypto"`
+	Description string            `json:"description"`
+	Pubkey      string            `json:"pubkey"`
+	Path        string            `json:"path"`
+	UUID        string            `json:"uuid"`
+	Version     int               `json:"version"`
+}
+
+// processBLSKeystorePassphrase - EIP-2335 password processing - NFKD normalization and control codes stripping.
+// NFKD normalization of ASCII string is same string, so only ASCII passphrases supported
+func processBLSKeystorePassphrase(passphrase string) ([]byte, error) {
+	if !utf8.ValidString(passphrase) {
+		return nil, ErrBLSKeystoreWrongPassphrase
+	}
+
+	processed := make([]byte, 0, len(passphrase))
+	for _, r := range passphrase {
+		if r >= utf8.RuneSelf {
+			return nil, ErrBLSKeystoreWrongPassphrase
+		}
+
+		// C0 control codes and delete
+		if r < 0x20 || r == 0x7f {
+			continue
+		}
+
+		processed = append(processed, byte(r))
+	}
+
+	if len(processed) == 0 {
+		return nil, ErrKeystorePassphraseEmpty
+	}
+
+	return processed, nil
+}
+
+// encryptBLSKeystore - encrypt BLS secret key to EIP-2335 keystore
+func encryptBLSKeystore(secretKey *big.Int,
+	path string,
+	passphrase string,
+	params *keystoreExportParams,
+) (*blsKeystore, error) {
+	processedPassphrase, err := processBLSKeystorePassphrase(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(processedPassphrase)
+
+	salt := make([]byte, keystoreSaltLength)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedKey, kdfParams, err := params.deriveKey(processedPassphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(derivedKey)
+
+	keyBytes := make([]byte, blsSecretKeyLength)
+	secretKey.FillBytes(keyBytes)
+	defer clear(keyBytes)
+
+	block, err := aes.NewCipher(derivedKey[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	cipherMessage := make([]byte, len(keyBytes))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherMessage, keyBytes)
+
+	checksum := sha256.Sum256(append(append([]byte{}, derivedKey[16:32]...), cipherMessage...))
+
+	keyUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey := blsPublicKey(secretKey)
+
+	return &blsKeystore{
+		Crypto: blsKeystoreCrypto{
+			KDF: blsKeystoreModule{
+				Function: params.KDF,
+				Params:   kdfParams,
+				Message:  "",
+			},
+			Checksum: blsKeystoreModule{
+				Function: "sha256",
+				Params:   map[string]interface{}{},
+				Message:  hex.EncodeToString(checksum[:]),
+			},
+			Cipher: blsKeystoreModule{
+				Function: keystoreCipher,
+				Params: map[string]interface{}{
+					"iv": hex.EncodeToString(iv),
+				},
+				Message: hex.EncodeToString(cipherMessage),
+			},
+		},
+		Description: "",
+		Pubkey:      hex.EncodeToString(pubKey[:]),
+		Path:        path,
+		UUID:        keyUUID.String(),
+		Version:     blsKeystoreVersion,
+	}, nil
+}
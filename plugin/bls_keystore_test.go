@@ -0,0 +1,151 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// decryptBLSKeystore - decrypt EIP-2335 keystore by processed password
+func decryptBLSKeystore(t *testing.T, keystore *blsKeystore, password []byte) []byte {
+	kdfParams := keystore.Crypto.KDF.Params
+	salt, _ := hex.DecodeString(kdfParams["salt"].(string))
+
+	var derivedKey []byte
+	var err error
+
+	switch keystore.Crypto.KDF.Function {
+	case "pbkdf2":
+		derivedKey = pbkdf2.Key(password, salt, int(kdfParams["c"].(float64)), 32, sha256.New)
+	case "scrypt":
+		derivedKey, err = scrypt.Key(password, salt, int(kdfParams["n"].(float64)), int(kdfParams["r"].(float64)),
+			int(kdfParams["p"].(float64)), 32)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to derive scrypt key", err)
+		}
+	default:
+		t.Fatalf("%s", "unsupported kdf function")
+	}
+
+	cipherMessage, _ := hex.DecodeString(keystore.Crypto.Cipher.Message)
+	checksum := sha256.Sum256(append(append([]byte{}, derivedKey[16:32]...), cipherMessage...))
+	if hex.EncodeToString(checksum[:]) != keystore.Crypto.Checksum.Message {
+		t.Fatalf("%s", "keystore checksum not equal with expected")
+	}
+
+	iv, _ := hex.DecodeString(keystore.Crypto.Cipher.Params["iv"].(string))
+	block, _ := aes.NewCipher(derivedKey[:16])
+
+	secretKey := make([]byte, len(cipherMessage))
+	cipher.NewCTR(block, iv).XORKeyStream(secretKey, cipherMessage)
+
+	return secretKey
+}
+
+func TestDecryptBLSKeystore_EIP2335(t *testing.T) {
+	// EIP-2335 pbkdf2 test vector, processed password - "testpassword🔑"
+	keystoreData := []byte(`{"crypto":{"kdf":{"function":"pbkdf2","params":{"dklen":32,"c":262144,` +
+		`"prf":"hmac-sha256","salt":"d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"},"message":""},` +
+		`"checksum":{"function":"sha256","params":{},` +
+		`"message":"8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1"},` +
+		`"cipher":{"function":"aes-128-ctr","params":{"iv":"264daa3f303d7259501c93d997d84fe6"},` +
+		`"message":"cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad"}},` +
+		`"description":"This is a test keystore that uses PBKDF2 to secure the secret.",` +
+		`"pubkey":"9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",` +
+		`"path":"m/12381/60/0/0","uuid":"64625def-3331-4eea-ab6f-782f3ed16a83","version":4}`)
+
+	keystore := &blsKeystore{}
+	err := json.Unmarshal(keystoreData, keystore)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal keystore", err)
+	}
+
+	secretKey := decryptBLSKeystore(t, keystore, []byte("testpassword\U0001F511"))
+	if hex.EncodeToString(secretKey) != "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f" {
+		t.Fatalf("%s", "secret key not equal with expected")
+	}
+
+	pubKey := blsPublicKey(new(big.Int).SetBytes(secretKey))
+	if hex.EncodeToString(pubKey[:]) != keystore.Pubkey {
+		t.Fatalf("%s", "public key not equal with expected")
+	}
+}
+
+func TestEncryptBLSKeystore(t *testing.T) {
+	secretKey, _ := new(big.Int).SetString("263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3", 16)
+
+	params := &keystoreExportParams{KDF: "scrypt", ScryptN: 4096}
+	_ = params.validate()
+
+	// control codes stripped from passphrase
+	keystore, err := encryptBLSKeystore(secretKey, "m/12381/3600/0/0/0", "unit\ttest-passphrase\x7f", params)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt keystore", err)
+	}
+
+	if keystore.Version != 4 || keystore.Path != "m/12381/3600/0/0/0" ||
+		keystore.Pubkey != "a491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a" {
+		t.Fatalf("%s", "keystore fields not equal with expected")
+	}
+
+	keystoreData, err := json.Marshal(keystore)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal keystore", err)
+	}
+
+	keystore = &blsKeystore{}
+	err = json.Unmarshal(keystoreData, keystore)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal keystore", err)
+	}
+
+	decryptedKey := decryptBLSKeystore(t, keystore, []byte("unittest-passphrase"))
+	if new(big.Int).SetBytes(decryptedKey).Cmp(secretKey) != 0 {
+		t.Fatalf("%s", "decrypted secret key not equal with expected")
+	}
+
+	_, err = encryptBLSKeystore(secretKey, "m/12381/3600/0/0/0", "pässword", params)
+	if !errors.Is(err, ErrBLSKeystoreWrongPassphrase) {
+		t.Fatalf("%s", "non-ASCII passphrase must not be accepted")
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+const sszChunkLength = 32
+
+// sszHashPair - hash of two merkle tree nodes
+func sszHashPair(left, right [sszChunkLength]byte) [sszChunkLength]byte {
+	hasher := sha256.New()
+	hasher.Write(left[:])
+	hasher.Write(right[:])
+
+	var result [sszChunkLength]byte
+	copy(result[:], hasher.Sum(nil))
+
+	return result
+}
+
+// sszMerkleize - merkle root of chunks, padded by zero chunks to next power of two
+func sszMerkleize(chunks ...[sszChunkLength]byte) [sszChunkLength]byte {
+	if len(chunks) == 0 {
+		return [sszChunkLength]byte{}
+	}
+
+	size := 1
+	for size < len(chunks) {
+		size *= 2
+	}
+
+	layer := make([][sszChunkLength]byte, size)
+	copy(layer, chunks)
+
+	for len(layer) > 1 {
+		for i := 0; i < len(layer)/2; i++ {
+			layer[i] = sszHashPair(layer[2*i], layer[2*i+1])
+		}
+
+		layer = layer[:len(layer)/2]
+	}
+
+	return layer[0]
+}
+
+// sszUint64Root - hash tree root of uint64
+func sszUint64Root(value uint64) [sszChunkLength]byte {
+	var chunk [sszChunkLength]byte
+	binary.LittleEndian.PutUint64(chunk[:], value)
+
+	return chunk
+}
+
+// sszBytesRoot - hash tree root of fixed size bytes vector
+func sszBytesRoot(data []byte) [sszChunkLength]byte {
+	chunks := make([][sszChunkLength]byte, (len(data)+sszChunkLength-1)/sszChunkLength)
+	for i := range chunks {
+		copy(chunks[i][:], data[i*sszChunkLength:])
+	}
+
+	return sszMerkleize(chunks...)
+}
+
+// computeDomain - beacon chain compute_domain by domain type, fork version and genesis validators root
+func computeDomain(domainType [4]byte,
+	forkVersion [4]byte,
+	genesisValidatorsRoot [sszChunkLength]byte,
+) [sszChunkLength]byte {
+	// ForkData hash tree root
+	forkDataRoot := sszMerkleize(sszBytesRoot(forkVersion[:]), genesisValidatorsRoot)
+
+	var domain [sszChunkLength]byte
+	copy(domain[:4], domainType[:])
+	copy(domain[4:], forkDataRoot[:28])
+
+	return domain
+}
+
+// computeSigningRoot - beacon chain compute_signing_root - SigningData hash tree root
+func computeSigningRoot(objectRoot, domain [sszChunkLength]byte) [sszChunkLength]byte {
+	return sszMerkleize(objectRoot, domain)
+}
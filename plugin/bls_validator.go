@@ -0,0 +1,240 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// blsValidatorsMaxRangeSize - max count of validators in one request
+const blsValidatorsMaxRangeSize = 1000
+
+// blsValidatorsRange - range of EIP-2334 validator indexes, JSON format
+type blsValidatorsRange struct {
+	ValidatorIndexFrom uint32 `json:"validatorIndexFrom"`
+	ValidatorIndexTo   uint32 `json:"validatorIndexTo"`
+}
+
+func (r *blsValidatorsRange) validate() error {
+	if r.ValidatorIndexFrom > r.ValidatorIndexTo {
+		return fmt.Errorf("%w: validator index from %d greater than validator index to %d",
+			ErrBLSWrongValidatorRange, r.ValidatorIndexFrom, r.ValidatorIndexTo)
+	}
+
+	size := uint64(r.ValidatorIndexTo-r.ValidatorIndexFrom) + 1
+	if size > blsValidatorsMaxRangeSize {
+		return fmt.Errorf("%w: range size %d greater than %d",
+			ErrBLSWrongValidatorRange, size, blsValidatorsMaxRangeSize)
+	}
+
+	return nil
+}
+
+// forEach - call function for every validator index of range
+func (r *blsValidatorsRange) forEach(ctx context.Context, fn func(validatorIndex uint32) error) error {
+	for validatorIndex := r.ValidatorIndexFrom; ; validatorIndex++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := fn(validatorIndex)
+		if err != nil {
+			return err
+		}
+
+		if validatorIndex == r.ValidatorIndexTo {
+			return nil
+		}
+	}
+}
+
+// blsValidatorKeys - EIP-2334 public keys of validator, JSON format
+type blsValidatorKeys struct {
+	ValidatorIndex      uint32        `json:"validatorIndex"`
+	SigningKeyPath      string        `json:"signingKeyPath"`
+	SigningPublicKey    hexutil.Bytes `json:"signingPublicKey"`
+	WithdrawalKeyPath   string        `json:"withdrawalKeyPath"`
+	WithdrawalPublicKey hexutil.Bytes `json:"withdrawalPublicKey"`
+}
+
+// GetBLSValidatorKeys - returns count and JSON-encoded list of BLS12-381 signing and withdrawal public keys
+// of validators range. Keys derived by EIP-2333 from wallet seed on EIP-2334 paths.
+// validatorsRangeData - JSON-encoded blsValidatorsRange
+func (u *mnemonicWalletUnit) GetBLSValidatorKeys(ctx context.Context,
+	validatorsRangeData []byte,
+) (uint, []byte, error) {
+	validatorsRange, err := unmarshalBLSValidatorsRange(validatorsRangeData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]*blsValidatorKeys, 0)
+
+	err = validatorsRange.forEach(ctx, func(validatorIndex uint32) error {
+		signingPubKey, loopErr := u.getBLSPublicKey(blsValidatorSigningKeyPath(validatorIndex))
+		if loopErr != nil {
+			return loopErr
+		}
+
+		withdrawalPubKey, loopErr := u.getBLSPublicKey(blsValidatorWithdrawalKeyPath(validatorIndex))
+		if loopErr != nil {
+			return loopErr
+		}
+
+		result = append(result, &blsValidatorKeys{
+			ValidatorIndex:      validatorIndex,
+			SigningKeyPath:      blsValidatorSigningKeyPath(validatorIndex),
+			SigningPublicKey:    signingPubKey[:],
+			WithdrawalKeyPath:   blsValidatorWithdrawalKeyPath(validatorIndex),
+			WithdrawalPublicKey: withdrawalPubKey[:],
+		})
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uint(len(result)), resultData, nil
+}
+
+// ExportBLSKeystores - encrypt signing keys of validators range to EIP-2335 keystores,
+// same as staking-deposit-cli keystore-m_*.json files. Returns count and JSON-encoded list of keystores.
+// Export must be enabled by KeystoreExportEnabled build-time variable.
+// exportParamsData - optional JSON-encoded KDF params, same as ExportKeystore params
+func (u *mnemonicWalletUnit) ExportBLSKeystores(ctx context.Context,
+	validatorsRangeData []byte,
+	passphrase string,
+	exportParamsData []byte,
+) (uint, []byte, error) {
+	if !keystoreExportEnabled {
+		return 0, nil, ErrKeystoreExportDisabled
+	}
+
+	validatorsRange, err := unmarshalBLSValidatorsRange(validatorsRangeData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	params := &keystoreExportParams{}
+	if len(exportParamsData) != 0 {
+		err = json.Unmarshal(exportParamsData, params)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %s", ErrKeystoreExportWrongParams, err.Error())
+		}
+	}
+
+	err = params.validate()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]*blsKeystore, 0)
+
+	err = validatorsRange.forEach(ctx, func(validatorIndex uint32) error {
+		path := blsValidatorSigningKeyPath(validatorIndex)
+
+		secretKey, loopErr := u.deriveBLSKey(path)
+		if loopErr != nil {
+			return loopErr
+		}
+		defer zeroBLSSecretKey(secretKey)
+
+		keystore, loopErr := encryptBLSKeystore(secretKey, path, passphrase, params)
+		if loopErr != nil {
+			return loopErr
+		}
+
+		result = append(result, keystore)
+
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uint(len(result)), resultData, nil
+}
+
+// deriveBLSKey - derive BLS secret key of EIP-2334 path from wallet seed. Secret key must be zeroed by caller
+func (u *mnemonicWalletUnit) deriveBLSKey(path string) (*big.Int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	hdWalletSvc, err := u.unlockedWallet()
+	if err != nil {
+		return nil, err
+	}
+
+	return deriveBLSKeyByPath(hdWalletSvc.Seed(), path)
+}
+
+func (u *mnemonicWalletUnit) getBLSPublicKey(path string) ([blsPublicKeyLength]byte, error) {
+	secretKey, err := u.deriveBLSKey(path)
+	if err != nil {
+		return [blsPublicKeyLength]byte{}, err
+	}
+	defer zeroBLSSecretKey(secretKey)
+
+	return blsPublicKey(secretKey), nil
+}
+
+func unmarshalBLSValidatorsRange(validatorsRangeData []byte) (*blsValidatorsRange, error) {
+	validatorsRange := &blsValidatorsRange{}
+	err := json.Unmarshal(validatorsRangeData, validatorsRange)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBLSWrongValidatorRange, err.Error())
+	}
+
+	err = validatorsRange.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return validatorsRange, nil
+}
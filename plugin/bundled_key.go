@@ -34,6 +34,8 @@ package main
 
 import (
 	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
 	"encoding/hex"
 	"math/big"
 
@@ -51,6 +53,15 @@ var (
 	// zeroQuote base zero
 	// nolint:gochecknoglobals // its library function
 	zeroQuote uint32 = 0x80000000
+	// masterKeySeedSalt - BIP-0032 HMAC-SHA512 key of master key generation
+	// nolint:gochecknoglobals // its library function
+	masterKeySeedSalt = []byte("Bitcoin seed")
+)
+
+const (
+	masterKeyLength       = 32
+	masterChainCodeLength = 32
+	masterKeyDataLength   = masterKeyLength + masterChainCodeLength
 )
 
 // keyBundle struct
@@ -73,23 +84,43 @@ type keyBundle struct {
 	PublicECDSA *ecdsa.PublicKey
 }
 
-// newBundledKeyBySeed generate new extended key
-func newBundledKeyBySeed(seed []byte) (*keyBundle, error) {
-	extendedKey, err := hdkeychain.NewMaster(seed, defaultNetwork)
-	if err != nil {
-		return nil, err
+// newBundledKeyByProtectedSeed generate new extended key same as hdkeychain.NewMaster, but private key and chain code
+// written to masterKeyData - 64 bytes slice of protected buffer. Extended key points to masterKeyData,
+// big.Int copies of master private key are not created
+func newBundledKeyByProtectedSeed(seed, masterKeyData []byte) (*keyBundle, error) {
+	hmac512 := hmac.New(sha512.New, masterKeySeedSalt)
+	_, _ = hmac512.Write(seed)
+	// masterKeyData has enough capacity - hash written in place, without heap copy
+	lr := hmac512.Sum(masterKeyData[:0:masterKeyDataLength])
+	hmac512.Reset()
+
+	secretKey := lr[:masterKeyLength:masterKeyLength]
+	chainCode := lr[masterKeyLength:]
+
+	var keyNum btcec.ModNScalar
+	overflow := keyNum.SetByteSlice(secretKey)
+	isZero := keyNum.IsZero()
+	keyNum.Zero()
+
+	if overflow || isZero {
+		clear(lr)
+
+		return nil, hdkeychain.ErrUnusableSeed
 	}
 
-	bundle := &keyBundle{
+	privKey, pubKey := btcec.PrivKeyFromBytes(secretKey)
+	privKey.Zero()
+
+	parentFP := []byte{0x00, 0x00, 0x00, 0x00}
+	extendedKey := hdkeychain.NewExtendedKey(defaultNetwork.HDPrivateKeyID[:], secretKey, chainCode,
+		parentFP, 0, 0, true)
+
+	return &keyBundle{
 		filler:      []big.Word{0, 0, 0, 0},
 		ExtendedKey: extendedKey,
 		Network:     defaultNetwork,
-	}
-	if err = bundle.init(); err != nil {
-		return nil, err
-	}
-
-	return bundle, nil
+		Public:      pubKey,
+	}, nil
 }
 
 // newBundledKeyByExtendedKey generate new bundled key
@@ -125,6 +156,11 @@ func (k *keyBundle) init() error {
 	return nil
 }
 
+// Fingerprint returns BIP-0032 key fingerprint - first 4 bytes of hash160 of compressed public key
+func (k *keyBundle) Fingerprint() []byte {
+	return btcutil.Hash160(k.Public.SerializeCompressed())[:4]
+}
+
 // GetPath return path in bip44 style
 func (k *keyBundle) GetPath(purpose, coinType, account, change, addressIndex uint32) []uint32 {
 	purpose = zeroQuote + purpose
@@ -186,6 +222,44 @@ func (k *keyBundle) GetChildKey(purpose, coinType,
 	return acc, newExtendedKey, err
 }
 
+// GetChangePublicKey returns public extended key of change level - m/purpose'/coinType'/account'/change.
+// Address keys can be derived from it without private keys - only non-hardened address index derivation
+func (k *keyBundle) GetChangePublicKey(purpose, coinType,
+	account,
+	change uint32,
+) (*hdkeychain.ExtendedKey, error) {
+	path := k.GetPath(purpose, coinType, account, change, 0)
+
+	// cloned key shares key bytes with master key - it must not be zeroed
+	extendedKey, err := k.ExtendedKey.CloneWithVersion(k.ExtendedKey.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range path[:4] {
+		childKey, deriveErr := extendedKey.Derive(v)
+		if i != 0 {
+			extendedKey.Zero()
+		}
+
+		if deriveErr != nil {
+			return nil, deriveErr
+		}
+
+		extendedKey = childKey
+	}
+
+	defer extendedKey.Zero()
+
+	// neutered key shares chain code and public key bytes with private key - so copy it by serialization
+	neuteredKey, err := extendedKey.Neuter()
+	if err != nil {
+		return nil, err
+	}
+
+	return hdkeychain.NewKeyFromString(neuteredKey.String())
+}
+
 // PublicHex generate public key to string by hex
 func (k *keyBundle) PublicHex() string {
 	return hex.EncodeToString(k.Public.SerializeCompressed())
@@ -282,16 +356,24 @@ func (k *keyBundle) CloneECDSAPrivateKey() *ecdsa.PrivateKey {
 
 func (k *keyBundle) ClearSecrets() {
 	k.ExtendedKey.Zero()
-	k.Private.Zero()
 
-	k.PublicECDSA.X.SetBits(k.filler)
-	k.PublicECDSA.X = nil
-	k.PublicECDSA.Y.SetBits(k.filler)
-	k.PublicECDSA.Y = nil
-	k.PublicECDSA.Curve = nil
+	// private keys of master key bundle stored only in protected buffer
+	if k.Private != nil {
+		k.Private.Zero()
+	}
+
+	if k.PublicECDSA != nil {
+		k.PublicECDSA.X.SetBits(k.filler)
+		k.PublicECDSA.X = nil
+		k.PublicECDSA.Y.SetBits(k.filler)
+		k.PublicECDSA.Y = nil
+		k.PublicECDSA.Curve = nil
+	}
 
-	k.PrivateECDSA.D.SetBits(k.filler)
-	k.PrivateECDSA.D = nil
+	if k.PrivateECDSA != nil {
+		k.PrivateECDSA.D.SetBits(k.filler)
+		k.PrivateECDSA.D = nil
+	}
 
 	k.Network = nil
 	k.PrivateECDSA = nil
@@ -302,3 +384,49 @@ func (k *keyBundle) ClearSecrets() {
 
 	k.filler = nil
 }
+
+// GetHardenedPrivateKey returns private key of fully hardened path - m/purpose'/coinType'/account'/change'/index'.
+// Child keys of hardened path can't be used for recovery of parent keys with parent public keys
+func (k *keyBundle) GetHardenedPrivateKey(purpose, coinType,
+	account,
+	change,
+	addressIndex uint32,
+) (*ecdsa.PrivateKey, error) {
+	path := []uint32{
+		zeroQuote + purpose,
+		zeroQuote + coinType,
+		zeroQuote + account,
+		zeroQuote + change,
+		zeroQuote + addressIndex,
+	}
+
+	// cloned key shares key bytes with master key - it must not be zeroed
+	extendedKey, err := k.ExtendedKey.CloneWithVersion(k.ExtendedKey.Version())
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range path {
+		childKey, deriveErr := extendedKey.Derive(v)
+		if i != 0 {
+			extendedKey.Zero()
+		}
+
+		if deriveErr != nil {
+			return nil, deriveErr
+		}
+
+		extendedKey = childKey
+	}
+
+	defer extendedKey.Zero()
+
+	privKey, err := extendedKey.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	defer privKey.Zero()
+
+	return privKey.ToECDSA(), nil
+}
@@ -0,0 +1,197 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var ErrContractPredictionAmbiguousInitCode = errors.New("initCode and initCodeHash values can't be used together")
+
+// contractAddressPrediction - params of contract addresses prediction.
+// Numeric fields accept hex (0x-prefixed) or decimal strings
+type contractAddressPrediction struct {
+	// Nonce - nonce of deployment transaction, used for CREATE address
+	Nonce math.HexOrDecimal64 `json:"nonce"`
+
+	// Salt - CREATE2 salt, CREATE2 address calculated only if salt and init code or init code hash are set
+	Salt *common.Hash `json:"salt,omitempty"`
+	// InitCode - contract creation code with constructor arguments
+	InitCode hexutil.Bytes `json:"initCode,omitempty"`
+	// InitCodeHash - keccak256 of init code
+	InitCodeHash *common.Hash `json:"initCodeHash,omitempty"`
+	// Deployer - optional CREATE2 deployer contract address, e.g. factory.
+	// By default - derived account address, for contract accounts or account abstraction wallets
+	Deployer *common.Address `json:"deployer,omitempty"`
+}
+
+// predictedContractAddresses - result of contract addresses prediction
+type predictedContractAddresses struct {
+	Sender         common.Address  `json:"sender"`
+	Nonce          hexutil.Uint64  `json:"nonce"`
+	CreateAddress  common.Address  `json:"createAddress"`
+	Deployer       *common.Address `json:"deployer,omitempty"`
+	Create2Salt    *common.Hash    `json:"create2Salt,omitempty"`
+	Create2Address *common.Address `json:"create2Address,omitempty"`
+}
+
+func predictContractAddresses(sender common.Address,
+	params *contractAddressPrediction,
+) (*predictedContractAddresses, error) {
+	result := &predictedContractAddresses{
+		Sender:        sender,
+		Nonce:         hexutil.Uint64(params.Nonce),
+		CreateAddress: crypto.CreateAddress(sender, uint64(params.Nonce)),
+	}
+
+	if params.InitCode != nil && params.InitCodeHash != nil {
+		return nil, ErrContractPredictionAmbiguousInitCode
+	}
+
+	if params.Salt == nil || (params.InitCode == nil && params.InitCodeHash == nil) {
+		return result, nil
+	}
+
+	initCodeHash := params.InitCodeHash
+	if initCodeHash == nil {
+		hash := crypto.Keccak256Hash(params.InitCode)
+		initCodeHash = &hash
+	}
+
+	deployer := sender
+	if params.Deployer != nil {
+		deployer = *params.Deployer
+	}
+
+	create2Address := crypto.CreateAddress2(deployer, *params.Salt, initCodeHash.Bytes())
+
+	result.Deployer = &deployer
+	result.Create2Salt = params.Salt
+	result.Create2Address = &create2Address
+
+	return result, nil
+}
+
+// PredictContractAddress - predict CREATE and CREATE2 contract addresses for derived account.
+// accountParameters - derivation path of account, predictionParamsData - JSON-encoded contractAddressPrediction.
+// Returns account address and JSON-encoded predictedContractAddresses
+func (u *mnemonicWalletUnit) PredictContractAddress(ctx context.Context,
+	accountParameters *anypb.Any,
+	predictionParamsData []byte,
+) (*string, []byte, error) {
+	accIdentity := &pbCommon.DerivationAddressIdentity{}
+	err := accountParameters.UnmarshalTo(accIdentity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := &contractAddressPrediction{}
+	err = json.Unmarshal(predictionParamsData, params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to unmarshal contract address prediction params: %w", err)
+	}
+
+	address, err := u.getAddressByPath(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := predictContractAddresses(common.HexToAddress(*address), params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return address, resultData, nil
+}
+
+// SignDataWithMetadata - same as SignData, but also returns JSON-encoded signedTxSummary metadata of signed
+// transaction. For contract creation transactions metadata contains CREATE address of deployed contract
+func (u *mnemonicWalletUnit) SignDataWithMetadata(ctx context.Context,
+	accountParameters *anypb.Any,
+	dataForSign []byte,
+) (*string, []byte, []byte, error) {
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = u.approveAccountTx(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		decodedTx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	addr, summary, err := u.signDecodedTx(ctx,
+		accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		decodedTx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	metadata, err := json.Marshal(summary)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return addr, summary.RawTx, metadata, nil
+}
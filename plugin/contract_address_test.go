@@ -0,0 +1,216 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestPredictContractAddresses(t *testing.T) {
+	type testCase struct {
+		Sender string
+		Params string
+
+		ExpectedCreateAddress  string
+		ExpectedCreate2Address string
+	}
+
+	testCases := []*testCase{
+		{
+			Sender:                "0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0",
+			Params:                `{"nonce":"0"}`,
+			ExpectedCreateAddress: "0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d",
+		},
+		{
+			Sender:                "0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0",
+			Params:                `{"nonce":"1"}`,
+			ExpectedCreateAddress: "0x343c43a37d37dff08ae8c4a11544c718abb4fcf8",
+		},
+		// EIP-1014 example 0
+		{
+			Sender: "0x0000000000000000000000000000000000000000",
+			Params: `{"nonce":"0","salt":"0x0000000000000000000000000000000000000000000000000000000000000000",` +
+				`"initCode":"0x00"}`,
+			ExpectedCreateAddress:  "0xbd770416a3345f91e4b34576cb804a576fa48eb1",
+			ExpectedCreate2Address: "0x4d1a2e2bb4f88f0250f26ffff098b0b30b26bf38",
+		},
+		// EIP-1014 example 2 with explicit deployer
+		{
+			Sender: "0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0",
+			Params: `{"nonce":"0","salt":"0x000000000000000000000000feed000000000000000000000000000000000000",` +
+				`"initCodeHash":"` + crypto.Keccak256Hash([]byte{0x00}).Hex() + `",` +
+				`"deployer":"0xdeadbeef00000000000000000000000000000000"}`,
+			ExpectedCreateAddress:  "0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d",
+			ExpectedCreate2Address: "0xd04116cdd17bebe565eb2422f2497e06cc1c9833",
+		},
+	}
+
+	for i, tCase := range testCases {
+		params := &contractAddressPrediction{}
+		err := json.Unmarshal([]byte(tCase.Params), params)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to unmarshal prediction params", err)
+		}
+
+		result, err := predictContractAddresses(common.HexToAddress(tCase.Sender), params)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to predict contract addresses", err)
+		}
+
+		if result.CreateAddress != common.HexToAddress(tCase.ExpectedCreateAddress) {
+			t.Fatalf("%s: %d", "create address not equal with expected", i)
+		}
+
+		if tCase.ExpectedCreate2Address == "" {
+			if result.Create2Address != nil {
+				t.Fatalf("%s: %d", "create2 address must not be calculated", i)
+			}
+
+			continue
+		}
+
+		if result.Create2Address == nil ||
+			*result.Create2Address != common.HexToAddress(tCase.ExpectedCreate2Address) {
+			t.Fatalf("%s: %d", "create2 address not equal with expected", i)
+		}
+	}
+}
+
+func TestMnemonicWalletUnit_SignDataWithMetadata_ContractCreation(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	expectedAddress := common.HexToAddress("0xf8A0F16782625B16260D0A4b0Ed107412bd95d56")
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity := &anypb.Any{}
+	_ = accountIdentity.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	deployTxData, err := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   poolUnit.dataSigner.ChainID(),
+		Nonce:     17,
+		GasTipCap: big.NewInt(1_000_000_000),
+		GasFeeCap: big.NewInt(20_000_000_000),
+		Gas:       500000,
+		To:        nil,
+		Value:     big.NewInt(0),
+		Data:      []byte{0x60, 0x80, 0x60, 0x40, 0x52},
+	}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal binary data", err)
+	}
+
+	addr, signedData, metadataData, err := poolUnit.SignDataWithMetadata(context.Background(),
+		accountIdentity, deployTxData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data:", err)
+	}
+
+	if *addr != expectedAddress.Hex() {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	metadata := &signedTxSummary{}
+	err = json.Unmarshal(metadataData, metadata)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal metadata", err)
+	}
+
+	if metadata.ContractAddress == nil ||
+		*metadata.ContractAddress != crypto.CreateAddress(expectedAddress, 17) {
+		t.Fatalf("%s", "contract address not equal with expected")
+	}
+
+	signedTx := &types.Transaction{}
+	err = signedTx.UnmarshalBinary(signedData)
+	if err != nil || signedTx.Hash() != metadata.TxHash {
+		t.Fatalf("%s", "signed transaction not equal with metadata")
+	}
+}
+
+func TestSignedTxSummary_FeeFields(t *testing.T) {
+	to := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+	chainID := big.NewInt(1)
+	gasPrice := big.NewInt(7_000_000_000)
+
+	testCases := []struct {
+		name        string
+		txData      types.TxData
+		hasGasPrice bool
+	}{
+		{name: "legacy", hasGasPrice: true, txData: &types.LegacyTx{GasPrice: gasPrice, Gas: 21000, To: &to}},
+		{name: "access list", hasGasPrice: true, txData: &types.AccessListTx{ChainID: chainID,
+			GasPrice: gasPrice, Gas: 21000, To: &to}},
+		{name: "dynamic fee", hasGasPrice: false, txData: &types.DynamicFeeTx{ChainID: chainID,
+			GasTipCap: big.NewInt(1), GasFeeCap: gasPrice, Gas: 21000, To: &to}},
+	}
+
+	for _, testCase := range testCases {
+		summary := &signedTxSummary{}
+		err := summary.fillFromTx(to, types.NewTx(testCase.txData))
+		if err != nil {
+			t.Fatalf("%s: %s: %e", testCase.name, "unable to fill summary", err)
+		}
+
+		if testCase.hasGasPrice && (summary.GasPrice.ToInt().Cmp(gasPrice) != 0 ||
+			summary.MaxFeePerGas != nil || summary.MaxPriorityFeePerGas != nil) {
+			t.Fatalf("%s: %s", testCase.name, "gas price transaction summary must contain only gas price")
+		}
+
+		if !testCase.hasGasPrice && (summary.GasPrice != nil || summary.MaxFeePerGas.ToInt().Cmp(gasPrice) != 0) {
+			t.Fatalf("%s: %s", testCase.name, "dynamic fee transaction summary must contain only fee caps")
+		}
+	}
+}
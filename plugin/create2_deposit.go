@@ -0,0 +1,378 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	create2FactoryMethodDeploy = "deploy"
+	create2FactoryMethodFlush  = "flush"
+
+	// create2DefaultDeploySignature - factory method, which deploys forwarder contract by salt
+	create2DefaultDeploySignature = "deploy(bytes32)"
+	// create2DefaultFlushSignature - factory method, which flush forwarder balance of token to treasury.
+	// Zero token address means native currency
+	create2DefaultFlushSignature = "flush(bytes32,address)"
+)
+
+var (
+	ErrCreate2ConfigNotSet        = errors.New("create2 deposit addresses config not set")
+	ErrCreate2MissingFactory      = errors.New("missing factory address in create2 deposit addresses config")
+	ErrCreate2MissingInitCodeHash = errors.New("missing init code hash in create2 deposit addresses config")
+	ErrCreate2UnknownMethod       = errors.New("unknown factory method")
+)
+
+// create2DepositConfig - config of counterfactual forwarder contracts, deployed by factory via CREATE2 opcode.
+// Salt of forwarder is keccak256(abi.encodePacked(saltNamespace, uint32(account), uint32(change), uint32(index))).
+// Salt depends only on derivation path and configured constant, so forwarder addresses stay reachable
+// after change of operator path. Wallets which use one factory must use different salt namespaces
+type create2DepositConfig struct {
+	Factory      common.Address `json:"factory"`
+	InitCodeHash common.Hash    `json:"initCodeHash"`
+	// SaltNamespace - optional constant prefix of salt, default zero hash
+	SaltNamespace common.Hash `json:"saltNamespace,omitempty"`
+	// Operator - derivation path of factory calls sender
+	Operator *derivationPath `json:"operator"`
+
+	// DeploySignature - optional, default deploy(bytes32)
+	DeploySignature string `json:"deploySignature,omitempty"`
+	// FlushSignature - optional, default flush(bytes32,address)
+	FlushSignature string `json:"flushSignature,omitempty"`
+}
+
+// derivationPath - JSON representation of account derivation path
+type derivationPath struct {
+	AccountIndex  uint32 `json:"accountIndex"`
+	InternalIndex uint32 `json:"internalIndex"`
+	AddressIndex  uint32 `json:"addressIndex"`
+}
+
+// create2FactoryCall - params of factory call for forwarder contract
+type create2FactoryCall struct {
+	// Method - deploy or flush
+	Method string `json:"method"`
+	// Token - ERC-20 token contract for flush call, empty value means native currency
+	Token common.Address      `json:"token,omitempty"`
+	Nonce math.HexOrDecimal64 `json:"nonce"`
+
+	txFeeParams
+}
+
+// signedCreate2FactoryCall - result of factory call signature
+type signedCreate2FactoryCall struct {
+	signedTxSummary
+
+	Method         string         `json:"method"`
+	Salt           common.Hash    `json:"salt"`
+	DepositAddress common.Address `json:"depositAddress"`
+}
+
+func (c *create2DepositConfig) validate() error {
+	if c.Factory == (common.Address{}) {
+		return ErrCreate2MissingFactory
+	}
+
+	if c.InitCodeHash == (common.Hash{}) {
+		return ErrCreate2MissingInitCodeHash
+	}
+
+	if c.Operator == nil {
+		c.Operator = &derivationPath{}
+	}
+
+	if c.DeploySignature == "" {
+		c.DeploySignature = create2DefaultDeploySignature
+	}
+
+	if c.FlushSignature == "" {
+		c.FlushSignature = create2DefaultFlushSignature
+	}
+
+	return nil
+}
+
+func (c *create2DepositConfig) salt(account, change, index uint32) common.Hash {
+	packed := make([]byte, common.HashLength+12)
+	copy(packed, c.SaltNamespace.Bytes())
+	binary.BigEndian.PutUint32(packed[common.HashLength:], account)
+	binary.BigEndian.PutUint32(packed[common.HashLength+4:], change)
+	binary.BigEndian.PutUint32(packed[common.HashLength+8:], index)
+
+	return crypto.Keccak256Hash(packed)
+}
+
+func (c *create2DepositConfig) depositAddress(account, change, index uint32) common.Address {
+	salt := c.salt(account, change, index)
+
+	return crypto.CreateAddress2(c.Factory, salt, c.InitCodeHash.Bytes())
+}
+
+func (c *create2DepositConfig) makeCallData(call *create2FactoryCall, salt common.Hash) ([]byte, error) {
+	switch call.Method {
+	case create2FactoryMethodDeploy:
+		data := make([]byte, 0, 4+common.HashLength)
+		data = append(data, crypto.Keccak256([]byte(c.DeploySignature))[:4]...)
+		data = append(data, salt.Bytes()...)
+
+		return data, nil
+
+	case create2FactoryMethodFlush:
+		data := make([]byte, 0, 4+common.HashLength*2)
+		data = append(data, crypto.Keccak256([]byte(c.FlushSignature))[:4]...)
+		data = append(data, salt.Bytes()...)
+		data = append(data, common.LeftPadBytes(call.Token.Bytes(), common.HashLength)...)
+
+		return data, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrCreate2UnknownMethod, call.Method)
+	}
+}
+
+// SetCreate2DepositConfig - set config of CREATE2 deposit addresses. configData - JSON-encoded create2DepositConfig
+func (u *mnemonicWalletUnit) SetCreate2DepositConfig(ctx context.Context,
+	configData []byte,
+) error {
+	config := &create2DepositConfig{}
+	err := json.Unmarshal(configData, config)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal create2 deposit config: %w", err)
+	}
+
+	err = config.validate()
+	if err != nil {
+		return err
+	}
+
+	// operator path must be derivable before usage in factory calls
+	_, err = u.getAddressByPath(ctx, config.Operator.AccountIndex,
+		config.Operator.InternalIndex,
+		config.Operator.AddressIndex)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.create2Config = config
+
+	return nil
+}
+
+// GetCreate2AccountAddress - returns EOA address and CREATE2 forwarder address of derivation path
+func (u *mnemonicWalletUnit) GetCreate2AccountAddress(ctx context.Context,
+	accountParameters *anypb.Any,
+) (*string, *string, error) {
+	accIdentity := &pbCommon.DerivationAddressIdentity{}
+	err := accountParameters.UnmarshalTo(accIdentity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err := u.getCreate2Config()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address, err := u.getAddressByPath(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	create2Address := config.depositAddress(accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex).Hex()
+
+	return address, &create2Address, nil
+}
+
+// GetMultipleCreate2Accounts - same as GetMultipleAccounts, but returns two lists with same order:
+// list of EOA addresses and list of CREATE2 forwarder addresses
+func (u *mnemonicWalletUnit) GetMultipleCreate2Accounts(ctx context.Context,
+	multipleAccountsParameters *anypb.Any,
+) (uint, []*pbCommon.AccountIdentity, []*pbCommon.AccountIdentity, error) {
+	config, err := u.getCreate2Config()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	count, accounts, err := u.GetMultipleAccounts(ctx, multipleAccountsParameters)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	create2Accounts := make([]*pbCommon.AccountIdentity, len(accounts))
+	for i, accountIdentity := range accounts {
+		if accountIdentity == nil {
+			continue
+		}
+
+		accIdentity := &pbCommon.DerivationAddressIdentity{}
+		err = accountIdentity.Parameters.UnmarshalTo(accIdentity)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+
+		create2Accounts[i] = &pbCommon.AccountIdentity{
+			Parameters: accountIdentity.Parameters,
+			Address: config.depositAddress(accIdentity.AccountIndex,
+				accIdentity.InternalIndex,
+				accIdentity.AddressIndex).Hex(),
+		}
+	}
+
+	return count, accounts, create2Accounts, nil
+}
+
+func (u *mnemonicWalletUnit) getCreate2Config() (*create2DepositConfig, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.create2Config == nil {
+		return nil, ErrCreate2ConfigNotSet
+	}
+
+	return u.create2Config, nil
+}
+
+// SignCreate2FactoryCall - sign factory deploy or flush call for CREATE2 forwarder of derivation path.
+// Transaction signed by operator path from config. accountParameters - derivation path of forwarder,
+// factoryCallData - JSON-encoded create2FactoryCall. Returns operator address and
+// JSON-encoded signedCreate2FactoryCall
+func (u *mnemonicWalletUnit) SignCreate2FactoryCall(ctx context.Context,
+	accountParameters *anypb.Any,
+	factoryCallData []byte,
+) (*string, []byte, error) {
+	accIdentity := &pbCommon.DerivationAddressIdentity{}
+	err := accountParameters.UnmarshalTo(accIdentity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	call := &create2FactoryCall{}
+	err = json.Unmarshal(factoryCallData, call)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to unmarshal factory call: %w", err)
+	}
+
+	u.mu.Lock()
+	config := u.create2Config
+	u.mu.Unlock()
+
+	if config == nil {
+		return nil, nil, ErrCreate2ConfigNotSet
+	}
+
+	result, tx, err := u.makeCreate2FactoryCall(config, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		call)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = u.approveAccountTx(ctx, config.Operator.AccountIndex,
+		config.Operator.InternalIndex,
+		config.Operator.AddressIndex,
+		tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, summary, err := u.signDecodedTx(ctx, config.Operator.AccountIndex,
+		config.Operator.InternalIndex,
+		config.Operator.AddressIndex,
+		tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.signedTxSummary = *summary
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	from := result.From.Hex()
+
+	return &from, resultData, nil
+}
+
+// makeCreate2FactoryCall - assemble factory call transaction of operator for CREATE2 forwarder of derivation path.
+// Returns factory call description without signed transaction summary
+func (u *mnemonicWalletUnit) makeCreate2FactoryCall(config *create2DepositConfig,
+	account, change, index uint32,
+	call *create2FactoryCall,
+) (*signedCreate2FactoryCall, *signingTx, error) {
+	err := call.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	salt := config.salt(account, change, index)
+	callData, err := config.makeCallData(call, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	factory := config.Factory
+	txData := call.makeTxData(u.dataSigner.ChainID(), uint64(call.Nonce), &factory, new(big.Int), callData)
+
+	return &signedCreate2FactoryCall{
+		Method:         call.Method,
+		Salt:           salt,
+		DepositAddress: config.depositAddress(account, change, index),
+	}, &signingTx{tx: types.NewTx(txData)}, nil
+}
@@ -0,0 +1,195 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMnemonicWalletUnit_Create2DepositAddresses(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	factory := common.HexToAddress("0x4e59b44847b379578588920cA78FbF26c0B4956C")
+	initCodeHash := crypto.Keccak256Hash([]byte("forwarder init code"))
+	// operator path 7'/8/9
+	operatorAddress := common.HexToAddress("0xf8A0F16782625B16260D0A4b0Ed107412bd95d56")
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity := &anypb.Any{}
+	_ = accountIdentity.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  1,
+		InternalIndex: 0,
+		AddressIndex:  15,
+	})
+
+	_, _, err = poolUnit.GetCreate2AccountAddress(context.Background(), accountIdentity)
+	if !errors.Is(err, ErrCreate2ConfigNotSet) {
+		t.Fatalf("%s", "create2 address must not be calculated without config")
+	}
+
+	saltNamespace := crypto.Keccak256Hash([]byte("wallet deposit namespace"))
+	configTemplate := `{
+		"factory": "` + factory.Hex() + `",
+		"initCodeHash": "` + initCodeHash.Hex() + `",
+		"saltNamespace": "` + saltNamespace.Hex() + `",
+		"operator": {"accountIndex": 7, "internalIndex": 8, "addressIndex": %d}
+	}`
+
+	expectedEOAAddress, _ := poolUnit.getAddressByPath(context.Background(), 1, 0, 15)
+
+	packedSalt := make([]byte, 44)
+	copy(packedSalt, saltNamespace.Bytes())
+	binary.BigEndian.PutUint32(packedSalt[32:], 1)
+	binary.BigEndian.PutUint32(packedSalt[36:], 0)
+	binary.BigEndian.PutUint32(packedSalt[40:], 15)
+	expectedSalt := crypto.Keccak256Hash(packedSalt)
+	expectedCreate2Address := crypto.CreateAddress2(factory, expectedSalt, initCodeHash.Bytes())
+
+	rangeRequest := &anypb.Any{}
+	_ = rangeRequest.MarshalFrom(&pbCommon.RangeUnitsList{
+		RangeUnits: []*pbCommon.RangeRequestUnit{
+			{AccountIndex: 1, InternalIndex: 0, AddressIndexFrom: 10, AddressIndexTo: 20},
+		},
+	})
+
+	// salt not depends on operator path - forwarder addresses same for any operator
+	for _, operatorIndex := range []uint32{10, 9} {
+		err = poolUnit.SetCreate2DepositConfig(context.Background(),
+			[]byte(fmt.Sprintf(configTemplate, operatorIndex)))
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to set create2 config", err)
+		}
+
+		eoaAddress, create2Address, loopErr := poolUnit.GetCreate2AccountAddress(context.Background(),
+			accountIdentity)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to get create2 address", loopErr)
+		}
+
+		if *eoaAddress != *expectedEOAAddress || *create2Address != expectedCreate2Address.Hex() {
+			t.Fatalf("%s", "eoa and create2 addresses not equal with expected")
+		}
+
+		// EOA address methods not affected by create2 config
+		address, loopErr := poolUnit.GetAccountAddress(context.Background(), accountIdentity)
+		if loopErr != nil || *address != *expectedEOAAddress {
+			t.Fatalf("%s", "account address must be eoa address")
+		}
+
+		count, eoaList, create2List, loopErr := poolUnit.GetMultipleCreate2Accounts(context.Background(),
+			rangeRequest)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to get multiple create2 addresses", loopErr)
+		}
+
+		if count != 11 || len(eoaList) != 11 || len(create2List) != 11 ||
+			eoaList[5].Address != *expectedEOAAddress || create2List[5].Address != *create2Address {
+			t.Fatalf("%s", "eoa and create2 addresses lists not equal with expected")
+		}
+	}
+
+	// EOA address returned next to create2 address resolvable for signing by address
+	recipient := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+	dataForSign, _ := types.NewTx(&types.LegacyTx{
+		Nonce:    1,
+		GasPrice: big.NewInt(10000000000),
+		Gas:      21000,
+		To:       &recipient,
+		Value:    big.NewInt(1500000),
+	}).MarshalBinary()
+
+	addressIdentity, _ := anypb.New(wrapperspb.String(*expectedEOAAddress))
+	signerAddress, _, err := poolUnit.SignData(context.Background(), addressIdentity, dataForSign)
+	if err != nil || *signerAddress != *expectedEOAAddress {
+		t.Fatalf("%s: %e", "unable to sign data by eoa address", err)
+	}
+
+	addr, resultData, err := poolUnit.SignCreate2FactoryCall(context.Background(), accountIdentity, []byte(`{
+		"method": "deploy", "nonce": "1", "gasLimit": "150000",
+		"maxFeePerGas": "30000000000", "maxPriorityFeePerGas": "1000000000"
+	}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign factory call", err)
+	}
+
+	if *addr != operatorAddress.Hex() {
+		t.Fatalf("%s", "factory call sender not equal with operator address")
+	}
+
+	result := &signedCreate2FactoryCall{}
+	_ = json.Unmarshal(resultData, result)
+
+	signedTx := &types.Transaction{}
+	err = signedTx.UnmarshalBinary(result.RawTx)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal factory call transaction", err)
+	}
+
+	sender, err := types.Sender(poolUnit.dataSigner, signedTx)
+	if err != nil || sender != operatorAddress {
+		t.Fatalf("%s", "factory call sender not equal with operator address")
+	}
+
+	expectedCallData := append(crypto.Keccak256([]byte("deploy(bytes32)"))[:4], expectedSalt.Bytes()...)
+	if *signedTx.To() != factory || !bytes.Equal(signedTx.Data(), expectedCallData) {
+		t.Fatalf("%s", "factory call payload not equal with expected")
+	}
+
+	if result.DepositAddress != expectedCreate2Address || result.Salt != expectedSalt {
+		t.Fatalf("%s", "factory call result not equal with expected")
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"golang.org/x/crypto/hkdf"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	sharedSecretDefaultLength = 32
+	sharedSecretMaxLength     = 64
+)
+
+var (
+	ErrSharedSecretWrongParams  = errors.New("wrong shared secret params")
+	ErrSharedSecretWrongPeerKey = errors.New("wrong peer public key")
+)
+
+// sharedSecretParams - HKDF-SHA256 params of shared secret, JSON format
+type sharedSecretParams struct {
+	// Info - required context label of shared secret, e.g. protocol name and version
+	Info string `json:"info"`
+	// Salt - optional HKDF salt
+	Salt hexutil.Bytes `json:"salt,omitempty"`
+	// Length - length of shared secret in bytes. Default 32, max 64
+	Length uint `json:"length,omitempty"`
+}
+
+func (p *sharedSecretParams) validate() error {
+	if p.Info == "" {
+		return fmt.Errorf("%w: info label is empty", ErrSharedSecretWrongParams)
+	}
+
+	if p.Length == 0 {
+		p.Length = sharedSecretDefaultLength
+	}
+
+	if p.Length > sharedSecretMaxLength {
+		return fmt.Errorf("%w: length %d greater than %d", ErrSharedSecretWrongParams,
+			p.Length, sharedSecretMaxLength)
+	}
+
+	return nil
+}
+
+// DeriveSharedSecret - secp256k1 ECDH between private key of account and peer public key.
+// Returns address of account and HKDF-SHA256 expanded shared secret, raw ECDH point never leaves plugin.
+// peerPublicKey - compressed or uncompressed secp256k1 public key,
+// sharedSecretParamsData - JSON-encoded sharedSecretParams
+func (u *mnemonicWalletUnit) DeriveSharedSecret(ctx context.Context,
+	accountParameters *anypb.Any,
+	peerPublicKey []byte,
+	sharedSecretParamsData []byte,
+) (*string, []byte, error) {
+	params := &sharedSecretParams{}
+	err := json.Unmarshal(sharedSecretParamsData, params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrSharedSecretWrongParams, err.Error())
+	}
+
+	err = params.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	peerPubKey, err := btcec.ParsePubKey(peerPublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrSharedSecretWrongPeerKey, err.Error())
+	}
+
+	addr, privKey, err := u.loadAccountKey(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroKey(privKey)
+
+	keyBytes := math.PaddedBigBytes(privKey.D, 32)
+	defer clear(keyBytes)
+
+	btcPrivKey, _ := btcec.PrivKeyFromBytes(keyBytes)
+	defer btcPrivKey.Zero()
+
+	// x coordinate of ECDH point
+	sharedPoint := btcec.GenerateSharedSecret(btcPrivKey, peerPubKey)
+	defer clear(sharedPoint)
+
+	sharedSecret := make([]byte, params.Length)
+	_, err = io.ReadFull(hkdf.New(sha256.New, sharedPoint, params.Salt, []byte(params.Info)), sharedSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addr, sharedSecret, nil
+}
@@ -0,0 +1,160 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/hkdf"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMnemonicWalletUnit_DeriveSharedSecret(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	firstIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+	secondIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  3,
+		InternalIndex: 0,
+		AddressIndex:  1,
+	})
+
+	getPubKey := func(accountIdentity *anypb.Any) *accountPublicKey {
+		_, pubKeyData, pubKeyErr := poolUnit.GetAccountPublicKey(context.Background(), accountIdentity)
+		if pubKeyErr != nil {
+			t.Fatalf("%s: %e", "unable to get account public key", pubKeyErr)
+		}
+
+		pubKey := &accountPublicKey{}
+		_ = json.Unmarshal(pubKeyData, pubKey)
+
+		return pubKey
+	}
+
+	firstPubKey := getPubKey(firstIdentity)
+	secondPubKey := getPubKey(secondIdentity)
+
+	params := []byte(`{"info":"settlement-messaging/v1","salt":"0x0102030405"}`)
+
+	addr, firstSecret, err := poolUnit.DeriveSharedSecret(context.Background(), firstIdentity,
+		secondPubKey.CompressedPublicKey, params)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive shared secret", err)
+	}
+
+	if *addr != firstPubKey.Address || len(firstSecret) != sharedSecretDefaultLength {
+		t.Fatalf("%s", "shared secret address or length not equal with expected")
+	}
+
+	_, secondSecret, err := poolUnit.DeriveSharedSecret(context.Background(), secondIdentity,
+		firstPubKey.UncompressedPublicKey, params)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive shared secret", err)
+	}
+
+	if !bytes.Equal(firstSecret, secondSecret) {
+		t.Fatalf("%s", "shared secrets of peers not equal")
+	}
+
+	// independent ECDH and HKDF calculation
+	hdWalletAccount, err := poolUnit.hdWalletSvc.NewAccount(3, 0, 1)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive account", err)
+	}
+
+	secondPrivKey, _ := crypto.ToECDSA(hdWalletAccount.CloneECDSAPrivateKey().D.Bytes())
+	hdWalletAccount.ClearSecrets()
+
+	firstECDSAPubKey, _ := crypto.UnmarshalPubkey(firstPubKey.UncompressedPublicKey)
+	sharedPoint, err := ecies.ImportECDSA(secondPrivKey).GenerateShared(ecies.ImportECDSAPublic(firstECDSAPubKey),
+		32, 0)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate ecdh shared point", err)
+	}
+
+	expectedSecret := make([]byte, sharedSecretDefaultLength)
+	_, _ = io.ReadFull(hkdf.New(sha256.New, sharedPoint, []byte{1, 2, 3, 4, 5},
+		[]byte("settlement-messaging/v1")), expectedSecret)
+
+	if !bytes.Equal(firstSecret, expectedSecret) {
+		t.Fatalf("%s", "shared secret not equal with expected")
+	}
+
+	_, otherLabelSecret, err := poolUnit.DeriveSharedSecret(context.Background(), firstIdentity,
+		secondPubKey.CompressedPublicKey, []byte(`{"info":"another-protocol/v1","length":64}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to derive shared secret", err)
+	}
+
+	if len(otherLabelSecret) != 64 || bytes.Equal(otherLabelSecret[:32], firstSecret) {
+		t.Fatalf("%s", "shared secret with another label must be different")
+	}
+
+	_, _, err = poolUnit.DeriveSharedSecret(context.Background(), firstIdentity,
+		secondPubKey.CompressedPublicKey, []byte(`{"info":""}`))
+	if !errors.Is(err, ErrSharedSecretWrongParams) {
+		t.Fatalf("%s", "shared secret without info label must not be derived")
+	}
+
+	_, _, err = poolUnit.DeriveSharedSecret(context.Background(), firstIdentity,
+		[]byte{0x02, 0x01}, params)
+	if !errors.Is(err, ErrSharedSecretWrongPeerKey) {
+		t.Fatalf("%s", "shared secret with wrong peer key must not be derived")
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// encryptionSchemeECIES - go-ethereum ECIES over secp256k1 with AES-128-CTR and HMAC-SHA256.
+	// Encryption public key - hex encoded uncompressed secp256k1 public key
+	encryptionSchemeECIES = "ecies"
+	// encryptionSchemeX25519 - MetaMask eth_getEncryptionPublicKey and eth_decrypt scheme.
+	// Encryption public key - base64 encoded x25519 public key of account private key
+	encryptionSchemeX25519 = "x25519-xsalsa20-poly1305"
+
+	x25519NonceLength = 24
+	x25519KeyLength   = 32
+)
+
+var (
+	ErrEncryptionSchemeUnsupported = errors.New("unsupported encryption scheme")
+	ErrEncryptedDataInvalid        = errors.New("invalid encrypted data")
+	ErrDecryptionFailed            = errors.New("unable to decrypt data")
+)
+
+// x25519EncryptedData - MetaMask eth_decrypt encrypted data, JSON format. Binary fields encoded in base64
+type x25519EncryptedData struct {
+	Version        string `json:"version"`
+	Nonce          string `json:"nonce"`
+	EphemPublicKey string `json:"ephemPublicKey"`
+	Ciphertext     string `json:"ciphertext"`
+}
+
+// GetEncryptionPublicKey - returns address and encryption public key of account for encryption scheme:
+//   - ecies - hex encoded uncompressed secp256k1 public key
+//   - x25519-xsalsa20-poly1305 - base64 encoded x25519 public key, same as MetaMask eth_getEncryptionPublicKey
+func (u *mnemonicWalletUnit) GetEncryptionPublicKey(ctx context.Context,
+	accountParameters *anypb.Any,
+	scheme string,
+) (*string, *string, error) {
+	addr, privKey, err := u.loadAccountKey(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroKey(privKey)
+
+	var pubKey string
+
+	switch scheme {
+	case encryptionSchemeECIES:
+		pubKey = hexutil.Encode(crypto.FromECDSAPub(&privKey.PublicKey))
+
+	case encryptionSchemeX25519:
+		secretKey := math.PaddedBigBytes(privKey.D, x25519KeyLength)
+		defer clear(secretKey)
+
+		x25519PubKey, x25519Err := curve25519.X25519(secretKey, curve25519.Basepoint)
+		if x25519Err != nil {
+			return nil, nil, x25519Err
+		}
+
+		pubKey = base64.StdEncoding.EncodeToString(x25519PubKey)
+
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrEncryptionSchemeUnsupported, scheme)
+	}
+
+	return addr, &pubKey, nil
+}
+
+// DecryptData - decrypt data, encrypted to encryption public key of account. Encrypted data format:
+//   - ecies - raw go-ethereum ECIES ciphertext, without shared info
+//   - x25519-xsalsa20-poly1305 - MetaMask eth_decrypt JSON with version, nonce, ephemPublicKey and ciphertext
+func (u *mnemonicWalletUnit) DecryptData(ctx context.Context,
+	accountParameters *anypb.Any,
+	scheme string,
+	encryptedData []byte,
+) (*string, []byte, error) {
+	if scheme != encryptionSchemeECIES && scheme != encryptionSchemeX25519 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrEncryptionSchemeUnsupported, scheme)
+	}
+
+	addr, privKey, err := u.loadAccountKey(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroKey(privKey)
+
+	var decryptedData []byte
+	if scheme == encryptionSchemeECIES {
+		decryptedData, err = decryptECIES(privKey, encryptedData)
+	} else {
+		decryptedData, err = decryptX25519(privKey, encryptedData)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addr, decryptedData, nil
+}
+
+func decryptECIES(privKey *ecdsa.PrivateKey, encryptedData []byte) ([]byte, error) {
+	keyBytes := math.PaddedBigBytes(privKey.D, x25519KeyLength)
+	defer clear(keyBytes)
+
+	// ECIES params resolved by curve of go-ethereum crypto package
+	eciesPrivKey, err := crypto.ToECDSA(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(eciesPrivKey)
+
+	decryptedData, err := ecies.ImportECDSA(eciesPrivKey).Decrypt(encryptedData, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDecryptionFailed, err.Error())
+	}
+
+	return decryptedData, nil
+}
+
+func decryptX25519(privKey *ecdsa.PrivateKey, encryptedData []byte) ([]byte, error) {
+	data := &x25519EncryptedData{}
+	err := json.Unmarshal(encryptedData, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrEncryptedDataInvalid, err.Error())
+	}
+
+	if data.Version != encryptionSchemeX25519 {
+		return nil, fmt.Errorf("%w: unsupported version %s", ErrEncryptedDataInvalid, data.Version)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(data.Nonce)
+	if err != nil || len(nonce) != x25519NonceLength {
+		return nil, fmt.Errorf("%w: wrong nonce", ErrEncryptedDataInvalid)
+	}
+
+	ephemPubKey, err := base64.StdEncoding.DecodeString(data.EphemPublicKey)
+	if err != nil || len(ephemPubKey) != x25519KeyLength {
+		return nil, fmt.Errorf("%w: wrong ephemeral public key", ErrEncryptedDataInvalid)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(data.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong ciphertext", ErrEncryptedDataInvalid)
+	}
+
+	var nonceArr [x25519NonceLength]byte
+	var ephemPubKeyArr, secretKeyArr [x25519KeyLength]byte
+
+	copy(nonceArr[:], nonce)
+	copy(ephemPubKeyArr[:], ephemPubKey)
+	privKey.D.FillBytes(secretKeyArr[:])
+	defer clear(secretKeyArr[:])
+
+	decryptedData, isOpened := box.Open(nil, ciphertext, &nonceArr, &ephemPubKeyArr, &secretKeyArr)
+	if !isOpened {
+		return nil, ErrDecryptionFailed
+	}
+
+	return decryptedData, nil
+}
+
+// loadAccountKey - returns address and cloned private key of account. Cloned key must be zeroed by caller
+func (u *mnemonicWalletUnit) loadAccountKey(ctx context.Context,
+	accountParameters *anypb.Any,
+) (*string, *ecdsa.PrivateKey, error) {
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.loadAccountDataByPath(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex)
+}
@@ -0,0 +1,203 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/nacl/box"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// encryptX25519 - encrypt data to x25519 encryption public key, same as MetaMask eth-sig-util encrypt function
+func encryptX25519(encryptionPubKey string, data []byte) ([]byte, error) {
+	pubKey, err := base64.StdEncoding.DecodeString(encryptionPubKey)
+	if err != nil || len(pubKey) != x25519KeyLength {
+		return nil, fmt.Errorf("%w: wrong encryption public key", ErrEncryptedDataInvalid)
+	}
+
+	var pubKeyArr [x25519KeyLength]byte
+	copy(pubKeyArr[:], pubKey)
+
+	ephemPubKey, ephemPrivKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(ephemPrivKey[:])
+
+	var nonce [x25519NonceLength]byte
+	_, err = rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := box.Seal(nil, data, &nonce, &pubKeyArr, ephemPrivKey)
+
+	return json.Marshal(&x25519EncryptedData{
+		Version:        encryptionSchemeX25519,
+		Nonce:          base64.StdEncoding.EncodeToString(nonce[:]),
+		EphemPublicKey: base64.StdEncoding.EncodeToString(ephemPubKey[:]),
+		Ciphertext:     base64.StdEncoding.EncodeToString(ciphertext),
+	})
+}
+
+func TestDecryptX25519(t *testing.T) {
+	// MetaMask eth-sig-util test vector
+	// WARN: DO NOT USE THIS PRIVATE KEY IN MAINNET OR TESTNET. Usage only in unit-tests
+	privKey, err := crypto.HexToECDSA("7e5374ec2ef0d91761a6e72fdf8f6ac665519bfdf6da0a2329cf0d804514b816")
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to parse private key", err)
+	}
+
+	encryptedData := []byte(`{"version":"x25519-xsalsa20-poly1305",` +
+		`"nonce":"1dvWO7uOnBnO7iNDJ9kO9pTasLuKNlej",` +
+		`"ephemPublicKey":"FBH1/pAEHOOW14Lu3FWkgV3qOEcuL78Zy+qW1RwzMXQ=",` +
+		`"ciphertext":"f8kBcl/NCyf3sybfbwAKk/np2Bzt9lRVkZejr6uh5FgnNlH/ic62DZzy"}`)
+
+	decryptedData, err := decryptX25519(privKey, encryptedData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decrypt data", err)
+	}
+
+	if string(decryptedData) != "My name is Satoshi Buterin" {
+		t.Fatalf("%s", "decrypted data not equal with expected")
+	}
+}
+
+func TestMnemonicWalletUnit_DecryptData(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	message := []byte("settlement instruction #1")
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	// ECIES flow
+	_, eciesPubKeyHex, err := poolUnit.GetEncryptionPublicKey(context.Background(), accountIdentity,
+		encryptionSchemeECIES)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get ecies encryption public key", err)
+	}
+
+	eciesPubKey, err := crypto.UnmarshalPubkey(hexutil.MustDecode(*eciesPubKeyHex))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal ecies public key", err)
+	}
+
+	if crypto.PubkeyToAddress(*eciesPubKey).Hex() != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" {
+		t.Fatalf("%s", "ecies public key not equal with expected")
+	}
+
+	eciesCiphertext, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(eciesPubKey), message, nil, nil)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt data by ecies", err)
+	}
+
+	addr, decryptedData, err := poolUnit.DecryptData(context.Background(), accountIdentity,
+		encryptionSchemeECIES, eciesCiphertext)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decrypt ecies data", err)
+	}
+
+	if string(decryptedData) != string(message) || *addr != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" {
+		t.Fatalf("%s", "decrypted ecies data not equal with expected")
+	}
+
+	eciesCiphertext[len(eciesCiphertext)-1] ^= 0x1
+	_, _, err = poolUnit.DecryptData(context.Background(), accountIdentity, encryptionSchemeECIES, eciesCiphertext)
+	if !errors.Is(err, ErrDecryptionFailed) {
+		t.Fatalf("%s", "corrupted ecies data must not be decrypted")
+	}
+
+	// MetaMask x25519-xsalsa20-poly1305 flow
+	_, x25519PubKey, err := poolUnit.GetEncryptionPublicKey(context.Background(), accountIdentity,
+		encryptionSchemeX25519)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get x25519 encryption public key", err)
+	}
+
+	x25519EncryptedJSON, err := encryptX25519(*x25519PubKey, message)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt data by x25519", err)
+	}
+
+	_, decryptedData, err = poolUnit.DecryptData(context.Background(), accountIdentity,
+		encryptionSchemeX25519, x25519EncryptedJSON)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decrypt x25519 data", err)
+	}
+
+	if string(decryptedData) != string(message) {
+		t.Fatalf("%s", "decrypted x25519 data not equal with expected")
+	}
+
+	encryptedData := &x25519EncryptedData{}
+	_ = json.Unmarshal(x25519EncryptedJSON, encryptedData)
+	encryptedData.Version = "x25519-unknown"
+	wrongVersionJSON, _ := json.Marshal(encryptedData)
+
+	_, _, err = poolUnit.DecryptData(context.Background(), accountIdentity, encryptionSchemeX25519, wrongVersionJSON)
+	if !errors.Is(err, ErrEncryptedDataInvalid) {
+		t.Fatalf("%s", "encrypted data with wrong version must not be decrypted")
+	}
+
+	_, _, err = poolUnit.GetEncryptionPublicKey(context.Background(), accountIdentity, "rsa")
+	if !errors.Is(err, ErrEncryptionSchemeUnsupported) {
+		t.Fatalf("%s", "unknown encryption scheme must not be supported")
+	}
+}
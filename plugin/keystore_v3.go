@@ -0,0 +1,339 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	keystoreVersion = 3
+
+	keystoreCipher     = "aes-128-ctr"
+	keystoreKDFScrypt  = "scrypt"
+	keystoreKDFPBKDF2  = "pbkdf2"
+	keystorePBKDF2PRF  = "hmac-sha256"
+	keystoreKeyLength  = 32
+	keystoreSaltLength = 32
+
+	keystoreScryptR        = 8
+	keystoreScryptMinN     = keystore.LightScryptN
+	keystoreScryptMaxN     = 1 << 20
+	keystoreScryptMaxP     = 16
+	keystorePBKDF2DefaultC = 262144
+	keystorePBKDF2MinC     = 10000
+	keystorePBKDF2MaxC     = 10000000
+)
+
+var (
+	ErrKeystoreExportDisabled    = errors.New("keystore export disabled by plugin build configuration")
+	ErrKeystorePassphraseEmpty   = errors.New("keystore passphrase is empty")
+	ErrKeystoreExportWrongParams = errors.New("wrong keystore export params")
+	ErrKeystoreExportTwoStepGate = errors.New("keystore export disabled while two-step signing required")
+)
+
+// keystoreExportParams - KDF parameters of exported keystore, JSON format. All fields are optional
+type keystoreExportParams struct {
+	// KDF - scrypt or pbkdf2, default scrypt
+	KDF string `json:"kdf"`
+	// ScryptN - scrypt CPU/memory cost, power of two. Default 262144
+	ScryptN int `json:"scryptN"`
+	// ScryptP - scrypt parallelization. Default 1
+	ScryptP int `json:"scryptP"`
+	// PBKDF2C - pbkdf2 iterations count. Default 262144
+	PBKDF2C int `json:"pbkdf2C"`
+}
+
+func (p *keystoreExportParams) validate() error {
+	switch p.KDF {
+	case "":
+		p.KDF = keystoreKDFScrypt
+		fallthrough
+	case keystoreKDFScrypt:
+		if p.ScryptN == 0 {
+			p.ScryptN = keystore.StandardScryptN
+		}
+
+		if p.ScryptP == 0 {
+			p.ScryptP = keystore.StandardScryptP
+		}
+
+		if p.ScryptN < keystoreScryptMinN || p.ScryptN > keystoreScryptMaxN || p.ScryptN&(p.ScryptN-1) != 0 {
+			return fmt.Errorf("%w: scryptN must be power of two in range %d-%d",
+				ErrKeystoreExportWrongParams, keystoreScryptMinN, keystoreScryptMaxN)
+		}
+
+		if p.ScryptP < 1 || p.ScryptP > keystoreScryptMaxP {
+			return fmt.Errorf("%w: scryptP must be in range 1-%d",
+				ErrKeystoreExportWrongParams, keystoreScryptMaxP)
+		}
+
+	case keystoreKDFPBKDF2:
+		if p.PBKDF2C == 0 {
+			p.PBKDF2C = keystorePBKDF2DefaultC
+		}
+
+		if p.PBKDF2C < keystorePBKDF2MinC || p.PBKDF2C > keystorePBKDF2MaxC {
+			return fmt.Errorf("%w: pbkdf2C must be in range %d-%d",
+				ErrKeystoreExportWrongParams, keystorePBKDF2MinC, keystorePBKDF2MaxC)
+		}
+
+	default:
+		return fmt.Errorf("%w: unsupported kdf %s", ErrKeystoreExportWrongParams, p.KDF)
+	}
+
+	return nil
+}
+
+// deriveKey - derive key by KDF and returns KDF params in keystore V3 format
+func (p *keystoreExportParams) deriveKey(passphrase, salt []byte) ([]byte, map[string]interface{}, error) {
+	kdfParams := map[string]interface{}{
+		"dklen": keystoreKeyLength,
+		"salt":  hex.EncodeToString(salt),
+	}
+
+	if p.KDF == keystoreKDFPBKDF2 {
+		kdfParams["c"] = p.PBKDF2C
+		kdfParams["prf"] = keystorePBKDF2PRF
+
+		return pbkdf2.Key(passphrase, salt, p.PBKDF2C, keystoreKeyLength, sha256.New), kdfParams, nil
+	}
+
+	kdfParams["n"] = p.ScryptN
+	kdfParams["r"] = keystoreScryptR
+	kdfParams["p"] = p.ScryptP
+
+	derivedKey, err := scrypt.Key(passphrase, salt, p.ScryptN, keystoreScryptR, p.ScryptP, keystoreKeyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return derivedKey, kdfParams, nil
+}
+
+type keystoreCipherParamsJSON struct {
+	IV string `json:"iv"`
+}
+
+type keystoreCryptoJSON struct {
+	Cipher       string                   `json:"cipher"`
+	CipherText   string                   `json:"ciphertext"`
+	CipherParams keystoreCipherParamsJSON `json:"cipherparams"`
+	KDF          string                   `json:"kdf"`
+	KDFParams    map[string]interface{}   `json:"kdfparams"`
+	MAC          string                   `json:"mac"`
+}
+
+// keystoreV3JSON - Web3 Secret Storage Definition, version 3
+// https://ethereum.org/en/developers/docs/data-structures-and-encoding/web3-secret-storage/
+type keystoreV3JSON struct {
+	Address string             `json:"address"`
+	Crypto  keystoreCryptoJSON `json:"crypto"`
+	ID      string             `json:"id"`
+	Version int                `json:"version"`
+}
+
+// encryptKeystoreV3 - encrypt private key to keystore V3 JSON, compatible with geth, MetaMask and Foundry
+func encryptKeystoreV3(privKey *ecdsa.PrivateKey,
+	passphrase string,
+	params *keystoreExportParams,
+) ([]byte, error) {
+	salt := make([]byte, keystoreSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	_, err = rand.Read(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedKey, kdfParams, err := params.deriveKey([]byte(passphrase), salt)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(derivedKey)
+
+	keyBytes := math.PaddedBigBytes(privKey.D, keystoreKeyLength)
+	defer clear(keyBytes)
+
+	block, err := aes.NewCipher(derivedKey[:16])
+	if err != nil {
+		return nil, err
+	}
+
+	cipherText := make([]byte, len(keyBytes))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherText, keyBytes)
+
+	keyUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	address := crypto.PubkeyToAddress(privKey.PublicKey)
+
+	return json.Marshal(&keystoreV3JSON{
+		Address: hex.EncodeToString(address[:]),
+		Crypto: keystoreCryptoJSON{
+			Cipher:     keystoreCipher,
+			CipherText: hex.EncodeToString(cipherText),
+			CipherParams: keystoreCipherParamsJSON{
+				IV: hex.EncodeToString(iv),
+			},
+			KDF:       params.KDF,
+			KDFParams: kdfParams,
+			MAC:       hex.EncodeToString(crypto.Keccak256(derivedKey[16:32], cipherText)),
+		},
+		ID:      keyUUID.String(),
+		Version: keystoreVersion,
+	})
+}
+
+// ExportKeystore - encrypt private key of derivation path to keystore V3 JSON.
+// Export must be enabled by KeystoreExportEnabled build-time variable, refused after RequireTwoStepSigning call
+func (u *mnemonicWalletUnit) ExportKeystore(ctx context.Context,
+	accountParameters *anypb.Any,
+	passphrase string,
+	exportParamsData []byte,
+) (*string, []byte, error) {
+	if !keystoreExportEnabled {
+		return nil, nil, ErrKeystoreExportDisabled
+	}
+
+	// exported private key can sign transactions outside of two-phase signing
+	if u.signingGate.isRequired.Load() {
+		return nil, nil, ErrKeystoreExportTwoStepGate
+	}
+
+	if passphrase == "" {
+		return nil, nil, ErrKeystorePassphraseEmpty
+	}
+
+	params := &keystoreExportParams{}
+	if len(exportParamsData) != 0 {
+		err := json.Unmarshal(exportParamsData, params)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrKeystoreExportWrongParams, err.Error())
+		}
+	}
+
+	err := params.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	hdWalletSvc, err := u.unlockedWallet()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hdWalletAccount, err := hdWalletSvc.NewAccount(accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address, err := hdWalletAccount.GetAddress()
+	if err != nil {
+		hdWalletAccount.ClearSecrets()
+
+		return nil, nil, err
+	}
+
+	privKey := hdWalletAccount.CloneECDSAPrivateKey()
+	hdWalletAccount.ClearSecrets()
+	defer zeroKey(privKey)
+
+	keystoreData, err := encryptKeystoreV3(privKey, passphrase, params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &address, keystoreData, nil
+}
+
+// NewKeystorePoolUnit - decrypt keystore V3 JSON and create private key pool unit with single labeled key.
+// Supported scrypt and pbkdf2 keystores of geth, MetaMask, Foundry and another tools
+func NewKeystorePoolUnit(walletUUID string,
+	label string,
+	keystoreData []byte,
+	passphrase string,
+) (interface{}, error) {
+	if strings.TrimSpace(label) == "" {
+		return nil, ErrPrivateKeyLabelEmpty
+	}
+
+	key, err := keystore.DecryptKey(keystoreData, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := newEmptyPrivateKeyPoolUnit(walletUUID, 1)
+
+	err = unit.addPrivateKey(label, key.PrivateKey)
+	zeroKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return unit, nil
+}
@@ -0,0 +1,212 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMnemonicWalletUnit_ExportKeystore(t *testing.T) {
+	type testCase struct {
+		ExportParams []byte
+		ExpectedKDF  string
+	}
+
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	// derivation path 7'/8/9
+	expectedAddress := "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56"
+	passphrase := "unit-test-passphrase"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	_, _, err = poolUnit.ExportKeystore(context.Background(), accountIdentity, passphrase, nil)
+	if !errors.Is(err, ErrKeystoreExportDisabled) {
+		t.Fatalf("%s", "keystore export must be disabled by default")
+	}
+
+	keystoreExportEnabled = true
+	defer func() {
+		keystoreExportEnabled = false
+	}()
+
+	_, _, err = poolUnit.ExportKeystore(context.Background(), accountIdentity, passphrase,
+		[]byte(`{"kdf":"scrypt","scryptN":1000}`))
+	if !errors.Is(err, ErrKeystoreExportWrongParams) {
+		t.Fatalf("%s", "wrong scrypt params must not be accepted")
+	}
+
+	_, _, err = poolUnit.ExportKeystore(context.Background(), accountIdentity, "", nil)
+	if !errors.Is(err, ErrKeystorePassphraseEmpty) {
+		t.Fatalf("%s", "empty passphrase must not be accepted")
+	}
+
+	testCases := []*testCase{
+		{
+			ExportParams: []byte(`{"kdf":"scrypt","scryptN":4096,"scryptP":1}`),
+			ExpectedKDF:  "scrypt",
+		},
+		{
+			ExportParams: []byte(`{"kdf":"pbkdf2","pbkdf2C":10000}`),
+			ExpectedKDF:  "pbkdf2",
+		},
+	}
+
+	for _, tCase := range testCases {
+		addr, keystoreData, err := poolUnit.ExportKeystore(context.Background(), accountIdentity,
+			passphrase, tCase.ExportParams)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to export keystore", err)
+		}
+
+		if *addr != expectedAddress {
+			t.Fatalf("%s", "address not equal with expected")
+		}
+
+		keystoreJSON := &keystoreV3JSON{}
+		_ = json.Unmarshal(keystoreData, keystoreJSON)
+		if keystoreJSON.Crypto.KDF != tCase.ExpectedKDF || keystoreJSON.Version != 3 {
+			t.Fatalf("%s", "keystore kdf or version not equal with expected")
+		}
+
+		_, err = keystore.DecryptKey(keystoreData, "wrong-passphrase")
+		if !errors.Is(err, keystore.ErrDecrypt) {
+			t.Fatalf("%s", "keystore must not be decrypted by wrong passphrase")
+		}
+
+		key, err := keystore.DecryptKey(keystoreData, passphrase)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to decrypt exported keystore", err)
+		}
+
+		if key.Address != common.HexToAddress(expectedAddress) {
+			t.Fatalf("%s", "decrypted key address not equal with expected")
+		}
+
+		unitIntrf, err := NewKeystorePoolUnit(uuid.NewString(), "exported", keystoreData, passphrase)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to create keystore pool unit", err)
+		}
+
+		labelIdentity, _ := anypb.New(wrapperspb.String("exported"))
+		importedAddr, err := unitIntrf.(*privateKeyWalletUnit).GetAccountAddress(context.Background(),
+			labelIdentity)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to get imported address", err)
+		}
+
+		if *importedAddr != expectedAddress {
+			t.Fatalf("%s", "imported address not equal with expected")
+		}
+	}
+
+	approvalTokenVerifier = &stubApprovalTokenVerifier{approvedTokens: make(map[string]bool)}
+	defer func() {
+		approvalTokenVerifier = nil
+	}()
+
+	err = poolUnit.RequireTwoStepSigning()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to require two-step signing", err)
+	}
+
+	_, _, err = poolUnit.ExportKeystore(context.Background(), accountIdentity, passphrase, nil)
+	if !errors.Is(err, ErrKeystoreExportTwoStepGate) {
+		t.Fatalf("%s", "keystore export must be refused while two-step signing required")
+	}
+}
+
+func TestNewKeystorePoolUnit(t *testing.T) {
+	// Web3 Secret Storage Definition pbkdf2 test vector, passphrase - testpassword
+	keystoreData := []byte(`{"crypto":{"cipher":"aes-128-ctr","cipherparams":{"iv":"6087dab2f9fdbbfaddc31a909735c1e6"},` +
+		`"ciphertext":"5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46","kdf":"pbkdf2",` +
+		`"kdfparams":{"c":262144,"dklen":32,"prf":"hmac-sha256",` +
+		`"salt":"ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},` +
+		`"mac":"517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"},` +
+		`"id":"3198bc9c-6672-5ab3-d995-4942343ae5b6","version":3}`)
+
+	_, err := NewKeystorePoolUnit(uuid.NewString(), "legacy", keystoreData, "wrongpassword")
+	if !errors.Is(err, keystore.ErrDecrypt) {
+		t.Fatalf("%s", "keystore must not be decrypted by wrong passphrase")
+	}
+
+	unitIntrf, err := NewKeystorePoolUnit(uuid.NewString(), "legacy", keystoreData, "testpassword")
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create keystore pool unit", err)
+	}
+
+	poolUnit, ok := unitIntrf.(*privateKeyWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	addressIdentity, _ := anypb.New(wrapperspb.String("0x008AeEda4D805471dF9b2A5B0f38A0C3bCBA786b"))
+	addr, err := poolUnit.GetAccountAddress(context.Background(), addressIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get address", err)
+	}
+
+	if *addr != "0x008AeEda4D805471dF9b2A5B0f38A0C3bCBA786b" {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	privKeyHex := common.Bytes2Hex(poolUnit.keysPool["legacy"].privateKey)
+	if privKeyHex != "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d" {
+		t.Fatalf("%s", "private key not equal with expected")
+	}
+}
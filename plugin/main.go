@@ -96,6 +96,18 @@ var (
 	// DO NOT EDIT THIS VARIABLE DIRECTLY. These are build-time constants
 	// DO NOT USE THESE VARIABLES IN APPLICATION CODE
 	NetworkName = evmDefaultPluginName
+
+	// KeystoreExportEnabled - gate of private keys export in Web3 Secret Storage (keystore V3) format.
+	// Export disabled by default, set "true" value for enable it
+	// DO NOT EDIT THIS VARIABLE DIRECTLY. These are build-time constants
+	// DO NOT USE THESE VARIABLES IN APPLICATION CODE
+	KeystoreExportEnabled = "false"
+
+	// CachedKeysEncryptionEnabled - gate of in-memory encryption of cached private keys by ephemeral key of wallet.
+	// Encryption disabled by default, set "true" value for enable it
+	// DO NOT EDIT THIS VARIABLE DIRECTLY. These are build-time constants
+	// DO NOT USE THESE VARIABLES IN APPLICATION CODE
+	CachedKeysEncryptionEnabled = "false"
 )
 
 var (
@@ -104,12 +116,17 @@ var (
 	pluginName     = evmDefaultPluginName
 	pluginSigner   types.Signer
 
-	prepareChainIDOnce       = sync.Once{}
-	setChainIDOnce           = sync.Once{}
-	prepareCoinTypeOnce      = sync.Once{}
-	setCoinTypeOnce          = sync.Once{}
-	setSignerOnce            = sync.Once{}
-	setPluginNetworkNameOnce = sync.Once{}
+	keystoreExportEnabled       = false
+	cachedKeysEncryptionEnabled = false
+
+	prepareChainIDOnce        = sync.Once{}
+	setChainIDOnce            = sync.Once{}
+	prepareCoinTypeOnce       = sync.Once{}
+	setCoinTypeOnce           = sync.Once{}
+	setSignerOnce             = sync.Once{}
+	setPluginNetworkNameOnce  = sync.Once{}
+	prepareKeystoreExportOnce = sync.Once{}
+	prepareKeysEncryptionOnce = sync.Once{}
 
 	ErrPluginValueAlreadySet = errors.New("plugin value already set.You can do it only once")
 )
@@ -119,6 +136,8 @@ func init() {
 	prepareChainID()
 	prepareCoinType()
 	prepareSigner()
+	prepareKeystoreExportGate()
+	prepareCachedKeysEncryptionGate()
 }
 
 func GetPluginName() string {
@@ -262,3 +281,45 @@ func prepareSigner() types.Signer {
 
 	return pluginSigner
 }
+
+func prepareKeystoreExportGate() bool {
+	prepareKeystoreExportOnce.Do(func() {
+		if KeystoreExportEnabled == "" {
+			keystoreExportEnabled = false
+
+			return
+		}
+
+		isEnabled, err := strconv.ParseBool(KeystoreExportEnabled)
+		if err != nil {
+			panic(fmt.Errorf("wrong keystore export gate format: %w", err))
+		}
+
+		keystoreExportEnabled = isEnabled
+
+		return
+	})
+
+	return keystoreExportEnabled
+}
+
+func prepareCachedKeysEncryptionGate() bool {
+	prepareKeysEncryptionOnce.Do(func() {
+		if CachedKeysEncryptionEnabled == "" {
+			cachedKeysEncryptionEnabled = false
+
+			return
+		}
+
+		isEnabled, err := strconv.ParseBool(CachedKeysEncryptionEnabled)
+		if err != nil {
+			panic(fmt.Errorf("wrong cached keys encryption gate format: %w", err))
+		}
+
+		cachedKeysEncryptionEnabled = isEnabled
+
+		return
+	})
+
+	return cachedKeysEncryptionEnabled
+}
@@ -0,0 +1,114 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetMnemonicFingerprint(t *testing.T) {
+	type testCase struct {
+		Mnemonic            string
+		ExpectedFingerprint string
+	}
+
+	testCases := []*testCase{
+		{
+			// BIP-0039 test vector mnemonic
+			Mnemonic:            "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			ExpectedFingerprint: "73c5da0a",
+		},
+		{
+			// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+			Mnemonic:            "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry",
+			ExpectedFingerprint: "1521c6c1",
+		},
+	}
+
+	for _, tCase := range testCases {
+		fingerprint, err := GetMnemonicFingerprint(tCase.Mnemonic)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to get mnemonic fingerprint", err)
+		}
+
+		if fingerprint != tCase.ExpectedFingerprint {
+			t.Fatalf("%s", "fingerprint not equal with expected")
+		}
+
+		poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), tCase.Mnemonic)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+		}
+
+		poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+		if !ok {
+			t.Fatalf("%s", "unable to cast interface to pool unit worker")
+		}
+
+		if poolUnit.mnemonicHash != tCase.ExpectedFingerprint {
+			t.Fatalf("%s", "mnemonicHash not equal with expected fingerprint")
+		}
+	}
+}
+
+func TestNewPoolUnitWithFingerprint(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	corruptedMnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	poolUnitIntrf, err := NewPoolUnitWithFingerprint(uuid.NewString(), mnemonic, "0x1521C6C1")
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	_ = poolUnit.UnloadWallet()
+
+	_, err = NewPoolUnitWithFingerprint(uuid.NewString(), corruptedMnemonic, "1521c6c1")
+	if !errors.Is(err, ErrMnemonicFingerprintMismatch) {
+		t.Fatalf("%s", "wallet with wrong fingerprint must not be created")
+	}
+
+	_, err = NewPoolUnitWithFingerprint(uuid.NewString(), mnemonic, "")
+	if !errors.Is(err, ErrMnemonicFingerprintEmpty) {
+		t.Fatalf("%s", "wallet without expected fingerprint must not be created")
+	}
+}
@@ -0,0 +1,272 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// mnemonicAssemblyDefaultTimeout - lifetime of partially assembled wallet
+const mnemonicAssemblyDefaultTimeout = 5 * time.Minute
+
+var (
+	mnemonicAssemblyTimeout = mnemonicAssemblyDefaultTimeout
+	mnemonicAssemblies      = make(map[string]*mnemonicAssembly)
+	mnemonicAssembliesMu    = sync.Mutex{}
+
+	ErrMnemonicAssemblyExists      = errors.New("mnemonic assembly already started")
+	ErrMnemonicAssemblyNotFound    = errors.New("mnemonic assembly not found or timed out")
+	ErrMnemonicAssemblyWrongParams = errors.New("wrong mnemonic assembly params")
+	ErrMnemonicShareInvalid        = errors.New("mnemonic share is invalid")
+	ErrMnemonicShareDuplicated     = errors.New("mnemonic share already added")
+)
+
+// mnemonicAssembly - partially assembled wallet - Shamir shares of BIP-0039 entropy
+type mnemonicAssembly struct {
+	walletUUID          string
+	threshold           uint8
+	expectedFingerprint string
+
+	// shares - map key - share index, map value - share of entropy
+	shares      map[uint8][]byte
+	shareLength int
+
+	timer *time.Timer
+}
+
+func (a *mnemonicAssembly) clear() {
+	for shareIndex, share := range a.shares {
+		clear(share)
+		delete(a.shares, shareIndex)
+	}
+
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+}
+
+// StartMnemonicAssembly - start staged creation of pool unit from Shamir shares of BIP-0039 entropy.
+// Shares added by AddMnemonicShare function. Partially assembled wallet dropped after timeout.
+// expectedFingerprint - optional BIP-0032 master key fingerprint of assembled mnemonic
+func StartMnemonicAssembly(walletUUID string,
+	threshold uint8,
+	expectedFingerprint string,
+) error {
+	if walletUUID == "" {
+		return fmt.Errorf("%w: wallet uuid is empty", ErrMnemonicAssemblyWrongParams)
+	}
+
+	if threshold < 2 {
+		return fmt.Errorf("%w: threshold must be greater than 1", ErrMnemonicAssemblyWrongParams)
+	}
+
+	mnemonicAssembliesMu.Lock()
+	defer mnemonicAssembliesMu.Unlock()
+
+	if _, isExists := mnemonicAssemblies[walletUUID]; isExists {
+		return fmt.Errorf("%w: wallet uuid %s", ErrMnemonicAssemblyExists, walletUUID)
+	}
+
+	assembly := &mnemonicAssembly{
+		walletUUID:          walletUUID,
+		threshold:           threshold,
+		expectedFingerprint: strings.ToLower(strings.TrimPrefix(expectedFingerprint, "0x")),
+		shares:              make(map[uint8][]byte, threshold),
+	}
+
+	assembly.timer = time.AfterFunc(mnemonicAssemblyTimeout, func() {
+		mnemonicAssembliesMu.Lock()
+		defer mnemonicAssembliesMu.Unlock()
+
+		if mnemonicAssemblies[walletUUID] == assembly {
+			delete(mnemonicAssemblies, walletUUID)
+			assembly.clear()
+		}
+	})
+
+	mnemonicAssemblies[walletUUID] = assembly
+
+	return nil
+}
+
+// AddMnemonicShare - add Shamir share of BIP-0039 entropy to started assembly.
+// Shares computed over GF(2^8) with AES polynomial x^8 + x^4 + x^3 + x + 1, share index is x coordinate.
+// Share data zeroed after call. Returns nil pool unit until threshold count of shares added,
+// after that - pool unit, same as created by NewPoolUnit
+func AddMnemonicShare(walletUUID string,
+	shareIndex uint8,
+	shareData []byte,
+) (interface{}, error) {
+	defer clear(shareData)
+
+	if shareIndex == 0 {
+		return nil, fmt.Errorf("%w: share index must be greater than 0", ErrMnemonicShareInvalid)
+	}
+
+	switch len(shareData) {
+	case 16, 20, 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: wrong share length %d", ErrMnemonicShareInvalid, len(shareData))
+	}
+
+	mnemonicAssembliesMu.Lock()
+	defer mnemonicAssembliesMu.Unlock()
+
+	assembly, isExists := mnemonicAssemblies[walletUUID]
+	if !isExists {
+		return nil, fmt.Errorf("%w: wallet uuid %s", ErrMnemonicAssemblyNotFound, walletUUID)
+	}
+
+	if assembly.shareLength != 0 && assembly.shareLength != len(shareData) {
+		return nil, fmt.Errorf("%w: share length %d not equal with length of added shares %d",
+			ErrMnemonicShareInvalid, len(shareData), assembly.shareLength)
+	}
+
+	if _, isExists = assembly.shares[shareIndex]; isExists {
+		return nil, fmt.Errorf("%w: share index %d", ErrMnemonicShareDuplicated, shareIndex)
+	}
+
+	assembly.shares[shareIndex] = append([]byte{}, shareData...)
+	assembly.shareLength = len(shareData)
+
+	if len(assembly.shares) < int(assembly.threshold) {
+		return nil, nil
+	}
+
+	delete(mnemonicAssemblies, walletUUID)
+	defer assembly.clear()
+
+	unit, err := assembly.assemble()
+	if err != nil {
+		return nil, err
+	}
+
+	return unit, nil
+}
+
+// CancelMnemonicAssembly - drop partially assembled wallet and zero its shares
+func CancelMnemonicAssembly(walletUUID string) error {
+	mnemonicAssembliesMu.Lock()
+	defer mnemonicAssembliesMu.Unlock()
+
+	assembly, isExists := mnemonicAssemblies[walletUUID]
+	if !isExists {
+		return fmt.Errorf("%w: wallet uuid %s", ErrMnemonicAssemblyNotFound, walletUUID)
+	}
+
+	delete(mnemonicAssemblies, walletUUID)
+	assembly.clear()
+
+	return nil
+}
+
+func (a *mnemonicAssembly) assemble() (*mnemonicWalletUnit, error) {
+	entropy := combineShamirShares(a.shares, a.shareLength)
+	defer clear(entropy)
+
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+
+	unit, err := newPoolUnit(a.walletUUID, mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.expectedFingerprint != "" &&
+		subtle.ConstantTimeCompare([]byte(unit.mnemonicHash), []byte(a.expectedFingerprint)) != 1 {
+		_ = unit.UnloadWallet()
+
+		return nil, fmt.Errorf("%w: wallet uuid %s", ErrMnemonicFingerprintMismatch, a.walletUUID)
+	}
+
+	return unit, nil
+}
+
+// gf256Mul - multiplication in GF(2^8) with AES polynomial, constant time
+func gf256Mul(a, b uint8) uint8 {
+	var result uint8
+	for i := 0; i < 8; i++ {
+		// mask - 0xff if lowest bit of b is set
+		mask := -(b & 1)
+		result ^= a & mask
+
+		carry := -(a >> 7)
+		a = (a << 1) ^ (0x1b & carry)
+		b >>= 1
+	}
+
+	return result
+}
+
+// gf256Inverse - inverse in GF(2^8), a^254
+func gf256Inverse(a uint8) uint8 {
+	result := uint8(1)
+	for i := 0; i < 254; i++ {
+		result = gf256Mul(result, a)
+	}
+
+	return result
+}
+
+// combineShamirShares - Lagrange interpolation of shares at zero, byte-wise
+func combineShamirShares(shares map[uint8][]byte, length int) []byte {
+	secret := make([]byte, length)
+
+	for shareIndex, share := range shares {
+		// Lagrange basis polynomial of share at zero - prod(x_j / (x_j - x_i)),
+		// subtraction in GF(2^8) is xor
+		basis := uint8(1)
+		for otherIndex := range shares {
+			if otherIndex == shareIndex {
+				continue
+			}
+
+			basis = gf256Mul(basis, gf256Mul(otherIndex, gf256Inverse(otherIndex^shareIndex)))
+		}
+
+		for i := range secret {
+			secret[i] ^= gf256Mul(share[i], basis)
+		}
+	}
+
+	return secret
+}
@@ -0,0 +1,233 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/google/uuid"
+	"github.com/tyler-smith/go-bip39"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// splitShamirSecret - split secret to shares over GF(2^8), share index - x coordinate
+func splitShamirSecret(t *testing.T, secret []byte, threshold uint8, sharesCount uint8) map[uint8][]byte {
+	coefficients := make([][]byte, len(secret))
+	for i := range secret {
+		coefficients[i] = make([]byte, threshold)
+		coefficients[i][0] = secret[i]
+
+		_, err := rand.Read(coefficients[i][1:])
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to generate coefficients", err)
+		}
+	}
+
+	shares := make(map[uint8][]byte, sharesCount)
+	for x := uint8(1); x <= sharesCount; x++ {
+		share := make([]byte, len(secret))
+		for i := range secret {
+			var y uint8
+			for j := int(threshold) - 1; j >= 0; j-- {
+				y = gf256Mul(y, x) ^ coefficients[i][j]
+			}
+
+			share[i] = y
+		}
+
+		shares[x] = share
+	}
+
+	return shares
+}
+
+func TestAddMnemonicShare(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	// derivation path 7'/8/9
+	expectedAddress := "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56"
+	expectedFingerprint := "1521c6c1"
+
+	entropy, err := bip39.EntropyFromMnemonic(mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get mnemonic entropy", err)
+	}
+
+	shares := splitShamirSecret(t, entropy, 3, 5)
+
+	walletUUID := uuid.NewString()
+	err = StartMnemonicAssembly(walletUUID, 3, expectedFingerprint)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to start mnemonic assembly", err)
+	}
+
+	err = StartMnemonicAssembly(walletUUID, 3, expectedFingerprint)
+	if !errors.Is(err, ErrMnemonicAssemblyExists) {
+		t.Fatalf("%s", "second assembly of same wallet must not be started")
+	}
+
+	for _, shareIndex := range []uint8{2, 5} {
+		share := shares[shareIndex]
+
+		unitIntrf, loopErr := AddMnemonicShare(walletUUID, shareIndex, share)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to add mnemonic share", loopErr)
+		}
+
+		if unitIntrf != nil {
+			t.Fatalf("%s", "pool unit must not be created before threshold reached")
+		}
+
+		for _, b := range share {
+			if b != 0 {
+				t.Fatalf("%s", "share data must be zeroed")
+			}
+		}
+	}
+
+	_, err = AddMnemonicShare(walletUUID, 2, make([]byte, len(entropy)))
+	if !errors.Is(err, ErrMnemonicShareDuplicated) {
+		t.Fatalf("%s", "duplicated share must not be accepted")
+	}
+
+	_, err = AddMnemonicShare(walletUUID, 3, make([]byte, 16))
+	if !errors.Is(err, ErrMnemonicShareInvalid) {
+		t.Fatalf("%s", "share with wrong length must not be accepted")
+	}
+
+	unitIntrf, err := AddMnemonicShare(walletUUID, 4, shares[4])
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to add mnemonic share", err)
+	}
+
+	poolUnit, ok := unitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	if poolUnit.GetWalletUUID() != walletUUID {
+		t.Fatalf("%s", "wallet uuid not equal with expected")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	address, err := poolUnit.GetAccountAddress(context.Background(), accountIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get address from pool unit", err)
+	}
+
+	if *address != expectedAddress {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	// assembly removed after wallet created
+	_, err = AddMnemonicShare(walletUUID, 1, shares[1])
+	if !errors.Is(err, ErrMnemonicAssemblyNotFound) {
+		t.Fatalf("%s", "assembly must be removed after wallet created")
+	}
+
+	// wrong share - wallet with other fingerprint
+	shares = splitShamirSecret(t, entropy, 2, 3)
+	walletUUID = uuid.NewString()
+	_ = StartMnemonicAssembly(walletUUID, 2, expectedFingerprint)
+	_, _ = AddMnemonicShare(walletUUID, 1, shares[1])
+
+	shares[3][0] ^= 0x1
+	_, err = AddMnemonicShare(walletUUID, 3, shares[3])
+	if !errors.Is(err, ErrMnemonicFingerprintMismatch) {
+		t.Fatalf("%s", "wallet with wrong fingerprint must not be created")
+	}
+}
+
+func TestAddMnemonicShare_Timeout(t *testing.T) {
+	mnemonicAssemblyTimeout = 50 * time.Millisecond
+	defer func() {
+		mnemonicAssemblyTimeout = mnemonicAssemblyDefaultTimeout
+	}()
+
+	walletUUID := uuid.NewString()
+	err := StartMnemonicAssembly(walletUUID, 2, "")
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to start mnemonic assembly", err)
+	}
+
+	_, err = AddMnemonicShare(walletUUID, 1, make([]byte, 32))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to add mnemonic share", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, err = AddMnemonicShare(walletUUID, 2, make([]byte, 32))
+	if !errors.Is(err, ErrMnemonicAssemblyNotFound) {
+		t.Fatalf("%s", "timed out assembly must be dropped")
+	}
+
+	err = StartMnemonicAssembly(walletUUID, 2, "")
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to restart mnemonic assembly", err)
+	}
+
+	err = CancelMnemonicAssembly(walletUUID)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to cancel mnemonic assembly", err)
+	}
+
+	err = StartMnemonicAssembly(walletUUID, 1, "")
+	if !errors.Is(err, ErrMnemonicAssemblyWrongParams) {
+		t.Fatalf("%s", "threshold less than 2 must not be accepted")
+	}
+}
+
+func TestGF256Mul(t *testing.T) {
+	// FIPS-197 multiplication example
+	if gf256Mul(0x57, 0x83) != 0xc1 || gf256Mul(0x57, 0x13) != 0xfe {
+		t.Fatalf("%s", "multiplication result not equal with expected")
+	}
+
+	for a := 1; a < 256; a++ {
+		if gf256Mul(uint8(a), gf256Inverse(uint8(a))) != 1 {
+			t.Fatalf("%s: %d", "inverse result not equal with expected", a)
+		}
+	}
+}
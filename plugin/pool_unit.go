@@ -38,31 +38,70 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"time"
 
 	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
 const addrPatKeyTemplate = "%d'/%d/%d"
 
 type addressData struct {
-	address    string
-	privateKey *ecdsa.PrivateKey
+	address   string
+	publicKey *ecdsa.PublicKey
+	// privateKey - slot of protected key store with private key, encrypted if cached keys encryption enabled
+	privateKey []byte
+	keyStore   *protectedKeyStore
 }
 
-func (e *addressData) ClonePrivateKey() *ecdsa.PrivateKey {
+// newAddressData - copy private key to protected key store. Source private key must be zeroed by caller
+func newAddressData(keyStore *protectedKeyStore, address string, privKey *ecdsa.PrivateKey) (*addressData, error) {
+	privKeySlot, err := keyStore.put(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &addressData{
+		address: address,
+		publicKey: &ecdsa.PublicKey{
+			Curve: btcec.S256(),
+			X:     (&big.Int{}).Set(privKey.X),
+			Y:     (&big.Int{}).Set(privKey.Y),
+		},
+		privateKey: privKeySlot,
+		keyStore:   keyStore,
+	}, nil
+}
+
+// ClonePrivateKey - returns decrypted copy of private key. Cloned key must be zeroed by caller
+func (e *addressData) ClonePrivateKey() (*ecdsa.PrivateKey, error) {
+	privKeyNum, err := e.keyStore.privateKey(e.privateKey)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ecdsa.PrivateKey{
 		PublicKey: ecdsa.PublicKey{
 			Curve: btcec.S256(),
-			X:     (&big.Int{}).SetBytes(e.privateKey.X.Bytes()),
-			Y:     (&big.Int{}).SetBytes(e.privateKey.Y.Bytes()),
+			X:     (&big.Int{}).Set(e.publicKey.X),
+			Y:     (&big.Int{}).Set(e.publicKey.Y),
 		},
-		D: (&big.Int{}).SetBytes(e.privateKey.D.Bytes()),
-	}
+		D: privKeyNum,
+	}, nil
+}
+
+// clear - wipe private key slot, slot must not be used after key store destroy
+func (e *addressData) clear() {
+	clear(e.privateKey)
+	e.privateKey = nil
+	e.publicKey = nil
+	e.keyStore = nil
+	e.address = ""
 }
 
 type mnemonicWalletUnit struct {
@@ -76,8 +115,26 @@ type mnemonicWalletUnit struct {
 
 	// addressPool is pool of derivation addresses with private keys and address
 	// map key - string with derivation path
-	// map value - ecdsa.PrivateKey and address string
+	// map value - private key slot of keyStore and address string
 	addressPool map[string]*addressData
+	// keyStore - protected memory storage of address pool private keys
+	keyStore *protectedKeyStore
+
+	// addrIndex - in-memory index of derived addresses for reverse lookup of derivation paths
+	addrIndex *addressIndex
+
+	// create2Config - config of CREATE2 deposit addresses, optional
+	create2Config *create2DepositConfig
+
+	// isLocked - private material wiped by LockWallet, only public data available
+	isLocked bool
+	// unlockTimer - lock timer of wallet unlocked by UnlockWallet
+	unlockTimer *time.Timer
+	// unlockGeneration - counter of unlock timer resets, protects from locking by stale timer
+	unlockGeneration uint64
+
+	// signingGate - pending signatures of two-phase signing and single-step signing gate
+	signingGate *twoStepSigningGate
 }
 
 func (u *mnemonicWalletUnit) Shutdown(ctx context.Context) error {
@@ -100,6 +157,26 @@ func (u *mnemonicWalletUnit) UnloadWallet() error {
 }
 
 func (u *mnemonicWalletUnit) unloadWallet() error {
+	u.stopUnlockTimer()
+	u.clearAddressPool()
+
+	u.addressPool = nil
+	u.addrIndex.clear()
+	u.create2Config = nil
+	u.signingGate.clear()
+	u.mnemonicWalletUUID = "0"
+	u.mnemonicHash = "0"
+
+	if u.hdWalletSvc != nil {
+		u.hdWalletSvc.ClearSecrets()
+		u.hdWalletSvc = nil
+	}
+
+	return nil
+}
+
+// clearAddressPool - wipe cached private keys and release protected key store
+func (u *mnemonicWalletUnit) clearAddressPool() {
 	for accountPath, _ := range u.addressPool {
 		addrData, isExist := u.addressPool[accountPath]
 		if !isExist {
@@ -110,24 +187,14 @@ func (u *mnemonicWalletUnit) unloadWallet() error {
 			continue
 		}
 
-		if addrData.privateKey != nil {
-			zeroKey(addrData.privateKey)
-		}
-		addrData.address = ""
+		addrData.clear()
 
 		u.addressPool[accountPath] = nil
 
 		delete(u.addressPool, accountPath)
 	}
 
-	u.addressPool = nil
-	u.mnemonicWalletUUID = "0"
-	u.mnemonicHash = "0"
-
-	u.hdWalletSvc.ClearSecrets()
-	u.hdWalletSvc = nil
-
-	return nil
+	u.keyStore.destroy()
 }
 
 func (u *mnemonicWalletUnit) GetWalletUUID() string {
@@ -138,14 +205,20 @@ func (u *mnemonicWalletUnit) SignData(ctx context.Context,
 	accountParameters *anypb.Any,
 	dataForSign []byte,
 ) (*string, []byte, error) {
-	accIdentity := &pbCommon.DerivationAddressIdentity{}
-	err := proto.Unmarshal(accountParameters.GetValue(), accIdentity)
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	tx := &types.Transaction{}
-	err = tx.UnmarshalBinary(dataForSign)
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = u.approveAccountTx(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		decodedTx)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -153,40 +226,131 @@ func (u *mnemonicWalletUnit) SignData(ctx context.Context,
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	return u.signData(ctx,
+	addr, summary, err := u.signDecodedTx(ctx,
 		accIdentity.AccountIndex,
 		accIdentity.InternalIndex,
 		accIdentity.AddressIndex,
-		tx)
+		decodedTx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addr, summary.RawTx, nil
+}
+
+// approveAccountTx - request decision of sign approver for transaction of account.
+// Must be called without unit lock - sign approver can be slow external service
+func (u *mnemonicWalletUnit) approveAccountTx(ctx context.Context,
+	account, change, index uint32,
+	tx *signingTx,
+) error {
+	if signApprover == nil {
+		return nil
+	}
+
+	err := u.checkSigningFlow(ctx)
+	if err != nil {
+		return err
+	}
+
+	addr, err := u.getAddressByPath(ctx, account, change, index)
+	if err != nil {
+		return err
+	}
+
+	return approveTxSignature(ctx, u.accountSigningSource(account, change, index, *addr), tx, u.dataSigner)
+}
+
+// signDecodedTx - sign decoded transaction or Celo CIP-64 transaction by account private key.
+// Sign approver decision must be requested by caller before unit lock, see approveAccountTx.
+// Returns sender address and summary of signed transaction
+func (u *mnemonicWalletUnit) signDecodedTx(ctx context.Context,
+	account, change, index uint32,
+	decodedTx *signingTx,
+) (*string, *signedTxSummary, error) {
+	summary := &signedTxSummary{}
+
+	if decodedTx.celoTx != nil {
+		addr, signedTx, err := u.signCeloTransaction(ctx, account, change, index, decodedTx.celoTx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return addr, summary, summary.fillFromCeloTx(common.HexToAddress(*addr), signedTx)
+	}
+
+	addr, signedTx, err := u.signTransaction(ctx, account, change, index, decodedTx.tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addr, summary, summary.fillFromTx(common.HexToAddress(*addr), signedTx)
 }
 
-func (u *mnemonicWalletUnit) signData(ctx context.Context,
+func (u *mnemonicWalletUnit) signTransaction(ctx context.Context,
 	account, change, index uint32,
 	txForSign *types.Transaction,
-) (*string, []byte, error) {
+) (*string, *types.Transaction, error) {
+	err := u.checkSigningFlow(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	addr, privKey, err := u.loadAccountDataByPath(ctx, account, change, index)
 	if err != nil {
 		return nil, nil, err
 	}
+	defer zeroKey(privKey)
+
+	source := u.accountSigningSource(account, change, index, *addr)
 
 	signedTx, err := types.SignTx(txForSign, u.dataSigner, privKey)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	signedTxRawData, err := signedTx.MarshalBinary()
+	err = auditTxSignature(source, signedTx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addr, signedTx, nil
+}
+
+func (u *mnemonicWalletUnit) signCeloTransaction(ctx context.Context,
+	account, change, index uint32,
+	txForSign *celoDynamicFeeTxV2,
+) (*string, *celoDynamicFeeTxV2, error) {
+	err := u.checkSigningFlow(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addr, privKey, err := u.loadAccountDataByPath(ctx, account, change, index)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroKey(privKey)
+
+	source := u.accountSigningSource(account, change, index, *addr)
+
+	signedTx, err := signCeloDynamicFeeTxV2(txForSign, u.dataSigner.ChainID(), privKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to sign: %w", err)
+		return nil, nil, err
+	}
+
+	err = auditCeloTxSignature(source, signedTx)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return addr, signedTxRawData, nil
+	return addr, signedTx, nil
 }
 
 func (u *mnemonicWalletUnit) LoadAccount(ctx context.Context,
 	accountParameters *anypb.Any,
 ) (*string, error) {
-	accIdentity := &pbCommon.DerivationAddressIdentity{}
-	err := accountParameters.UnmarshalTo(accIdentity)
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
 	if err != nil {
 		return nil, err
 	}
@@ -194,47 +358,70 @@ func (u *mnemonicWalletUnit) LoadAccount(ctx context.Context,
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	addrData, _, err := u.loadAccountDataByPath(ctx, accIdentity.AccountIndex,
+	addrData, err := u.loadAddressDataByPath(accIdentity.AccountIndex,
 		accIdentity.InternalIndex,
 		accIdentity.AddressIndex)
 	if err != nil {
 		return nil, err
 	}
 
-	if addrData == nil {
-		return nil, nil
-	}
-
-	return addrData, nil
+	return &addrData.address, nil
 }
 
-func (u *mnemonicWalletUnit) loadAccountDataByPath(ctx context.Context,
+// loadAccountDataByPath - returns address and decrypted copy of private key. Private key must be zeroed by caller
+func (u *mnemonicWalletUnit) loadAccountDataByPath(_ context.Context,
 	account, change, index uint32,
 ) (*string, *ecdsa.PrivateKey, error) {
+	addrData, err := u.loadAddressDataByPath(account, change, index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privKey, err := addrData.ClonePrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &addrData.address, privKey, nil
+}
+
+// loadAddressDataByPath - derive account and cache its private key in protected key store
+func (u *mnemonicWalletUnit) loadAddressDataByPath(account, change, index uint32) (*addressData, error) {
 	mapKey := fmt.Sprintf(addrPatKeyTemplate, account, change, index)
 	addrData, isExists := u.addressPool[mapKey]
-	if !isExists {
-		hdWalletAccount, walletErr := u.hdWalletSvc.NewAccount(account, change, index)
-		if walletErr != nil {
-			return nil, nil, walletErr
-		}
+	if isExists {
+		return addrData, nil
+	}
 
-		addr, walletErr := hdWalletAccount.GetAddress()
-		if walletErr != nil {
-			return nil, nil, walletErr
-		}
+	hdWalletSvc, err := u.unlockedWallet()
+	if err != nil {
+		return nil, err
+	}
 
-		addrData = &addressData{
-			address:    addr,
-			privateKey: hdWalletAccount.CloneECDSAPrivateKey(),
-		}
-		u.addressPool[mapKey] = addrData
+	hdWalletAccount, err := hdWalletSvc.NewAccount(account, change, index)
+	if err != nil {
+		return nil, err
+	}
 
-		hdWalletAccount.ClearSecrets()
-		hdWalletAccount = nil
+	addr, err := hdWalletAccount.GetAddress()
+	if err != nil {
+		return nil, err
 	}
 
-	return &addrData.address, addrData.ClonePrivateKey(), nil
+	privKey := hdWalletAccount.CloneECDSAPrivateKey()
+	hdWalletAccount.ClearSecrets()
+	hdWalletAccount = nil
+
+	addrData, err = newAddressData(u.keyStore, addr, privKey)
+	zeroKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	u.addressPool[mapKey] = addrData
+	u.addrIndex.putHex(addr, account, change, index)
+
+	return addrData, nil
 }
 
 func (u *mnemonicWalletUnit) GetAccountAddress(ctx context.Context,
@@ -359,30 +546,46 @@ func (u *mnemonicWalletUnit) getAddressAndMarshal(ctx context.Context,
 	}, nil
 }
 
+// getAddressByPath - derive address by public change node, works in locked state if change node is cached
 func (u *mnemonicWalletUnit) getAddressByPath(_ context.Context,
 	account, change, index uint32,
 ) (*string, error) {
-	hdWalletAccount, err := u.hdWalletSvc.NewAccount(account, change, index)
+	if index >= hdkeychain.HardenedKeyStart {
+		return u.getHardenedAddressByPath(account, change, index)
+	}
+
+	node, err := u.getChangePublicNode(account, change)
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		hdWalletAccount.ClearSecrets()
-		hdWalletAccount = nil
-	}()
+	pubKey, err := u.derivePublicKey(node, account, change, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pubKey.Address, nil
+}
 
-	blockchainAddress, err := hdWalletAccount.GetAddress()
+// getHardenedAddressByPath - derive address of hardened address index, private key required
+func (u *mnemonicWalletUnit) getHardenedAddressByPath(account, change, index uint32) (*string, error) {
+	pubKey, err := u.deriveHardenedPublicKey(account, change, index)
 	if err != nil {
 		return nil, err
 	}
 
-	return &blockchainAddress, nil
+	return &pubKey.Address, nil
 }
 
 func NewPoolUnit(walletUUID string,
 	mnemonicDecryptedData string,
 ) (interface{}, error) {
+	return newPoolUnit(walletUUID, mnemonicDecryptedData)
+}
+
+func newPoolUnit(walletUUID string,
+	mnemonicDecryptedData string,
+) (*mnemonicWalletUnit, error) {
 	hdWalletSvc, createErr := newWalletFromMnemonic(mnemonicDecryptedData)
 	if createErr != nil {
 		return nil, createErr
@@ -396,7 +599,11 @@ func NewPoolUnit(walletUUID string,
 		dataSigner: pluginSigner,
 
 		mnemonicWalletUUID: walletUUID,
+		mnemonicHash:       hdWalletSvc.MasterFingerprint(),
 
 		addressPool: make(map[string]*addressData),
+		keyStore:    newProtectedKeyStore(),
+		addrIndex:   newAddressIndex(),
+		signingGate: newTwoStepSigningGate(),
 	}, nil
 }
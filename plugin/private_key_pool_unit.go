@@ -0,0 +1,537 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var (
+	ErrPrivateKeysListEmpty      = errors.New("labeled private keys list is empty")
+	ErrPrivateKeyLabelEmpty      = errors.New("private key label is empty")
+	ErrPrivateKeyInvalid         = errors.New("private key is invalid")
+	ErrPrivateKeyDuplicated      = errors.New("private key already exists in pool unit")
+	ErrPrivateKeyAccountNotFound = errors.New("account not found in private keys pool unit")
+)
+
+// privateKeyWalletUnit - pool unit of labeled raw secp256k1 private keys, which are not part of mnemonic wallet.
+// Account parameters of unit methods can contain:
+//   - StringValue - label of private key or hex address of private key
+//   - DerivationAddressIdentity - only with zero account and internal indexes, address index is
+//     position of private key in list of labels, sorted in ascending order
+type privateKeyWalletUnit struct {
+	mu *sync.Mutex
+
+	dataSigner types.Signer
+
+	walletUUID string
+
+	// labels - sorted list of private keys labels
+	labels []string
+	// keysPool - pool of private keys with addresses
+	// map key - label of private key
+	// map value - private key slot of keyStore and address string
+	keysPool map[string]*addressData
+	// keyStore - protected memory storage of private keys
+	keyStore *protectedKeyStore
+	// addresses - map of private key address to label
+	addresses map[common.Address]string
+	// signingGate - pending signatures of two-phase signing and single-step signing gate
+	signingGate *twoStepSigningGate
+}
+
+func (u *privateKeyWalletUnit) Shutdown(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	err := u.unloadWallet()
+	if err != nil {
+		return fmt.Errorf("unable to unload wallet: %w", err)
+	}
+
+	return nil
+}
+
+func (u *privateKeyWalletUnit) UnloadWallet() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.unloadWallet()
+}
+
+func (u *privateKeyWalletUnit) unloadWallet() error {
+	for label, keyData := range u.keysPool {
+		if keyData == nil {
+			continue
+		}
+
+		keyData.clear()
+
+		u.keysPool[label] = nil
+
+		delete(u.keysPool, label)
+	}
+
+	u.keysPool = nil
+	u.keyStore.destroy()
+	u.addresses = nil
+	u.labels = nil
+	u.signingGate.clear()
+	u.walletUUID = "0"
+
+	return nil
+}
+
+func (u *privateKeyWalletUnit) GetWalletUUID() string {
+	return u.walletUUID
+}
+
+func (u *privateKeyWalletUnit) LoadAccount(ctx context.Context,
+	accountParameters *anypb.Any,
+) (*string, error) {
+	return u.GetAccountAddress(ctx, accountParameters)
+}
+
+func (u *privateKeyWalletUnit) GetAccountAddress(_ context.Context,
+	accountParameters *anypb.Any,
+) (*string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	keyData, err := u.resolveAccount(accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	address := keyData.address
+
+	return &address, nil
+}
+
+func (u *privateKeyWalletUnit) GetMultipleAccounts(_ context.Context,
+	multipleAccountsParameters *anypb.Any,
+) (uint, []*pbCommon.AccountIdentity, error) {
+	rangeList := &pbCommon.RangeUnitsList{}
+	err := multipleAccountsParameters.UnmarshalTo(rangeList)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	result := make([]*pbCommon.AccountIdentity, 0)
+
+	for _, rangeUnit := range rangeList.RangeUnits {
+		if rangeUnit.AccountIndex != 0 || rangeUnit.InternalIndex != 0 ||
+			rangeUnit.AddressIndexFrom > rangeUnit.AddressIndexTo ||
+			int(rangeUnit.AddressIndexTo) >= len(u.labels) {
+			return 0, nil, fmt.Errorf("%w: range %d'/%d/%d-%d", ErrPrivateKeyAccountNotFound,
+				rangeUnit.AccountIndex, rangeUnit.InternalIndex,
+				rangeUnit.AddressIndexFrom, rangeUnit.AddressIndexTo)
+		}
+
+		for i := rangeUnit.AddressIndexFrom; i <= rangeUnit.AddressIndexTo; i++ {
+			label := u.labels[i]
+
+			labelParams := &anypb.Any{}
+			err = labelParams.MarshalFrom(wrapperspb.String(label))
+			if err != nil {
+				return 0, nil, err
+			}
+
+			result = append(result, &pbCommon.AccountIdentity{
+				Parameters: labelParams,
+				Address:    u.keysPool[label].address,
+			})
+		}
+	}
+
+	return uint(len(result)), result, nil
+}
+
+func (u *privateKeyWalletUnit) SignData(ctx context.Context,
+	accountParameters *anypb.Any,
+	dataForSign []byte,
+) (*string, []byte, error) {
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := u.signingSource(accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return u.signDecodedTx(ctx, accountParameters, source, decodedTx)
+}
+
+// PrepareSign - first step of two-phase signing. Returns JSON-encoded preparedSignature with transaction summary
+// and single-use approval token, transaction not signed. Refused if approval token verifier not configured
+func (u *privateKeyWalletUnit) PrepareSign(_ context.Context,
+	accountParameters *anypb.Any,
+	dataForSign []byte,
+) ([]byte, error) {
+	if approvalTokenVerifier == nil {
+		return nil, ErrApprovalVerifierNotConfigured
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, err
+	}
+
+	signingHash, err := decodedTx.signingHash(u.dataSigner)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := u.signingSource(accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	approvalToken, expiresAt, err := u.signingGate.prepare(&pendingSignature{
+		address:     source.address,
+		payloadHash: sha256.Sum256(dataForSign),
+		signingHash: signingHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&preparedSignature{
+		Address:       source.address,
+		SigningHash:   signingHash,
+		Summary:       decodedTx.summary(source.address, u.dataSigner),
+		ApprovalToken: approvalToken,
+		ExpiresAt:     expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// CommitSign - second step of two-phase signing. Sign transaction only with valid and unexpired approval token
+// and payload identical to payload of PrepareSign call, approved by approval token verifier.
+// Approval token consumed by any CommitSign call. Refused if approval token verifier not configured
+func (u *privateKeyWalletUnit) CommitSign(ctx context.Context,
+	accountParameters *anypb.Any,
+	dataForSign []byte,
+	approvalToken string,
+) (*string, []byte, error) {
+	if approvalTokenVerifier == nil {
+		return nil, nil, ErrApprovalVerifierNotConfigured
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signingHash, err := decodedTx.signingHash(u.dataSigner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := u.signingSource(accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = u.signingGate.commit(u.walletUUID, approvalToken, &pendingSignature{
+		address:     source.address,
+		payloadHash: sha256.Sum256(dataForSign),
+		signingHash: signingHash,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return u.signDecodedTx(withApprovedByToken(ctx), accountParameters, source, decodedTx)
+}
+
+// RequireTwoStepSigning - disable single-step signing of transactions for wallet. After call transactions signed
+// only by CommitSign with approved token, SignData refused. Gate can't be disabled.
+// Approval token verifier must be configured before call
+func (u *privateKeyWalletUnit) RequireTwoStepSigning() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.keysPool == nil {
+		return ErrWalletUnloaded
+	}
+
+	return u.signingGate.require()
+}
+
+// signDecodedTx - check signing flow, request sign approver decision and sign decoded transaction
+func (u *privateKeyWalletUnit) signDecodedTx(ctx context.Context,
+	accountParameters *anypb.Any,
+	source *signingSource,
+	decodedTx *signingTx,
+) (*string, []byte, error) {
+	err := u.signingGate.check(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// sign approver can be slow external service - decision requested before unit lock
+	err = approveTxSignature(ctx, source, decodedTx, u.dataSigner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	keyData, err := u.resolveAccount(accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if common.HexToAddress(keyData.address) != source.address {
+		return nil, nil, fmt.Errorf("%w: %s", ErrPrivateKeyAccountNotFound, source.address.Hex())
+	}
+
+	privKey, err := keyData.ClonePrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroKey(privKey)
+
+	signedTxRawData, err := signRawTransactionData(u.dataSigner, privKey, decodedTx, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address := keyData.address
+
+	return &address, signedTxRawData, nil
+}
+
+// signingSource - audit source of private key account
+func (u *privateKeyWalletUnit) signingSource(accountParameters *anypb.Any) (*signingSource, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	keyData, err := u.resolveAccount(accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	keyAddress := common.HexToAddress(keyData.address)
+
+	return &signingSource{
+		walletUUID: u.walletUUID,
+		keyLabel:   u.addresses[keyAddress],
+		address:    keyAddress,
+	}, nil
+}
+
+func (u *privateKeyWalletUnit) resolveAccount(accountParameters *anypb.Any) (*addressData, error) {
+	var label string
+
+	labelIdentity := &wrapperspb.StringValue{}
+	if accountParameters.MessageIs(labelIdentity) {
+		err := accountParameters.UnmarshalTo(labelIdentity)
+		if err != nil {
+			return nil, err
+		}
+
+		label = labelIdentity.GetValue()
+		if common.IsHexAddress(label) {
+			addrLabel, isExists := u.addresses[common.HexToAddress(label)]
+			if isExists {
+				label = addrLabel
+			}
+		}
+	} else {
+		accIdentity := &pbCommon.DerivationAddressIdentity{}
+		err := accountParameters.UnmarshalTo(accIdentity)
+		if err != nil {
+			return nil, err
+		}
+
+		if accIdentity.AccountIndex != 0 || accIdentity.InternalIndex != 0 ||
+			int(accIdentity.AddressIndex) >= len(u.labels) {
+			return nil, fmt.Errorf("%w: %d'/%d/%d", ErrPrivateKeyAccountNotFound,
+				accIdentity.AccountIndex, accIdentity.InternalIndex, accIdentity.AddressIndex)
+		}
+
+		label = u.labels[accIdentity.AddressIndex]
+	}
+
+	keyData, isExists := u.keysPool[label]
+	if !isExists || keyData == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPrivateKeyAccountNotFound, label)
+	}
+
+	return keyData, nil
+}
+
+// signRawTransactionData - sign decoded transaction by private key and record signature to audit log.
+// Sign approver decision must be requested by caller before signing
+func signRawTransactionData(signer types.Signer,
+	privKey *ecdsa.PrivateKey,
+	decodedTx *signingTx,
+	source *signingSource,
+) ([]byte, error) {
+	if decodedTx.celoTx != nil {
+		signedTx, signErr := signCeloDynamicFeeTxV2(decodedTx.celoTx, signer.ChainID(), privKey)
+		if signErr != nil {
+			return nil, signErr
+		}
+
+		err := auditCeloTxSignature(source, signedTx)
+		if err != nil {
+			return nil, err
+		}
+
+		return signedTx.MarshalBinary()
+	}
+
+	signedTx, err := types.SignTx(decodedTx.tx, signer, privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = auditTxSignature(source, signedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTxRawData, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign: %w", err)
+	}
+
+	return signedTxRawData, nil
+}
+
+func newEmptyPrivateKeyPoolUnit(walletUUID string, size int) *privateKeyWalletUnit {
+	return &privateKeyWalletUnit{
+		mu: &sync.Mutex{},
+
+		dataSigner: pluginSigner,
+
+		walletUUID: walletUUID,
+
+		labels:    make([]string, 0, size),
+		keysPool:  make(map[string]*addressData, size),
+		keyStore:  newProtectedKeyStore(),
+		addresses: make(map[common.Address]string, size),
+
+		signingGate: newTwoStepSigningGate(),
+	}
+}
+
+// addPrivateKey - copy labeled private key to pool unit. Sorting of labels list is responsibility of caller,
+// source private key must be zeroed by caller
+func (u *privateKeyWalletUnit) addPrivateKey(label string, privKey *ecdsa.PrivateKey) error {
+	if label == "" {
+		return ErrPrivateKeyLabelEmpty
+	}
+
+	addr := crypto.PubkeyToAddress(privKey.PublicKey)
+	if existedLabel, isExists := u.addresses[addr]; isExists {
+		return fmt.Errorf("%w: labels %s and %s", ErrPrivateKeyDuplicated, existedLabel, label)
+	}
+
+	keyData, err := newAddressData(u.keyStore, addr.Hex(), privKey)
+	if err != nil {
+		return err
+	}
+
+	u.labels = append(u.labels, label)
+	u.addresses[addr] = label
+	u.keysPool[label] = keyData
+
+	return nil
+}
+
+// NewPrivateKeyPoolUnit - create pool unit of labeled secp256k1 private keys, e.g. legacy hot wallets.
+// Map key - label of private key, map value - hex encoded private key with or without 0x prefix.
+// Unit contains same methods as mnemonic wallet pool unit - UnloadWallet, GetWalletUUID, LoadAccount,
+// GetAccountAddress, GetMultipleAccounts and SignData
+func NewPrivateKeyPoolUnit(walletUUID string,
+	labeledPrivateKeys map[string]string,
+) (interface{}, error) {
+	return newPrivateKeyPoolUnit(walletUUID, labeledPrivateKeys)
+}
+
+func newPrivateKeyPoolUnit(walletUUID string,
+	labeledPrivateKeys map[string]string,
+) (*privateKeyWalletUnit, error) {
+	if len(labeledPrivateKeys) == 0 {
+		return nil, ErrPrivateKeysListEmpty
+	}
+
+	unit := newEmptyPrivateKeyPoolUnit(walletUUID, len(labeledPrivateKeys))
+
+	for label, privKeyHex := range labeledPrivateKeys {
+		privKey, err := crypto.HexToECDSA(strings.TrimPrefix(privKeyHex, "0x"))
+		if err != nil {
+			_ = unit.unloadWallet()
+
+			return nil, fmt.Errorf("%w: label %s", ErrPrivateKeyInvalid, label)
+		}
+
+		err = unit.addPrivateKey(label, privKey)
+		zeroKey(privKey)
+		if err != nil {
+			_ = unit.unloadWallet()
+
+			return nil, err
+		}
+	}
+
+	sort.Strings(unit.labels)
+
+	return unit, nil
+}
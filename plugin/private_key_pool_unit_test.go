@@ -0,0 +1,340 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// WARN: DO NOT USE THESE PRIVATE KEYS IN MAINNET OR TESTNET. Usage only in unit-tests
+var testLabeledPrivateKeys = map[string]string{
+	"legacy-hot-1": "0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+	"legacy-hot-0": "59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+}
+
+func TestPrivateKeyWalletUnit_GetAccountAddress(t *testing.T) {
+	type testCase struct {
+		Identity        proto.Message
+		ExpectedAddress string
+	}
+
+	poolUnitIntrf, err := NewPrivateKeyPoolUnit(uuid.NewString(), testLabeledPrivateKeys)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create private keys pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*privateKeyWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	testCases := []*testCase{
+		{
+			Identity:        wrapperspb.String("legacy-hot-1"),
+			ExpectedAddress: "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		},
+		{
+			Identity:        wrapperspb.String("0x70997970c51812dc3a010c7d01b50e0d17dc79c8"),
+			ExpectedAddress: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		},
+		{
+			// position of label in sorted list of labels
+			Identity: &pbCommon.DerivationAddressIdentity{
+				AddressIndex: 0,
+			},
+			ExpectedAddress: "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+		},
+	}
+
+	for _, tCase := range testCases {
+		accountIdentity, err := anypb.New(tCase.Identity)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to marshal account identity", err)
+		}
+
+		addr, err := poolUnit.GetAccountAddress(context.Background(), accountIdentity)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to get address", err)
+		}
+
+		if *addr != tCase.ExpectedAddress {
+			t.Fatalf("%s", "address not equal with expected")
+		}
+	}
+
+	unknownIdentity, _ := anypb.New(wrapperspb.String("unknown"))
+	_, err = poolUnit.GetAccountAddress(context.Background(), unknownIdentity)
+	if !errors.Is(err, ErrPrivateKeyAccountNotFound) {
+		t.Fatalf("%s", "unknown label must not be resolved")
+	}
+
+	rangeParams, _ := anypb.New(&pbCommon.RangeUnitsList{
+		RangeUnits: []*pbCommon.RangeRequestUnit{
+			{AddressIndexFrom: 0, AddressIndexTo: 1},
+		},
+	})
+
+	count, list, err := poolUnit.GetMultipleAccounts(context.Background(), rangeParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get multiple accounts", err)
+	}
+
+	if count != 2 || list[0].Address != "0x70997970C51812dc3A010C7d01b50e0d17dc79C8" ||
+		list[1].Address != "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266" {
+		t.Fatalf("%s", "accounts list not equal with expected")
+	}
+}
+
+func TestPrivateKeyWalletUnit_SignData(t *testing.T) {
+	poolUnitIntrf, err := NewPrivateKeyPoolUnit(uuid.NewString(), testLabeledPrivateKeys)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create private keys pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*privateKeyWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	recipient := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+	dataForSign, _ := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   poolUnit.dataSigner.ChainID(),
+		Nonce:     3,
+		GasTipCap: big.NewInt(1000000000),
+		GasFeeCap: big.NewInt(30000000000),
+		Gas:       21000,
+		To:        &recipient,
+		Value:     big.NewInt(1500000),
+	}).MarshalBinary()
+
+	accountIdentity, _ := anypb.New(wrapperspb.String("legacy-hot-1"))
+
+	addr, signedData, err := poolUnit.SignData(context.Background(), accountIdentity, dataForSign)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data", err)
+	}
+
+	signedTx := &types.Transaction{}
+	err = signedTx.UnmarshalBinary(signedData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal signed transaction", err)
+	}
+
+	sender, err := types.Sender(poolUnit.dataSigner, signedTx)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get sender of signed transaction", err)
+	}
+
+	if sender.Hex() != *addr || *addr != "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266" {
+		t.Fatalf("%s", "sender not equal with expected")
+	}
+
+	keysData := make([]*addressData, 0)
+	for _, keyData := range poolUnit.keysPool {
+		keysData = append(keysData, keyData)
+	}
+
+	err = poolUnit.UnloadWallet()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unload wallet", err)
+	}
+
+	for _, keyData := range keysData {
+		if keyData.privateKey != nil || keyData.publicKey != nil || keyData.address != "" {
+			t.Fatalf("%s", "private key not zeroed after unload")
+		}
+	}
+
+	if poolUnit.keysPool != nil || poolUnit.walletUUID != "0" {
+		t.Fatalf("%s", "pool unit not cleared after unload")
+	}
+}
+
+func TestPrivateKeyWalletUnit_PrepareCommitSign(t *testing.T) {
+	poolUnitIntrf, err := NewPrivateKeyPoolUnit(uuid.NewString(), testLabeledPrivateKeys)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create private keys pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*privateKeyWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	recipient := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+	dataForSign, _ := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   poolUnit.dataSigner.ChainID(),
+		Nonce:     3,
+		GasTipCap: big.NewInt(1000000000),
+		GasFeeCap: big.NewInt(30000000000),
+		Gas:       21000,
+		To:        &recipient,
+		Value:     big.NewInt(1500000),
+	}).MarshalBinary()
+
+	accountIdentity, _ := anypb.New(wrapperspb.String("legacy-hot-1"))
+	anotherIdentity, _ := anypb.New(wrapperspb.String("legacy-hot-0"))
+
+	err = poolUnit.RequireTwoStepSigning()
+	if !errors.Is(err, ErrApprovalVerifierNotConfigured) {
+		t.Fatalf("%s", "two-step signing gate without approval token verifier must be refused")
+	}
+
+	_, err = poolUnit.PrepareSign(context.Background(), accountIdentity, dataForSign)
+	if !errors.Is(err, ErrApprovalVerifierNotConfigured) {
+		t.Fatalf("%s", "two-phase signing without approval token verifier must be refused")
+	}
+
+	verifier := &stubApprovalTokenVerifier{approvedTokens: make(map[string]bool)}
+	approvalTokenVerifier = verifier
+	defer func() {
+		approvalTokenVerifier = nil
+	}()
+
+	err = poolUnit.RequireTwoStepSigning()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to require two-step signing", err)
+	}
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, dataForSign)
+	if !errors.Is(err, ErrSingleStepSigningDisabled) {
+		t.Fatalf("%s", "single-step signing must be refused")
+	}
+
+	prepare := func() *preparedSignature {
+		preparedData, prepareErr := poolUnit.PrepareSign(context.Background(), accountIdentity, dataForSign)
+		if prepareErr != nil {
+			t.Fatalf("%s: %e", "unable to prepare signature", prepareErr)
+		}
+
+		prepared := &preparedSignature{}
+		prepareErr = json.Unmarshal(preparedData, prepared)
+		if prepareErr != nil {
+			t.Fatalf("%s: %e", "unable to unmarshal prepared signature", prepareErr)
+		}
+
+		return prepared
+	}
+
+	prepared := prepare()
+	verifier.approvedTokens[prepared.ApprovalToken] = true
+
+	_, _, err = poolUnit.CommitSign(context.Background(), anotherIdentity, dataForSign, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalPayloadMismatch) {
+		t.Fatalf("%s", "account not equal with prepared account must be refused")
+	}
+
+	prepared = prepare()
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, dataForSign, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalDenied) {
+		t.Fatalf("%s", "approval token not approved by verifier must be refused")
+	}
+
+	prepared = prepare()
+	verifier.approvedTokens[prepared.ApprovalToken] = true
+
+	addr, signedData, err := poolUnit.CommitSign(context.Background(), accountIdentity, dataForSign,
+		prepared.ApprovalToken)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to commit signature", err)
+	}
+
+	signedTx := &types.Transaction{}
+	err = signedTx.UnmarshalBinary(signedData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal signed transaction", err)
+	}
+
+	sender, err := types.Sender(poolUnit.dataSigner, signedTx)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get sender of signed transaction", err)
+	}
+
+	if sender.Hex() != *addr || sender != prepared.Address {
+		t.Fatalf("%s", "sender not equal with expected")
+	}
+
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, dataForSign, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalTokenNotFound) {
+		t.Fatalf("%s", "approval token must be single-use")
+	}
+}
+
+func TestNewPrivateKeyPoolUnit_Errors(t *testing.T) {
+	type testCase struct {
+		Keys        map[string]string
+		ExpectedErr error
+	}
+
+	testCases := []*testCase{
+		{
+			Keys:        map[string]string{},
+			ExpectedErr: ErrPrivateKeysListEmpty,
+		},
+		{
+			Keys:        map[string]string{"": testLabeledPrivateKeys["legacy-hot-0"]},
+			ExpectedErr: ErrPrivateKeyLabelEmpty,
+		},
+		{
+			Keys:        map[string]string{"broken": "0x1234"},
+			ExpectedErr: ErrPrivateKeyInvalid,
+		},
+		{
+			Keys: map[string]string{
+				"first":  testLabeledPrivateKeys["legacy-hot-0"],
+				"second": "0x" + testLabeledPrivateKeys["legacy-hot-0"],
+			},
+			ExpectedErr: ErrPrivateKeyDuplicated,
+		},
+	}
+
+	for _, tCase := range testCases {
+		_, err := NewPrivateKeyPoolUnit(uuid.NewString(), tCase.Keys)
+		if !errors.Is(err, tCase.ExpectedErr) {
+			t.Fatalf("%s", "error not equal with expected")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//go:build linux
+
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocProtectedRegion - mmap region with guard pages before and after data pages.
+// Data pages locked by mlock and excluded from core dumps
+func allocProtectedRegion(size int) ([]byte, []byte, error) {
+	if size <= 0 {
+		return nil, nil, fmt.Errorf("%w: wrong size %d", ErrProtectedMemoryUnavailable, size)
+	}
+
+	pageSize := os.Getpagesize()
+	dataPagesSize := (size + pageSize - 1) / pageSize * pageSize
+
+	region, err := unix.Mmap(-1, 0, dataPagesSize+2*pageSize,
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: mmap: %s", ErrProtectedMemoryUnavailable, err.Error())
+	}
+
+	dataPages := region[pageSize : pageSize+dataPagesSize]
+
+	err = protectRegion(region, dataPages, pageSize)
+	if err != nil {
+		_ = unix.Munmap(region)
+
+		return nil, nil, err
+	}
+
+	return region, dataPages[dataPagesSize-size:], nil
+}
+
+func protectRegion(region, dataPages []byte, pageSize int) error {
+	err := unix.Mprotect(region[:pageSize], unix.PROT_NONE)
+	if err != nil {
+		return fmt.Errorf("%w: mprotect: %s", ErrProtectedMemoryUnavailable, err.Error())
+	}
+
+	err = unix.Mprotect(region[len(region)-pageSize:], unix.PROT_NONE)
+	if err != nil {
+		return fmt.Errorf("%w: mprotect: %s", ErrProtectedMemoryUnavailable, err.Error())
+	}
+
+	err = unix.Mlock(dataPages)
+	if err != nil {
+		return fmt.Errorf("%w: mlock, check RLIMIT_MEMLOCK: %s", ErrProtectedMemoryUnavailable, err.Error())
+	}
+
+	err = unix.Madvise(dataPages, unix.MADV_DONTDUMP)
+	if err != nil {
+		_ = unix.Munlock(dataPages)
+
+		return fmt.Errorf("%w: madvise: %s", ErrProtectedMemoryUnavailable, err.Error())
+	}
+
+	return nil
+}
+
+// freeProtectedRegion - unlock and unmap region, data must be wiped by caller
+func freeProtectedRegion(region []byte) {
+	pageSize := os.Getpagesize()
+
+	_ = unix.Munlock(region[pageSize : len(region)-pageSize])
+	_ = unix.Munmap(region)
+}
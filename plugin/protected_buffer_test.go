@@ -0,0 +1,169 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	bip39 "github.com/tyler-smith/go-bip39"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestProtectedBuffer(t *testing.T) {
+	_, err := newProtectedBuffer(0)
+	if !errors.Is(err, ErrProtectedMemoryUnavailable) {
+		t.Fatalf("%s", "zero size buffer must not be allocated")
+	}
+
+	buffer, err := newProtectedBuffer(100)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to allocate protected buffer", err)
+	}
+
+	data := buffer.Bytes()
+	if len(data) != 100 {
+		t.Fatalf("%s", "buffer length not equal with expected")
+	}
+
+	for i := range data {
+		if data[i] != 0 {
+			t.Fatalf("%s", "new buffer must be zeroed")
+		}
+
+		data[i] = 0xff
+	}
+
+	buffer.Destroy()
+	// second destroy must be safe
+	buffer.Destroy()
+
+	if buffer.Bytes() != nil || buffer.region != nil {
+		t.Fatalf("%s", "buffer not released after destroy")
+	}
+}
+
+func TestWallet_ProtectedSecrets(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	hdWallet, err := newWalletFromMnemonic(mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create wallet", err)
+	}
+
+	seed := bip39.NewSeed(mnemonic, "")
+	masterKey, err := hdkeychain.NewMaster(seed, defaultNetwork)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create master key", err)
+	}
+
+	if hdWallet.ExtendedKey.String() != masterKey.String() {
+		t.Fatalf("%s", "master key not equal with hdkeychain master key")
+	}
+
+	if string(hdWallet.Seed()) != string(seed) {
+		t.Fatalf("%s", "seed not equal with expected")
+	}
+
+	if hdWallet.keyBundle.Private != nil || hdWallet.keyBundle.PrivateECDSA != nil {
+		t.Fatalf("%s", "master private key must be stored only in protected buffer")
+	}
+
+	hdWallet.ClearSecrets()
+
+	if hdWallet.Seed() != nil || hdWallet.secrets.Bytes() != nil {
+		t.Fatalf("%s", "wallet secrets not released")
+	}
+}
+
+func TestMnemonicWalletUnit_ProtectedAddressPool(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	addr, err := poolUnit.LoadAccount(context.Background(), accountIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to load account", err)
+	}
+
+	if *addr != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	addrData := poolUnit.addressPool["7'/8/9"]
+	if addrData == nil || len(addrData.privateKey) != protectedKeyLength {
+		t.Fatalf("%s", "private key not stored in protected key store")
+	}
+
+	privKey, err := addrData.ClonePrivateKey()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to clone private key", err)
+	}
+
+	if crypto.PubkeyToAddress(privKey.PublicKey).Hex() != *addr ||
+		crypto.PubkeyToAddress(*addrData.publicKey).Hex() != *addr {
+		t.Fatalf("%s", "stored private key not equal with account key")
+	}
+	zeroKey(privKey)
+
+	err = poolUnit.UnloadWallet()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unload wallet", err)
+	}
+
+	if addrData.privateKey != nil || poolUnit.keyStore.buffers != nil {
+		t.Fatalf("%s", "protected key store not released after unload")
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// protectedKeyLength - fixed size of private key in protected storage
+	protectedKeyLength = 32
+	// encryptedKeyLength - size of encrypted private key - XChaCha20-Poly1305 nonce, ciphertext and tag
+	encryptedKeyLength = chacha20poly1305.NonceSizeX + protectedKeyLength + chacha20poly1305.Overhead
+)
+
+var ErrProtectedKeyDecrypt = errors.New("unable to decrypt cached private key")
+
+// protectedKeyStore - storage of private keys in fixed size slots of protected buffers.
+// Buffers allocated by memory pages when current buffer is full.
+// If cached keys encryption enabled - slots contain private keys encrypted by XChaCha20-Poly1305
+// with ephemeral key of store, ephemeral key stored in separate protected buffer.
+// Store is not thread safe - it must be used under mutex of pool unit
+type protectedKeyStore struct {
+	buffers []*protectedBuffer
+	// used - count of used slots of last buffer
+	used int
+	// slotsCount - count of slots in one buffer
+	slotsCount int
+	slotLength int
+
+	isEncrypted bool
+	// cipherData - ephemeral encryption key and scratch space of decrypted key, allocated on first usage
+	cipherData *protectedBuffer
+}
+
+func newProtectedKeyStore() *protectedKeyStore {
+	slotLength := protectedKeyLength
+	if cachedKeysEncryptionEnabled {
+		slotLength = encryptedKeyLength
+	}
+
+	return &protectedKeyStore{
+		slotsCount:  os.Getpagesize() / slotLength,
+		slotLength:  slotLength,
+		isEncrypted: cachedKeysEncryptionEnabled,
+	}
+}
+
+// put - copy private key to new slot. Returns slot with 32 bytes big-endian private key or encrypted private key
+func (s *protectedKeyStore) put(privKey *ecdsa.PrivateKey) ([]byte, error) {
+	if len(s.buffers) == 0 || s.used == s.slotsCount {
+		buffer, err := newProtectedBuffer(s.slotsCount * s.slotLength)
+		if err != nil {
+			return nil, err
+		}
+
+		s.buffers = append(s.buffers, buffer)
+		s.used = 0
+	}
+
+	data := s.buffers[len(s.buffers)-1].Bytes()
+	slot := data[s.used*s.slotLength : (s.used+1)*s.slotLength : (s.used+1)*s.slotLength]
+
+	if !s.isEncrypted {
+		privKey.D.FillBytes(slot)
+		s.used++
+
+		return slot, nil
+	}
+
+	aead, plainKey, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plainKey)
+
+	privKey.D.FillBytes(plainKey)
+
+	nonce := slot[:chacha20poly1305.NonceSizeX]
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// slot has enough capacity - ciphertext written in place
+	aead.Seal(slot[chacha20poly1305.NonceSizeX:chacha20poly1305.NonceSizeX], nonce, plainKey, nil)
+	s.used++
+
+	return slot, nil
+}
+
+// privateKey - returns private key of slot, decrypted if encryption enabled. Private key must be zeroed by caller
+func (s *protectedKeyStore) privateKey(slot []byte) (*big.Int, error) {
+	if !s.isEncrypted {
+		return (&big.Int{}).SetBytes(slot), nil
+	}
+
+	aead, plainKey, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	defer clear(plainKey)
+
+	_, err = aead.Open(plainKey[:0], slot[:chacha20poly1305.NonceSizeX], slot[chacha20poly1305.NonceSizeX:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrProtectedKeyDecrypt, err.Error())
+	}
+
+	return (&big.Int{}).SetBytes(plainKey), nil
+}
+
+// cipher - returns AEAD of ephemeral key and scratch space for plain private key.
+// AEAD created for every operation - its copy of ephemeral key must not live in heap longer than operation
+func (s *protectedKeyStore) cipher() (cipher.AEAD, []byte, error) {
+	if s.cipherData == nil {
+		buffer, err := newProtectedBuffer(chacha20poly1305.KeySize + protectedKeyLength)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, err = rand.Read(buffer.Bytes()[:chacha20poly1305.KeySize])
+		if err != nil {
+			buffer.Destroy()
+
+			return nil, nil, err
+		}
+
+		s.cipherData = buffer
+	}
+
+	data := s.cipherData.Bytes()
+
+	aead, err := chacha20poly1305.NewX(data[:chacha20poly1305.KeySize])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aead, data[chacha20poly1305.KeySize:], nil
+}
+
+// destroy - wipe all slots and ephemeral key, release buffers
+func (s *protectedKeyStore) destroy() {
+	for _, buffer := range s.buffers {
+		buffer.Destroy()
+	}
+
+	if s.cipherData != nil {
+		s.cipherData.Destroy()
+		s.cipherData = nil
+	}
+
+	s.buffers = nil
+	s.used = 0
+}
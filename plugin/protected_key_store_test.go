@@ -0,0 +1,212 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestProtectedKeyStore(t *testing.T) {
+	type testCase struct {
+		IsEncrypted        bool
+		ExpectedSlotLength int
+	}
+
+	testCases := []*testCase{
+		{
+			IsEncrypted:        false,
+			ExpectedSlotLength: protectedKeyLength,
+		},
+		{
+			IsEncrypted:        true,
+			ExpectedSlotLength: encryptedKeyLength,
+		},
+	}
+
+	defer func() {
+		cachedKeysEncryptionEnabled = false
+	}()
+
+	for _, tCase := range testCases {
+		cachedKeysEncryptionEnabled = tCase.IsEncrypted
+		store := newProtectedKeyStore()
+
+		// keys of two buffers
+		count := store.slotsCount + 1
+		keysData := make([]*addressData, 0, count)
+		for i := 0; i < count; i++ {
+			privKey, err := crypto.GenerateKey()
+			if err != nil {
+				t.Fatalf("%s: %e", "unable to generate private key", err)
+			}
+
+			keyData, err := newAddressData(store, crypto.PubkeyToAddress(privKey.PublicKey).Hex(), privKey)
+			if err != nil {
+				t.Fatalf("%s: %e", "unable to put private key to key store", err)
+			}
+
+			if len(keyData.privateKey) != tCase.ExpectedSlotLength ||
+				cap(keyData.privateKey) != tCase.ExpectedSlotLength {
+				t.Fatalf("%s", "private key slot size not equal with expected")
+			}
+
+			isPlain := string(keyData.privateKey) == string(privKey.D.FillBytes(make([]byte, protectedKeyLength)))
+			if isPlain == tCase.IsEncrypted {
+				t.Fatalf("%s", "private key slot encryption not equal with expected")
+			}
+
+			clonedKey, err := keyData.ClonePrivateKey()
+			if err != nil {
+				t.Fatalf("%s: %e", "unable to clone private key", err)
+			}
+
+			if clonedKey.D.Cmp(privKey.D) != 0 ||
+				crypto.PubkeyToAddress(clonedKey.PublicKey).Hex() != keyData.address {
+				t.Fatalf("%s", "cloned private key not equal with source key")
+			}
+
+			zeroKey(privKey)
+			zeroKey(clonedKey)
+
+			clonedKey, err = keyData.ClonePrivateKey()
+			if err != nil || clonedKey.D.Sign() == 0 {
+				t.Fatalf("%s", "stored private key must not depend on source and cloned keys")
+			}
+
+			keysData = append(keysData, keyData)
+		}
+
+		if len(store.buffers) != 2 || store.used != 1 {
+			t.Fatalf("%s", "count of key store buffers not equal with expected")
+		}
+
+		if tCase.IsEncrypted {
+			// tampered slot must not be decrypted
+			keysData[0].privateKey[encryptedKeyLength-1] ^= 0x01
+			_, err := keysData[0].ClonePrivateKey()
+			if !errors.Is(err, ErrProtectedKeyDecrypt) {
+				t.Fatalf("%s", "tampered private key must not be decrypted")
+			}
+		}
+
+		for _, keyData := range keysData {
+			keyData.clear()
+		}
+
+		store.destroy()
+
+		if store.buffers != nil || store.cipherData != nil {
+			t.Fatalf("%s", "key store buffers not released after destroy")
+		}
+	}
+}
+
+func TestMnemonicWalletUnit_EncryptedAddressPool(t *testing.T) {
+	cachedKeysEncryptionEnabled = true
+	defer func() {
+		cachedKeysEncryptionEnabled = false
+	}()
+
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	tx := types.NewTransaction(0, crypto.PubkeyToAddress(*poolUnit.hdWalletSvc.Public.ToECDSA()),
+		nil, 21000, nil, nil)
+	txData, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	addr, signedTxData, err := poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign transaction", err)
+	}
+
+	if *addr != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	addrData := poolUnit.addressPool["7'/8/9"]
+	if addrData == nil || len(addrData.privateKey) != encryptedKeyLength || poolUnit.keyStore.cipherData == nil {
+		t.Fatalf("%s", "cached private key not encrypted")
+	}
+
+	signedTx := &types.Transaction{}
+	err = signedTx.UnmarshalBinary(signedTxData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal signed transaction", err)
+	}
+
+	sender, err := types.Sender(poolUnit.dataSigner, signedTx)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get sender of signed transaction", err)
+	}
+
+	if sender.Hex() != *addr {
+		t.Fatalf("%s", "sender not equal with expected")
+	}
+
+	err = poolUnit.UnloadWallet()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unload wallet", err)
+	}
+
+	if poolUnit.keyStore.cipherData != nil {
+		t.Fatalf("%s", "ephemeral key not released after unload")
+	}
+}
@@ -0,0 +1,449 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	approvalTokenLength = 32
+	approvalTokenTTL    = 10 * time.Minute
+)
+
+var (
+	// approvalTokenVerifier - plugin-wide verifier of approval tokens, shared by all pool units.
+	// Two-phase signing refused until verifier configured
+	approvalTokenVerifier        ApprovalTokenVerifier
+	setApprovalTokenVerifierOnce = sync.Once{}
+
+	ErrApprovalVerifierWrongType     = errors.New("approval token verifier must implement VerifyApprovalToken method")
+	ErrApprovalVerifierNotConfigured = errors.New("approval token verifier not configured")
+	ErrSingleStepSigningDisabled     = errors.New("single-step signing disabled, use PrepareSign and CommitSign")
+	ErrApprovalTokenNotFound         = errors.New("approval token not found or already used")
+	ErrApprovalTokenExpired          = errors.New("approval token expired")
+	ErrApprovalPayloadMismatch       = errors.New("signing payload not equal with prepared payload")
+	ErrApprovalDenied                = errors.New("signature not approved")
+)
+
+// ApprovalTokenVerifier - verifier of approval tokens, issued by PrepareSign pool unit method.
+// VerifyApprovalToken called by CommitSign before signature, signature produced only if verifier returns true
+type ApprovalTokenVerifier interface {
+	VerifyApprovalToken(walletUUID string, approvalToken string, signingHash []byte) (bool, error)
+}
+
+// preparedSignature - result of PrepareSign, JSON format
+type preparedSignature struct {
+	Address       common.Address `json:"address"`
+	SigningHash   common.Hash    `json:"signingHash"`
+	Summary       *txSummary     `json:"summary"`
+	ApprovalToken string         `json:"approvalToken"`
+	ExpiresAt     string         `json:"expiresAt"`
+}
+
+// pendingSignature - prepared signature, waiting for CommitSign call with approval token.
+// Account bound by derivation path in mnemonic pool unit and by address in another pool units
+type pendingSignature struct {
+	account, change, index uint32
+	address                common.Address
+
+	payloadHash common.Hash
+	signingHash common.Hash
+	expiresAt   time.Time
+}
+
+// isSameSignature - account, payload and signing hash of pending signature equal with another signature
+func (p *pendingSignature) isSameSignature(another *pendingSignature) bool {
+	return p.account == another.account && p.change == another.change && p.index == another.index &&
+		p.address == another.address &&
+		p.payloadHash == another.payloadHash && p.signingHash == another.signingHash
+}
+
+// twoStepSigningGate - pending signatures of two-phase signing and single-step signing gate of pool unit
+type twoStepSigningGate struct {
+	mu *sync.Mutex
+
+	// pendingSignatures - signatures prepared by PrepareSign, map key - sha256 of approval token
+	pendingSignatures map[common.Hash]*pendingSignature
+	// isRequired - single-step signing of transactions disabled, see RequireTwoStepSigning
+	isRequired atomic.Bool
+}
+
+func newTwoStepSigningGate() *twoStepSigningGate {
+	return &twoStepSigningGate{
+		mu: &sync.Mutex{},
+	}
+}
+
+// prepare - store pending signature, returns single-use approval token and its expiration time
+func (g *twoStepSigningGate) prepare(pending *pendingSignature) (string, time.Time, error) {
+	if approvalTokenVerifier == nil {
+		return "", time.Time{}, ErrApprovalVerifierNotConfigured
+	}
+
+	token := make([]byte, approvalTokenLength)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	approvalToken := hexutil.Encode(token)
+	pending.expiresAt = time.Now().Add(approvalTokenTTL)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.dropExpiredPendingSignatures()
+
+	if g.pendingSignatures == nil {
+		g.pendingSignatures = make(map[common.Hash]*pendingSignature)
+	}
+
+	g.pendingSignatures[sha256.Sum256([]byte(approvalToken))] = pending
+
+	return approvalToken, pending.expiresAt, nil
+}
+
+// commit - consume pending signature of approval token, compare it with expected signature and
+// request approval token verifier decision. Must be called without unit lock - verifier can be slow external service
+func (g *twoStepSigningGate) commit(walletUUID string,
+	approvalToken string,
+	expected *pendingSignature,
+) (*pendingSignature, error) {
+	if approvalTokenVerifier == nil {
+		return nil, ErrApprovalVerifierNotConfigured
+	}
+
+	pending, err := g.consume(approvalToken, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	isApproved, err := approvalTokenVerifier.VerifyApprovalToken(walletUUID, approvalToken,
+		pending.signingHash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if !isApproved {
+		return nil, ErrApprovalDenied
+	}
+
+	return pending, nil
+}
+
+// consume - take pending signature of approval token and compare it with expected signature.
+// Approval token consumed even if signature mismatched
+func (g *twoStepSigningGate) consume(approvalToken string, expected *pendingSignature) (*pendingSignature, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tokenHash := sha256.Sum256([]byte(approvalToken))
+	pending, isExist := g.pendingSignatures[tokenHash]
+	if !isExist {
+		return nil, ErrApprovalTokenNotFound
+	}
+
+	delete(g.pendingSignatures, tokenHash)
+
+	if time.Now().After(pending.expiresAt) {
+		return nil, ErrApprovalTokenExpired
+	}
+
+	if !pending.isSameSignature(expected) {
+		return nil, ErrApprovalPayloadMismatch
+	}
+
+	return pending, nil
+}
+
+// require - disable single-step signing. Gate can't be disabled
+func (g *twoStepSigningGate) require() error {
+	if approvalTokenVerifier == nil {
+		return ErrApprovalVerifierNotConfigured
+	}
+
+	g.isRequired.Store(true)
+
+	return nil
+}
+
+// check - refuse single-step signing if two-step signing required
+func (g *twoStepSigningGate) check(ctx context.Context) error {
+	if g.isRequired.Load() && !isApprovedByToken(ctx) {
+		return ErrSingleStepSigningDisabled
+	}
+
+	return nil
+}
+
+func (g *twoStepSigningGate) clear() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pendingSignatures = nil
+}
+
+func (g *twoStepSigningGate) dropExpiredPendingSignatures() {
+	now := time.Now()
+	for tokenHash, pending := range g.pendingSignatures {
+		if now.After(pending.expiresAt) {
+			delete(g.pendingSignatures, tokenHash)
+		}
+	}
+}
+
+// signingTx - transaction for signature, decoded by type of signing data
+type signingTx struct {
+	tx     *types.Transaction
+	celoTx *celoDynamicFeeTxV2
+}
+
+func decodeSigningTx(signer types.Signer, dataForSign []byte) (*signingTx, error) {
+	if isCeloChainID(signer.ChainID()) && isCeloDynamicFeeTxV2Data(dataForSign) {
+		celoTx := &celoDynamicFeeTxV2{}
+		err := celoTx.UnmarshalBinary(dataForSign)
+		if err != nil {
+			return nil, err
+		}
+
+		return &signingTx{celoTx: celoTx}, nil
+	}
+
+	tx := &types.Transaction{}
+	err := tx.UnmarshalBinary(dataForSign)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signingTx{tx: tx}, nil
+}
+
+// signingHash - hash signed by account private key. Chain ID of typed transactions must be equal with plugin chain ID
+func (t *signingTx) signingHash(signer types.Signer) (common.Hash, error) {
+	if t.celoTx != nil {
+		if t.celoTx.ChainID == nil || t.celoTx.ChainID.Cmp(signer.ChainID()) != 0 {
+			return common.Hash{}, fmt.Errorf("%w: have %d want %d", types.ErrInvalidChainId,
+				t.celoTx.ChainID, signer.ChainID())
+		}
+
+		return t.celoTx.SigHash()
+	}
+
+	if t.tx.Type() != types.LegacyTxType && t.tx.ChainId().Cmp(signer.ChainID()) != 0 {
+		return common.Hash{}, fmt.Errorf("%w: have %d want %d", types.ErrInvalidChainId,
+			t.tx.ChainId(), signer.ChainID())
+	}
+
+	return signer.Hash(t.tx), nil
+}
+
+func (t *signingTx) summary(from common.Address, signer types.Signer) *txSummary {
+	summary := &txSummary{}
+	if t.celoTx != nil {
+		summary.fillFromCeloTx(from, t.celoTx)
+
+		return summary
+	}
+
+	summary.fillFromTx(from, t.tx)
+	// chain ID of unsigned legacy transaction is not encoded in transaction data
+	summary.ChainID = (*hexutil.Big)(signer.ChainID())
+
+	return summary
+}
+
+// PrepareSign - first step of two-phase signing. Validate and decode transaction, returns JSON-encoded
+// preparedSignature - signing hash, decoded transaction summary and single-use approval token, bound to
+// signing hash and payload. Signature produced by CommitSign call with approval token and same payload.
+// Refused if approval token verifier not configured
+func (u *mnemonicWalletUnit) PrepareSign(ctx context.Context,
+	accountParameters *anypb.Any,
+	dataForSign []byte,
+) ([]byte, error) {
+	if approvalTokenVerifier == nil {
+		return nil, ErrApprovalVerifierNotConfigured
+	}
+
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, err
+	}
+
+	signingHash, err := decodedTx.signingHash(u.dataSigner)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := u.getAddressByPath(ctx, accIdentity.AccountIndex, accIdentity.InternalIndex,
+		accIdentity.AddressIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.isUnloaded() {
+		return nil, ErrWalletUnloaded
+	}
+
+	approvalToken, expiresAt, err := u.signingGate.prepare(&pendingSignature{
+		account:     accIdentity.AccountIndex,
+		change:      accIdentity.InternalIndex,
+		index:       accIdentity.AddressIndex,
+		payloadHash: sha256.Sum256(dataForSign),
+		signingHash: signingHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&preparedSignature{
+		Address:       common.HexToAddress(*addr),
+		SigningHash:   signingHash,
+		Summary:       decodedTx.summary(common.HexToAddress(*addr), u.dataSigner),
+		ApprovalToken: approvalToken,
+		ExpiresAt:     expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// CommitSign - second step of two-phase signing. Sign transaction only with valid and unexpired approval token
+// and payload identical to payload of PrepareSign call, approved by approval token verifier.
+// Approval token consumed by any CommitSign call. Refused if approval token verifier not configured
+func (u *mnemonicWalletUnit) CommitSign(ctx context.Context,
+	accountParameters *anypb.Any,
+	dataForSign []byte,
+	approvalToken string,
+) (*string, []byte, error) {
+	if approvalTokenVerifier == nil {
+		return nil, nil, ErrApprovalVerifierNotConfigured
+	}
+
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signingHash, err := decodedTx.signingHash(u.dataSigner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pending, err := u.signingGate.commit(u.mnemonicWalletUUID, approvalToken, &pendingSignature{
+		account:     accIdentity.AccountIndex,
+		change:      accIdentity.InternalIndex,
+		index:       accIdentity.AddressIndex,
+		payloadHash: sha256.Sum256(dataForSign),
+		signingHash: signingHash,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx = withApprovedByToken(ctx)
+
+	err = u.approveAccountTx(ctx, pending.account, pending.change, pending.index, decodedTx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	addr, summary, err := u.signDecodedTx(ctx, pending.account, pending.change, pending.index, decodedTx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addr, summary.RawTx, nil
+}
+
+// RequireTwoStepSigning - disable single-step signing of transactions for wallet. After call transactions signed
+// only by CommitSign with approved token, another signing methods refused. Gate can't be disabled.
+// Approval token verifier must be configured before call
+func (u *mnemonicWalletUnit) RequireTwoStepSigning() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.isUnloaded() {
+		return ErrWalletUnloaded
+	}
+
+	return u.signingGate.require()
+}
+
+// checkSigningFlow - refuse single-step signing if two-step signing required
+func (u *mnemonicWalletUnit) checkSigningFlow(ctx context.Context) error {
+	return u.signingGate.check(ctx)
+}
+
+// SetApprovalTokenVerifier - set plugin-wide verifier of approval tokens. Verifier must implement
+// ApprovalTokenVerifier interface. Verifier can be set only once
+func SetApprovalTokenVerifier(verifier interface{}) error {
+	tokenVerifier, ok := verifier.(ApprovalTokenVerifier)
+	if !ok || tokenVerifier == nil {
+		return ErrApprovalVerifierWrongType
+	}
+
+	var err = fmt.Errorf("%w: %s", ErrPluginValueAlreadySet, "approvalTokenVerifier")
+	setApprovalTokenVerifierOnce.Do(func() {
+		approvalTokenVerifier = tokenVerifier
+		err = nil
+	})
+
+	return err
+}
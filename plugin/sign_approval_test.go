@@ -0,0 +1,271 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type stubApprovalTokenVerifier struct {
+	approvedTokens map[string]bool
+}
+
+func (v *stubApprovalTokenVerifier) VerifyApprovalToken(_ string, approvalToken string, _ []byte) (bool, error) {
+	return v.approvedTokens[approvalToken], nil
+}
+
+func TestMnemonicWalletUnit_PrepareCommitSign(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+	anotherIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  10,
+	})
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		big.NewInt(1000), 21000, big.NewInt(1), nil)
+	txData, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	anotherTx := types.NewTransaction(0, common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		big.NewInt(1000000), 21000, big.NewInt(1), nil)
+	anotherTxData, err := anotherTx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	_, err = poolUnit.PrepareSign(context.Background(), accountIdentity, txData)
+	if !errors.Is(err, ErrApprovalVerifierNotConfigured) {
+		t.Fatalf("%s", "two-phase signing without approval token verifier must be refused")
+	}
+
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, txData, "0x01")
+	if !errors.Is(err, ErrApprovalVerifierNotConfigured) {
+		t.Fatalf("%s", "two-phase signing without approval token verifier must be refused")
+	}
+
+	verifier := &stubApprovalTokenVerifier{approvedTokens: make(map[string]bool)}
+	approvalTokenVerifier = verifier
+	defer func() {
+		approvalTokenVerifier = nil
+	}()
+
+	prepare := func() *preparedSignature {
+		preparedData, prepareErr := poolUnit.PrepareSign(context.Background(), accountIdentity, txData)
+		if prepareErr != nil {
+			t.Fatalf("%s: %e", "unable to prepare signature", prepareErr)
+		}
+
+		prepared := &preparedSignature{}
+		prepareErr = json.Unmarshal(preparedData, prepared)
+		if prepareErr != nil {
+			t.Fatalf("%s: %e", "unable to unmarshal prepared signature", prepareErr)
+		}
+
+		return prepared
+	}
+
+	prepared := prepare()
+	if prepared.SigningHash != poolUnit.dataSigner.Hash(tx) {
+		t.Fatalf("%s", "wrong signing hash of prepared signature")
+	}
+
+	if prepared.Address != common.HexToAddress("0xf8A0F16782625B16260D0A4b0Ed107412bd95d56") ||
+		prepared.Summary.Value.ToInt().Cmp(big.NewInt(1000)) != 0 ||
+		prepared.Summary.ChainID.ToInt().Cmp(poolUnit.dataSigner.ChainID()) != 0 {
+		t.Fatalf("%s", "wrong summary of prepared signature")
+	}
+
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, anotherTxData, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalPayloadMismatch) {
+		t.Fatalf("%s", "payload not equal with prepared payload must be refused")
+	}
+
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, txData, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalTokenNotFound) {
+		t.Fatalf("%s", "approval token must be single-use")
+	}
+
+	prepared = prepare()
+	_, _, err = poolUnit.CommitSign(context.Background(), anotherIdentity, txData, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalPayloadMismatch) {
+		t.Fatalf("%s", "account not equal with prepared account must be refused")
+	}
+
+	prepared = prepare()
+	for _, pending := range poolUnit.signingGate.pendingSignatures {
+		pending.expiresAt = time.Now().Add(-time.Second)
+	}
+
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, txData, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalTokenExpired) {
+		t.Fatalf("%s", "expired approval token must be refused")
+	}
+
+	prepared = prepare()
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, txData, prepared.ApprovalToken)
+	if !errors.Is(err, ErrApprovalDenied) {
+		t.Fatalf("%s", "approval token not approved by verifier must be refused")
+	}
+
+	prepared = prepare()
+	verifier.approvedTokens[prepared.ApprovalToken] = true
+
+	addr, signedTxData, err := poolUnit.CommitSign(context.Background(), accountIdentity, txData,
+		prepared.ApprovalToken)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to commit signature", err)
+	}
+
+	signedTx := &types.Transaction{}
+	err = signedTx.UnmarshalBinary(signedTxData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal signed transaction", err)
+	}
+
+	sender, err := types.Sender(poolUnit.dataSigner, signedTx)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to recover transaction sender", err)
+	}
+
+	if sender != common.HexToAddress(*addr) || sender != prepared.Address {
+		t.Fatalf("%s", "transaction signed by wrong account")
+	}
+
+	if len(poolUnit.signingGate.pendingSignatures) != 0 {
+		t.Fatalf("%s", "pending signatures not dropped")
+	}
+}
+
+func TestMnemonicWalletUnit_RequireTwoStepSigning(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	err = poolUnit.RequireTwoStepSigning()
+	if !errors.Is(err, ErrApprovalVerifierNotConfigured) {
+		t.Fatalf("%s", "two-step signing gate without approval token verifier must be refused")
+	}
+
+	verifier := &stubApprovalTokenVerifier{approvedTokens: make(map[string]bool)}
+	approvalTokenVerifier = verifier
+	defer func() {
+		approvalTokenVerifier = nil
+	}()
+
+	err = poolUnit.RequireTwoStepSigning()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to require two-step signing", err)
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		big.NewInt(1000), 21000, big.NewInt(1), nil)
+	txData, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if !errors.Is(err, ErrSingleStepSigningDisabled) {
+		t.Fatalf("%s", "single-step signing must be refused")
+	}
+
+	_, err = poolUnit.SignSweepBatch(context.Background(), []byte(`{}`))
+	if !errors.Is(err, ErrSingleStepSigningDisabled) {
+		t.Fatalf("%s", "single-step sweep signing must be refused")
+	}
+
+	preparedData, err := poolUnit.PrepareSign(context.Background(), accountIdentity, txData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prepare signature", err)
+	}
+
+	prepared := &preparedSignature{}
+	err = json.Unmarshal(preparedData, prepared)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal prepared signature", err)
+	}
+
+	verifier.approvedTokens[prepared.ApprovalToken] = true
+
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, txData, prepared.ApprovalToken)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to commit signature", err)
+	}
+}
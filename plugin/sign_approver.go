@@ -0,0 +1,318 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	signDecisionAllow       = "allow"
+	signDecisionDeny        = "deny"
+	signDecisionRequireMore = "require_more"
+
+	httpSignApproverMaxResponseSize = 1 << 16
+)
+
+var (
+	// signApprover - plugin-wide external approver of signatures, shared by all pool units. Optional
+	signApprover        SignApprover
+	setSignApproverOnce = sync.Once{}
+
+	ErrSignApproverWrongType     = errors.New("sign approver must implement ApproveSign method")
+	ErrSignApproverWrongConfig   = errors.New("wrong sign approver config")
+	ErrSignApproverUnavailable   = errors.New("sign approver unavailable")
+	ErrSignApproverWrongResponse = errors.New("wrong response of sign approver")
+	ErrSignApproverWrongDecision = errors.New("unknown decision of sign approver")
+	ErrSignatureDenied           = errors.New("signature denied by sign approver")
+	ErrSignatureApprovalRequired = errors.New("additional approval of signature required")
+)
+
+// SignApprover - external approver of signatures, e.g. risk engine. ApproveSign called before every signature
+// of all pool units with JSON-encoded signApprovalRequest. Returns decision - allow, deny or require_more
+// and optional reason. Signature not produced if decision is not allow or error returned
+type SignApprover interface {
+	ApproveSign(ctx context.Context, request []byte) (decision string, reason string, err error)
+}
+
+// signApprovalRequest - decoded signing request for external approver, JSON format
+type signApprovalRequest struct {
+	WalletUUID     string `json:"walletUUID"`
+	DerivationPath string `json:"derivationPath,omitempty"`
+	// KeyLabel - label of private key, only for private key pool units
+	KeyLabel string       `json:"keyLabel,omitempty"`
+	Address  string       `json:"address"`
+	ChainID  *hexutil.Big `json:"chainId,omitempty"`
+	Kind     string       `json:"kind"`
+	// SigningHash - transaction signing hash or signing root of beacon object
+	SigningHash common.Hash `json:"signingHash"`
+	// Transaction - decoded transaction, only for transaction signing
+	Transaction *txSummary `json:"transaction,omitempty"`
+	// Object - signed beacon object or deposit message
+	Object json.RawMessage `json:"object,omitempty"`
+	// ApprovedByToken - request signed by CommitSign with approval token of two-phase signing
+	ApprovedByToken bool `json:"approvedByToken"`
+}
+
+type approvedByTokenCtxKey struct{}
+
+// withApprovedByToken - mark signing context as approved by approval token of two-phase signing
+func withApprovedByToken(ctx context.Context) context.Context {
+	return context.WithValue(ctx, approvedByTokenCtxKey{}, true)
+}
+
+func isApprovedByToken(ctx context.Context) bool {
+	isApproved, _ := ctx.Value(approvedByTokenCtxKey{}).(bool)
+
+	return isApproved
+}
+
+// approveSignature - request decision of sign approver, if it's configured
+func approveSignature(ctx context.Context, request *signApprovalRequest, object interface{}) error {
+	if signApprover == nil {
+		return nil
+	}
+
+	if object != nil {
+		objectData, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+
+		request.Object = objectData
+	}
+
+	request.ApprovedByToken = isApprovedByToken(ctx)
+
+	requestData, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	decision, reason, err := signApprover.ApproveSign(ctx, requestData)
+	if err != nil {
+		return err
+	}
+
+	switch decision {
+	case signDecisionAllow:
+		return nil
+	case signDecisionDeny:
+		return fmt.Errorf("%w: %s", ErrSignatureDenied, reason)
+	case signDecisionRequireMore:
+		return fmt.Errorf("%w: %s", ErrSignatureApprovalRequired, reason)
+	default:
+		return fmt.Errorf("%w: %s", ErrSignApproverWrongDecision, decision)
+	}
+}
+
+// approveTxSignature - request decision of sign approver for decoded transaction
+func approveTxSignature(ctx context.Context, source *signingSource, tx *signingTx, signer types.Signer) error {
+	if signApprover == nil {
+		return nil
+	}
+
+	signingHash, err := tx.signingHash(signer)
+	if err != nil {
+		return err
+	}
+
+	return approveSignature(ctx, &signApprovalRequest{
+		WalletUUID:     source.walletUUID,
+		DerivationPath: source.derivationPath,
+		KeyLabel:       source.keyLabel,
+		Address:        source.address.Hex(),
+		ChainID:        (*hexutil.Big)(signer.ChainID()),
+		Kind:           signingKindTransaction,
+		SigningHash:    signingHash,
+		Transaction:    tx.summary(source.address, signer),
+	}, nil)
+}
+
+// httpSignApproverResponse - response of sign approver endpoint, JSON format
+type httpSignApproverResponse struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// httpSignApprover - reference sign approver, POST signApprovalRequest to local HTTP endpoint
+// or HTTP over unix-socket endpoint
+type httpSignApprover struct {
+	client   *http.Client
+	endpoint string
+	// failClosed - deny signatures if endpoint unavailable, otherwise signatures allowed
+	failClosed bool
+}
+
+func newHTTPSignApprover(endpoint string,
+	socketPath string,
+	timeout time.Duration,
+	failClosed bool,
+) (*httpSignApprover, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSignApproverWrongConfig, err.Error())
+	}
+
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: endpoint scheme must be http or https", ErrSignApproverWrongConfig)
+	}
+
+	if timeout <= 0 {
+		return nil, fmt.Errorf("%w: timeout must be positive", ErrSignApproverWrongConfig)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if socketPath != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			dialer := &net.Dialer{}
+
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	}
+
+	return &httpSignApprover{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		endpoint:   endpoint,
+		failClosed: failClosed,
+	}, nil
+}
+
+// ApproveSign - request decision of endpoint. Only unavailable or timed out endpoint allows signature
+// in fail-open mode. Error status, malformed response and unknown decision always refuse signature
+func (a *httpSignApprover) ApproveSign(ctx context.Context, request []byte) (string, string, error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(request))
+	if err != nil {
+		return "", "", err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := a.client.Do(httpRequest)
+	if err != nil {
+		return a.unavailable(err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%w: unexpected response status %d", ErrSignApproverWrongResponse,
+			httpResponse.StatusCode)
+	}
+
+	response := &httpSignApproverResponse{}
+	err = json.NewDecoder(io.LimitReader(httpResponse.Body, httpSignApproverMaxResponseSize)).Decode(response)
+	if err != nil {
+		// response body read is limited by client timeout too
+		if isTimeoutError(ctx, err) {
+			return a.unavailable(err)
+		}
+
+		return "", "", fmt.Errorf("%w: %s", ErrSignApproverWrongResponse, err.Error())
+	}
+
+	switch response.Decision {
+	case signDecisionAllow, signDecisionDeny, signDecisionRequireMore:
+		return response.Decision, response.Reason, nil
+	default:
+		return "", "", fmt.Errorf("%w: %s", ErrSignApproverWrongDecision, response.Decision)
+	}
+}
+
+func (a *httpSignApprover) unavailable(err error) (string, string, error) {
+	if a.failClosed {
+		return "", "", fmt.Errorf("%w: %s", ErrSignApproverUnavailable, err.Error())
+	}
+
+	return signDecisionAllow, "", nil
+}
+
+func isTimeoutError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// SetSignApprover - set plugin-wide external approver of signatures. Approver must implement
+// SignApprover interface. Approver can be set only once
+func SetSignApprover(approver interface{}) error {
+	signApproverSvc, ok := approver.(SignApprover)
+	if !ok || signApproverSvc == nil {
+		return ErrSignApproverWrongType
+	}
+
+	return setSignApprover(signApproverSvc)
+}
+
+// SetHTTPSignApprover - set reference sign approver, which POST JSON-encoded signing requests to endpoint and
+// expects JSON response with decision and reason fields. If socketPath is not empty, endpoint called over unix-socket.
+// failClosed - deny signatures if endpoint unavailable or timed out, otherwise such signatures allowed.
+// Error status, malformed response and unknown decision deny signature in both modes.
+// Approver can be set only once
+func SetHTTPSignApprover(endpoint string, socketPath string, timeout time.Duration, failClosed bool) error {
+	approver, err := newHTTPSignApprover(endpoint, socketPath, timeout, failClosed)
+	if err != nil {
+		return err
+	}
+
+	return setSignApprover(approver)
+}
+
+func setSignApprover(approver SignApprover) error {
+	var err = fmt.Errorf("%w: %s", ErrPluginValueAlreadySet, "signApprover")
+	setSignApproverOnce.Do(func() {
+		signApprover = approver
+		err = nil
+	})
+
+	return err
+}
@@ -0,0 +1,381 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// stubRiskEngine - in-process sign approver endpoint. Transfers with value greater than limit denied,
+// transfers with value equal to limit require approval by two-phase signing
+type stubRiskEngine struct {
+	mu       sync.Mutex
+	limit    *big.Int
+	requests []*signApprovalRequest
+}
+
+func (e *stubRiskEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := &signApprovalRequest{}
+	err := json.NewDecoder(r.Body).Decode(request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	e.mu.Lock()
+	e.requests = append(e.requests, request)
+	e.mu.Unlock()
+
+	response := &httpSignApproverResponse{Decision: signDecisionAllow}
+	switch value := request.Transaction.Value.ToInt(); {
+	case value.Cmp(e.limit) > 0:
+		response.Decision, response.Reason = signDecisionDeny, "transfer limit exceeded"
+	case value.Cmp(e.limit) == 0 && !request.ApprovedByToken:
+		response.Decision, response.Reason = signDecisionRequireMore, "manual approval required"
+	}
+
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (e *stubRiskEngine) lastRequest() *signApprovalRequest {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.requests[len(e.requests)-1]
+}
+
+func newSignApproverTestPoolUnit(t *testing.T) (*mnemonicWalletUnit, *anypb.Any) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	return poolUnit, accountIdentity
+}
+
+func marshalTestTransfer(t *testing.T, value int64) []byte {
+	tx := types.NewTransaction(0, common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		big.NewInt(value), 21000, big.NewInt(1), nil)
+	txData, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	return txData
+}
+
+func TestHTTPSignApprover_Decisions(t *testing.T) {
+	riskEngine := &stubRiskEngine{limit: big.NewInt(1000)}
+	server := httptest.NewServer(riskEngine)
+	defer server.Close()
+
+	approver, err := newHTTPSignApprover(server.URL, "", time.Second, true)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create http sign approver", err)
+	}
+
+	signApprover = approver
+	defer func() {
+		signApprover = nil
+	}()
+
+	poolUnit, accountIdentity := newSignApproverTestPoolUnit(t)
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, marshalTestTransfer(t, 10))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign allowed transaction", err)
+	}
+
+	request := riskEngine.lastRequest()
+	if request.WalletUUID != poolUnit.mnemonicWalletUUID || request.DerivationPath != "m/44'/60'/7'/8/9" ||
+		request.Kind != signingKindTransaction ||
+		request.Address != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" ||
+		request.ChainID.ToInt().Cmp(poolUnit.dataSigner.ChainID()) != 0 ||
+		*request.Transaction.To != common.HexToAddress("0x0000000000000000000000000000000000000001") {
+		t.Fatalf("%s", "wrong decoded signing request")
+	}
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, marshalTestTransfer(t, 1001))
+	if !errors.Is(err, ErrSignatureDenied) {
+		t.Fatalf("%s", "transaction denied by approver must not be signed")
+	}
+
+	txData := marshalTestTransfer(t, 1000)
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if !errors.Is(err, ErrSignatureApprovalRequired) {
+		t.Fatalf("%s", "transaction which requires more approval must not be signed")
+	}
+
+	verifier := &stubApprovalTokenVerifier{approvedTokens: make(map[string]bool)}
+	approvalTokenVerifier = verifier
+	defer func() {
+		approvalTokenVerifier = nil
+	}()
+
+	preparedData, err := poolUnit.PrepareSign(context.Background(), accountIdentity, txData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prepare signature", err)
+	}
+
+	prepared := &preparedSignature{}
+	err = json.Unmarshal(preparedData, prepared)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal prepared signature", err)
+	}
+
+	verifier.approvedTokens[prepared.ApprovalToken] = true
+
+	_, _, err = poolUnit.CommitSign(context.Background(), accountIdentity, txData, prepared.ApprovalToken)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign transaction approved by two-phase signing", err)
+	}
+
+	if !riskEngine.lastRequest().ApprovedByToken {
+		t.Fatalf("%s", "two-phase signing request not marked as approved by token")
+	}
+}
+
+func TestHTTPSignApprover_FailMode(t *testing.T) {
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(&httpSignApproverResponse{Decision: signDecisionAllow})
+	}))
+	defer slowServer.Close()
+
+	brokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer brokenServer.Close()
+
+	wrongDecisionServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&httpSignApproverResponse{Decision: "maybe"})
+	}))
+	defer wrongDecisionServer.Close()
+
+	malformedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"decision":`))
+	}))
+	defer malformedServer.Close()
+
+	closedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedServer.Close()
+
+	poolUnit, accountIdentity := newSignApproverTestPoolUnit(t)
+	txData := marshalTestTransfer(t, 10)
+
+	defer func() {
+		signApprover = nil
+	}()
+
+	testCases := []struct {
+		name       string
+		endpoint   string
+		failClosed bool
+		expected   error
+	}{
+		{name: "timeout, fail-closed", endpoint: slowServer.URL, failClosed: true,
+			expected: ErrSignApproverUnavailable},
+		{name: "timeout, fail-open", endpoint: slowServer.URL, failClosed: false},
+		// responded endpoint never skipped
+		{name: "error status, fail-closed", endpoint: brokenServer.URL, failClosed: true,
+			expected: ErrSignApproverWrongResponse},
+		{name: "error status, fail-open", endpoint: brokenServer.URL, failClosed: false,
+			expected: ErrSignApproverWrongResponse},
+		{name: "malformed response, fail-open", endpoint: malformedServer.URL, failClosed: false,
+			expected: ErrSignApproverWrongResponse},
+		{name: "unknown decision, fail-closed", endpoint: wrongDecisionServer.URL, failClosed: true,
+			expected: ErrSignApproverWrongDecision},
+		{name: "unknown decision, fail-open", endpoint: wrongDecisionServer.URL, failClosed: false,
+			expected: ErrSignApproverWrongDecision},
+		{name: "unavailable, fail-open", endpoint: closedServer.URL, failClosed: false},
+		{name: "unavailable, fail-closed", endpoint: closedServer.URL, failClosed: true,
+			expected: ErrSignApproverUnavailable},
+	}
+
+	for _, testCase := range testCases {
+		approver, err := newHTTPSignApprover(testCase.endpoint, "", 50*time.Millisecond, testCase.failClosed)
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to create http sign approver", err)
+		}
+
+		signApprover = approver
+
+		_, _, err = poolUnit.SignData(context.Background(), accountIdentity, txData)
+		if testCase.expected == nil && err != nil {
+			t.Fatalf("%s: %s: %e", "unable to sign transaction", testCase.name, err)
+		}
+
+		if testCase.expected != nil && !errors.Is(err, testCase.expected) {
+			t.Fatalf("%s: %s", "wrong error of sign approver", testCase.name)
+		}
+	}
+
+	_, err := newHTTPSignApprover("unix:///var/run/approver.sock", "", time.Second, true)
+	if !errors.Is(err, ErrSignApproverWrongConfig) {
+		t.Fatalf("%s", "endpoint with wrong scheme must be refused")
+	}
+}
+
+func TestHTTPSignApprover_UnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "approver.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to listen unix socket", err)
+	}
+
+	riskEngine := &stubRiskEngine{limit: big.NewInt(1000)}
+	server := httptest.NewUnstartedServer(riskEngine)
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	approver, err := newHTTPSignApprover("http://approver/v1/approve", socketPath, time.Second, true)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create http sign approver", err)
+	}
+
+	signApprover = approver
+	defer func() {
+		signApprover = nil
+	}()
+
+	poolUnit, accountIdentity := newSignApproverTestPoolUnit(t)
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, marshalTestTransfer(t, 10))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign transaction allowed over unix socket", err)
+	}
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, marshalTestTransfer(t, 1001))
+	if !errors.Is(err, ErrSignatureDenied) {
+		t.Fatalf("%s", "transaction denied over unix socket must not be signed")
+	}
+}
+
+// lockCheckingApprover - sign approver, which denies signatures if pool unit lock held while approval requested
+type lockCheckingApprover struct {
+	poolUnit *mnemonicWalletUnit
+}
+
+func (a *lockCheckingApprover) ApproveSign(_ context.Context, _ []byte) (string, string, error) {
+	if !a.poolUnit.mu.TryLock() {
+		return signDecisionDeny, "unit lock held by signing call", nil
+	}
+	a.poolUnit.mu.Unlock()
+
+	return signDecisionAllow, "", nil
+}
+
+func TestSignApprover_CalledWithoutUnitLock(t *testing.T) {
+	poolUnit, accountIdentity := newSignApproverTestPoolUnit(t)
+
+	signApprover = &lockCheckingApprover{poolUnit: poolUnit}
+	defer func() {
+		signApprover = nil
+	}()
+
+	_, _, err := poolUnit.SignData(context.Background(), accountIdentity, marshalTestTransfer(t, 10))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data", err)
+	}
+
+	_, _, _, err = poolUnit.SignDataWithMetadata(context.Background(), accountIdentity, marshalTestTransfer(t, 10))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data with metadata", err)
+	}
+
+	_, _, err = poolUnit.SignTransfer(context.Background(), accountIdentity, []byte(`{
+		"kind": "native",
+		"to": "0x0000000000000000000000000000000000000001",
+		"amount": "10",
+		"nonce": "1",
+		"gasLimit": "21000",
+		"gasPrice": "1000000000"
+	}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign transfer", err)
+	}
+
+	summaryData, err := poolUnit.SignSweepBatch(context.Background(), []byte(`{
+		"treasury": "0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8",
+		"feePolicy": {"gasLimit": "21000", "gasPrice": "1000000000"},
+		"sources": [
+			{"accountIndex": 7, "internalIndex": 8, "addressIndex": 9, "balance": "1000000000000000000"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign sweep batch", err)
+	}
+
+	summary := &sweepSummary{}
+	err = json.Unmarshal(summaryData, summary)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal sweep summary", err)
+	}
+
+	if summary.SignedCount != 1 {
+		t.Fatalf("%s: %s", "sweep transaction not signed", summaryData)
+	}
+}
@@ -0,0 +1,410 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	signingKindTransaction         = "transaction"
+	signingKindBeaconBlock         = "beaconBlock"
+	signingKindBeaconAttestation   = "beaconAttestation"
+	signingKindBeaconVoluntaryExit = "beaconVoluntaryExit"
+	signingKindDepositMessage      = "depositMessage"
+)
+
+var (
+	// signingAudit - plugin-wide signing audit log, shared by all pool units. Optional
+	signingAudit        *signingAuditLog
+	setSigningAuditOnce = sync.Once{}
+
+	ErrAuditSinkWrongType = errors.New("audit sink must implement Append and LastRecord methods")
+	ErrAuditLogBroken     = errors.New("audit log hash chain broken")
+	ErrAuditWriteFailed   = errors.New("unable to write audit log record, signature discarded")
+)
+
+// AuditSink - storage of signing audit log records. Records passed to Append in chain order,
+// every record is JSON-encoded signingAuditRecord. LastRecord returns last appended record or nil
+// for empty storage, it's used for hash chain continuation after plugin restart
+type AuditSink interface {
+	Append(record []byte) error
+	LastRecord() ([]byte, error)
+}
+
+// signingAuditRecord - record of signing audit log, JSON format.
+// Hash - sha256 of JSON-encoded record with empty hash field, PrevHash - hash of previous record
+type signingAuditRecord struct {
+	Sequence       uint64 `json:"sequence"`
+	Timestamp      string `json:"timestamp"`
+	WalletUUID     string `json:"walletUUID"`
+	DerivationPath string `json:"derivationPath,omitempty"`
+	// KeyLabel - label of private key, only for private key pool units
+	KeyLabel string       `json:"keyLabel,omitempty"`
+	Address  string       `json:"address"`
+	ChainID  *hexutil.Big `json:"chainId,omitempty"`
+	Kind     string       `json:"kind"`
+	// Digest - transaction hash or signing root of signed object
+	Digest   common.Hash     `json:"digest"`
+	Summary  json.RawMessage `json:"summary,omitempty"`
+	PrevHash common.Hash     `json:"prevHash"`
+	Hash     common.Hash     `json:"hash"`
+}
+
+func (r *signingAuditRecord) computeHash() (common.Hash, error) {
+	recordCopy := *r
+	recordCopy.Hash = common.Hash{}
+
+	data, err := json.Marshal(&recordCopy)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return sha256.Sum256(data), nil
+}
+
+// signingAuditLog - hash chain of signing records over audit sink
+type signingAuditLog struct {
+	mu *sync.Mutex
+
+	sink     AuditSink
+	sequence uint64
+	lastHash common.Hash
+}
+
+func newSigningAuditLog(sink AuditSink) (*signingAuditLog, error) {
+	auditLog := &signingAuditLog{
+		mu:   &sync.Mutex{},
+		sink: sink,
+	}
+
+	lastRecordData, err := sink.LastRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(lastRecordData)) == 0 {
+		return auditLog, nil
+	}
+
+	lastRecord, err := unmarshalAuditRecord(lastRecordData)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog.sequence = lastRecord.Sequence
+	auditLog.lastHash = lastRecord.Hash
+
+	return auditLog, nil
+}
+
+func (l *signingAuditLog) append(record *signingAuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.Sequence = l.sequence + 1
+	record.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	record.PrevHash = l.lastHash
+
+	recordHash, err := record.computeHash()
+	if err != nil {
+		return err
+	}
+
+	record.Hash = recordHash
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	err = l.sink.Append(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrAuditWriteFailed, err.Error())
+	}
+
+	l.sequence = record.Sequence
+	l.lastHash = record.Hash
+
+	return nil
+}
+
+// fileAuditSink - local append-only file of audit records, one JSON-encoded record per line
+type fileAuditSink struct {
+	mu *sync.Mutex
+
+	path string
+	file *os.File
+}
+
+func newFileAuditSink(path string) (*fileAuditSink, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileAuditSink{
+		mu:   &sync.Mutex{},
+		path: path,
+		file: file,
+	}, nil
+}
+
+func (s *fileAuditSink) Append(record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.file.Write(append(record, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+func (s *fileAuditSink) LastRecord() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	return data[bytes.LastIndexByte(data, '\n')+1:], nil
+}
+
+// SetAuditSink - set plugin-wide sink of signing audit log. Sink must implement AuditSink interface.
+// Every signature of all pool units recorded to sink before return of signature - signature discarded
+// if record not written. Audit log can be set only once
+func SetAuditSink(sink interface{}) error {
+	auditSink, ok := sink.(AuditSink)
+	if !ok || auditSink == nil {
+		return ErrAuditSinkWrongType
+	}
+
+	return setAuditSink(auditSink)
+}
+
+// SetAuditLogFilePath - set local append-only file as sink of signing audit log.
+// Existing file verified before usage. Audit log can be set only once
+func SetAuditLogFilePath(path string) error {
+	logData, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	_, err = VerifyAuditLog(logData)
+	if err != nil {
+		return err
+	}
+
+	sink, err := newFileAuditSink(path)
+	if err != nil {
+		return err
+	}
+
+	return setAuditSink(sink)
+}
+
+func setAuditSink(sink AuditSink) error {
+	var err = fmt.Errorf("%w: %s", ErrPluginValueAlreadySet, "auditSink")
+	setSigningAuditOnce.Do(func() {
+		var auditLog *signingAuditLog
+		auditLog, err = newSigningAuditLog(sink)
+		if err != nil {
+			return
+		}
+
+		signingAudit = auditLog
+	})
+
+	return err
+}
+
+// VerifyAuditLog - verify hash chain of audit log - sequence numbers, previous record hashes and record hashes.
+// logData - audit records, one JSON-encoded record per line. Returns count of verified records
+func VerifyAuditLog(logData []byte) (uint64, error) {
+	var count uint64
+	prevHash := common.Hash{}
+
+	for _, line := range bytes.Split(logData, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		record, err := unmarshalAuditRecord(line)
+		if err != nil {
+			return count, err
+		}
+
+		if record.Sequence != count+1 {
+			return count, fmt.Errorf("%w: record %d has sequence %d", ErrAuditLogBroken, count+1,
+				record.Sequence)
+		}
+
+		if record.PrevHash != prevHash {
+			return count, fmt.Errorf("%w: record %d previous hash not equal with hash of previous record",
+				ErrAuditLogBroken, record.Sequence)
+		}
+
+		prevHash = record.Hash
+		count++
+	}
+
+	return count, nil
+}
+
+// unmarshalAuditRecord - unmarshal record and verify its hash
+func unmarshalAuditRecord(recordData []byte) (*signingAuditRecord, error) {
+	record := &signingAuditRecord{}
+	err := json.Unmarshal(recordData, record)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAuditLogBroken, err.Error())
+	}
+
+	recordHash, err := record.computeHash()
+	if err != nil {
+		return nil, err
+	}
+
+	if recordHash != record.Hash {
+		return nil, fmt.Errorf("%w: record %d hash not equal with content", ErrAuditLogBroken, record.Sequence)
+	}
+
+	return record, nil
+}
+
+// signingSource - wallet and account of signature
+type signingSource struct {
+	walletUUID     string
+	derivationPath string
+	keyLabel       string
+	address        common.Address
+}
+
+// auditSignature - append record to audit log, if it's configured
+func auditSignature(record *signingAuditRecord, summary interface{}) error {
+	if signingAudit == nil {
+		return nil
+	}
+
+	if summary != nil {
+		summaryData, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+
+		record.Summary = summaryData
+	}
+
+	return signingAudit.append(record)
+}
+
+// auditTxSignature - append record of signed transaction with decoded transaction summary
+func auditTxSignature(source *signingSource, signedTx *types.Transaction) error {
+	if signingAudit == nil {
+		return nil
+	}
+
+	summary := &signedTxSummary{}
+	err := summary.fillFromTx(source.address, signedTx)
+	if err != nil {
+		return err
+	}
+
+	return auditSignature(&signingAuditRecord{
+		WalletUUID:     source.walletUUID,
+		DerivationPath: source.derivationPath,
+		KeyLabel:       source.keyLabel,
+		Address:        source.address.Hex(),
+		ChainID:        (*hexutil.Big)(signedTx.ChainId()),
+		Kind:           signingKindTransaction,
+		Digest:         signedTx.Hash(),
+	}, summary)
+}
+
+// auditCeloTxSignature - same as auditTxSignature for Celo CIP-64 transaction
+func auditCeloTxSignature(source *signingSource, signedTx *celoDynamicFeeTxV2) error {
+	if signingAudit == nil {
+		return nil
+	}
+
+	summary := &signedTxSummary{}
+	err := summary.fillFromCeloTx(source.address, signedTx)
+	if err != nil {
+		return err
+	}
+
+	return auditSignature(&signingAuditRecord{
+		WalletUUID:     source.walletUUID,
+		DerivationPath: source.derivationPath,
+		KeyLabel:       source.keyLabel,
+		Address:        source.address.Hex(),
+		ChainID:        (*hexutil.Big)(new(big.Int).Set(signedTx.ChainID)),
+		Kind:           signingKindTransaction,
+		Digest:         summary.TxHash,
+	}, summary)
+}
+
+// accountSigningSource - audit source of mnemonic wallet account, derivation path same as wallet account path
+func (u *mnemonicWalletUnit) accountSigningSource(account, change, index uint32, address string) *signingSource {
+	return &signingSource{
+		walletUUID:     u.mnemonicWalletUUID,
+		derivationPath: fmt.Sprintf("m/%d'/%d'/%d'/%d/%d", Bip44Purpose, pluginCoinType, account, change, index),
+		address:        common.HexToAddress(address),
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type failingAuditSink struct{}
+
+func (s *failingAuditSink) Append(_ []byte) error {
+	return errors.New("audit storage unavailable")
+}
+
+func (s *failingAuditSink) LastRecord() ([]byte, error) {
+	return nil, nil
+}
+
+func TestSigningAuditLog_FileSink(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	logPath := filepath.Join(t.TempDir(), "audit", "signing_audit.log")
+	sink, err := newFileAuditSink(logPath)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create audit file sink", err)
+	}
+
+	signingAudit, err = newSigningAuditLog(sink)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create signing audit log", err)
+	}
+	defer func() {
+		signingAudit = nil
+	}()
+
+	walletUUID := uuid.NewString()
+	poolUnitIntrf, err := NewPoolUnit(walletUUID, mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	for nonce := uint64(0); nonce != 2; nonce++ {
+		tx := types.NewTransaction(nonce, common.HexToAddress("0x0000000000000000000000000000000000000001"),
+			nil, 21000, nil, nil)
+		txData, loopErr := tx.MarshalBinary()
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to marshal transaction", loopErr)
+		}
+
+		_, _, loopErr = poolUnit.SignData(context.Background(), accountIdentity, txData)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to sign transaction", loopErr)
+		}
+	}
+
+	logData, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to read audit log file", err)
+	}
+
+	count, err := VerifyAuditLog(logData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to verify audit log", err)
+	}
+
+	if count != 2 {
+		t.Fatalf("%s: %d", "wrong count of audit records", count)
+	}
+
+	lastRecordData, err := sink.LastRecord()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to read last audit record", err)
+	}
+
+	lastRecord, err := unmarshalAuditRecord(lastRecordData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal last audit record", err)
+	}
+
+	if lastRecord.WalletUUID != walletUUID || lastRecord.Kind != signingKindTransaction ||
+		lastRecord.DerivationPath != "m/44'/60'/7'/8/9" ||
+		lastRecord.Address != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" {
+		t.Fatalf("%s", "wrong content of audit record")
+	}
+
+	// restart of plugin - chain continued from last record of file
+	restoredLog, err := newSigningAuditLog(sink)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to restore signing audit log", err)
+	}
+
+	if restoredLog.sequence != 2 || restoredLog.lastHash != lastRecord.Hash {
+		t.Fatalf("%s", "signing audit log state not restored from sink")
+	}
+
+	lines := bytes.Split(bytes.TrimRight(logData, "\n"), []byte("\n"))
+
+	tamperedRecord := bytes.Replace(lines[0], []byte(`"nonce":"0x0"`), []byte(`"nonce":"0x5"`), 1)
+	if bytes.Equal(tamperedRecord, lines[0]) {
+		t.Fatalf("%s", "unable to tamper audit record")
+	}
+
+	testCases := []struct {
+		name    string
+		logData []byte
+	}{
+		{name: "modified record", logData: bytes.Join([][]byte{tamperedRecord, lines[1]}, []byte("\n"))},
+		{name: "removed record", logData: lines[1]},
+		{name: "reordered records", logData: bytes.Join([][]byte{lines[1], lines[0]}, []byte("\n"))},
+	}
+
+	for _, testCase := range testCases {
+		_, err = VerifyAuditLog(testCase.logData)
+		if !errors.Is(err, ErrAuditLogBroken) {
+			t.Fatalf("%s: %s", "broken audit log not detected", testCase.name)
+		}
+	}
+}
+
+func TestSigningAuditLog_WriteFailed(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	err := SetAuditSink(struct{}{})
+	if !errors.Is(err, ErrAuditSinkWrongType) {
+		t.Fatalf("%s", "sink without AuditSink methods must be refused")
+	}
+
+	signingAudit, err = newSigningAuditLog(&failingAuditSink{})
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create signing audit log", err)
+	}
+	defer func() {
+		signingAudit = nil
+	}()
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		nil, 21000, nil, nil)
+	txData, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	_, signedData, err := poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if !errors.Is(err, ErrAuditWriteFailed) {
+		t.Fatalf("%s", "signature must be discarded if audit record not written")
+	}
+
+	if signedData != nil {
+		t.Fatalf("%s", "signed data returned without audit record")
+	}
+}
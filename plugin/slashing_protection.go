@@ -0,0 +1,603 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// slashingProtectionInterchangeVersion - supported EIP-3076 interchange format version
+	slashingProtectionInterchangeVersion = "5"
+
+	slashingProtectionFileMode = 0o600
+	slashingProtectionDirMode  = 0o700
+)
+
+var (
+	// slashingProtection - plugin-wide slashing protection database, shared by all pool units
+	slashingProtection        *slashingProtectionDB
+	setSlashingProtectionOnce = sync.Once{}
+
+	ErrSlashingProtectionNotConfigured    = errors.New("slashing protection database not configured")
+	ErrSlashingProtectionGenesisMismatch  = errors.New("slashing protection genesis validators root mismatch")
+	ErrSlashingProtectionWrongInterchange = errors.New("wrong slashing protection interchange data")
+	ErrSlashableBlock                     = errors.New("slashable block proposal")
+	ErrSlashableAttestation               = errors.New("slashable attestation")
+)
+
+// slashingProtectionInterchange - EIP-3076 interchange format, complete form.
+// Same format used for local database file
+type slashingProtectionInterchange struct {
+	Metadata *slashingProtectionMetadata `json:"metadata"`
+	Data     []*slashingProtectionData   `json:"data"`
+}
+
+type slashingProtectionMetadata struct {
+	InterchangeFormatVersion string       `json:"interchange_format_version"`
+	GenesisValidatorsRoot    *common.Hash `json:"genesis_validators_root"`
+}
+
+type slashingProtectionData struct {
+	Pubkey             hexutil.Bytes                    `json:"pubkey"`
+	SignedBlocks       []*slashingProtectionBlock       `json:"signed_blocks"`
+	SignedAttestations []*slashingProtectionAttestation `json:"signed_attestations"`
+}
+
+type slashingProtectionBlock struct {
+	Slot        string       `json:"slot"`
+	SigningRoot *common.Hash `json:"signing_root,omitempty"`
+}
+
+type slashingProtectionAttestation struct {
+	SourceEpoch string       `json:"source_epoch"`
+	TargetEpoch string       `json:"target_epoch"`
+	SigningRoot *common.Hash `json:"signing_root,omitempty"`
+}
+
+type signedBlockRecord struct {
+	slot        uint64
+	signingRoot *common.Hash
+}
+
+type signedAttestationRecord struct {
+	sourceEpoch uint64
+	targetEpoch uint64
+	signingRoot *common.Hash
+}
+
+// slashingProtectionRecords - per-validator EIP-3076 watermarks. History of signed objects pruned
+// to watermarks, as EIP-3076 allows: signing of block at or below watermark slot and attestation
+// with source or target epoch below watermark epochs refused, so older records not needed
+type slashingProtectionRecords struct {
+	// lastBlock - block with highest signed slot
+	lastBlock *signedBlockRecord
+	// lastAttestation - highest signed source and target epochs. Signing root is empty if
+	// highest epochs belong to different attestations
+	lastAttestation *signedAttestationRecord
+}
+
+func isSameSigningRoot(left, right *common.Hash) bool {
+	return left != nil && right != nil && *left == *right
+}
+
+// addBlock - move block watermark. Conflicting records of same slot lose signing root,
+// so repeat signing of this slot refused
+func (r *slashingProtectionRecords) addBlock(block *signedBlockRecord) {
+	last := r.lastBlock
+
+	switch {
+	case last == nil || block.slot > last.slot:
+		r.lastBlock = &signedBlockRecord{slot: block.slot, signingRoot: block.signingRoot}
+
+	case block.slot == last.slot && !isSameSigningRoot(block.signingRoot, last.signingRoot):
+		r.lastBlock = &signedBlockRecord{slot: last.slot}
+	}
+}
+
+// addAttestation - move attestation watermarks to highest source and target epochs
+func (r *slashingProtectionRecords) addAttestation(attestation *signedAttestationRecord) {
+	last := r.lastAttestation
+	if last == nil {
+		r.lastAttestation = &signedAttestationRecord{
+			sourceEpoch: attestation.sourceEpoch,
+			targetEpoch: attestation.targetEpoch,
+			signingRoot: attestation.signingRoot,
+		}
+
+		return
+	}
+
+	switch {
+	case attestation.sourceEpoch == last.sourceEpoch && attestation.targetEpoch == last.targetEpoch:
+		if !isSameSigningRoot(attestation.signingRoot, last.signingRoot) {
+			r.lastAttestation = &signedAttestationRecord{sourceEpoch: last.sourceEpoch, targetEpoch: last.targetEpoch}
+		}
+
+	case attestation.sourceEpoch >= last.sourceEpoch && attestation.targetEpoch >= last.targetEpoch:
+		r.lastAttestation = &signedAttestationRecord{
+			sourceEpoch: attestation.sourceEpoch,
+			targetEpoch: attestation.targetEpoch,
+			signingRoot: attestation.signingRoot,
+		}
+
+	case attestation.sourceEpoch > last.sourceEpoch || attestation.targetEpoch > last.targetEpoch:
+		r.lastAttestation = &signedAttestationRecord{
+			sourceEpoch: max(attestation.sourceEpoch, last.sourceEpoch),
+			targetEpoch: max(attestation.targetEpoch, last.targetEpoch),
+		}
+	}
+}
+
+// slashingProtectionDB - EIP-3076 slashing protection database of BLS validator keys.
+// Every signing record persisted to local file before signature returned to caller
+type slashingProtectionDB struct {
+	mu sync.Mutex
+
+	path                  string
+	genesisValidatorsRoot *common.Hash
+	records               map[[blsPublicKeyLength]byte]*slashingProtectionRecords
+}
+
+// SetSlashingProtectionDBPath - set path of local slashing protection database file and load it.
+// Beacon chain signing refused until database configured. Can be set only once
+func SetSlashingProtectionDBPath(path string) error {
+	var err = fmt.Errorf("%w: %s", ErrPluginValueAlreadySet, "slashingProtectionDBPath")
+	setSlashingProtectionOnce.Do(func() {
+		var db *slashingProtectionDB
+		db, err = newSlashingProtectionDB(path)
+		if err != nil {
+			return
+		}
+
+		slashingProtection = db
+	})
+
+	return err
+}
+
+// ImportSlashingProtection - merge EIP-3076 interchange data to slashing protection database
+func ImportSlashingProtection(interchangeData []byte) error {
+	if slashingProtection == nil {
+		return ErrSlashingProtectionNotConfigured
+	}
+
+	return slashingProtection.importInterchange(interchangeData)
+}
+
+// ExportSlashingProtection - returns slashing protection database as EIP-3076 interchange data
+func ExportSlashingProtection() ([]byte, error) {
+	if slashingProtection == nil {
+		return nil, ErrSlashingProtectionNotConfigured
+	}
+
+	return slashingProtection.exportInterchange()
+}
+
+func newSlashingProtectionDB(path string) (*slashingProtectionDB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty database path", ErrSlashingProtectionNotConfigured)
+	}
+
+	db := &slashingProtectionDB{
+		path:    path,
+		records: make(map[[blsPublicKeyLength]byte]*slashingProtectionRecords),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return db, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.mergeInterchange(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// checkBlock - check and persist block proposal. Refuse double proposals and proposals with slot
+// lower than or equal to block watermark. Same block signing repeat allowed
+func (db *slashingProtectionDB) checkBlock(genesisValidatorsRoot common.Hash,
+	pubKey [blsPublicKeyLength]byte,
+	slot uint64,
+	signingRoot common.Hash,
+) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	err := db.checkGenesisValidatorsRoot(genesisValidatorsRoot)
+	if err != nil {
+		return err
+	}
+
+	records := db.records[pubKey]
+	if records == nil {
+		records = &slashingProtectionRecords{}
+	}
+
+	if last := records.lastBlock; last != nil {
+		switch {
+		case slot == last.slot && isSameSigningRoot(last.signingRoot, &signingRoot):
+			return nil
+
+		case slot == last.slot:
+			return fmt.Errorf("%w: double proposal at slot %d", ErrSlashableBlock, slot)
+
+		case slot < last.slot:
+			return fmt.Errorf("%w: slot %d lower than already signed slot %d",
+				ErrSlashableBlock, slot, last.slot)
+		}
+	}
+
+	return db.insert(genesisValidatorsRoot, pubKey, &slashingProtectionRecords{
+		lastBlock:       &signedBlockRecord{slot: slot, signingRoot: &signingRoot},
+		lastAttestation: records.lastAttestation,
+	})
+}
+
+// checkAttestation - check and persist attestation. Refuse double votes and attestations
+// below attestation watermarks - surround votes always below watermarks. Same attestation signing repeat allowed
+func (db *slashingProtectionDB) checkAttestation(genesisValidatorsRoot common.Hash,
+	pubKey [blsPublicKeyLength]byte,
+	sourceEpoch, targetEpoch uint64,
+	signingRoot common.Hash,
+) error {
+	if sourceEpoch > targetEpoch {
+		return fmt.Errorf("%w: source epoch %d greater than target epoch %d",
+			ErrSlashableAttestation, sourceEpoch, targetEpoch)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	err := db.checkGenesisValidatorsRoot(genesisValidatorsRoot)
+	if err != nil {
+		return err
+	}
+
+	records := db.records[pubKey]
+	if records == nil {
+		records = &slashingProtectionRecords{}
+	}
+
+	if last := records.lastAttestation; last != nil {
+		switch {
+		case targetEpoch == last.targetEpoch && sourceEpoch == last.sourceEpoch &&
+			isSameSigningRoot(last.signingRoot, &signingRoot):
+			return nil
+
+		case targetEpoch == last.targetEpoch:
+			return fmt.Errorf("%w: double vote at target epoch %d", ErrSlashableAttestation, targetEpoch)
+
+		case sourceEpoch < last.sourceEpoch || targetEpoch < last.targetEpoch:
+			return fmt.Errorf("%w: attestation %d-%d lower than already signed %d-%d",
+				ErrSlashableAttestation, sourceEpoch, targetEpoch, last.sourceEpoch, last.targetEpoch)
+		}
+	}
+
+	return db.insert(genesisValidatorsRoot, pubKey, &slashingProtectionRecords{
+		lastBlock: records.lastBlock,
+		lastAttestation: &signedAttestationRecord{
+			sourceEpoch: sourceEpoch,
+			targetEpoch: targetEpoch,
+			signingRoot: &signingRoot,
+		},
+	})
+}
+
+func (db *slashingProtectionDB) checkGenesisValidatorsRoot(genesisValidatorsRoot common.Hash) error {
+	if db.genesisValidatorsRoot != nil && *db.genesisValidatorsRoot != genesisValidatorsRoot {
+		return fmt.Errorf("%w: database root %s, requested root %s", ErrSlashingProtectionGenesisMismatch,
+			db.genesisValidatorsRoot.Hex(), genesisValidatorsRoot.Hex())
+	}
+
+	return nil
+}
+
+// insert - replace records of public key and persist database. Previous state restored if persist failed
+func (db *slashingProtectionDB) insert(genesisValidatorsRoot common.Hash,
+	pubKey [blsPublicKeyLength]byte,
+	records *slashingProtectionRecords,
+) error {
+	prevGenesisValidatorsRoot := db.genesisValidatorsRoot
+	prevRecords, isExists := db.records[pubKey]
+
+	db.genesisValidatorsRoot = &genesisValidatorsRoot
+	db.records[pubKey] = records
+
+	err := db.persist()
+	if err != nil {
+		db.genesisValidatorsRoot = prevGenesisValidatorsRoot
+		if isExists {
+			db.records[pubKey] = prevRecords
+		} else {
+			delete(db.records, pubKey)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// persist - atomic write of database to local file: temporary file in same directory synced to disk
+// and renamed over database file, then directory synced to make rename durable
+func (db *slashingProtectionDB) persist() error {
+	data, err := db.marshalInterchange()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(db.path), slashingProtectionDirMode)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(db.path), filepath.Base(db.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Chmod(slashingProtectionFileMode)
+	}
+
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+
+	closeErr := tmpFile.Close()
+	if err != nil {
+		return err
+	}
+
+	if closeErr != nil {
+		return closeErr
+	}
+
+	err = os.Rename(tmpFile.Name(), db.path)
+	if err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(db.path))
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	err = dir.Sync()
+	closeErr := dir.Close()
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+func (db *slashingProtectionDB) importInterchange(interchangeData []byte) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	prevGenesisValidatorsRoot := db.genesisValidatorsRoot
+	prevRecords := make(map[[blsPublicKeyLength]byte]*slashingProtectionRecords, len(db.records))
+	for pubKey, records := range db.records {
+		prevRecords[pubKey] = records
+	}
+
+	err := db.mergeInterchange(interchangeData)
+	if err == nil {
+		err = db.persist()
+	}
+
+	if err != nil {
+		db.genesisValidatorsRoot = prevGenesisValidatorsRoot
+		db.records = prevRecords
+
+		return err
+	}
+
+	return nil
+}
+
+func (db *slashingProtectionDB) exportInterchange() ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.marshalInterchange()
+}
+
+// mergeInterchange - validate interchange data and merge its records to database watermarks
+func (db *slashingProtectionDB) mergeInterchange(interchangeData []byte) error {
+	interchange := &slashingProtectionInterchange{}
+	err := json.Unmarshal(interchangeData, interchange)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSlashingProtectionWrongInterchange, err.Error())
+	}
+
+	if interchange.Metadata == nil || interchange.Metadata.GenesisValidatorsRoot == nil {
+		return fmt.Errorf("%w: metadata missing", ErrSlashingProtectionWrongInterchange)
+	}
+
+	if interchange.Metadata.InterchangeFormatVersion != slashingProtectionInterchangeVersion {
+		return fmt.Errorf("%w: unsupported interchange format version %s",
+			ErrSlashingProtectionWrongInterchange, interchange.Metadata.InterchangeFormatVersion)
+	}
+
+	err = db.checkGenesisValidatorsRoot(*interchange.Metadata.GenesisValidatorsRoot)
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[[blsPublicKeyLength]byte]*slashingProtectionRecords)
+	for _, data := range interchange.Data {
+		if len(data.Pubkey) != blsPublicKeyLength {
+			return fmt.Errorf("%w: wrong public key %s", ErrSlashingProtectionWrongInterchange,
+				data.Pubkey.String())
+		}
+
+		var pubKey [blsPublicKeyLength]byte
+		copy(pubKey[:], data.Pubkey)
+
+		records := merged[pubKey]
+		if records == nil {
+			records = &slashingProtectionRecords{}
+			if existing := db.records[pubKey]; existing != nil {
+				records.lastBlock = existing.lastBlock
+				records.lastAttestation = existing.lastAttestation
+			}
+
+			merged[pubKey] = records
+		}
+
+		for _, block := range data.SignedBlocks {
+			slot, loopErr := parseInterchangeUint64(block.Slot)
+			if loopErr != nil {
+				return loopErr
+			}
+
+			records.addBlock(&signedBlockRecord{
+				slot:        slot,
+				signingRoot: block.SigningRoot,
+			})
+		}
+
+		for _, attestation := range data.SignedAttestations {
+			sourceEpoch, loopErr := parseInterchangeUint64(attestation.SourceEpoch)
+			if loopErr != nil {
+				return loopErr
+			}
+
+			targetEpoch, loopErr := parseInterchangeUint64(attestation.TargetEpoch)
+			if loopErr != nil {
+				return loopErr
+			}
+
+			if sourceEpoch > targetEpoch {
+				return fmt.Errorf("%w: source epoch %d greater than target epoch %d",
+					ErrSlashingProtectionWrongInterchange, sourceEpoch, targetEpoch)
+			}
+
+			records.addAttestation(&signedAttestationRecord{
+				sourceEpoch: sourceEpoch,
+				targetEpoch: targetEpoch,
+				signingRoot: attestation.SigningRoot,
+			})
+		}
+	}
+
+	genesisValidatorsRoot := *interchange.Metadata.GenesisValidatorsRoot
+	db.genesisValidatorsRoot = &genesisValidatorsRoot
+	for pubKey, records := range merged {
+		db.records[pubKey] = records
+	}
+
+	return nil
+}
+
+func (db *slashingProtectionDB) marshalInterchange() ([]byte, error) {
+	interchange := &slashingProtectionInterchange{
+		Metadata: &slashingProtectionMetadata{
+			InterchangeFormatVersion: slashingProtectionInterchangeVersion,
+			GenesisValidatorsRoot:    db.genesisValidatorsRoot,
+		},
+		Data: make([]*slashingProtectionData, 0, len(db.records)),
+	}
+
+	if interchange.Metadata.GenesisValidatorsRoot == nil {
+		interchange.Metadata.GenesisValidatorsRoot = &common.Hash{}
+	}
+
+	for pubKey, records := range db.records {
+		// pruned history exported in minimal form - one record of every watermark
+		data := &slashingProtectionData{
+			Pubkey:             append([]byte{}, pubKey[:]...),
+			SignedBlocks:       make([]*slashingProtectionBlock, 0, 1),
+			SignedAttestations: make([]*slashingProtectionAttestation, 0, 1),
+		}
+
+		if block := records.lastBlock; block != nil {
+			data.SignedBlocks = append(data.SignedBlocks, &slashingProtectionBlock{
+				Slot:        strconv.FormatUint(block.slot, 10),
+				SigningRoot: block.signingRoot,
+			})
+		}
+
+		if attestation := records.lastAttestation; attestation != nil {
+			data.SignedAttestations = append(data.SignedAttestations, &slashingProtectionAttestation{
+				SourceEpoch: strconv.FormatUint(attestation.sourceEpoch, 10),
+				TargetEpoch: strconv.FormatUint(attestation.targetEpoch, 10),
+				SigningRoot: attestation.signingRoot,
+			})
+		}
+
+		interchange.Data = append(interchange.Data, data)
+	}
+
+	sort.Slice(interchange.Data, func(i, j int) bool {
+		return bytes.Compare(interchange.Data[i].Pubkey, interchange.Data[j].Pubkey) < 0
+	})
+
+	return json.Marshal(interchange)
+}
+
+func parseInterchangeUint64(value string) (uint64, error) {
+	result, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: wrong number %s", ErrSlashingProtectionWrongInterchange, value)
+	}
+
+	return result, nil
+}
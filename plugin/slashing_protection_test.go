@@ -0,0 +1,288 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSlashingProtectionDB_CheckBlock(t *testing.T) {
+	type testCase struct {
+		Slot          uint64
+		SigningRoot   common.Hash
+		ExpectedError error
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "slashing_protection.json")
+	genesisValidatorsRoot := common.HexToHash("0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673")
+
+	db, err := newSlashingProtectionDB(dbPath)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create slashing protection database", err)
+	}
+
+	var pubKey [blsPublicKeyLength]byte
+	pubKey[0] = 0xa1
+
+	testCases := []*testCase{
+		{Slot: 10, SigningRoot: common.HexToHash("0x0a"), ExpectedError: nil},
+		// same block repeat
+		{Slot: 10, SigningRoot: common.HexToHash("0x0a"), ExpectedError: nil},
+		// double proposal
+		{Slot: 10, SigningRoot: common.HexToHash("0x0b"), ExpectedError: ErrSlashableBlock},
+		{Slot: 9, SigningRoot: common.HexToHash("0x09"), ExpectedError: ErrSlashableBlock},
+		{Slot: 11, SigningRoot: common.HexToHash("0x0b"), ExpectedError: nil},
+	}
+
+	for _, tCase := range testCases {
+		loopErr := db.checkBlock(genesisValidatorsRoot, pubKey, tCase.Slot, tCase.SigningRoot)
+		if !errors.Is(loopErr, tCase.ExpectedError) {
+			t.Fatalf("%s: slot %d", "block check result not equal with expected", tCase.Slot)
+		}
+	}
+
+	err = db.checkBlock(common.HexToHash("0x01"), pubKey, 12, common.HexToHash("0x0c"))
+	if !errors.Is(err, ErrSlashingProtectionGenesisMismatch) {
+		t.Fatalf("%s", "other genesis validators root must not be accepted")
+	}
+
+	// records must be persisted
+	reloadedDB, err := newSlashingProtectionDB(dbPath)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to reload slashing protection database", err)
+	}
+
+	err = reloadedDB.checkBlock(genesisValidatorsRoot, pubKey, 11, common.HexToHash("0x0c"))
+	if !errors.Is(err, ErrSlashableBlock) {
+		t.Fatalf("%s", "double proposal must be refused after database reload")
+	}
+}
+
+func TestSlashingProtectionDB_CheckAttestation(t *testing.T) {
+	type testCase struct {
+		SourceEpoch   uint64
+		TargetEpoch   uint64
+		SigningRoot   common.Hash
+		ExpectedError error
+	}
+
+	genesisValidatorsRoot := common.HexToHash("0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673")
+
+	db, err := newSlashingProtectionDB(filepath.Join(t.TempDir(), "slashing_protection.json"))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create slashing protection database", err)
+	}
+
+	var pubKey [blsPublicKeyLength]byte
+	pubKey[0] = 0xa1
+
+	testCases := []*testCase{
+		{SourceEpoch: 1, TargetEpoch: 2, SigningRoot: common.HexToHash("0x01"), ExpectedError: nil},
+		// same attestation repeat
+		{SourceEpoch: 1, TargetEpoch: 2, SigningRoot: common.HexToHash("0x01"), ExpectedError: nil},
+		// double vote
+		{SourceEpoch: 1, TargetEpoch: 2, SigningRoot: common.HexToHash("0x02"), ExpectedError: ErrSlashableAttestation},
+		{SourceEpoch: 2, TargetEpoch: 3, SigningRoot: common.HexToHash("0x03"), ExpectedError: nil},
+		// surround vote
+		{SourceEpoch: 0, TargetEpoch: 4, SigningRoot: common.HexToHash("0x04"), ExpectedError: ErrSlashableAttestation},
+		{SourceEpoch: 3, TargetEpoch: 10, SigningRoot: common.HexToHash("0x05"), ExpectedError: nil},
+		// surrounded vote
+		{SourceEpoch: 4, TargetEpoch: 6, SigningRoot: common.HexToHash("0x06"), ExpectedError: ErrSlashableAttestation},
+		// lower than already signed
+		{SourceEpoch: 3, TargetEpoch: 9, SigningRoot: common.HexToHash("0x07"), ExpectedError: ErrSlashableAttestation},
+		{SourceEpoch: 11, TargetEpoch: 10, SigningRoot: common.HexToHash("0x08"), ExpectedError: ErrSlashableAttestation},
+		{SourceEpoch: 10, TargetEpoch: 11, SigningRoot: common.HexToHash("0x09"), ExpectedError: nil},
+	}
+
+	for _, tCase := range testCases {
+		loopErr := db.checkAttestation(genesisValidatorsRoot, pubKey, tCase.SourceEpoch, tCase.TargetEpoch,
+			tCase.SigningRoot)
+		if !errors.Is(loopErr, tCase.ExpectedError) {
+			t.Fatalf("%s: %d-%d", "attestation check result not equal with expected",
+				tCase.SourceEpoch, tCase.TargetEpoch)
+		}
+	}
+}
+
+func TestSlashingProtectionDB_Interchange(t *testing.T) {
+	// EIP-3076 interchange example
+	interchangeData := []byte(`{"metadata":{"interchange_format_version":"5",` +
+		`"genesis_validators_root":"0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"},` +
+		`"data":[{"pubkey":"0xb845089a1457f811bfc000588fbb4e713669be8ce060ea6be3c6ece09afc3794106c91ca73acda5e5457122d58723bed",` +
+		`"signed_blocks":[{"slot":"81952",` +
+		`"signing_root":"0x4ff6f743a43f3b4f95350831aeaf0a122a1a392922c45d804280284a69eb850b"},{"slot":"81951"}],` +
+		`"signed_attestations":[{"source_epoch":"2290","target_epoch":"3007",` +
+		`"signing_root":"0x587d6a4f59a58fe24f406e0502413e77fe1babddee641fda30034ed37ecc884d"},` +
+		`{"source_epoch":"2290","target_epoch":"3008"}]}]}`)
+	genesisValidatorsRoot := common.HexToHash("0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673")
+
+	dbPath := filepath.Join(t.TempDir(), "slashing_protection.json")
+	db, err := newSlashingProtectionDB(dbPath)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create slashing protection database", err)
+	}
+
+	err = db.importInterchange(interchangeData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to import interchange data", err)
+	}
+
+	var pubKey [blsPublicKeyLength]byte
+	copy(pubKey[:], common.FromHex("0xb845089a1457f811bfc000588fbb4e713669be8ce060ea6be3c6ece09afc3794106c91ca73acda5e5457122d58723bed"))
+
+	// block without signing root can't be signed again
+	err = db.checkBlock(genesisValidatorsRoot, pubKey, 81951, common.HexToHash("0x01"))
+	if !errors.Is(err, ErrSlashableBlock) {
+		t.Fatalf("%s", "imported block slot must be refused")
+	}
+
+	err = db.checkBlock(genesisValidatorsRoot, pubKey, 81952,
+		common.HexToHash("0x4ff6f743a43f3b4f95350831aeaf0a122a1a392922c45d804280284a69eb850b"))
+	if err != nil {
+		t.Fatalf("%s: %e", "imported block repeat must be allowed", err)
+	}
+
+	err = db.checkAttestation(genesisValidatorsRoot, pubKey, 2290, 3008, common.HexToHash("0x01"))
+	if !errors.Is(err, ErrSlashableAttestation) {
+		t.Fatalf("%s", "imported attestation target must be refused")
+	}
+
+	err = db.checkAttestation(genesisValidatorsRoot, pubKey, 3008, 3009, common.HexToHash("0x01"))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to check attestation", err)
+	}
+
+	exportedData, err := db.exportInterchange()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to export interchange data", err)
+	}
+
+	interchange := &slashingProtectionInterchange{}
+	err = json.Unmarshal(exportedData, interchange)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal exported interchange data", err)
+	}
+
+	if interchange.Metadata.InterchangeFormatVersion != "5" ||
+		*interchange.Metadata.GenesisValidatorsRoot != genesisValidatorsRoot ||
+		len(interchange.Data) != 1 ||
+		len(interchange.Data[0].SignedBlocks) != 1 ||
+		len(interchange.Data[0].SignedAttestations) != 1 {
+		t.Fatalf("%s", "exported interchange data not equal with expected")
+	}
+
+	// history pruned to watermarks
+	if interchange.Data[0].SignedBlocks[0].Slot != "81952" ||
+		interchange.Data[0].SignedAttestations[0].SourceEpoch != "3008" ||
+		interchange.Data[0].SignedAttestations[0].TargetEpoch != "3009" {
+		t.Fatalf("%s", "exported watermarks not equal with expected")
+	}
+
+	tmpFiles, _ := filepath.Glob(dbPath + ".*.tmp")
+	if len(tmpFiles) != 0 {
+		t.Fatalf("%s", "temporary database files must be removed")
+	}
+
+	wrongInterchangeList := [][]byte{
+		[]byte(`{"metadata":{"interchange_format_version":"4",` +
+			`"genesis_validators_root":"0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"},"data":[]}`),
+		[]byte(`{"metadata":{"interchange_format_version":"5",` +
+			`"genesis_validators_root":"0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"},` +
+			`"data":[{"pubkey":"0xb845","signed_blocks":[],"signed_attestations":[]}]}`),
+		[]byte(`{"metadata":{"interchange_format_version":"5",` +
+			`"genesis_validators_root":"0x04700007fabc8282644aed6d1c7c9e21d38a03a0c4ba193f3afe428824b3a673"},` +
+			`"data":[{"pubkey":"0xb845089a1457f811bfc000588fbb4e713669be8ce060ea6be3c6ece09afc3794106c91ca73acda5e5457122d58723bed",` +
+			`"signed_blocks":[{"slot":"-1"}],"signed_attestations":[]}]}`),
+	}
+
+	for _, wrongData := range wrongInterchangeList {
+		loopErr := db.importInterchange(wrongData)
+		if !errors.Is(loopErr, ErrSlashingProtectionWrongInterchange) {
+			t.Fatalf("%s", "wrong interchange data must not be accepted")
+		}
+	}
+
+	err = db.importInterchange([]byte(`{"metadata":{"interchange_format_version":"5",` +
+		`"genesis_validators_root":"0x0000000000000000000000000000000000000000000000000000000000000001"},"data":[]}`))
+	if !errors.Is(err, ErrSlashingProtectionGenesisMismatch) {
+		t.Fatalf("%s", "interchange data of other network must not be accepted")
+	}
+
+	reloadedDB, err := newSlashingProtectionDB(dbPath)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to reload slashing protection database", err)
+	}
+
+	err = reloadedDB.checkAttestation(genesisValidatorsRoot, pubKey, 3008, 3009, common.HexToHash("0x02"))
+	if !errors.Is(err, ErrSlashableAttestation) {
+		t.Fatalf("%s", "double vote must be refused after database reload")
+	}
+}
+
+func TestSlashingProtectionRecords_Watermarks(t *testing.T) {
+	records := &slashingProtectionRecords{}
+
+	signingRoot := common.HexToHash("0x01")
+	otherSigningRoot := common.HexToHash("0x02")
+
+	records.addAttestation(&signedAttestationRecord{sourceEpoch: 5, targetEpoch: 10, signingRoot: &signingRoot})
+	records.addAttestation(&signedAttestationRecord{sourceEpoch: 3, targetEpoch: 8})
+	if records.lastAttestation.sourceEpoch != 5 || records.lastAttestation.targetEpoch != 10 ||
+		records.lastAttestation.signingRoot == nil {
+		t.Fatalf("%s", "lower attestation must not move watermarks")
+	}
+
+	// highest epochs of different attestations
+	records.addAttestation(&signedAttestationRecord{sourceEpoch: 7, targetEpoch: 9, signingRoot: &signingRoot})
+	if records.lastAttestation.sourceEpoch != 7 || records.lastAttestation.targetEpoch != 10 ||
+		records.lastAttestation.signingRoot != nil {
+		t.Fatalf("%s", "attestation watermarks not equal with expected")
+	}
+
+	records.addBlock(&signedBlockRecord{slot: 10, signingRoot: &signingRoot})
+	records.addBlock(&signedBlockRecord{slot: 9})
+	if records.lastBlock.slot != 10 || records.lastBlock.signingRoot == nil {
+		t.Fatalf("%s", "lower block must not move watermark")
+	}
+
+	// conflicting records of same slot
+	records.addBlock(&signedBlockRecord{slot: 10, signingRoot: &otherSigningRoot})
+	if records.lastBlock.slot != 10 || records.lastBlock.signingRoot != nil {
+		t.Fatalf("%s", "block watermark not equal with expected")
+	}
+}
@@ -0,0 +1,362 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// stealthKeysPurpose - purpose of dedicated fully hardened path of stealth keys
+	// m/5564'/coinType'/account'/change'/index', where change' - 0' for spending key and 1' for viewing key
+	stealthKeysPurpose        = 5564
+	stealthSpendingKeyChange  = 0
+	stealthViewingKeyChange   = 1
+	stealthMetaAddressPrefix  = "st:eth:0x"
+	stealthAnnouncementsLimit = 100_000
+)
+
+var (
+	ErrStealthWrongAccountParams = errors.New("wrong stealth keys account parameters")
+	ErrStealthWrongEphemeralKey  = errors.New("wrong stealth ephemeral public key")
+	ErrStealthWrongAnnouncements = errors.New("wrong stealth announcements")
+)
+
+// stealthAnnouncement - ERC-5564 Announcement event data, JSON format
+type stealthAnnouncement struct {
+	StealthAddress     common.Address `json:"stealthAddress"`
+	EphemeralPublicKey hexutil.Bytes  `json:"ephemeralPublicKey"`
+	// ViewTag - first byte of announcement metadata
+	ViewTag uint8 `json:"viewTag"`
+}
+
+// matchedStealthAnnouncement - announcement of payment to stealth address, owned by stealth keys
+type matchedStealthAnnouncement struct {
+	// Position - position of announcement in scanned list
+	Position           uint           `json:"position"`
+	StealthAddress     common.Address `json:"stealthAddress"`
+	EphemeralPublicKey hexutil.Bytes  `json:"ephemeralPublicKey"`
+}
+
+// stealthSharedSecretHash - ERC-5564 scheme 1 hashed shared secret - keccak256 of compressed ECDH point
+func stealthSharedSecretHash(privKey *ecdsa.PrivateKey, pubKey *btcec.PublicKey) *btcec.ModNScalar {
+	keyBytes := math.PaddedBigBytes(privKey.D, 32)
+	defer clear(keyBytes)
+
+	var privScalar btcec.ModNScalar
+	privScalar.SetByteSlice(keyBytes)
+	defer privScalar.Zero()
+
+	var pubPoint, sharedPoint btcec.JacobianPoint
+	pubKey.AsJacobian(&pubPoint)
+	btcec.ScalarMultNonConst(&privScalar, &pubPoint, &sharedPoint)
+	sharedPoint.ToAffine()
+
+	sharedSecret := btcec.NewPublicKey(&sharedPoint.X, &sharedPoint.Y).SerializeCompressed()
+	defer clear(sharedSecret)
+
+	sharedSecretHash := crypto.Keccak256(sharedSecret)
+	defer clear(sharedSecretHash)
+
+	// hash reduced modulo curve order
+	var hashScalar btcec.ModNScalar
+	hashScalar.SetByteSlice(sharedSecretHash)
+
+	return &hashScalar
+}
+
+// stealthPublicKey - P_stealth = P_spend + G*s_h
+func stealthPublicKey(spendingPubKey *btcec.PublicKey, sharedSecretHash *btcec.ModNScalar) *btcec.PublicKey {
+	var spendingPoint, hashPoint, stealthPoint btcec.JacobianPoint
+	spendingPubKey.AsJacobian(&spendingPoint)
+	btcec.ScalarBaseMultNonConst(sharedSecretHash, &hashPoint)
+	btcec.AddNonConst(&spendingPoint, &hashPoint, &stealthPoint)
+	stealthPoint.ToAffine()
+
+	return btcec.NewPublicKey(&stealthPoint.X, &stealthPoint.Y)
+}
+
+// stealthPrivateKey - p_stealth = p_spend + s_h
+func stealthPrivateKey(spendingPrivKey *ecdsa.PrivateKey, sharedSecretHash *btcec.ModNScalar) *ecdsa.PrivateKey {
+	keyBytes := math.PaddedBigBytes(spendingPrivKey.D, 32)
+	defer clear(keyBytes)
+
+	var stealthScalar btcec.ModNScalar
+	stealthScalar.SetByteSlice(keyBytes)
+	stealthScalar.Add(sharedSecretHash)
+
+	privKey := btcec.PrivKeyFromScalar(&stealthScalar)
+	defer privKey.Zero()
+
+	stealthScalar.Zero()
+
+	return privKey.ToECDSA()
+}
+
+// GetStealthMetaAddress - returns ERC-5564 scheme 1 stealth meta-address - st:eth:0x<spendingPubKey><viewingPubKey>.
+// Stealth keys derived by dedicated fully hardened path m/5564'/coinType'/account'/change'/index'.
+// accountParameters - DerivationAddressIdentity with account and address indexes, internal index must be zero
+func (u *mnemonicWalletUnit) GetStealthMetaAddress(_ context.Context,
+	accountParameters *anypb.Any,
+) (*string, error) {
+	accIdentity, err := unmarshalStealthKeysIdentity(accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	spendingPrivKey, viewingPrivKey, err := u.deriveStealthKeys(accIdentity)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(spendingPrivKey)
+	defer zeroKey(viewingPrivKey)
+
+	metaAddress := stealthMetaAddressPrefix +
+		hexutil.Encode(crypto.CompressPubkey(&spendingPrivKey.PublicKey))[2:] +
+		hexutil.Encode(crypto.CompressPubkey(&viewingPrivKey.PublicKey))[2:]
+
+	return &metaAddress, nil
+}
+
+// ScanStealthAnnouncements - check ERC-5564 announcements by viewing key and spending public key.
+// announcementsData - JSON-encoded list of stealthAnnouncement.
+// Returns count and JSON-encoded list of matched announcements
+func (u *mnemonicWalletUnit) ScanStealthAnnouncements(ctx context.Context,
+	accountParameters *anypb.Any,
+	announcementsData []byte,
+) (uint, []byte, error) {
+	accIdentity, err := unmarshalStealthKeysIdentity(accountParameters)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	announcements := make([]*stealthAnnouncement, 0)
+	err = json.Unmarshal(announcementsData, &announcements)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %s", ErrStealthWrongAnnouncements, err.Error())
+	}
+
+	if len(announcements) > stealthAnnouncementsLimit {
+		return 0, nil, fmt.Errorf("%w: count %d greater than %d", ErrStealthWrongAnnouncements,
+			len(announcements), stealthAnnouncementsLimit)
+	}
+
+	u.mu.Lock()
+	spendingPrivKey, viewingPrivKey, err := u.deriveStealthKeys(accIdentity)
+	u.mu.Unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zeroKey(viewingPrivKey)
+
+	// only public spending key used for scanning
+	spendingPubKey, err := btcec.ParsePubKey(crypto.CompressPubkey(&spendingPrivKey.PublicKey))
+	zeroKey(spendingPrivKey)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	matched := make([]*matchedStealthAnnouncement, 0)
+
+	for i, announcement := range announcements {
+		if ctx.Err() != nil {
+			return 0, nil, ctx.Err()
+		}
+
+		if announcement == nil {
+			continue
+		}
+
+		ephemeralPubKey, parseErr := btcec.ParsePubKey(announcement.EphemeralPublicKey)
+		if parseErr != nil {
+			continue
+		}
+
+		sharedSecretHash := stealthSharedSecretHash(viewingPrivKey, ephemeralPubKey)
+
+		var hashBytes [32]byte
+		sharedSecretHash.PutBytes(&hashBytes)
+		viewTag := hashBytes[0]
+		clear(hashBytes[:])
+
+		if viewTag != announcement.ViewTag {
+			sharedSecretHash.Zero()
+
+			continue
+		}
+
+		stealthPubKey := stealthPublicKey(spendingPubKey, sharedSecretHash)
+		sharedSecretHash.Zero()
+
+		if crypto.PubkeyToAddress(*stealthPubKey.ToECDSA()) != announcement.StealthAddress {
+			continue
+		}
+
+		matched = append(matched, &matchedStealthAnnouncement{
+			Position:           uint(i),
+			StealthAddress:     announcement.StealthAddress,
+			EphemeralPublicKey: announcement.EphemeralPublicKey,
+		})
+	}
+
+	matchedData, err := json.Marshal(matched)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return uint(len(matched)), matchedData, nil
+}
+
+// SignStealthData - sign transaction from stealth address, computed by stealth keys and ephemeral public key.
+// Returns stealth address and signed transaction
+func (u *mnemonicWalletUnit) SignStealthData(ctx context.Context,
+	accountParameters *anypb.Any,
+	ephemeralPublicKey []byte,
+	dataForSign []byte,
+) (*string, []byte, error) {
+	accIdentity, err := unmarshalStealthKeysIdentity(accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ephemeralPubKey, err := btcec.ParsePubKey(ephemeralPublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrStealthWrongEphemeralKey, err.Error())
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	err = u.checkSigningFlow(ctx)
+	if err != nil {
+		u.mu.Unlock()
+
+		return nil, nil, err
+	}
+
+	spendingPrivKey, viewingPrivKey, err := u.deriveStealthKeys(accIdentity)
+	u.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zeroKey(spendingPrivKey)
+	defer zeroKey(viewingPrivKey)
+
+	sharedSecretHash := stealthSharedSecretHash(viewingPrivKey, ephemeralPubKey)
+
+	stealthPrivKey := stealthPrivateKey(spendingPrivKey, sharedSecretHash)
+	sharedSecretHash.Zero()
+	defer zeroKey(stealthPrivKey)
+
+	stealthAddress := crypto.PubkeyToAddress(stealthPrivKey.PublicKey)
+
+	// derivation path of spending key - stealth key is spending key tweaked by shared secret
+	source := &signingSource{
+		walletUUID: u.mnemonicWalletUUID,
+		derivationPath: fmt.Sprintf("m/%d'/%d'/%d'/%d'/%d'", stealthKeysPurpose, pluginCoinType,
+			accIdentity.AccountIndex, stealthSpendingKeyChange, accIdentity.AddressIndex),
+		address: stealthAddress,
+	}
+
+	// unit lock released - stealth key is not cached
+	err = approveTxSignature(ctx, source, decodedTx, u.dataSigner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signedTxRawData, err := signRawTransactionData(u.dataSigner, stealthPrivKey, decodedTx, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stealthAddressHex := stealthAddress.Hex()
+
+	return &stealthAddressHex, signedTxRawData, nil
+}
+
+func (u *mnemonicWalletUnit) deriveStealthKeys(accIdentity *pbCommon.DerivationAddressIdentity,
+) (*ecdsa.PrivateKey, *ecdsa.PrivateKey, error) {
+	hdWalletSvc, err := u.unlockedWallet()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	spendingPrivKey, err := hdWalletSvc.NewHardenedPrivateKey(stealthKeysPurpose,
+		accIdentity.AccountIndex, stealthSpendingKeyChange, accIdentity.AddressIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	viewingPrivKey, err := hdWalletSvc.NewHardenedPrivateKey(stealthKeysPurpose,
+		accIdentity.AccountIndex, stealthViewingKeyChange, accIdentity.AddressIndex)
+	if err != nil {
+		zeroKey(spendingPrivKey)
+
+		return nil, nil, err
+	}
+
+	return spendingPrivKey, viewingPrivKey, nil
+}
+
+func unmarshalStealthKeysIdentity(accountParameters *anypb.Any) (*pbCommon.DerivationAddressIdentity, error) {
+	accIdentity := &pbCommon.DerivationAddressIdentity{}
+	err := accountParameters.UnmarshalTo(accIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	if accIdentity.InternalIndex != 0 {
+		return nil, fmt.Errorf("%w: internal index must be zero", ErrStealthWrongAccountParams)
+	}
+
+	return accIdentity, nil
+}
@@ -0,0 +1,201 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// generateStealthAddress - sender side of ERC-5564 scheme 1, implemented by big.Int curve arithmetic.
+// Returns stealth address, ephemeral public key and view tag
+func generateStealthAddress(t *testing.T,
+	metaAddress string,
+	ephemeralPrivKey *ecdsa.PrivateKey,
+) (common.Address, []byte, uint8) {
+	if !strings.HasPrefix(metaAddress, "st:eth:0x") {
+		t.Fatalf("%s", "wrong stealth meta-address prefix")
+	}
+
+	keysData := hexutil.MustDecode(metaAddress[len("st:eth:"):])
+	if len(keysData) != 66 {
+		t.Fatalf("%s", "wrong stealth meta-address length")
+	}
+
+	spendingPubKey, err := crypto.DecompressPubkey(keysData[:33])
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decompress spending public key", err)
+	}
+
+	viewingPubKey, err := crypto.DecompressPubkey(keysData[33:])
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decompress viewing public key", err)
+	}
+
+	curve := crypto.S256()
+
+	sharedX, sharedY := curve.ScalarMult(viewingPubKey.X, viewingPubKey.Y, ephemeralPrivKey.D.Bytes())
+	sharedSecret := crypto.CompressPubkey(&ecdsa.PublicKey{Curve: curve, X: sharedX, Y: sharedY})
+	sharedSecretHash := crypto.Keccak256(sharedSecret)
+
+	hashX, hashY := curve.ScalarBaseMult(sharedSecretHash)
+	stealthX, stealthY := curve.Add(spendingPubKey.X, spendingPubKey.Y, hashX, hashY)
+
+	stealthAddress := crypto.PubkeyToAddress(ecdsa.PublicKey{Curve: curve, X: stealthX, Y: stealthY})
+
+	return stealthAddress, crypto.CompressPubkey(&ephemeralPrivKey.PublicKey), sharedSecretHash[0]
+}
+
+func TestMnemonicWalletUnit_StealthAddresses(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	stealthIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex: 0,
+		AddressIndex: 0,
+	})
+	anotherStealthIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex: 0,
+		AddressIndex: 1,
+	})
+
+	metaAddress, err := poolUnit.GetStealthMetaAddress(context.Background(), stealthIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get stealth meta-address", err)
+	}
+
+	if !strings.HasPrefix(*metaAddress, "st:eth:0x") || len(*metaAddress) != len("st:eth:0x")+132 {
+		t.Fatalf("%s", "stealth meta-address format not equal with expected")
+	}
+
+	anotherMetaAddress, err := poolUnit.GetStealthMetaAddress(context.Background(), anotherStealthIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get stealth meta-address", err)
+	}
+
+	// WARN: DO NOT USE THESE PRIVATE KEYS IN MAINNET OR TESTNET. Usage only in unit-tests
+	firstEphemeralKey, _ := crypto.HexToECDSA("ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	secondEphemeralKey, _ := crypto.HexToECDSA("59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d")
+
+	firstAddress, firstEphemeralPubKey, firstViewTag := generateStealthAddress(t, *metaAddress, firstEphemeralKey)
+	foreignAddress, foreignEphemeralPubKey, foreignViewTag := generateStealthAddress(t, *anotherMetaAddress,
+		firstEphemeralKey)
+	secondAddress, secondEphemeralPubKey, secondViewTag := generateStealthAddress(t, *metaAddress,
+		secondEphemeralKey)
+
+	announcements := []*stealthAnnouncement{
+		{StealthAddress: firstAddress, EphemeralPublicKey: firstEphemeralPubKey, ViewTag: firstViewTag},
+		{StealthAddress: foreignAddress, EphemeralPublicKey: foreignEphemeralPubKey, ViewTag: foreignViewTag},
+		{StealthAddress: firstAddress, EphemeralPublicKey: firstEphemeralPubKey, ViewTag: firstViewTag + 1},
+		{StealthAddress: secondAddress, EphemeralPublicKey: secondEphemeralPubKey, ViewTag: secondViewTag},
+		{StealthAddress: secondAddress, EphemeralPublicKey: []byte{0x02, 0x01}, ViewTag: secondViewTag},
+	}
+	announcementsData, _ := json.Marshal(announcements)
+
+	count, matchedData, err := poolUnit.ScanStealthAnnouncements(context.Background(), stealthIdentity,
+		announcementsData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to scan stealth announcements", err)
+	}
+
+	matched := make([]*matchedStealthAnnouncement, 0)
+	_ = json.Unmarshal(matchedData, &matched)
+
+	if count != 2 || len(matched) != 2 ||
+		matched[0].Position != 0 || matched[0].StealthAddress != firstAddress ||
+		matched[1].Position != 3 || matched[1].StealthAddress != secondAddress {
+		t.Fatalf("%s", "matched announcements not equal with expected")
+	}
+
+	recipient := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+	dataForSign, _ := types.NewTx(&types.LegacyTx{
+		Nonce:    0,
+		GasPrice: big.NewInt(10000000000),
+		Gas:      21000,
+		To:       &recipient,
+		Value:    big.NewInt(1500000),
+	}).MarshalBinary()
+
+	addr, signedData, err := poolUnit.SignStealthData(context.Background(), stealthIdentity,
+		secondEphemeralPubKey, dataForSign)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign stealth data", err)
+	}
+
+	signedTx := &types.Transaction{}
+	_ = signedTx.UnmarshalBinary(signedData)
+
+	sender, err := types.Sender(poolUnit.dataSigner, signedTx)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get sender of signed transaction", err)
+	}
+
+	if sender != secondAddress || *addr != secondAddress.Hex() {
+		t.Fatalf("%s", "sender not equal with stealth address")
+	}
+
+	_, _, err = poolUnit.SignStealthData(context.Background(), stealthIdentity, []byte{0x02, 0x01}, dataForSign)
+	if !errors.Is(err, ErrStealthWrongEphemeralKey) {
+		t.Fatalf("%s", "wrong ephemeral public key must not be accepted")
+	}
+
+	wrongIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{InternalIndex: 1})
+	_, err = poolUnit.GetStealthMetaAddress(context.Background(), wrongIdentity)
+	if !errors.Is(err, ErrStealthWrongAccountParams) {
+		t.Fatalf("%s", "stealth keys identity with internal index must not be accepted")
+	}
+}
@@ -0,0 +1,376 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	sweepDefaultWorkersCount = 4
+	sweepMaxWorkersCount     = 64
+
+	sweepStatusSigned = "signed"
+	sweepStatusDust   = "skipped_dust"
+	sweepStatusFailed = "failed"
+)
+
+var (
+	ErrSweepMissingTreasury = errors.New("missing treasury address in sweep batch")
+	ErrSweepMissingSources  = errors.New("missing sources in sweep batch")
+	ErrSweepMissingBalance  = errors.New("missing or negative source balance")
+)
+
+// sweepSource - deposit address for sweep, identified by derivation path
+type sweepSource struct {
+	AccountIndex  uint32                `json:"accountIndex"`
+	InternalIndex uint32                `json:"internalIndex"`
+	AddressIndex  uint32                `json:"addressIndex"`
+	Balance       *math.HexOrDecimal256 `json:"balance"`
+	Nonce         math.HexOrDecimal64   `json:"nonce"`
+}
+
+// sweepBatch - batch of deposit addresses for consolidation into treasury address
+type sweepBatch struct {
+	Treasury  common.Address `json:"treasury"`
+	Sources   []*sweepSource `json:"sources"`
+	FeePolicy *txFeeParams   `json:"feePolicy"`
+	// DustThreshold - sources with sweepable amount lower or equal than threshold will be skipped
+	DustThreshold *math.HexOrDecimal256 `json:"dustThreshold,omitempty"`
+	// Workers - size of worker pool, default 4, max 64
+	Workers uint `json:"workers,omitempty"`
+}
+
+// sweepItemResult - sweep result of one source
+type sweepItemResult struct {
+	AccountIndex  uint32         `json:"accountIndex"`
+	InternalIndex uint32         `json:"internalIndex"`
+	AddressIndex  uint32         `json:"addressIndex"`
+	Address       common.Address `json:"address,omitempty"`
+
+	Status string        `json:"status"`
+	Amount *hexutil.Big  `json:"amount,omitempty"`
+	Fee    *hexutil.Big  `json:"fee,omitempty"`
+	RawTx  hexutil.Bytes `json:"rawTx,omitempty"`
+	TxHash *common.Hash  `json:"txHash,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// sweepSummary - result of sweep batch signature
+type sweepSummary struct {
+	Treasury common.Address     `json:"treasury"`
+	Items    []*sweepItemResult `json:"items"`
+
+	SignedCount  uint         `json:"signedCount"`
+	SkippedCount uint         `json:"skippedCount"`
+	FailedCount  uint         `json:"failedCount"`
+	TotalAmount  *hexutil.Big `json:"totalAmount"`
+	TotalFee     *hexutil.Big `json:"totalFee"`
+}
+
+func (b *sweepBatch) validate() error {
+	if b.Treasury == (common.Address{}) {
+		return ErrSweepMissingTreasury
+	}
+
+	if len(b.Sources) == 0 {
+		return ErrSweepMissingSources
+	}
+
+	if b.FeePolicy == nil {
+		return ErrTransferIntentMissingFees
+	}
+
+	err := b.FeePolicy.validate()
+	if err != nil {
+		return err
+	}
+
+	// plain value transfer, contract treasury may need more gas
+	if uint64(b.FeePolicy.GasLimit) < params.TxGas {
+		return fmt.Errorf("%w: gas limit lower than %d", ErrTransferIntentMissingGasLimit, params.TxGas)
+	}
+
+	for i, source := range b.Sources {
+		if source == nil || source.Balance == nil || (*big.Int)(source.Balance).Sign() < 0 {
+			return fmt.Errorf("%w: source position %d", ErrSweepMissingBalance, i)
+		}
+	}
+
+	if b.Workers == 0 {
+		b.Workers = sweepDefaultWorkersCount
+	}
+
+	if b.Workers > sweepMaxWorkersCount {
+		b.Workers = sweepMaxWorkersCount
+	}
+
+	return nil
+}
+
+// SignSweepBatch - sign one consolidation transaction per source address of batch.
+// sweepBatchData - JSON-encoded sweepBatch. Returns JSON-encoded sweepSummary
+func (u *mnemonicWalletUnit) SignSweepBatch(ctx context.Context,
+	sweepBatchData []byte,
+) ([]byte, error) {
+	batch := &sweepBatch{}
+	err := json.Unmarshal(sweepBatchData, batch)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal sweep batch: %w", err)
+	}
+
+	err = u.checkSigningFlow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := u.signSweepBatch(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(summary)
+}
+
+// signSweepBatch - prepare and approve sweep transactions without unit lock, sign approved transactions
+// under unit lock. Sign approver can be slow external service
+func (u *mnemonicWalletUnit) signSweepBatch(ctx context.Context,
+	batch *sweepBatch,
+) (*sweepSummary, error) {
+	err := batch.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	chainID := u.dataSigner.ChainID()
+	fee := batch.FeePolicy.maxFee()
+	dustThreshold := new(big.Int)
+	if batch.DustThreshold != nil {
+		dustThreshold.Set((*big.Int)(batch.DustThreshold))
+	}
+
+	items := make([]*sweepItemResult, len(batch.Sources))
+	txs := make([]*types.Transaction, len(batch.Sources))
+
+	err = runSweepWorkers(ctx, batch, func(position int) {
+		items[position], txs[position] = u.prepareSweepSource(ctx, chainID, batch, batch.Sources[position],
+			fee, dustThreshold)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	err = runSweepWorkers(ctx, batch, func(position int) {
+		if txs[position] == nil {
+			return
+		}
+
+		u.signSweepSource(batch.Sources[position], txs[position], items[position])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &sweepSummary{
+		Treasury:    batch.Treasury,
+		Items:       items,
+		TotalAmount: (*hexutil.Big)(new(big.Int)),
+		TotalFee:    (*hexutil.Big)(new(big.Int)),
+	}
+
+	for _, item := range items {
+		switch item.Status {
+		case sweepStatusSigned:
+			summary.SignedCount++
+			(*big.Int)(summary.TotalAmount).Add((*big.Int)(summary.TotalAmount), (*big.Int)(item.Amount))
+			(*big.Int)(summary.TotalFee).Add((*big.Int)(summary.TotalFee), (*big.Int)(item.Fee))
+		case sweepStatusDust:
+			summary.SkippedCount++
+		default:
+			summary.FailedCount++
+		}
+	}
+
+	return summary, nil
+}
+
+// runSweepWorkers - process positions of batch sources by batch workers
+func runSweepWorkers(ctx context.Context, batch *sweepBatch, process func(position int)) error {
+	positions := make(chan int)
+
+	wg := sync.WaitGroup{}
+	wg.Add(int(batch.Workers))
+
+	for i := uint(0); i != batch.Workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for position := range positions {
+				process(position)
+			}
+		}()
+	}
+
+	for position := range batch.Sources {
+		if ctx.Err() != nil {
+			break
+		}
+
+		positions <- position
+	}
+	close(positions)
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// prepareSweepSource - calculate sweep amount and assemble transaction of source, request sign approver decision.
+// Returns nil transaction if source skipped or failed
+func (u *mnemonicWalletUnit) prepareSweepSource(ctx context.Context,
+	chainID *big.Int,
+	batch *sweepBatch,
+	source *sweepSource,
+	fee, dustThreshold *big.Int,
+) (*sweepItemResult, *types.Transaction) {
+	result := &sweepItemResult{
+		AccountIndex:  source.AccountIndex,
+		InternalIndex: source.InternalIndex,
+		AddressIndex:  source.AddressIndex,
+		Fee:           (*hexutil.Big)(new(big.Int).Set(fee)),
+	}
+
+	amount := new(big.Int).Sub((*big.Int)(source.Balance), fee)
+	if amount.Sign() < 0 {
+		amount.SetUint64(0)
+	}
+
+	result.Amount = (*hexutil.Big)(amount)
+	if amount.Sign() == 0 || amount.Cmp(dustThreshold) <= 0 {
+		result.Status = sweepStatusDust
+
+		return result, nil
+	}
+
+	tx := types.NewTx(batch.FeePolicy.makeTxData(chainID, uint64(source.Nonce), &batch.Treasury, amount, nil))
+
+	err := u.approveAccountTx(ctx, source.AccountIndex, source.InternalIndex, source.AddressIndex,
+		&signingTx{tx: tx})
+	if err != nil {
+		result.Status, result.Error = sweepStatusFailed, err.Error()
+
+		return result, nil
+	}
+
+	return result, tx
+}
+
+// signSweepSource - sign approved sweep transaction of source. Must be called under unit lock
+func (u *mnemonicWalletUnit) signSweepSource(source *sweepSource,
+	tx *types.Transaction,
+	result *sweepItemResult,
+) {
+	// sweep sources are not cached in address pool - thousands of one-time used private keys
+	hdWalletSvc, err := u.unlockedWallet()
+	if err != nil {
+		result.Status, result.Error = sweepStatusFailed, err.Error()
+
+		return
+	}
+
+	hdWalletAccount, err := hdWalletSvc.NewAccount(source.AccountIndex,
+		source.InternalIndex, source.AddressIndex)
+	if err != nil {
+		result.Status, result.Error = sweepStatusFailed, err.Error()
+
+		return
+	}
+
+	defer func() {
+		hdWalletAccount.ClearSecrets()
+		hdWalletAccount = nil
+	}()
+
+	addr, err := hdWalletAccount.GetAddress()
+	if err != nil {
+		result.Status, result.Error = sweepStatusFailed, err.Error()
+
+		return
+	}
+	result.Address = common.HexToAddress(addr)
+
+	privKey := hdWalletAccount.CloneECDSAPrivateKey()
+	defer zeroKey(privKey)
+
+	signingSrc := u.accountSigningSource(source.AccountIndex, source.InternalIndex, source.AddressIndex, addr)
+
+	signedTx, err := types.SignTx(tx, u.dataSigner, privKey)
+	if err != nil {
+		result.Status, result.Error = sweepStatusFailed, err.Error()
+
+		return
+	}
+
+	err = auditTxSignature(signingSrc, signedTx)
+	if err != nil {
+		result.Status, result.Error = sweepStatusFailed, err.Error()
+
+		return
+	}
+
+	rawTx, err := signedTx.MarshalBinary()
+	if err != nil {
+		result.Status, result.Error = sweepStatusFailed, err.Error()
+
+		return
+	}
+
+	txHash := signedTx.Hash()
+	result.Status = sweepStatusSigned
+	result.RawTx = rawTx
+	result.TxHash = &txHash
+}
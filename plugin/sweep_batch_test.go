@@ -0,0 +1,148 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+)
+
+func TestMnemonicWalletUnit_SignSweepBatch(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	treasury := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	// fee = 21000 * 20 gwei = 420000000000000 wei
+	batchData := []byte(`{
+		"treasury": "` + treasury.Hex() + `",
+		"feePolicy": {"gasLimit": "21000", "maxFeePerGas": "20000000000", "maxPriorityFeePerGas": "1000000000"},
+		"dustThreshold": "1000000000000000",
+		"workers": 2,
+		"sources": [
+			{"accountIndex": 7, "internalIndex": 8, "addressIndex": 9, "balance": "1000000000000000000", "nonce": "3"},
+			{"accountIndex": 7, "internalIndex": 8, "addressIndex": 10, "balance": "1000000000000000"},
+			{"accountIndex": 7, "internalIndex": 8, "addressIndex": 11, "balance": "0"},
+			{"accountIndex": 7, "internalIndex": 8, "addressIndex": 12, "balance": "0x2386f26fc10000"}
+		]
+	}`)
+
+	summaryData, err := poolUnit.SignSweepBatch(context.Background(), batchData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign sweep batch", err)
+	}
+
+	summary := &sweepSummary{}
+	err = json.Unmarshal(summaryData, summary)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal sweep summary", err)
+	}
+
+	if summary.SignedCount != 2 || summary.SkippedCount != 2 || summary.FailedCount != 0 {
+		t.Fatalf("%s", "sweep summary counters not equal with expected")
+	}
+
+	expectedStatuses := []string{sweepStatusSigned, sweepStatusDust, sweepStatusDust, sweepStatusSigned}
+	expectedAmounts := []string{"999580000000000000", "580000000000000", "0", "9580000000000000"}
+
+	for i, item := range summary.Items {
+		if item.Status != expectedStatuses[i] {
+			t.Fatalf("%s: %d", "sweep item status not equal with expected", i)
+		}
+
+		if (*big.Int)(item.Amount).String() != expectedAmounts[i] {
+			t.Fatalf("%s: %d", "sweep item amount not equal with expected", i)
+		}
+
+		if item.Status != sweepStatusSigned {
+			if len(item.RawTx) != 0 {
+				t.Fatalf("%s: %d", "skipped sweep item contains transaction", i)
+			}
+
+			continue
+		}
+
+		signedTx := &types.Transaction{}
+		loopErr := signedTx.UnmarshalBinary(item.RawTx)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to unmarshal sweep transaction", loopErr)
+		}
+
+		sender, loopErr := types.Sender(poolUnit.dataSigner, signedTx)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to recover sender", loopErr)
+		}
+
+		expectedAddress, loopErr := poolUnit.getAddressByPath(context.Background(),
+			item.AccountIndex, item.InternalIndex, item.AddressIndex)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to derive address", loopErr)
+		}
+
+		if sender.Hex() != *expectedAddress || item.Address.Hex() != *expectedAddress {
+			t.Fatalf("%s: %d", "sweep transaction sender not equal with expected", i)
+		}
+
+		if *signedTx.To() != treasury || signedTx.Value().String() != expectedAmounts[i] {
+			t.Fatalf("%s: %d", "sweep transaction payload not equal with expected", i)
+		}
+
+		if signedTx.Hash() != *item.TxHash {
+			t.Fatalf("%s: %d", "sweep transaction hash not equal with expected", i)
+		}
+	}
+
+	expectedTotal, _ := new(big.Int).SetString("1009160000000000000", 10)
+	if (*big.Int)(summary.TotalAmount).Cmp(expectedTotal) != 0 {
+		t.Fatalf("%s", "sweep summary total amount not equal with expected")
+	}
+
+	if len(poolUnit.addressPool) != 0 {
+		t.Fatalf("%s", "sweep sources must not be cached in address pool")
+	}
+}
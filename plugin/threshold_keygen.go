@@ -0,0 +1,631 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	thresholdMaxPartiesCount = 16
+	thresholdNonceLength     = 32
+
+	thresholdKeygenRoundCommit   = 1
+	thresholdKeygenRoundDecommit = 2
+)
+
+var (
+	ErrThresholdWrongParams      = errors.New("wrong threshold params")
+	ErrThresholdWrongKeyShare    = errors.New("wrong threshold key share")
+	ErrThresholdProtocolAborted  = errors.New("threshold protocol aborted")
+	ErrThresholdCommitmentFailed = errors.New("threshold commitment verification failed")
+)
+
+// thresholdKeygenParams - params of distributed key generation, JSON format
+type thresholdKeygenParams struct {
+	// SessionID - unique key generation session ID, same for all parties
+	SessionID string `json:"sessionId"`
+	// PartyID - ID of current party in range 1..partiesCount
+	PartyID uint32 `json:"partyId"`
+	// Threshold - count of parties required for signing
+	Threshold uint32 `json:"threshold"`
+	// PartiesCount - count of key shares holders
+	PartiesCount uint32 `json:"partiesCount"`
+}
+
+func (p *thresholdKeygenParams) validate() error {
+	if p.SessionID == "" {
+		return fmt.Errorf("%w: session id is empty", ErrThresholdWrongParams)
+	}
+
+	if p.PartiesCount < 2 || p.PartiesCount > thresholdMaxPartiesCount {
+		return fmt.Errorf("%w: parties count must be in range 2-%d", ErrThresholdWrongParams,
+			thresholdMaxPartiesCount)
+	}
+
+	if p.Threshold < 2 || p.Threshold > p.PartiesCount {
+		return fmt.Errorf("%w: threshold must be in range 2-%d", ErrThresholdWrongParams, p.PartiesCount)
+	}
+
+	if p.PartyID < 1 || p.PartyID > p.PartiesCount {
+		return fmt.Errorf("%w: party id must be in range 1-%d", ErrThresholdWrongParams, p.PartiesCount)
+	}
+
+	return nil
+}
+
+// thresholdKeyShare - key share of party, JSON format. Contains secret values -
+// must be stored by host same as mnemonic
+type thresholdKeyShare struct {
+	PartyID   uint32   `json:"partyId"`
+	Threshold uint32   `json:"threshold"`
+	Parties   []uint32 `json:"parties"`
+	// Share - secret share of signing key
+	Share hexutil.Bytes `json:"share"`
+	// PublicKey - compressed public key of signing key
+	PublicKey hexutil.Bytes `json:"publicKey"`
+	// PublicShares - compressed public keys of parties shares
+	PublicShares map[uint32]hexutil.Bytes `json:"publicShares"`
+	// PaillierP, PaillierQ - primes of party Paillier private key
+	PaillierP hexutil.Bytes `json:"paillierP"`
+	PaillierQ hexutil.Bytes `json:"paillierQ"`
+	// PaillierPublicKeys - Paillier modulus of every party
+	PaillierPublicKeys map[uint32]hexutil.Bytes `json:"paillierPublicKeys"`
+	// RingPedersenS, RingPedersenT - ring-Pedersen params of every party over party Paillier modulus
+	RingPedersenS map[uint32]hexutil.Bytes `json:"ringPedersenS"`
+	RingPedersenT map[uint32]hexutil.Bytes `json:"ringPedersenT"`
+}
+
+type thresholdKeygenCommitMessage struct {
+	Commitment hexutil.Bytes `json:"commitment"`
+	PaillierN  hexutil.Bytes `json:"paillierN"`
+	// RingPedersenS, RingPedersenT - ring-Pedersen params of sender
+	RingPedersenS hexutil.Bytes `json:"ringPedersenS"`
+	RingPedersenT hexutil.Bytes `json:"ringPedersenT"`
+	// ModProof - proof of Paillier-Blum modulus of sender
+	ModProof *paillierBlumModulusProof `json:"modProof"`
+	// PrmProof - proof of ring-Pedersen params of sender
+	PrmProof *ringPedersenParamsProof `json:"prmProof"`
+}
+
+type thresholdKeygenDecommitMessage struct {
+	// Coefficients - Feldman VSS commitments of polynomial coefficients
+	Coefficients []hexutil.Bytes `json:"coefficients"`
+	Nonce        hexutil.Bytes   `json:"nonce"`
+	// ProofR, ProofS - Schnorr proof of knowledge of free coefficient
+	ProofR hexutil.Bytes `json:"proofR"`
+	ProofS hexutil.Bytes `json:"proofS"`
+	// Share - secret share of recipient party
+	Share hexutil.Bytes `json:"share"`
+	// FacProof - proof of no small factors of sender Paillier modulus by ring-Pedersen params of recipient
+	FacProof *noSmallFactorProof `json:"facProof"`
+}
+
+// thresholdHash - sha256 of length-prefixed parts
+func thresholdHash(parts ...[]byte) []byte {
+	hasher := sha256.New()
+	for _, part := range parts {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(part)))
+		hasher.Write(length[:])
+		hasher.Write(part)
+	}
+
+	return hasher.Sum(nil)
+}
+
+func thresholdPartyBytes(partyID uint32) []byte {
+	var result [4]byte
+	binary.BigEndian.PutUint32(result[:], partyID)
+
+	return result[:]
+}
+
+func randomScalar() (*btcec.ModNScalar, error) {
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &privKey.Key, nil
+}
+
+func scalarFromBytes(data []byte) (*btcec.ModNScalar, error) {
+	if len(data) != 32 {
+		return nil, ErrThresholdWrongKeyShare
+	}
+
+	var result btcec.ModNScalar
+	overflow := result.SetByteSlice(data)
+	if overflow {
+		return nil, ErrThresholdWrongKeyShare
+	}
+
+	return &result, nil
+}
+
+func scalarBytes(s *btcec.ModNScalar) []byte {
+	result := s.Bytes()
+
+	return result[:]
+}
+
+func scalarBigInt(s *btcec.ModNScalar) *big.Int {
+	return new(big.Int).SetBytes(scalarBytes(s))
+}
+
+func scalarFromBigInt(value *big.Int) *btcec.ModNScalar {
+	var result btcec.ModNScalar
+	result.SetByteSlice(new(big.Int).Mod(value, btcec.S256().N).Bytes())
+
+	return &result
+}
+
+func scalarBaseMult(s *btcec.ModNScalar) *btcec.JacobianPoint {
+	var result btcec.JacobianPoint
+	btcec.ScalarBaseMultNonConst(s, &result)
+
+	return &result
+}
+
+func pointMult(s *btcec.ModNScalar, point *btcec.JacobianPoint) *btcec.JacobianPoint {
+	var result btcec.JacobianPoint
+	btcec.ScalarMultNonConst(s, point, &result)
+
+	return &result
+}
+
+func pointAdd(a, b *btcec.JacobianPoint) *btcec.JacobianPoint {
+	var result btcec.JacobianPoint
+	btcec.AddNonConst(a, b, &result)
+
+	return &result
+}
+
+func pointEquals(a, b *btcec.JacobianPoint) bool {
+	aAffine, bAffine := *a, *b
+	aAffine.ToAffine()
+	bAffine.ToAffine()
+
+	return aAffine.X.Equals(&bAffine.X) && aAffine.Y.Equals(&bAffine.Y)
+}
+
+func pointToPublicKey(point *btcec.JacobianPoint) *btcec.PublicKey {
+	affine := *point
+	affine.ToAffine()
+
+	return btcec.NewPublicKey(&affine.X, &affine.Y)
+}
+
+func parsePoint(data []byte) (*btcec.JacobianPoint, error) {
+	pubKey, err := btcec.ParsePubKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcec.JacobianPoint
+	pubKey.AsJacobian(&result)
+
+	return &result, nil
+}
+
+// evaluatePolynomial - f(x) = a_0 + a_1*x + ... + a_t-1*x^(t-1)
+func evaluatePolynomial(coefficients []*btcec.ModNScalar, x uint32) *btcec.ModNScalar {
+	var xScalar, result btcec.ModNScalar
+	xScalar.SetInt(x)
+
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result.Mul(&xScalar)
+		result.Add(coefficients[i])
+	}
+
+	return &result
+}
+
+// evaluateCommitments - F(x) = A_0 + A_1*x + ... + A_t-1*x^(t-1), Feldman VSS public share of x
+func evaluateCommitments(commitments []*btcec.JacobianPoint, x uint32) *btcec.JacobianPoint {
+	var xScalar btcec.ModNScalar
+	xScalar.SetInt(x)
+
+	result := commitments[len(commitments)-1]
+	for i := len(commitments) - 2; i >= 0; i-- {
+		result = pointAdd(pointMult(&xScalar, result), commitments[i])
+	}
+
+	return result
+}
+
+// lagrangeCoefficient - Lagrange coefficient of party at zero for parties set
+func lagrangeCoefficient(partyID uint32, parties []uint32) *btcec.ModNScalar {
+	var numerator, denominator btcec.ModNScalar
+	numerator.SetInt(1)
+	denominator.SetInt(1)
+
+	for _, otherPartyID := range parties {
+		if otherPartyID == partyID {
+			continue
+		}
+
+		var other, diff, self btcec.ModNScalar
+		other.SetInt(otherPartyID)
+		self.SetInt(partyID)
+		self.Negate()
+		diff.Add2(&other, &self)
+
+		numerator.Mul(&other)
+		denominator.Mul(&diff)
+	}
+
+	denominator.InverseNonConst()
+
+	return numerator.Mul(&denominator)
+}
+
+// schnorrChallenge - challenge of Schnorr proof of knowledge of discrete logarithm
+func schnorrChallenge(sessionID string, partyID uint32, publicPoint, commitmentPoint *btcec.JacobianPoint) *btcec.ModNScalar {
+	var challenge btcec.ModNScalar
+	challenge.SetByteSlice(thresholdHash([]byte(sessionID), thresholdPartyBytes(partyID),
+		pointToPublicKey(publicPoint).SerializeCompressed(),
+		pointToPublicKey(commitmentPoint).SerializeCompressed()))
+
+	return &challenge
+}
+
+// keygenCommitment - hash commitment of Feldman VSS coefficients commitments
+func keygenCommitment(sessionID string, partyID uint32, coefficients []hexutil.Bytes, nonce []byte) []byte {
+	parts := [][]byte{[]byte(sessionID), thresholdPartyBytes(partyID)}
+	for _, coefficient := range coefficients {
+		parts = append(parts, coefficient)
+	}
+
+	return thresholdHash(append(parts, nonce)...)
+}
+
+// runThresholdKeygen - distributed key generation - Feldman VSS of random secret of every party
+// with hash commitments and Schnorr proofs of free coefficients. Signing key never exists in one place.
+// Every party proves Paillier-Blum modulus, ring-Pedersen params and no small factors of Paillier modulus.
+// Returns key share of current party
+func runThresholdKeygen(ctx context.Context,
+	router *thresholdRouter,
+	params *thresholdKeygenParams,
+) (*thresholdKeyShare, error) {
+	parties := make([]uint32, params.PartiesCount)
+	for i := range parties {
+		parties[i] = uint32(i) + 1
+	}
+
+	session := newThresholdSession(router, params.SessionID, params.PartyID, parties)
+	defer session.clear()
+
+	paillierKey, err := generatePaillierKey()
+	if err != nil {
+		return nil, err
+	}
+	defer paillierKey.zero()
+
+	ringPedersen, ringPedersenLambda, err := paillierKey.generateRingPedersenParams()
+	if err != nil {
+		return nil, err
+	}
+	defer ringPedersenLambda.SetInt64(0)
+
+	broadcastProofCtx := &proofContext{sessionID: params.SessionID, proverID: params.PartyID}
+
+	modProof, err := provePaillierBlumModulus(broadcastProofCtx, paillierKey)
+	if err != nil {
+		return nil, err
+	}
+
+	prmProof, err := proveRingPedersenParams(broadcastProofCtx, ringPedersen, ringPedersenLambda, paillierKey.phi)
+	if err != nil {
+		return nil, err
+	}
+
+	coefficients := make([]*btcec.ModNScalar, params.Threshold)
+	coefficientsCommitments := make([]hexutil.Bytes, params.Threshold)
+	for i := range coefficients {
+		coefficients[i], err = randomScalar()
+		if err != nil {
+			return nil, err
+		}
+
+		coefficientsCommitments[i] = pointToPublicKey(scalarBaseMult(coefficients[i])).SerializeCompressed()
+	}
+	defer func() {
+		for _, coefficient := range coefficients {
+			coefficient.Zero()
+		}
+	}()
+
+	nonce := make([]byte, thresholdNonceLength)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// round 1 - commitment of coefficients, Paillier public key and ring-Pedersen params with proofs
+	err = session.broadcast(ctx, thresholdKeygenRoundCommit, &thresholdKeygenCommitMessage{
+		Commitment:    keygenCommitment(params.SessionID, params.PartyID, coefficientsCommitments, nonce),
+		PaillierN:     paillierKey.n.Bytes(),
+		RingPedersenS: ringPedersen.s.Bytes(),
+		RingPedersenT: ringPedersen.t.Bytes(),
+		ModProof:      modProof,
+		PrmProof:      prmProof,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	commitMessages, err := session.collect(ctx, thresholdKeygenRoundCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make(map[uint32]*thresholdKeygenCommitMessage, len(commitMessages))
+	paillierPublicKeys := map[uint32]hexutil.Bytes{params.PartyID: paillierKey.n.Bytes()}
+	ringPedersenS := map[uint32]hexutil.Bytes{params.PartyID: ringPedersen.s.Bytes()}
+	ringPedersenT := map[uint32]hexutil.Bytes{params.PartyID: ringPedersen.t.Bytes()}
+	for partyID, payload := range commitMessages {
+		commit := &thresholdKeygenCommitMessage{}
+		err = json.Unmarshal(payload, commit)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrThresholdWrongMessage, err.Error())
+		}
+
+		err = verifyKeygenPaillierKey(params.SessionID, partyID, commit)
+		if err != nil {
+			return nil, err
+		}
+
+		commits[partyID] = commit
+		paillierPublicKeys[partyID] = commit.PaillierN
+		ringPedersenS[partyID] = commit.RingPedersenS
+		ringPedersenT[partyID] = commit.RingPedersenT
+	}
+
+	// round 2 - decommitment, proof of knowledge of secret and secret share of recipient
+	proofNonce, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	defer proofNonce.Zero()
+
+	freePoint := scalarBaseMult(coefficients[0])
+	proofPoint := scalarBaseMult(proofNonce)
+	var proofS btcec.ModNScalar
+	proofS.Mul2(schnorrChallenge(params.SessionID, params.PartyID, freePoint, proofPoint), coefficients[0])
+	proofS.Add(proofNonce)
+
+	for _, partyID := range session.otherParties() {
+		// commits verified by verifyKeygenPaillierKey, params always valid
+		recipientRingPedersen, _ := newRingPedersenParams(new(big.Int).SetBytes(commits[partyID].PaillierN),
+			new(big.Int).SetBytes(commits[partyID].RingPedersenS), new(big.Int).SetBytes(commits[partyID].RingPedersenT))
+
+		facProof, loopErr := proveNoSmallFactor(&proofContext{
+			sessionID:  params.SessionID,
+			proverID:   params.PartyID,
+			verifierID: partyID,
+		}, paillierKey, recipientRingPedersen)
+		if loopErr != nil {
+			return nil, loopErr
+		}
+
+		share := evaluatePolynomial(coefficients, partyID)
+
+		err = session.send(ctx, partyID, thresholdKeygenRoundDecommit, &thresholdKeygenDecommitMessage{
+			Coefficients: coefficientsCommitments,
+			Nonce:        nonce,
+			ProofR:       pointToPublicKey(proofPoint).SerializeCompressed(),
+			ProofS:       scalarBytes(&proofS),
+			Share:        scalarBytes(share),
+			FacProof:     facProof,
+		})
+		share.Zero()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	decommitMessages, err := session.collect(ctx, thresholdKeygenRoundDecommit)
+	if err != nil {
+		return nil, err
+	}
+
+	secretShare := evaluatePolynomial(coefficients, params.PartyID)
+	defer secretShare.Zero()
+
+	allCommitments := map[uint32][]*btcec.JacobianPoint{}
+	ownCommitments := make([]*btcec.JacobianPoint, len(coefficientsCommitments))
+	for i, coefficient := range coefficientsCommitments {
+		ownCommitments[i], _ = parsePoint(coefficient)
+	}
+	allCommitments[params.PartyID] = ownCommitments
+
+	for partyID, payload := range decommitMessages {
+		decommit := &thresholdKeygenDecommitMessage{}
+		err = json.Unmarshal(payload, decommit)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrThresholdWrongMessage, err.Error())
+		}
+
+		commitments, loopErr := verifyKeygenDecommit(params, partyID, commits[partyID], decommit)
+		if loopErr != nil {
+			return nil, loopErr
+		}
+
+		loopErr = decommit.FacProof.verify(&proofContext{
+			sessionID:  params.SessionID,
+			proverID:   partyID,
+			verifierID: params.PartyID,
+		}, new(big.Int).SetBytes(commits[partyID].PaillierN), ringPedersen)
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: party %d", loopErr, partyID)
+		}
+
+		share, loopErr := scalarFromBytes(decommit.Share)
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong share of party %d", ErrThresholdProtocolAborted, partyID)
+		}
+
+		// Feldman VSS share verification
+		if !pointEquals(scalarBaseMult(share), evaluateCommitments(commitments, params.PartyID)) {
+			share.Zero()
+
+			return nil, fmt.Errorf("%w: share of party %d not match commitments",
+				ErrThresholdProtocolAborted, partyID)
+		}
+
+		secretShare.Add(share)
+		share.Zero()
+
+		allCommitments[partyID] = commitments
+	}
+
+	publicKey := &btcec.JacobianPoint{}
+	for _, commitments := range allCommitments {
+		publicKey = pointAdd(publicKey, commitments[0])
+	}
+
+	publicShares := make(map[uint32]hexutil.Bytes, len(parties))
+	for _, partyID := range parties {
+		publicShare := &btcec.JacobianPoint{}
+		for _, commitments := range allCommitments {
+			publicShare = pointAdd(publicShare, evaluateCommitments(commitments, partyID))
+		}
+
+		publicShares[partyID] = pointToPublicKey(publicShare).SerializeCompressed()
+	}
+
+	if subtle.ConstantTimeCompare(publicShares[params.PartyID],
+		pointToPublicKey(scalarBaseMult(secretShare)).SerializeCompressed()) != 1 {
+		return nil, fmt.Errorf("%w: secret share not match public share", ErrThresholdProtocolAborted)
+	}
+
+	return &thresholdKeyShare{
+		PartyID:            params.PartyID,
+		Threshold:          params.Threshold,
+		Parties:            parties,
+		Share:              scalarBytes(secretShare),
+		PublicKey:          pointToPublicKey(publicKey).SerializeCompressed(),
+		PublicShares:       publicShares,
+		PaillierP:          paillierKey.p.Bytes(),
+		PaillierQ:          paillierKey.q.Bytes(),
+		PaillierPublicKeys: paillierPublicKeys,
+		RingPedersenS:      ringPedersenS,
+		RingPedersenT:      ringPedersenT,
+	}, nil
+}
+
+// verifyKeygenPaillierKey - verify Paillier public key and ring-Pedersen params of party with proofs
+func verifyKeygenPaillierKey(sessionID string, partyID uint32, commit *thresholdKeygenCommitMessage) error {
+	n := new(big.Int).SetBytes(commit.PaillierN)
+	_, err := newPaillierPublicKey(n)
+	if err != nil {
+		return fmt.Errorf("%w: party %d", err, partyID)
+	}
+
+	ringPedersen, err := newRingPedersenParams(n, new(big.Int).SetBytes(commit.RingPedersenS),
+		new(big.Int).SetBytes(commit.RingPedersenT))
+	if err != nil {
+		return fmt.Errorf("%w: party %d", err, partyID)
+	}
+
+	proofCtx := &proofContext{sessionID: sessionID, proverID: partyID}
+
+	err = commit.ModProof.verify(proofCtx, n)
+	if err != nil {
+		return fmt.Errorf("%w: party %d", err, partyID)
+	}
+
+	err = commit.PrmProof.verify(proofCtx, ringPedersen)
+	if err != nil {
+		return fmt.Errorf("%w: party %d", err, partyID)
+	}
+
+	return nil
+}
+
+// verifyKeygenDecommit - verify commitment of party coefficients and Schnorr proof of free coefficient
+func verifyKeygenDecommit(params *thresholdKeygenParams,
+	partyID uint32,
+	commit *thresholdKeygenCommitMessage,
+	decommit *thresholdKeygenDecommitMessage,
+) ([]*btcec.JacobianPoint, error) {
+	if uint32(len(decommit.Coefficients)) != params.Threshold {
+		return nil, fmt.Errorf("%w: wrong coefficients count of party %d", ErrThresholdProtocolAborted, partyID)
+	}
+
+	commitment := keygenCommitment(params.SessionID, partyID, decommit.Coefficients, decommit.Nonce)
+	if subtle.ConstantTimeCompare(commitment, commit.Commitment) != 1 {
+		return nil, fmt.Errorf("%w: party %d", ErrThresholdCommitmentFailed, partyID)
+	}
+
+	commitments := make([]*btcec.JacobianPoint, len(decommit.Coefficients))
+	for i, coefficient := range decommit.Coefficients {
+		point, err := parsePoint(coefficient)
+		if err != nil {
+			return nil, fmt.Errorf("%w: wrong coefficient of party %d", ErrThresholdProtocolAborted, partyID)
+		}
+
+		commitments[i] = point
+	}
+
+	proofPoint, err := parsePoint(decommit.ProofR)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong proof of party %d", ErrThresholdProtocolAborted, partyID)
+	}
+
+	proofS, err := scalarFromBytes(decommit.ProofS)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong proof of party %d", ErrThresholdProtocolAborted, partyID)
+	}
+
+	// s*G == R + e*A_0
+	challenge := schnorrChallenge(params.SessionID, partyID, commitments[0], proofPoint)
+	if !pointEquals(scalarBaseMult(proofS), pointAdd(proofPoint, pointMult(challenge, commitments[0]))) {
+		return nil, fmt.Errorf("%w: proof of party %d", ErrThresholdCommitmentFailed, partyID)
+	}
+
+	return commitments, nil
+}
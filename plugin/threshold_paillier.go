@@ -0,0 +1,394 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// paillierPrimeBits - size of Paillier modulus safe primes, modulus size - 2048 bits
+const paillierPrimeBits = 1024
+
+// safePrimeSieveBound - bound of small primes for sieve of safe prime candidates
+const safePrimeSieveBound = 1 << 14
+
+var (
+	ErrPaillierWrongPublicKey  = errors.New("wrong paillier public key")
+	ErrPaillierWrongCiphertext = errors.New("wrong paillier ciphertext")
+	ErrRingPedersenWrongParams = errors.New("wrong ring-pedersen params")
+)
+
+// safePrimeSievePrimes - odd primes lower than safePrimeSieveBound
+var safePrimeSievePrimes = func() []uint64 {
+	result := make([]uint64, 0, 2048)
+	for candidate := uint64(3); candidate < safePrimeSieveBound; candidate += 2 {
+		isPrime := true
+		for _, prime := range result {
+			if prime*prime > candidate {
+				break
+			}
+
+			if candidate%prime == 0 {
+				isPrime = false
+
+				break
+			}
+		}
+
+		if isPrime {
+			result = append(result, candidate)
+		}
+	}
+
+	return result
+}()
+
+// paillierPublicKey - Paillier public key with generator N+1
+type paillierPublicKey struct {
+	n        *big.Int
+	nSquared *big.Int
+}
+
+// paillierPrivateKey - Paillier private key over Paillier-Blum modulus of safe primes,
+// phi - Euler's totient of N
+type paillierPrivateKey struct {
+	paillierPublicKey
+
+	p   *big.Int
+	q   *big.Int
+	phi *big.Int
+	// phiInv - inverse of phi modulo N
+	phiInv *big.Int
+}
+
+// ringPedersenParams - ring-Pedersen commitment params of party: s = t^lambda mod N, N - Paillier modulus of party.
+// Used by other parties as commitment params of range proofs to party
+type ringPedersenParams struct {
+	n *big.Int
+	s *big.Int
+	t *big.Int
+}
+
+func newPaillierPublicKey(n *big.Int) (*paillierPublicKey, error) {
+	if n.BitLen() < 2*paillierPrimeBits-1 || n.Bit(0) == 0 {
+		return nil, ErrPaillierWrongPublicKey
+	}
+
+	return &paillierPublicKey{
+		n:        n,
+		nSquared: new(big.Int).Mul(n, n),
+	}, nil
+}
+
+func generatePaillierKey() (*paillierPrivateKey, error) {
+	for {
+		p, err := generateSafePrime(paillierPrimeBits)
+		if err != nil {
+			return nil, err
+		}
+
+		q, err := generateSafePrime(paillierPrimeBits)
+		if err != nil {
+			return nil, err
+		}
+
+		privKey, err := newPaillierPrivateKey(p, q)
+		if err != nil {
+			continue
+		}
+
+		return privKey, nil
+	}
+}
+
+// generateSafePrime - random safe prime p = 2*p' + 1 with prime p' and two top bits set.
+// Safe prime is Blum prime - p = 3 mod 4. Candidates sieved by small primes before primality tests
+func generateSafePrime(bits int) (*big.Int, error) {
+	one, two := big.NewInt(1), big.NewInt(2)
+	residues := make([]uint64, len(safePrimeSievePrimes))
+
+	for {
+		// p' - odd number of bits-1 length with two top bits set
+		base, err := rand.Int(rand.Reader, new(big.Int).Lsh(one, uint(bits-1)))
+		if err != nil {
+			return nil, err
+		}
+
+		base.SetBit(base, bits-2, 1)
+		base.SetBit(base, bits-3, 1)
+		base.SetBit(base, 0, 1)
+
+		for i, prime := range safePrimeSievePrimes {
+			residues[i] = new(big.Int).Mod(base, new(big.Int).SetUint64(prime)).Uint64()
+		}
+
+	candidates:
+		for delta := uint64(0); delta < 1<<20; delta += 2 {
+			// p' and 2*p' + 1 must not be divisible by small primes
+			for i, prime := range safePrimeSievePrimes {
+				residue := (residues[i] + delta) % prime
+				if residue == 0 || (2*residue+1)%prime == 0 {
+					continue candidates
+				}
+			}
+
+			halfPrime := new(big.Int).Add(base, new(big.Int).SetUint64(delta))
+			prime := new(big.Int).Lsh(halfPrime, 1)
+			prime.Add(prime, one)
+			if prime.BitLen() != bits {
+				break
+			}
+
+			// cheap Fermat test of p before primality tests
+			if new(big.Int).Exp(two, new(big.Int).Sub(prime, one), prime).Cmp(one) != 0 {
+				continue
+			}
+
+			if halfPrime.ProbablyPrime(20) && prime.ProbablyPrime(20) {
+				return prime, nil
+			}
+		}
+	}
+}
+
+func newPaillierPrivateKey(p, q *big.Int) (*paillierPrivateKey, error) {
+	if p.Cmp(q) == 0 {
+		return nil, ErrPaillierWrongPublicKey
+	}
+
+	// Paillier-Blum modulus required by modulus proof - p = q = 3 mod 4
+	if p.Bit(0) == 0 || p.Bit(1) == 0 || q.Bit(0) == 0 || q.Bit(1) == 0 {
+		return nil, ErrPaillierWrongPublicKey
+	}
+
+	n := new(big.Int).Mul(p, q)
+	pubKey, err := newPaillierPublicKey(n)
+	if err != nil {
+		return nil, err
+	}
+
+	one := big.NewInt(1)
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+
+	phiInv := new(big.Int).ModInverse(phi, n)
+	if phiInv == nil {
+		return nil, ErrPaillierWrongPublicKey
+	}
+
+	return &paillierPrivateKey{
+		paillierPublicKey: *pubKey,
+		p:                 p,
+		q:                 q,
+		phi:               phi,
+		phiInv:            phiInv,
+	}, nil
+}
+
+// encrypt - c = (1 + m*N) * r^N mod N^2
+func (k *paillierPublicKey) encrypt(m *big.Int) (*big.Int, error) {
+	c, _, err := k.encryptWithRandomNonce(m)
+
+	return c, err
+}
+
+// encryptWithRandomNonce - encrypt plaintext in range [0, N) by random nonce r.
+// Returns ciphertext and nonce, nonce is witness of range proofs
+func (k *paillierPublicKey) encryptWithRandomNonce(m *big.Int) (*big.Int, *big.Int, error) {
+	if m.Sign() < 0 || m.Cmp(k.n) >= 0 {
+		return nil, nil, ErrPaillierWrongCiphertext
+	}
+
+	nonce, err := randomUnit(k.n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return k.encryptWithNonce(m, nonce), nonce, nil
+}
+
+// encryptWithNonce - c = (1 + N)^m * nonce^N mod N^2 = (1 + (m mod N)*N) * nonce^N mod N^2, m - any integer
+func (k *paillierPublicKey) encryptWithNonce(m, nonce *big.Int) *big.Int {
+	c := new(big.Int).Mod(m, k.n)
+	c.Mul(c, k.n)
+	c.Add(c, big.NewInt(1))
+	c.Mul(c, new(big.Int).Exp(nonce, k.n, k.nSquared))
+
+	return c.Mod(c, k.nSquared)
+}
+
+// add - homomorphic addition of plaintexts
+func (k *paillierPublicKey) add(c1, c2 *big.Int) *big.Int {
+	result := new(big.Int).Mul(c1, c2)
+
+	return result.Mod(result, k.nSquared)
+}
+
+// mul - homomorphic multiplication of plaintext by constant
+func (k *paillierPublicKey) mul(c, constant *big.Int) *big.Int {
+	return new(big.Int).Exp(c, constant, k.nSquared)
+}
+
+// validateCiphertext - ciphertext must be in range (0, N^2) and co-prime with N
+func (k *paillierPublicKey) validateCiphertext(c *big.Int) error {
+	if c.Sign() <= 0 || c.Cmp(k.nSquared) >= 0 ||
+		new(big.Int).GCD(nil, nil, c, k.n).Cmp(big.NewInt(1)) != 0 {
+		return ErrPaillierWrongCiphertext
+	}
+
+	return nil
+}
+
+// decrypt - m = L(c^phi mod N^2) * phi^-1 mod N, L(u) = (u - 1) / N
+func (k *paillierPrivateKey) decrypt(c *big.Int) (*big.Int, error) {
+	err := k.validateCiphertext(c)
+	if err != nil {
+		return nil, err
+	}
+
+	u := new(big.Int).Exp(c, k.phi, k.nSquared)
+	u.Sub(u, big.NewInt(1))
+	u.Div(u, k.n)
+	u.Mul(u, k.phiInv)
+
+	return u.Mod(u, k.n), nil
+}
+
+// decryptSigned - decrypt plaintext in range (-N/2, N/2], range proofs bound plaintexts by signed ranges
+func (k *paillierPrivateKey) decryptSigned(c *big.Int) (*big.Int, error) {
+	m, err := k.decrypt(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Cmp(new(big.Int).Rsh(k.n, 1)) > 0 {
+		m.Sub(m, k.n)
+	}
+
+	return m, nil
+}
+
+// generateRingPedersenParams - ring-Pedersen params over Paillier modulus: t = tau^2, s = t^lambda mod N.
+// Returns params and lambda - witness of params proof
+func (k *paillierPrivateKey) generateRingPedersenParams() (*ringPedersenParams, *big.Int, error) {
+	for {
+		tau, err := randomUnit(k.n)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lambda, err := rand.Int(rand.Reader, k.phi)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		t := new(big.Int).Exp(tau, big.NewInt(2), k.n)
+		s := new(big.Int).Exp(t, lambda, k.n)
+
+		params, err := newRingPedersenParams(k.n, s, t)
+		if err != nil {
+			continue
+		}
+
+		return params, lambda, nil
+	}
+}
+
+func (k *paillierPrivateKey) zero() {
+	for _, value := range []*big.Int{k.p, k.q, k.phi, k.phiInv} {
+		if value != nil {
+			value.SetInt64(0)
+		}
+	}
+}
+
+// newRingPedersenParams - s and t must be co-prime with N, not equal with each other and not equal with 1
+func newRingPedersenParams(n, s, t *big.Int) (*ringPedersenParams, error) {
+	one := big.NewInt(1)
+	if !isUnit(s, n) || !isUnit(t, n) || s.Cmp(one) == 0 || t.Cmp(one) == 0 || s.Cmp(t) == 0 {
+		return nil, ErrRingPedersenWrongParams
+	}
+
+	return &ringPedersenParams{
+		n: n,
+		s: s,
+		t: t,
+	}, nil
+}
+
+// commit - s^x * t^y mod N, x and y - any integers
+func (p *ringPedersenParams) commit(x, y *big.Int) *big.Int {
+	return mulMod(expMod(p.s, x, p.n), expMod(p.t, y, p.n), p.n)
+}
+
+// randomUnit - random element of multiplicative group of integers modulo n
+func randomUnit(n *big.Int) (*big.Int, error) {
+	for {
+		r, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return nil, err
+		}
+
+		if isUnit(r, n) {
+			return r, nil
+		}
+	}
+}
+
+// isUnit - value in range [1, n) and co-prime with n
+func isUnit(value, n *big.Int) bool {
+	return value != nil && value.Sign() > 0 && value.Cmp(n) < 0 &&
+		new(big.Int).GCD(nil, nil, value, n).Cmp(big.NewInt(1)) == 0
+}
+
+// expMod - base^exponent mod modulus, negative exponent - power of base inverse.
+// Returns zero if exponent negative and base not co-prime with modulus
+func expMod(base, exponent, modulus *big.Int) *big.Int {
+	if exponent.Sign() >= 0 {
+		return new(big.Int).Exp(base, exponent, modulus)
+	}
+
+	inverse := new(big.Int).ModInverse(base, modulus)
+	if inverse == nil {
+		return new(big.Int)
+	}
+
+	return inverse.Exp(inverse, new(big.Int).Neg(exponent), modulus)
+}
+
+func mulMod(a, b, modulus *big.Int) *big.Int {
+	result := new(big.Int).Mul(a, b)
+
+	return result.Mod(result, modulus)
+}
@@ -0,0 +1,654 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var (
+	ErrThresholdSigningSessionRequired = errors.New("threshold signing requires signing session params")
+	ErrThresholdAccountNotFound        = errors.New("account not found in threshold pool unit")
+	ErrThresholdUnitUnloaded           = errors.New("threshold pool unit unloaded")
+)
+
+// thresholdWalletUnit - pool unit of threshold ECDSA (t-of-n) key share. Complete signing key never
+// exists in one pool unit - signing is multi-round GG18 protocol between threshold count of units
+// with CGGMP zero-knowledge proofs of Paillier keys and MtA ranges.
+// Protocol messages exchanged by host-provided transport.
+// Security model - misbehaving party detected by proofs or by final signature verification, both cases abort
+// signing without identification of party. Transport must be authenticated and confidential.
+// Unit contains single account - group address. Account parameters of unit methods can contain:
+//   - StringValue - hex group address
+//   - DerivationAddressIdentity - only with zero indexes
+type thresholdWalletUnit struct {
+	mu *sync.Mutex
+
+	dataSigner types.Signer
+	router     *thresholdRouter
+
+	walletUUID string
+
+	partyID   uint32
+	threshold uint32
+	parties   []uint32
+
+	// share - secret share of signing key
+	share        *btcec.ModNScalar
+	publicKey    *btcec.PublicKey
+	publicShares map[uint32]*btcec.PublicKey
+
+	paillierKey        *paillierPrivateKey
+	paillierPublicKeys map[uint32]*paillierPublicKey
+	// ringPedersenParams - ring-Pedersen params of every party, used by range proofs to party
+	ringPedersenParams map[uint32]*ringPedersenParams
+
+	address string
+	// signingGate - pending signatures of two-phase signing and single-step signing gate
+	signingGate *twoStepSigningGate
+}
+
+// GenerateThresholdPoolUnit - run distributed key generation with other parties and create pool unit
+// with key share of current party. All parties must call function with same session ID concurrently.
+// keygenParamsData - JSON-encoded thresholdKeygenParams.
+// transport - host implementation of Send(ctx, toPartyID uint32, message []byte) error and
+// Receive(ctx) (fromPartyID uint32, message []byte, err error) methods
+func GenerateThresholdPoolUnit(ctx context.Context,
+	walletUUID string,
+	keygenParamsData []byte,
+	transport interface{},
+) (interface{}, error) {
+	params := &thresholdKeygenParams{}
+	err := json.Unmarshal(keygenParamsData, params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrThresholdWrongParams, err.Error())
+	}
+
+	err = params.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	router, err := newThresholdRouter(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	keyShare, err := runThresholdKeygen(ctx, router, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return newThresholdPoolUnit(walletUUID, keyShare, router)
+}
+
+// NewThresholdPoolUnit - create pool unit by previously generated key share.
+// keyShareData - JSON-encoded key share, returned by ExportKeyShare pool unit method
+func NewThresholdPoolUnit(walletUUID string,
+	keyShareData []byte,
+	transport interface{},
+) (interface{}, error) {
+	keyShare := &thresholdKeyShare{}
+	err := json.Unmarshal(keyShareData, keyShare)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrThresholdWrongKeyShare, err.Error())
+	}
+
+	router, err := newThresholdRouter(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	return newThresholdPoolUnit(walletUUID, keyShare, router)
+}
+
+func newThresholdPoolUnit(walletUUID string,
+	keyShare *thresholdKeyShare,
+	router *thresholdRouter,
+) (*thresholdWalletUnit, error) {
+	parties := append([]uint32{}, keyShare.Parties...)
+	sort.Slice(parties, func(i, j int) bool {
+		return parties[i] < parties[j]
+	})
+
+	if len(parties) < 2 || len(parties) > thresholdMaxPartiesCount ||
+		keyShare.Threshold < 2 || keyShare.Threshold > uint32(len(parties)) {
+		return nil, fmt.Errorf("%w: wrong parties or threshold", ErrThresholdWrongKeyShare)
+	}
+
+	for i, partyID := range parties {
+		if partyID == 0 || (i > 0 && parties[i-1] == partyID) {
+			return nil, fmt.Errorf("%w: wrong party id %d", ErrThresholdWrongKeyShare, partyID)
+		}
+	}
+
+	share, err := scalarFromBytes(keyShare.Share)
+	if err != nil || share.IsZero() {
+		return nil, fmt.Errorf("%w: wrong share", ErrThresholdWrongKeyShare)
+	}
+
+	publicKey, err := btcec.ParsePubKey(keyShare.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong public key", ErrThresholdWrongKeyShare)
+	}
+
+	unit := &thresholdWalletUnit{
+		mu:                 &sync.Mutex{},
+		dataSigner:         pluginSigner,
+		router:             router,
+		walletUUID:         walletUUID,
+		partyID:            keyShare.PartyID,
+		threshold:          keyShare.Threshold,
+		parties:            parties,
+		share:              share,
+		publicKey:          publicKey,
+		publicShares:       make(map[uint32]*btcec.PublicKey, len(parties)),
+		paillierPublicKeys: make(map[uint32]*paillierPublicKey, len(parties)),
+		ringPedersenParams: make(map[uint32]*ringPedersenParams, len(parties)),
+		address:            crypto.PubkeyToAddress(*publicKey.ToECDSA()).Hex(),
+		signingGate:        newTwoStepSigningGate(),
+	}
+
+	for _, partyID := range parties {
+		publicShare, loopErr := btcec.ParsePubKey(keyShare.PublicShares[partyID])
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong public share of party %d", ErrThresholdWrongKeyShare, partyID)
+		}
+
+		paillierPubKey, loopErr := newPaillierPublicKey(new(big.Int).SetBytes(keyShare.PaillierPublicKeys[partyID]))
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong paillier public key of party %d", ErrThresholdWrongKeyShare, partyID)
+		}
+
+		ringPedersen, loopErr := newRingPedersenParams(paillierPubKey.n,
+			new(big.Int).SetBytes(keyShare.RingPedersenS[partyID]),
+			new(big.Int).SetBytes(keyShare.RingPedersenT[partyID]))
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong ring-pedersen params of party %d", ErrThresholdWrongKeyShare, partyID)
+		}
+
+		unit.publicShares[partyID] = publicShare
+		unit.paillierPublicKeys[partyID] = paillierPubKey
+		unit.ringPedersenParams[partyID] = ringPedersen
+	}
+
+	ownPublicShare, isExists := unit.publicShares[unit.partyID]
+	if !isExists || !pointToPublicKey(scalarBaseMult(share)).IsEqual(ownPublicShare) {
+		return nil, fmt.Errorf("%w: share not match public share", ErrThresholdWrongKeyShare)
+	}
+
+	unit.paillierKey, err = newPaillierPrivateKey(new(big.Int).SetBytes(keyShare.PaillierP),
+		new(big.Int).SetBytes(keyShare.PaillierQ))
+	if err != nil || unit.paillierKey.n.Cmp(unit.paillierPublicKeys[unit.partyID].n) != 0 {
+		return nil, fmt.Errorf("%w: wrong paillier private key", ErrThresholdWrongKeyShare)
+	}
+
+	return unit, nil
+}
+
+func (u *thresholdWalletUnit) Shutdown(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	err := u.unloadWallet()
+	if err != nil {
+		return fmt.Errorf("unable to unload wallet: %w", err)
+	}
+
+	return nil
+}
+
+func (u *thresholdWalletUnit) UnloadWallet() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.unloadWallet()
+}
+
+func (u *thresholdWalletUnit) unloadWallet() error {
+	if u.share != nil {
+		u.share.Zero()
+		u.share = nil
+	}
+
+	if u.paillierKey != nil {
+		u.paillierKey.zero()
+		u.paillierKey = nil
+	}
+
+	u.router = nil
+	u.publicShares = nil
+	u.paillierPublicKeys = nil
+	u.ringPedersenParams = nil
+	u.signingGate.clear()
+	u.address = ""
+	u.walletUUID = "0"
+
+	return nil
+}
+
+func (u *thresholdWalletUnit) GetWalletUUID() string {
+	return u.walletUUID
+}
+
+func (u *thresholdWalletUnit) LoadAccount(ctx context.Context,
+	accountParameters *anypb.Any,
+) (*string, error) {
+	return u.GetAccountAddress(ctx, accountParameters)
+}
+
+func (u *thresholdWalletUnit) GetAccountAddress(_ context.Context,
+	accountParameters *anypb.Any,
+) (*string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	err := u.resolveAccount(accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	address := u.address
+
+	return &address, nil
+}
+
+// SignData - not supported by threshold pool unit, SignDataThreshold must be used
+func (u *thresholdWalletUnit) SignData(_ context.Context,
+	_ *anypb.Any,
+	_ []byte,
+) (*string, []byte, error) {
+	return nil, nil, ErrThresholdSigningSessionRequired
+}
+
+// SignDataThreshold - sign binary encoded transaction by threshold signing session.
+// All signing parties must call method with same session params and transaction data concurrently.
+// signingParamsData - JSON-encoded thresholdSigningParams
+func (u *thresholdWalletUnit) SignDataThreshold(ctx context.Context,
+	accountParameters *anypb.Any,
+	signingParamsData []byte,
+	dataForSign []byte,
+) (*string, []byte, error) {
+	params := &thresholdSigningParams{}
+	err := json.Unmarshal(signingParamsData, params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrThresholdWrongParams, err.Error())
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := u.signingSource(accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return u.signDecodedTx(ctx, accountParameters, params, source, decodedTx)
+}
+
+// PrepareSignThreshold - first step of two-phase threshold signing. Returns JSON-encoded preparedSignature with
+// transaction summary and single-use approval token, signing session not started.
+// Approval token bound to session params and transaction. Refused if approval token verifier not configured
+func (u *thresholdWalletUnit) PrepareSignThreshold(_ context.Context,
+	accountParameters *anypb.Any,
+	signingParamsData []byte,
+	dataForSign []byte,
+) ([]byte, error) {
+	if approvalTokenVerifier == nil {
+		return nil, ErrApprovalVerifierNotConfigured
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, err
+	}
+
+	signingHash, err := decodedTx.signingHash(u.dataSigner)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := u.signingSource(accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	approvalToken, expiresAt, err := u.signingGate.prepare(&pendingSignature{
+		address:     source.address,
+		payloadHash: common.BytesToHash(thresholdHash(signingParamsData, dataForSign)),
+		signingHash: signingHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&preparedSignature{
+		Address:       source.address,
+		SigningHash:   signingHash,
+		Summary:       decodedTx.summary(source.address, u.dataSigner),
+		ApprovalToken: approvalToken,
+		ExpiresAt:     expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// CommitSignThreshold - second step of two-phase threshold signing. Start signing session only with valid and
+// unexpired approval token, session params and transaction identical to PrepareSignThreshold call,
+// approved by approval token verifier. Approval token consumed by any CommitSignThreshold call
+func (u *thresholdWalletUnit) CommitSignThreshold(ctx context.Context,
+	accountParameters *anypb.Any,
+	signingParamsData []byte,
+	dataForSign []byte,
+	approvalToken string,
+) (*string, []byte, error) {
+	if approvalTokenVerifier == nil {
+		return nil, nil, ErrApprovalVerifierNotConfigured
+	}
+
+	params := &thresholdSigningParams{}
+	err := json.Unmarshal(signingParamsData, params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrThresholdWrongParams, err.Error())
+	}
+
+	decodedTx, err := decodeSigningTx(u.dataSigner, dataForSign)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signingHash, err := decodedTx.signingHash(u.dataSigner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := u.signingSource(accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = u.signingGate.commit(u.walletUUID, approvalToken, &pendingSignature{
+		address:     source.address,
+		payloadHash: common.BytesToHash(thresholdHash(signingParamsData, dataForSign)),
+		signingHash: signingHash,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return u.signDecodedTx(withApprovedByToken(ctx), accountParameters, params, source, decodedTx)
+}
+
+// RequireTwoStepSigning - disable single-step signing of transactions for current party. After call transactions
+// signed only by CommitSignThreshold with approved token, SignDataThreshold refused. Gate can't be disabled.
+// Approval token verifier must be configured before call
+func (u *thresholdWalletUnit) RequireTwoStepSigning() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.share == nil {
+		return ErrThresholdUnitUnloaded
+	}
+
+	return u.signingGate.require()
+}
+
+// signDecodedTx - check signing flow, request sign approver decision and sign decoded transaction
+// by threshold signing session
+func (u *thresholdWalletUnit) signDecodedTx(ctx context.Context,
+	accountParameters *anypb.Any,
+	params *thresholdSigningParams,
+	source *signingSource,
+	decodedTx *signingTx,
+) (*string, []byte, error) {
+	err := u.signingGate.check(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// sign approver can be slow external service - decision requested before unit lock
+	err = approveTxSignature(ctx, source, decodedTx, u.dataSigner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	err = u.resolveAccount(accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = u.validateSigningParams(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signedTxRawData, err := signRawTransactionDataByHash(u.dataSigner, decodedTx,
+		func(hash []byte) ([]byte, error) {
+			return u.thresholdSign(ctx, params, hash)
+		}, source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address := u.address
+
+	return &address, signedTxRawData, nil
+}
+
+// signingSource - audit source of threshold key
+func (u *thresholdWalletUnit) signingSource(accountParameters *anypb.Any) (*signingSource, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	err := u.resolveAccount(accountParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signingSource{
+		walletUUID: u.walletUUID,
+		address:    common.HexToAddress(u.address),
+	}, nil
+}
+
+// ExportKeyShare - returns JSON-encoded key share of current party for persistent storage by host.
+// Key share contains secret values and must be stored same as mnemonic
+func (u *thresholdWalletUnit) ExportKeyShare() ([]byte, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.share == nil {
+		return nil, ErrThresholdUnitUnloaded
+	}
+
+	keyShare := &thresholdKeyShare{
+		PartyID:            u.partyID,
+		Threshold:          u.threshold,
+		Parties:            u.parties,
+		Share:              scalarBytes(u.share),
+		PublicKey:          u.publicKey.SerializeCompressed(),
+		PublicShares:       make(map[uint32]hexutil.Bytes, len(u.parties)),
+		PaillierP:          u.paillierKey.p.Bytes(),
+		PaillierQ:          u.paillierKey.q.Bytes(),
+		PaillierPublicKeys: make(map[uint32]hexutil.Bytes, len(u.parties)),
+		RingPedersenS:      make(map[uint32]hexutil.Bytes, len(u.parties)),
+		RingPedersenT:      make(map[uint32]hexutil.Bytes, len(u.parties)),
+	}
+
+	for _, partyID := range u.parties {
+		keyShare.PublicShares[partyID] = u.publicShares[partyID].SerializeCompressed()
+		keyShare.PaillierPublicKeys[partyID] = u.paillierPublicKeys[partyID].n.Bytes()
+		keyShare.RingPedersenS[partyID] = u.ringPedersenParams[partyID].s.Bytes()
+		keyShare.RingPedersenT[partyID] = u.ringPedersenParams[partyID].t.Bytes()
+	}
+
+	return json.Marshal(keyShare)
+}
+
+func (u *thresholdWalletUnit) resolveAccount(accountParameters *anypb.Any) error {
+	if u.share == nil {
+		return ErrThresholdUnitUnloaded
+	}
+
+	addressIdentity := &wrapperspb.StringValue{}
+	if accountParameters.MessageIs(addressIdentity) {
+		err := accountParameters.UnmarshalTo(addressIdentity)
+		if err != nil {
+			return err
+		}
+
+		if !common.IsHexAddress(addressIdentity.GetValue()) ||
+			!strings.EqualFold(common.HexToAddress(addressIdentity.GetValue()).Hex(), u.address) {
+			return fmt.Errorf("%w: %s", ErrThresholdAccountNotFound, addressIdentity.GetValue())
+		}
+
+		return nil
+	}
+
+	accIdentity := &pbCommon.DerivationAddressIdentity{}
+	err := accountParameters.UnmarshalTo(accIdentity)
+	if err != nil {
+		return err
+	}
+
+	if accIdentity.AccountIndex != 0 || accIdentity.InternalIndex != 0 || accIdentity.AddressIndex != 0 {
+		return fmt.Errorf("%w: %d'/%d/%d", ErrThresholdAccountNotFound,
+			accIdentity.AccountIndex, accIdentity.InternalIndex, accIdentity.AddressIndex)
+	}
+
+	return nil
+}
+
+func (u *thresholdWalletUnit) validateSigningParams(params *thresholdSigningParams) error {
+	if params.SessionID == "" {
+		return fmt.Errorf("%w: session id is empty", ErrThresholdWrongParams)
+	}
+
+	if uint32(len(params.Parties)) < u.threshold {
+		return fmt.Errorf("%w: signing parties count less than threshold %d", ErrThresholdWrongParams,
+			u.threshold)
+	}
+
+	isMember := false
+	uniqueParties := make(map[uint32]struct{}, len(params.Parties))
+	for _, partyID := range params.Parties {
+		if _, isExists := u.publicShares[partyID]; !isExists {
+			return fmt.Errorf("%w: unknown party %d", ErrThresholdWrongParams, partyID)
+		}
+
+		if _, isExists := uniqueParties[partyID]; isExists {
+			return fmt.Errorf("%w: duplicated party %d", ErrThresholdWrongParams, partyID)
+		}
+
+		uniqueParties[partyID] = struct{}{}
+		isMember = isMember || partyID == u.partyID
+	}
+
+	if !isMember {
+		return fmt.Errorf("%w: party %d", ErrThresholdPartyNotInSession, u.partyID)
+	}
+
+	return nil
+}
+
+// signRawTransactionDataByHash - sign decoded transaction by external signature function,
+// which returns 65 bytes signature of transaction hash in crypto.Sign format. Signature recorded to audit log.
+// Sign approver decision must be requested by caller before signing
+func signRawTransactionDataByHash(signer types.Signer,
+	decodedTx *signingTx,
+	signHash func(hash []byte) ([]byte, error),
+	source *signingSource,
+) ([]byte, error) {
+	// signing hash also validates chain ID of transaction
+	sigHash, err := decodedTx.signingHash(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := signHash(sigHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	if decodedTx.celoTx != nil {
+		signedTx := *decodedTx.celoTx
+		signedTx.R = new(big.Int).SetBytes(sig[:32])
+		signedTx.S = new(big.Int).SetBytes(sig[32:64])
+		signedTx.V = new(big.Int).SetBytes([]byte{sig[64]})
+
+		err = auditCeloTxSignature(source, &signedTx)
+		if err != nil {
+			return nil, err
+		}
+
+		return signedTx.MarshalBinary()
+	}
+
+	signedTx, err := decodedTx.tx.WithSignature(signer, sig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = auditTxSignature(source, signedTx)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTxRawData, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign: %w", err)
+	}
+
+	return signedTxRawData, nil
+}
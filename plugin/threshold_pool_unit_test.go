@@ -0,0 +1,508 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type memoryTransportEnvelope struct {
+	from    uint32
+	message []byte
+}
+
+// memoryTransportHub - in-process transport of threshold protocol messages between parties
+type memoryTransportHub struct {
+	inboxes map[uint32]chan *memoryTransportEnvelope
+}
+
+type memoryTransport struct {
+	partyID uint32
+	hub     *memoryTransportHub
+}
+
+func newMemoryTransportHub(partiesCount uint32) *memoryTransportHub {
+	hub := &memoryTransportHub{inboxes: make(map[uint32]chan *memoryTransportEnvelope)}
+	for partyID := uint32(1); partyID <= partiesCount; partyID++ {
+		hub.inboxes[partyID] = make(chan *memoryTransportEnvelope, 1024)
+	}
+
+	return hub
+}
+
+func (h *memoryTransportHub) transport(partyID uint32) *memoryTransport {
+	return &memoryTransport{partyID: partyID, hub: h}
+}
+
+func (t *memoryTransport) Send(ctx context.Context, toPartyID uint32, message []byte) error {
+	inbox, isExists := t.hub.inboxes[toPartyID]
+	if !isExists {
+		return fmt.Errorf("unknown party %d", toPartyID)
+	}
+
+	select {
+	case inbox <- &memoryTransportEnvelope{from: t.partyID, message: message}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *memoryTransport) Receive(ctx context.Context) (uint32, []byte, error) {
+	select {
+	case envelope := <-t.hub.inboxes[t.partyID]:
+		return envelope.from, envelope.message, nil
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// interceptTransport - transport of party with hook of outgoing protocol messages
+type interceptTransport struct {
+	thresholdTransport
+	intercept func(toPartyID uint32, message *thresholdMessage)
+}
+
+func (t *interceptTransport) Send(ctx context.Context, toPartyID uint32, messageData []byte) error {
+	message := &thresholdMessage{}
+	err := json.Unmarshal(messageData, message)
+	if err != nil {
+		return err
+	}
+
+	t.intercept(toPartyID, message)
+
+	messageData, err = json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	return t.thresholdTransport.Send(ctx, toPartyID, messageData)
+}
+
+func signThresholdTx(t *testing.T,
+	units map[uint32]*thresholdWalletUnit,
+	parties []uint32,
+	sessionID string,
+	rawTx []byte,
+) [][]byte {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{})
+	signingParams := []byte(fmt.Sprintf(`{"sessionId":"%s","parties":[%d,%d]}`, sessionID, parties[0], parties[1]))
+
+	results := make([][]byte, len(parties))
+	errs := make([]error, len(parties))
+	wg := sync.WaitGroup{}
+	for i, partyID := range parties {
+		wg.Add(1)
+		go func(i int, unit *thresholdWalletUnit) {
+			defer wg.Done()
+			_, results[i], errs[i] = unit.SignDataThreshold(ctx, accountIdentity, signingParams, rawTx)
+		}(i, units[partyID])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to sign transaction by threshold session", err)
+		}
+	}
+
+	return results
+}
+
+func TestThresholdWalletUnit_KeygenAndSign(t *testing.T) {
+	const partiesCount = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	hub := newMemoryTransportHub(partiesCount)
+	units := make(map[uint32]*thresholdWalletUnit, partiesCount)
+	errs := make([]error, partiesCount+1)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for partyID := uint32(1); partyID <= partiesCount; partyID++ {
+		wg.Add(1)
+		go func(partyID uint32) {
+			defer wg.Done()
+
+			keygenParams := []byte(fmt.Sprintf(`{"sessionId":"keygen-1","partyId":%d,"threshold":2,"partiesCount":%d}`,
+				partyID, partiesCount))
+
+			unitIntrf, err := GenerateThresholdPoolUnit(ctx, uuid.NewString(), keygenParams, hub.transport(partyID))
+			if err != nil {
+				errs[partyID] = err
+
+				return
+			}
+
+			mu.Lock()
+			units[partyID] = unitIntrf.(*thresholdWalletUnit)
+			mu.Unlock()
+		}(partyID)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to generate threshold key", err)
+		}
+	}
+
+	groupAddress := units[1].address
+	for _, unit := range units {
+		if unit.address != groupAddress {
+			t.Fatalf("%s", "group address of parties not equal")
+		}
+	}
+
+	// any threshold count of shares must reconstruct key of group address
+	var privKeyScalar btcec.ModNScalar
+	for _, partyID := range []uint32{1, 3} {
+		var term btcec.ModNScalar
+		term.Mul2(lagrangeCoefficient(partyID, []uint32{1, 3}), units[partyID].share)
+		privKeyScalar.Add(&term)
+	}
+
+	privKey := btcec.PrivKeyFromScalar(&privKeyScalar).ToECDSA()
+	if crypto.PubkeyToAddress(privKey.PublicKey).Hex() != groupAddress {
+		t.Fatalf("%s", "reconstructed key not match group address")
+	}
+
+	to := common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+	rawTx, err := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   pluginSigner.ChainID(),
+		Nonce:     1,
+		GasTipCap: big.NewInt(1_000_000_000),
+		GasFeeCap: big.NewInt(30_000_000_000),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1_000_000_000_000_000),
+	}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	for i, parties := range [][]uint32{{1, 3}, {2, 3}} {
+		results := signThresholdTx(t, units, parties, fmt.Sprintf("sign-%d", i), rawTx)
+
+		for _, signedTxData := range results {
+			signedTx := &types.Transaction{}
+			err = signedTx.UnmarshalBinary(signedTxData)
+			if err != nil {
+				t.Fatalf("%s: %e", "unable to unmarshal signed transaction", err)
+			}
+
+			sender, loopErr := types.Sender(pluginSigner, signedTx)
+			if loopErr != nil {
+				t.Fatalf("%s: %e", "unable to recover transaction sender", loopErr)
+			}
+
+			if sender.Hex() != groupAddress {
+				t.Fatalf("%s", "transaction sender not equal with group address")
+			}
+		}
+	}
+
+	// restored key share
+	keyShareData, err := units[2].ExportKeyShare()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to export key share", err)
+	}
+
+	// key share without ring-Pedersen params - range proofs to party impossible
+	legacyKeyShare := &thresholdKeyShare{}
+	_ = json.Unmarshal(keyShareData, legacyKeyShare)
+	legacyKeyShare.RingPedersenS = nil
+	legacyKeyShare.RingPedersenT = nil
+	legacyKeyShareData, _ := json.Marshal(legacyKeyShare)
+
+	_, err = NewThresholdPoolUnit(uuid.NewString(), legacyKeyShareData, newMemoryTransportHub(partiesCount).transport(2))
+	if !errors.Is(err, ErrThresholdWrongKeyShare) {
+		t.Fatalf("%s", "key share without ring-pedersen params must not be accepted")
+	}
+
+	restoredHub := newMemoryTransportHub(partiesCount)
+	units[1].router.transport = restoredHub.transport(1)
+
+	restoredUnitIntrf, err := NewThresholdPoolUnit(uuid.NewString(), keyShareData, restoredHub.transport(2))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to restore threshold pool unit", err)
+	}
+	units[2] = restoredUnitIntrf.(*thresholdWalletUnit)
+
+	results := signThresholdTx(t, units, []uint32{1, 2}, "sign-restored", rawTx)
+
+	signedTx := &types.Transaction{}
+	_ = signedTx.UnmarshalBinary(results[0])
+	sender, err := types.Sender(pluginSigner, signedTx)
+	if err != nil || sender.Hex() != groupAddress {
+		t.Fatalf("%s", "restored unit transaction sender not equal with group address")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{})
+	_, _, err = units[1].SignData(ctx, accountIdentity, rawTx)
+	if !errors.Is(err, ErrThresholdSigningSessionRequired) {
+		t.Fatalf("%s", "signing without session must not be supported")
+	}
+
+	_, _, err = units[1].SignDataThreshold(ctx, accountIdentity, []byte(`{"sessionId":"sign-x","parties":[1]}`), rawTx)
+	if !errors.Is(err, ErrThresholdWrongParams) {
+		t.Fatalf("%s", "signing parties count less than threshold must not be accepted")
+	}
+
+	_, _, err = units[1].SignDataThreshold(ctx, accountIdentity, []byte(`{"sessionId":"sign-x","parties":[2,3]}`), rawTx)
+	if !errors.Is(err, ErrThresholdPartyNotInSession) {
+		t.Fatalf("%s", "signing session without current party must not be accepted")
+	}
+
+	wrongAccountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{AddressIndex: 1})
+	_, err = units[1].GetAccountAddress(ctx, wrongAccountIdentity)
+	if !errors.Is(err, ErrThresholdAccountNotFound) {
+		t.Fatalf("%s", "unknown account must not be found")
+	}
+
+	// party 1 sends tampered delta share - party 2 must abort before signature share
+	tamperedHub := newMemoryTransportHub(partiesCount)
+	units[1].router.transport = &interceptTransport{
+		thresholdTransport: tamperedHub.transport(1),
+		intercept: func(_ uint32, message *thresholdMessage) {
+			if message.Round != thresholdSignRoundDelta {
+				return
+			}
+
+			deltaMessage := &thresholdSignDeltaMessage{}
+			_ = json.Unmarshal(message.Payload, deltaMessage)
+
+			tamperedDelta, _ := scalarFromBytes(deltaMessage.Delta)
+			tamperedDelta.Add(new(btcec.ModNScalar).SetInt(1))
+			deltaMessage.Delta = scalarBytes(tamperedDelta)
+
+			message.Payload, _ = json.Marshal(deltaMessage)
+		},
+	}
+
+	sentRounds := make(map[uint32]bool)
+	sentRoundsMu := sync.Mutex{}
+	units[2].router.transport = &interceptTransport{
+		thresholdTransport: tamperedHub.transport(2),
+		intercept: func(_ uint32, message *thresholdMessage) {
+			sentRoundsMu.Lock()
+			sentRounds[message.Round] = true
+			sentRoundsMu.Unlock()
+		},
+	}
+
+	tamperedCtx, tamperedCancel := context.WithCancel(ctx)
+	tamperedParams := []byte(`{"sessionId":"sign-tampered","parties":[1,2]}`)
+	tamperedErrs := make([]error, 2)
+	for i, partyID := range []uint32{1, 2} {
+		wg.Add(1)
+		go func(i int, unit *thresholdWalletUnit) {
+			defer wg.Done()
+			_, _, tamperedErrs[i] = unit.SignDataThreshold(tamperedCtx, accountIdentity, tamperedParams, rawTx)
+			if i == 1 {
+				// tampering party waits for signature share of honest party
+				tamperedCancel()
+			}
+		}(i, units[partyID])
+	}
+	wg.Wait()
+	tamperedCancel()
+
+	if !errors.Is(tamperedErrs[1], ErrThresholdProtocolAborted) {
+		t.Fatalf("%s", "signing with tampered delta must be aborted")
+	}
+
+	if !sentRounds[thresholdSignRoundDelta] || sentRounds[thresholdSignRoundSignature] {
+		t.Fatalf("%s", "signature share must not be sent after tampered delta")
+	}
+
+	units[1].router.transport = restoredHub.transport(1)
+	units[2].router.transport = restoredHub.transport(2)
+
+	// two-step signing gate of parties
+	verifier := &stubApprovalTokenVerifier{approvedTokens: make(map[string]bool)}
+	approvalTokenVerifier = verifier
+	defer func() {
+		approvalTokenVerifier = nil
+	}()
+
+	signingParams := []byte(`{"sessionId":"sign-two-step","parties":[1,2]}`)
+	for _, partyID := range []uint32{1, 2} {
+		err = units[partyID].RequireTwoStepSigning()
+		if err != nil {
+			t.Fatalf("%s: %e", "unable to require two-step signing", err)
+		}
+
+		_, _, err = units[partyID].SignDataThreshold(ctx, accountIdentity, signingParams, rawTx)
+		if !errors.Is(err, ErrSingleStepSigningDisabled) {
+			t.Fatalf("%s", "single-step threshold signing must be refused")
+		}
+	}
+
+	approvalTokens := make(map[uint32]string, 2)
+	for _, partyID := range []uint32{1, 2} {
+		preparedData, loopErr := units[partyID].PrepareSignThreshold(ctx, accountIdentity, signingParams, rawTx)
+		if loopErr != nil {
+			t.Fatalf("%s: %e", "unable to prepare threshold signature", loopErr)
+		}
+
+		prepared := &preparedSignature{}
+		_ = json.Unmarshal(preparedData, prepared)
+		if prepared.Address.Hex() != groupAddress {
+			t.Fatalf("%s", "prepared signature address not equal with group address")
+		}
+
+		verifier.approvedTokens[prepared.ApprovalToken] = true
+		approvalTokens[partyID] = prepared.ApprovalToken
+	}
+
+	_, _, err = units[1].CommitSignThreshold(ctx, accountIdentity,
+		[]byte(`{"sessionId":"sign-another","parties":[1,2]}`), rawTx, approvalTokens[1])
+	if !errors.Is(err, ErrApprovalPayloadMismatch) {
+		t.Fatalf("%s", "session params not equal with prepared params must be refused")
+	}
+
+	preparedData, err := units[1].PrepareSignThreshold(ctx, accountIdentity, signingParams, rawTx)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prepare threshold signature", err)
+	}
+
+	prepared := &preparedSignature{}
+	_ = json.Unmarshal(preparedData, prepared)
+	verifier.approvedTokens[prepared.ApprovalToken] = true
+	approvalTokens[1] = prepared.ApprovalToken
+
+	results = make([][]byte, 2)
+	commitErrs := make([]error, 2)
+	for i, partyID := range []uint32{1, 2} {
+		wg.Add(1)
+		go func(i int, partyID uint32) {
+			defer wg.Done()
+			_, results[i], commitErrs[i] = units[partyID].CommitSignThreshold(ctx, accountIdentity, signingParams,
+				rawTx, approvalTokens[partyID])
+		}(i, partyID)
+	}
+	wg.Wait()
+
+	for i, commitErr := range commitErrs {
+		if commitErr != nil {
+			t.Fatalf("%s: %e", "unable to commit threshold signature", commitErr)
+		}
+
+		signedTx = &types.Transaction{}
+		_ = signedTx.UnmarshalBinary(results[i])
+		sender, err = types.Sender(pluginSigner, signedTx)
+		if err != nil || sender.Hex() != groupAddress {
+			t.Fatalf("%s", "two-step transaction sender not equal with group address")
+		}
+	}
+}
+
+func TestGenerateThresholdPoolUnit_WrongParams(t *testing.T) {
+	hub := newMemoryTransportHub(3)
+
+	wrongParamsList := [][]byte{
+		[]byte(`{"sessionId":"","partyId":1,"threshold":2,"partiesCount":3}`),
+		[]byte(`{"sessionId":"keygen","partyId":1,"threshold":1,"partiesCount":3}`),
+		[]byte(`{"sessionId":"keygen","partyId":1,"threshold":4,"partiesCount":3}`),
+		[]byte(`{"sessionId":"keygen","partyId":4,"threshold":2,"partiesCount":3}`),
+		[]byte(`{"sessionId":"keygen","partyId":1,"threshold":2,"partiesCount":17}`),
+	}
+
+	for _, params := range wrongParamsList {
+		_, err := GenerateThresholdPoolUnit(context.Background(), uuid.NewString(), params, hub.transport(1))
+		if !errors.Is(err, ErrThresholdWrongParams) {
+			t.Fatalf("%s", "wrong keygen params must not be accepted")
+		}
+	}
+
+	_, err := GenerateThresholdPoolUnit(context.Background(), uuid.NewString(),
+		[]byte(`{"sessionId":"keygen","partyId":1,"threshold":2,"partiesCount":3}`), "wrong transport")
+	if !errors.Is(err, ErrThresholdWrongTransport) {
+		t.Fatalf("%s", "wrong transport must not be accepted")
+	}
+}
+
+func TestPaillierKey(t *testing.T) {
+	privKey, err := generatePaillierKey()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate paillier key", err)
+	}
+
+	c1, err := privKey.encrypt(big.NewInt(100500))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt", err)
+	}
+
+	c2, err := privKey.encrypt(big.NewInt(42))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt", err)
+	}
+
+	// 100500 * 3 + 42
+	result, err := privKey.decrypt(privKey.add(privKey.mul(c1, big.NewInt(3)), c2))
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decrypt", err)
+	}
+
+	if result.Int64() != 301542 {
+		t.Fatalf("%s", "homomorphic operations result not equal with expected")
+	}
+
+	_, err = privKey.decrypt(privKey.nSquared)
+	if !errors.Is(err, ErrPaillierWrongCiphertext) {
+		t.Fatalf("%s", "wrong ciphertext must not be accepted")
+	}
+}
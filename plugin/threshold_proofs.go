@@ -0,0 +1,923 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Zero-knowledge proofs of CGGMP threshold ECDSA protocol (Canetti, Gennaro, Goldfeder, Makriyannis, Peled -
+// UC Non-Interactive, Proactive, Threshold ECDSA with Identifiable Aborts). Proofs are non-interactive -
+// challenges derived by Fiat-Shamir transform of transcript, bound to session and prover and verifier parties
+const (
+	// proofL - ℓ, bit length of secp256k1 group order
+	proofL = 256
+	// proofLPrime - ℓ' = 5ℓ, bit length of MtA additive masks
+	proofLPrime = 5 * proofL
+	// proofEpsilon - ε = 2ℓ, slackness of range proofs
+	proofEpsilon = 2 * proofL
+	// proofRepetitions - m, repetitions of proofs with binary challenges, soundness error 2^-m
+	proofRepetitions = 80
+
+	proofLabelBlumModulus   = "cggmp/pi-mod"
+	proofLabelRingPedersen  = "cggmp/pi-prm"
+	proofLabelNoSmallFactor = "cggmp/pi-fac"
+	proofLabelEncryption    = "cggmp/pi-enc"
+	proofLabelLogStar       = "cggmp/pi-log"
+	proofLabelAffineOp      = "cggmp/pi-aff-g"
+)
+
+var ErrThresholdProofFailed = errors.New("threshold zk proof verification failed")
+
+// proofContext - Fiat-Shamir context of proof. verifierID - zero for proofs broadcast to all parties
+type proofContext struct {
+	sessionID  string
+	proverID   uint32
+	verifierID uint32
+}
+
+// proofTranscript - Fiat-Shamir transcript of non-interactive proof
+type proofTranscript struct {
+	parts [][]byte
+}
+
+func newProofTranscript(label string, proofCtx *proofContext) *proofTranscript {
+	return &proofTranscript{parts: [][]byte{[]byte(label), []byte(proofCtx.sessionID),
+		thresholdPartyBytes(proofCtx.proverID), thresholdPartyBytes(proofCtx.verifierID)}}
+}
+
+// appendInts - append signed integers to transcript, sign encoded by first byte
+func (t *proofTranscript) appendInts(values ...*big.Int) *proofTranscript {
+	for _, value := range values {
+		t.parts = append(t.parts, append([]byte{byte(value.Sign() + 1)}, value.Bytes()...))
+	}
+
+	return t
+}
+
+func (t *proofTranscript) appendBytes(values ...[]byte) *proofTranscript {
+	t.parts = append(t.parts, values...)
+
+	return t
+}
+
+// expand - length bytes of transcript hash expanded by counter, domain separates expanded values
+func (t *proofTranscript) expand(domain uint32, length int) []byte {
+	seed := thresholdHash(t.parts...)
+
+	result := make([]byte, 0, length+sha256.Size)
+	for counter := uint32(0); len(result) < length; counter++ {
+		var suffix [8]byte
+		binary.BigEndian.PutUint32(suffix[:4], domain)
+		binary.BigEndian.PutUint32(suffix[4:], counter)
+
+		result = append(result, thresholdHash(seed, suffix[:])...)
+	}
+
+	return result[:length]
+}
+
+// challenge - challenge in range [0, q)
+func (t *proofTranscript) challenge() *big.Int {
+	challenge := new(big.Int).SetBytes(t.expand(0, 2*sha256.Size))
+
+	return challenge.Mod(challenge, btcec.S256().N)
+}
+
+// challengeBits - binary challenges of repetitions
+func (t *proofTranscript) challengeBits(count int) []bool {
+	data := t.expand(0, (count+7)/8)
+
+	result := make([]bool, count)
+	for i := range result {
+		result[i] = data[i/8]&(1<<(i%8)) != 0
+	}
+
+	return result
+}
+
+// challengeElement - challenge element of Z_N, index - index of repetition
+func (t *proofTranscript) challengeElement(index int, n *big.Int) *big.Int {
+	element := new(big.Int).SetBytes(t.expand(uint32(index)+1, (n.BitLen()+7)/8+sha256.Size))
+
+	return element.Mod(element, n)
+}
+
+// proofBound - 2^bits * factor
+func proofBound(bits uint, factor *big.Int) *big.Int {
+	result := new(big.Int).Lsh(big.NewInt(1), bits)
+	if factor != nil {
+		result.Mul(result, factor)
+	}
+
+	return result
+}
+
+// randomSignedInt - random integer in range [-bound, bound]
+func randomSignedInt(bound *big.Int) (*big.Int, error) {
+	width := new(big.Int).Lsh(bound, 1)
+	width.Add(width, big.NewInt(1))
+
+	value, err := rand.Int(rand.Reader, width)
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Sub(value, bound), nil
+}
+
+// isInSignedRange - value in range [-bound, bound]
+func isInSignedRange(value, bound *big.Int) bool {
+	return value != nil && new(big.Int).Abs(value).Cmp(bound) <= 0
+}
+
+func isNilValue(values ...*big.Int) bool {
+	for _, value := range values {
+		if value == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func zeroValues(values ...*big.Int) {
+	for _, value := range values {
+		if value != nil {
+			value.SetInt64(0)
+		}
+	}
+}
+
+// paillierBlumModulusProof - Π^mod, proof that N is Paillier-Blum modulus: N = p*q, p = q = 3 mod 4
+// and gcd(N, phi(N)) = 1. For every challenge y: z^N = y and x^4 = (-1)^a * w^b * y mod N
+type paillierBlumModulusProof struct {
+	W *big.Int   `json:"w"`
+	X []*big.Int `json:"x"`
+	A []bool     `json:"a"`
+	B []bool     `json:"b"`
+	Z []*big.Int `json:"z"`
+}
+
+func provePaillierBlumModulus(proofCtx *proofContext, key *paillierPrivateKey) (*paillierBlumModulusProof, error) {
+	n := key.n
+
+	// w - quadratic non-residue with Jacobi symbol -1
+	var w *big.Int
+	for {
+		var err error
+		w, err = randomUnit(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if big.Jacobi(w, n) == -1 {
+			break
+		}
+	}
+
+	nInv := new(big.Int).ModInverse(n, key.phi)
+	if nInv == nil {
+		return nil, ErrPaillierWrongPublicKey
+	}
+	defer nInv.SetInt64(0)
+
+	proof := &paillierBlumModulusProof{
+		W: w,
+		X: make([]*big.Int, proofRepetitions),
+		A: make([]bool, proofRepetitions),
+		B: make([]bool, proofRepetitions),
+		Z: make([]*big.Int, proofRepetitions),
+	}
+
+	transcript := newProofTranscript(proofLabelBlumModulus, proofCtx).appendInts(n, w)
+	for i := 0; i < proofRepetitions; i++ {
+		y := transcript.challengeElement(i, n)
+
+		x, a, b, err := key.blumFourthRoot(y, w)
+		if err != nil {
+			return nil, err
+		}
+
+		proof.X[i], proof.A[i], proof.B[i] = x, a, b
+		proof.Z[i] = new(big.Int).Exp(y, nInv, n)
+	}
+
+	return proof, nil
+}
+
+// blumFourthRoot - find a and b so (-1)^a * w^b * y is quadratic residue modulo p and q,
+// returns fourth root of it
+func (k *paillierPrivateKey) blumFourthRoot(y, w *big.Int) (*big.Int, bool, bool, error) {
+	for _, a := range []bool{false, true} {
+		for _, b := range []bool{false, true} {
+			value := blumAdjust(y, w, a, b, k.n)
+
+			rootP := fourthRootModPrime(value, k.p)
+			rootQ := fourthRootModPrime(value, k.q)
+			if rootP == nil || rootQ == nil {
+				continue
+			}
+
+			// CRT - x = rootP * q * (q^-1 mod p) + rootQ * p * (p^-1 mod q) mod N
+			x := new(big.Int).Mul(rootP, k.q)
+			x.Mul(x, new(big.Int).ModInverse(k.q, k.p))
+			termQ := new(big.Int).Mul(rootQ, k.p)
+			termQ.Mul(termQ, new(big.Int).ModInverse(k.p, k.q))
+			x.Add(x, termQ)
+
+			return x.Mod(x, k.n), a, b, nil
+		}
+	}
+
+	return nil, false, false, ErrPaillierWrongPublicKey
+}
+
+func fourthRootModPrime(value, p *big.Int) *big.Int {
+	residue := new(big.Int).Mod(value, p)
+	if residue.Sign() == 0 {
+		return nil
+	}
+
+	root := new(big.Int).ModSqrt(residue, p)
+	if root == nil {
+		return nil
+	}
+
+	// exactly one of square roots ±root modulo Blum prime is quadratic residue
+	if big.Jacobi(root, p) != 1 {
+		root.Sub(p, root)
+	}
+
+	return new(big.Int).ModSqrt(root, p)
+}
+
+// blumAdjust - (-1)^a * w^b * y mod N
+func blumAdjust(y, w *big.Int, a, b bool, n *big.Int) *big.Int {
+	result := new(big.Int).Set(y)
+	if b {
+		result = mulMod(result, w, n)
+	}
+
+	if a {
+		result.Sub(n, result)
+		result.Mod(result, n)
+	}
+
+	return result
+}
+
+func (proof *paillierBlumModulusProof) verify(proofCtx *proofContext, n *big.Int) error {
+	if proof == nil || len(proof.X) != proofRepetitions || len(proof.A) != proofRepetitions ||
+		len(proof.B) != proofRepetitions || len(proof.Z) != proofRepetitions {
+		return fmt.Errorf("%w: wrong modulus proof", ErrThresholdProofFailed)
+	}
+
+	if n.Bit(0) == 0 || n.ProbablyPrime(20) {
+		return fmt.Errorf("%w: modulus is even or prime", ErrThresholdProofFailed)
+	}
+
+	if !isUnit(proof.W, n) || big.Jacobi(proof.W, n) != -1 {
+		return fmt.Errorf("%w: wrong modulus proof non-residue", ErrThresholdProofFailed)
+	}
+
+	four := big.NewInt(4)
+	transcript := newProofTranscript(proofLabelBlumModulus, proofCtx).appendInts(n, proof.W)
+	for i := 0; i < proofRepetitions; i++ {
+		if !isUnit(proof.X[i], n) || !isUnit(proof.Z[i], n) {
+			return fmt.Errorf("%w: wrong modulus proof value", ErrThresholdProofFailed)
+		}
+
+		y := transcript.challengeElement(i, n)
+
+		if new(big.Int).Exp(proof.Z[i], n, n).Cmp(y) != 0 {
+			return fmt.Errorf("%w: modulus is not co-prime with phi", ErrThresholdProofFailed)
+		}
+
+		if new(big.Int).Exp(proof.X[i], four, n).Cmp(blumAdjust(y, proof.W, proof.A[i], proof.B[i], n)) != 0 {
+			return fmt.Errorf("%w: modulus is not Paillier-Blum modulus", ErrThresholdProofFailed)
+		}
+	}
+
+	return nil
+}
+
+// ringPedersenParamsProof - Π^prm, proof of knowledge of lambda: s = t^lambda mod N.
+// For every binary challenge e: t^z = A * s^e mod N
+type ringPedersenParamsProof struct {
+	A []*big.Int `json:"a"`
+	Z []*big.Int `json:"z"`
+}
+
+func proveRingPedersenParams(proofCtx *proofContext,
+	params *ringPedersenParams,
+	lambda, phi *big.Int,
+) (*ringPedersenParamsProof, error) {
+	alphas := make([]*big.Int, proofRepetitions)
+	defer zeroValues(alphas...)
+
+	proof := &ringPedersenParamsProof{
+		A: make([]*big.Int, proofRepetitions),
+		Z: make([]*big.Int, proofRepetitions),
+	}
+
+	for i := range alphas {
+		alpha, err := rand.Int(rand.Reader, phi)
+		if err != nil {
+			return nil, err
+		}
+
+		alphas[i] = alpha
+		proof.A[i] = new(big.Int).Exp(params.t, alpha, params.n)
+	}
+
+	challenges := newProofTranscript(proofLabelRingPedersen, proofCtx).
+		appendInts(params.n, params.s, params.t).
+		appendInts(proof.A...).
+		challengeBits(proofRepetitions)
+
+	for i, challenge := range challenges {
+		z := new(big.Int).Set(alphas[i])
+		if challenge {
+			z.Add(z, lambda)
+		}
+
+		proof.Z[i] = z.Mod(z, phi)
+	}
+
+	return proof, nil
+}
+
+func (proof *ringPedersenParamsProof) verify(proofCtx *proofContext, params *ringPedersenParams) error {
+	if proof == nil || len(proof.A) != proofRepetitions || len(proof.Z) != proofRepetitions {
+		return fmt.Errorf("%w: wrong ring-pedersen params proof", ErrThresholdProofFailed)
+	}
+
+	for i := range proof.A {
+		if !isUnit(proof.A[i], params.n) || proof.Z[i] == nil || proof.Z[i].Sign() < 0 {
+			return fmt.Errorf("%w: wrong ring-pedersen params proof value", ErrThresholdProofFailed)
+		}
+	}
+
+	challenges := newProofTranscript(proofLabelRingPedersen, proofCtx).
+		appendInts(params.n, params.s, params.t).
+		appendInts(proof.A...).
+		challengeBits(proofRepetitions)
+
+	for i, challenge := range challenges {
+		expected := proof.A[i]
+		if challenge {
+			expected = mulMod(expected, params.s, params.n)
+		}
+
+		if new(big.Int).Exp(params.t, proof.Z[i], params.n).Cmp(expected) != 0 {
+			return fmt.Errorf("%w: ring-pedersen params", ErrThresholdProofFailed)
+		}
+	}
+
+	return nil
+}
+
+// noSmallFactorProof - Π^fac, proof that factors p and q of prover modulus N0 are in range ±sqrt(N0)*2^(ℓ+ε),
+// so modulus has no factors smaller than about 2^ℓ. Commitments by ring-Pedersen params of verifier (N^, s, t):
+//
+//	P = s^p*t^mu, Q = s^q*t^nu, A = s^alpha*t^x, B = s^beta*t^y, T = Q^alpha*t^r, R = s^N0*t^sigma
+//	s^z1*t^w1 = A*P^e, s^z2*t^w2 = B*Q^e, Q^z1*t^v = T*R^e mod N^
+type noSmallFactorProof struct {
+	P     *big.Int `json:"p"`
+	Q     *big.Int `json:"q"`
+	A     *big.Int `json:"a"`
+	B     *big.Int `json:"b"`
+	T     *big.Int `json:"t"`
+	Sigma *big.Int `json:"sigma"`
+	Z1    *big.Int `json:"z1"`
+	Z2    *big.Int `json:"z2"`
+	W1    *big.Int `json:"w1"`
+	W2    *big.Int `json:"w2"`
+	V     *big.Int `json:"v"`
+}
+
+func proveNoSmallFactor(proofCtx *proofContext,
+	key *paillierPrivateKey,
+	setup *ringPedersenParams,
+) (*noSmallFactorProof, error) {
+	sqrtN0 := new(big.Int).Sqrt(key.n)
+	n0Setup := new(big.Int).Mul(key.n, setup.n)
+
+	bounds := []*big.Int{
+		proofBound(proofL+proofEpsilon, sqrtN0),  // alpha
+		proofBound(proofL+proofEpsilon, sqrtN0),  // beta
+		proofBound(proofL, setup.n),              // mu
+		proofBound(proofL, setup.n),              // nu
+		proofBound(proofL, n0Setup),              // sigma
+		proofBound(proofL+proofEpsilon, n0Setup), // r
+		proofBound(proofL+proofEpsilon, setup.n), // x
+		proofBound(proofL+proofEpsilon, setup.n), // y
+	}
+
+	values := make([]*big.Int, len(bounds))
+	defer zeroValues(values...)
+
+	for i, bound := range bounds {
+		value, err := randomSignedInt(bound)
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	alpha, beta, mu, nu, sigma, r, x, y := values[0], values[1], values[2], values[3],
+		values[4], values[5], values[6], values[7]
+
+	proof := &noSmallFactorProof{
+		P:     setup.commit(key.p, mu),
+		Q:     setup.commit(key.q, nu),
+		A:     setup.commit(alpha, x),
+		B:     setup.commit(beta, y),
+		Sigma: new(big.Int).Set(sigma),
+	}
+	proof.T = mulMod(expMod(proof.Q, alpha, setup.n), expMod(setup.t, r, setup.n), setup.n)
+
+	e := newProofTranscript(proofLabelNoSmallFactor, proofCtx).
+		appendInts(key.n, setup.n, setup.s, setup.t, proof.P, proof.Q, proof.A, proof.B, proof.T, proof.Sigma).
+		challenge()
+
+	// sigmaHat = sigma - nu*p
+	sigmaHat := new(big.Int).Mul(nu, key.p)
+	sigmaHat.Sub(sigma, sigmaHat)
+	defer sigmaHat.SetInt64(0)
+
+	proof.Z1 = new(big.Int).Add(alpha, new(big.Int).Mul(e, key.p))
+	proof.Z2 = new(big.Int).Add(beta, new(big.Int).Mul(e, key.q))
+	proof.W1 = new(big.Int).Add(x, new(big.Int).Mul(e, mu))
+	proof.W2 = new(big.Int).Add(y, new(big.Int).Mul(e, nu))
+	proof.V = new(big.Int).Add(r, new(big.Int).Mul(e, sigmaHat))
+
+	return proof, nil
+}
+
+func (proof *noSmallFactorProof) verify(proofCtx *proofContext, n0 *big.Int, setup *ringPedersenParams) error {
+	if proof == nil || isNilValue(proof.Sigma, proof.Z1, proof.Z2, proof.W1, proof.W2, proof.V) {
+		return fmt.Errorf("%w: wrong no small factor proof", ErrThresholdProofFailed)
+	}
+
+	for _, value := range []*big.Int{proof.P, proof.Q, proof.A, proof.B, proof.T} {
+		if !isUnit(value, setup.n) {
+			return fmt.Errorf("%w: wrong no small factor proof value", ErrThresholdProofFailed)
+		}
+	}
+
+	bound := proofBound(proofL+proofEpsilon, new(big.Int).Sqrt(n0))
+	if !isInSignedRange(proof.Z1, bound) || !isInSignedRange(proof.Z2, bound) {
+		return fmt.Errorf("%w: modulus factors out of range", ErrThresholdProofFailed)
+	}
+
+	e := newProofTranscript(proofLabelNoSmallFactor, proofCtx).
+		appendInts(n0, setup.n, setup.s, setup.t, proof.P, proof.Q, proof.A, proof.B, proof.T, proof.Sigma).
+		challenge()
+
+	if setup.commit(proof.Z1, proof.W1).Cmp(mulMod(proof.A, expMod(proof.P, e, setup.n), setup.n)) != 0 ||
+		setup.commit(proof.Z2, proof.W2).Cmp(mulMod(proof.B, expMod(proof.Q, e, setup.n), setup.n)) != 0 {
+		return fmt.Errorf("%w: modulus factors commitments", ErrThresholdProofFailed)
+	}
+
+	r := setup.commit(n0, proof.Sigma)
+	left := mulMod(expMod(proof.Q, proof.Z1, setup.n), expMod(setup.t, proof.V, setup.n), setup.n)
+	if left.Cmp(mulMod(proof.T, expMod(r, e, setup.n), setup.n)) != 0 {
+		return fmt.Errorf("%w: modulus factors product", ErrThresholdProofFailed)
+	}
+
+	return nil
+}
+
+// encryptionInRangeProof - Π^enc, proof that Paillier ciphertext K = (1+N0)^k * rho^N0 mod N0^2 of prover key
+// encrypts k in range ±2^(ℓ+ε). Commitments by ring-Pedersen params of verifier (N^, s, t):
+//
+//	S = s^k*t^mu, A = (1+N0)^alpha*r^N0, C = s^alpha*t^gamma
+//	(1+N0)^z1*z2^N0 = A*K^e mod N0^2, s^z1*t^z3 = C*S^e mod N^
+type encryptionInRangeProof struct {
+	S  *big.Int `json:"s"`
+	A  *big.Int `json:"a"`
+	C  *big.Int `json:"c"`
+	Z1 *big.Int `json:"z1"`
+	Z2 *big.Int `json:"z2"`
+	Z3 *big.Int `json:"z3"`
+}
+
+func proveEncryptionInRange(proofCtx *proofContext,
+	key *paillierPublicKey,
+	ciphertext, plaintext, nonce *big.Int,
+	setup *ringPedersenParams,
+) (*encryptionInRangeProof, error) {
+	alpha, err := randomSignedInt(proofBound(proofL+proofEpsilon, nil))
+	if err != nil {
+		return nil, err
+	}
+	defer alpha.SetInt64(0)
+
+	mu, err := randomSignedInt(proofBound(proofL, setup.n))
+	if err != nil {
+		return nil, err
+	}
+	defer mu.SetInt64(0)
+
+	r, err := randomUnit(key.n)
+	if err != nil {
+		return nil, err
+	}
+	defer r.SetInt64(0)
+
+	gamma, err := randomSignedInt(proofBound(proofL+proofEpsilon, setup.n))
+	if err != nil {
+		return nil, err
+	}
+	defer gamma.SetInt64(0)
+
+	proof := &encryptionInRangeProof{
+		S: setup.commit(plaintext, mu),
+		A: key.encryptWithNonce(alpha, r),
+		C: setup.commit(alpha, gamma),
+	}
+
+	e := newProofTranscript(proofLabelEncryption, proofCtx).
+		appendInts(key.n, ciphertext, setup.n, setup.s, setup.t, proof.S, proof.A, proof.C).
+		challenge()
+
+	proof.Z1 = new(big.Int).Add(alpha, new(big.Int).Mul(e, plaintext))
+	proof.Z2 = mulMod(r, new(big.Int).Exp(nonce, e, key.n), key.n)
+	proof.Z3 = new(big.Int).Add(gamma, new(big.Int).Mul(e, mu))
+
+	return proof, nil
+}
+
+func (proof *encryptionInRangeProof) verify(proofCtx *proofContext,
+	key *paillierPublicKey,
+	ciphertext *big.Int,
+	setup *ringPedersenParams,
+) error {
+	if proof == nil || isNilValue(proof.Z1, proof.Z3) || !isUnit(proof.S, setup.n) ||
+		!isUnit(proof.C, setup.n) || !isUnit(proof.Z2, key.n) || key.validateCiphertext(proof.A) != nil {
+		return fmt.Errorf("%w: wrong encryption proof", ErrThresholdProofFailed)
+	}
+
+	if !isInSignedRange(proof.Z1, proofBound(proofL+proofEpsilon, nil)) {
+		return fmt.Errorf("%w: encrypted value out of range", ErrThresholdProofFailed)
+	}
+
+	e := newProofTranscript(proofLabelEncryption, proofCtx).
+		appendInts(key.n, ciphertext, setup.n, setup.s, setup.t, proof.S, proof.A, proof.C).
+		challenge()
+
+	if key.encryptWithNonce(proof.Z1, proof.Z2).Cmp(
+		mulMod(proof.A, new(big.Int).Exp(ciphertext, e, key.nSquared), key.nSquared)) != 0 {
+		return fmt.Errorf("%w: encryption", ErrThresholdProofFailed)
+	}
+
+	if setup.commit(proof.Z1, proof.Z3).Cmp(mulMod(proof.C, expMod(proof.S, e, setup.n), setup.n)) != 0 {
+		return fmt.Errorf("%w: encryption commitment", ErrThresholdProofFailed)
+	}
+
+	return nil
+}
+
+// logStarStatement - statement of Π^log*: C = (1+N0)^x * rho^N0 mod N0^2 by prover key N0 and X = x*g
+type logStarStatement struct {
+	key *paillierPublicKey
+	c   *big.Int
+	x   *btcec.JacobianPoint
+	g   *btcec.JacobianPoint
+}
+
+// logStarProof - Π^log*, proof that Paillier ciphertext C of prover key encrypts discrete logarithm of X
+// by base g in range ±2^(ℓ+ε). Commitments by ring-Pedersen params of verifier (N^, s, t):
+//
+//	S = s^x*t^mu, A = (1+N0)^alpha*r^N0, Y = alpha*g, D = s^alpha*t^gamma
+//	(1+N0)^z1*z2^N0 = A*C^e mod N0^2, z1*g = Y + e*X, s^z1*t^z3 = D*S^e mod N^
+type logStarProof struct {
+	S  *big.Int      `json:"s"`
+	A  *big.Int      `json:"a"`
+	Y  hexutil.Bytes `json:"y"`
+	D  *big.Int      `json:"d"`
+	Z1 *big.Int      `json:"z1"`
+	Z2 *big.Int      `json:"z2"`
+	Z3 *big.Int      `json:"z3"`
+}
+
+func (s *logStarStatement) transcript(proofCtx *proofContext,
+	setup *ringPedersenParams,
+	proof *logStarProof,
+) *proofTranscript {
+	return newProofTranscript(proofLabelLogStar, proofCtx).
+		appendInts(s.key.n, s.c).
+		appendBytes(pointToPublicKey(s.x).SerializeCompressed(), pointToPublicKey(s.g).SerializeCompressed()).
+		appendInts(setup.n, setup.s, setup.t, proof.S, proof.A).
+		appendBytes(proof.Y).
+		appendInts(proof.D)
+}
+
+func proveLogStar(proofCtx *proofContext,
+	statement *logStarStatement,
+	plaintext, nonce *big.Int,
+	setup *ringPedersenParams,
+) (*logStarProof, error) {
+	alpha, err := randomSignedInt(proofBound(proofL+proofEpsilon, nil))
+	if err != nil {
+		return nil, err
+	}
+	defer alpha.SetInt64(0)
+
+	mu, err := randomSignedInt(proofBound(proofL, setup.n))
+	if err != nil {
+		return nil, err
+	}
+	defer mu.SetInt64(0)
+
+	r, err := randomUnit(statement.key.n)
+	if err != nil {
+		return nil, err
+	}
+	defer r.SetInt64(0)
+
+	gamma, err := randomSignedInt(proofBound(proofL+proofEpsilon, setup.n))
+	if err != nil {
+		return nil, err
+	}
+	defer gamma.SetInt64(0)
+
+	alphaScalar := scalarFromBigInt(alpha)
+	defer alphaScalar.Zero()
+	if alphaScalar.IsZero() {
+		return nil, fmt.Errorf("%w: zero log proof nonce", ErrThresholdProtocolAborted)
+	}
+
+	proof := &logStarProof{
+		S: setup.commit(plaintext, mu),
+		A: statement.key.encryptWithNonce(alpha, r),
+		Y: pointToPublicKey(pointMult(alphaScalar, statement.g)).SerializeCompressed(),
+		D: setup.commit(alpha, gamma),
+	}
+
+	e := statement.transcript(proofCtx, setup, proof).challenge()
+
+	proof.Z1 = new(big.Int).Add(alpha, new(big.Int).Mul(e, plaintext))
+	proof.Z2 = mulMod(r, new(big.Int).Exp(nonce, e, statement.key.n), statement.key.n)
+	proof.Z3 = new(big.Int).Add(gamma, new(big.Int).Mul(e, mu))
+
+	return proof, nil
+}
+
+func (proof *logStarProof) verify(proofCtx *proofContext,
+	statement *logStarStatement,
+	setup *ringPedersenParams,
+) error {
+	key := statement.key
+
+	if proof == nil || isNilValue(proof.Z1, proof.Z3) || !isUnit(proof.S, setup.n) ||
+		!isUnit(proof.D, setup.n) || !isUnit(proof.Z2, key.n) || key.validateCiphertext(proof.A) != nil {
+		return fmt.Errorf("%w: wrong log proof", ErrThresholdProofFailed)
+	}
+
+	y, err := parsePoint(proof.Y)
+	if err != nil {
+		return fmt.Errorf("%w: wrong log proof point", ErrThresholdProofFailed)
+	}
+
+	if !isInSignedRange(proof.Z1, proofBound(proofL+proofEpsilon, nil)) {
+		return fmt.Errorf("%w: discrete logarithm out of range", ErrThresholdProofFailed)
+	}
+
+	e := statement.transcript(proofCtx, setup, proof).challenge()
+
+	if key.encryptWithNonce(proof.Z1, proof.Z2).Cmp(
+		mulMod(proof.A, new(big.Int).Exp(statement.c, e, key.nSquared), key.nSquared)) != 0 {
+		return fmt.Errorf("%w: log encryption", ErrThresholdProofFailed)
+	}
+
+	if !pointEquals(pointMult(scalarFromBigInt(proof.Z1), statement.g),
+		pointAdd(y, pointMult(scalarFromBigInt(e), statement.x))) {
+		return fmt.Errorf("%w: log group commitment", ErrThresholdProofFailed)
+	}
+
+	if setup.commit(proof.Z1, proof.Z3).Cmp(mulMod(proof.D, expMod(proof.S, e, setup.n), setup.n)) != 0 {
+		return fmt.Errorf("%w: log commitment", ErrThresholdProofFailed)
+	}
+
+	return nil
+}
+
+// affineOperationStatement - statement of Π^aff-g: D = C^x * (1+N0)^y * rho^N0 mod N0^2 by verifier key N0,
+// Y = (1+N1)^y * rhoY^N1 mod N1^2 by prover key N1 and X = x*G
+type affineOperationStatement struct {
+	verifierKey *paillierPublicKey
+	proverKey   *paillierPublicKey
+	c           *big.Int
+	d           *big.Int
+	y           *big.Int
+	x           *btcec.JacobianPoint
+}
+
+// affineOperationWitness - witness of Π^aff-g, x in range ±2^ℓ, y in range ±2^ℓ'
+type affineOperationWitness struct {
+	x    *big.Int
+	y    *big.Int
+	rho  *big.Int
+	rhoY *big.Int
+}
+
+// affineOperationProof - Π^aff-g, proof of Paillier affine operation with group commitment in range.
+// Proves x in range ±2^(ℓ+ε) and y in range ±2^(ℓ'+ε). Commitments by ring-Pedersen params of verifier (N^, s, t):
+//
+//	A = C^alpha*(1+N0)^beta*r^N0, Bx = alpha*G, By = (1+N1)^beta*rY^N1,
+//	E = s^alpha*t^gamma, S = s^x*t^m, F = s^beta*t^delta, T = s^y*t^mu
+//	C^z1*(1+N0)^z2*w^N0 = A*D^e mod N0^2, z1*G = Bx + e*X, (1+N1)^z2*wY^N1 = By*Y^e mod N1^2,
+//	s^z1*t^z3 = E*S^e, s^z2*t^z4 = F*T^e mod N^
+type affineOperationProof struct {
+	A  *big.Int      `json:"a"`
+	Bx hexutil.Bytes `json:"bx"`
+	By *big.Int      `json:"by"`
+	E  *big.Int      `json:"e"`
+	S  *big.Int      `json:"s"`
+	F  *big.Int      `json:"f"`
+	T  *big.Int      `json:"t"`
+	Z1 *big.Int      `json:"z1"`
+	Z2 *big.Int      `json:"z2"`
+	Z3 *big.Int      `json:"z3"`
+	Z4 *big.Int      `json:"z4"`
+	W  *big.Int      `json:"w"`
+	WY *big.Int      `json:"wY"`
+}
+
+func (s *affineOperationStatement) transcript(proofCtx *proofContext,
+	setup *ringPedersenParams,
+	proof *affineOperationProof,
+) *proofTranscript {
+	return newProofTranscript(proofLabelAffineOp, proofCtx).
+		appendInts(s.verifierKey.n, s.proverKey.n, s.c, s.d, s.y).
+		appendBytes(pointToPublicKey(s.x).SerializeCompressed()).
+		appendInts(setup.n, setup.s, setup.t, proof.A).
+		appendBytes(proof.Bx).
+		appendInts(proof.By, proof.E, proof.S, proof.F, proof.T)
+}
+
+func proveAffineOperation(proofCtx *proofContext,
+	statement *affineOperationStatement,
+	witness *affineOperationWitness,
+	setup *ringPedersenParams,
+) (*affineOperationProof, error) {
+	bounds := []*big.Int{
+		proofBound(proofL+proofEpsilon, nil),      // alpha
+		proofBound(proofLPrime+proofEpsilon, nil), // beta
+		proofBound(proofL+proofEpsilon, setup.n),  // gamma
+		proofBound(proofL, setup.n),               // m
+		proofBound(proofL+proofEpsilon, setup.n),  // delta
+		proofBound(proofL, setup.n),               // mu
+	}
+
+	values := make([]*big.Int, len(bounds), len(bounds)+2)
+	defer zeroValues(values...)
+
+	for i, bound := range bounds {
+		value, err := randomSignedInt(bound)
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	alpha, beta, gamma, m, delta, mu := values[0], values[1], values[2], values[3], values[4], values[5]
+
+	r, err := randomUnit(statement.verifierKey.n)
+	if err != nil {
+		return nil, err
+	}
+
+	rY, err := randomUnit(statement.proverKey.n)
+	if err != nil {
+		return nil, err
+	}
+
+	values = append(values, r, rY)
+
+	alphaScalar := scalarFromBigInt(alpha)
+	defer alphaScalar.Zero()
+	if alphaScalar.IsZero() {
+		return nil, fmt.Errorf("%w: zero affine operation proof nonce", ErrThresholdProtocolAborted)
+	}
+
+	verifierKey := statement.verifierKey
+	proof := &affineOperationProof{
+		A: verifierKey.add(expMod(statement.c, alpha, verifierKey.nSquared),
+			verifierKey.encryptWithNonce(beta, r)),
+		Bx: pointToPublicKey(scalarBaseMult(alphaScalar)).SerializeCompressed(),
+		By: statement.proverKey.encryptWithNonce(beta, rY),
+		E:  setup.commit(alpha, gamma),
+		S:  setup.commit(witness.x, m),
+		F:  setup.commit(beta, delta),
+		T:  setup.commit(witness.y, mu),
+	}
+
+	e := statement.transcript(proofCtx, setup, proof).challenge()
+
+	proof.Z1 = new(big.Int).Add(alpha, new(big.Int).Mul(e, witness.x))
+	proof.Z2 = new(big.Int).Add(beta, new(big.Int).Mul(e, witness.y))
+	proof.Z3 = new(big.Int).Add(gamma, new(big.Int).Mul(e, m))
+	proof.Z4 = new(big.Int).Add(delta, new(big.Int).Mul(e, mu))
+	proof.W = mulMod(r, new(big.Int).Exp(witness.rho, e, verifierKey.n), verifierKey.n)
+	proof.WY = mulMod(rY, new(big.Int).Exp(witness.rhoY, e, statement.proverKey.n), statement.proverKey.n)
+
+	return proof, nil
+}
+
+func (proof *affineOperationProof) verify(proofCtx *proofContext,
+	statement *affineOperationStatement,
+	setup *ringPedersenParams,
+) error {
+	verifierKey, proverKey := statement.verifierKey, statement.proverKey
+
+	if proof == nil || isNilValue(proof.Z1, proof.Z2, proof.Z3, proof.Z4) ||
+		verifierKey.validateCiphertext(proof.A) != nil || proverKey.validateCiphertext(proof.By) != nil ||
+		!isUnit(proof.W, verifierKey.n) || !isUnit(proof.WY, proverKey.n) {
+		return fmt.Errorf("%w: wrong affine operation proof", ErrThresholdProofFailed)
+	}
+
+	for _, value := range []*big.Int{proof.E, proof.S, proof.F, proof.T} {
+		if !isUnit(value, setup.n) {
+			return fmt.Errorf("%w: wrong affine operation proof value", ErrThresholdProofFailed)
+		}
+	}
+
+	bx, err := parsePoint(proof.Bx)
+	if err != nil {
+		return fmt.Errorf("%w: wrong affine operation proof point", ErrThresholdProofFailed)
+	}
+
+	if !isInSignedRange(proof.Z1, proofBound(proofL+proofEpsilon, nil)) ||
+		!isInSignedRange(proof.Z2, proofBound(proofLPrime+proofEpsilon, nil)) {
+		return fmt.Errorf("%w: affine operation values out of range", ErrThresholdProofFailed)
+	}
+
+	e := statement.transcript(proofCtx, setup, proof).challenge()
+
+	left := verifierKey.add(expMod(statement.c, proof.Z1, verifierKey.nSquared),
+		verifierKey.encryptWithNonce(proof.Z2, proof.W))
+	if left.Cmp(verifierKey.add(proof.A, new(big.Int).Exp(statement.d, e, verifierKey.nSquared))) != 0 {
+		return fmt.Errorf("%w: affine operation", ErrThresholdProofFailed)
+	}
+
+	if !pointEquals(scalarBaseMult(scalarFromBigInt(proof.Z1)),
+		pointAdd(bx, pointMult(scalarFromBigInt(e), statement.x))) {
+		return fmt.Errorf("%w: affine operation group commitment", ErrThresholdProofFailed)
+	}
+
+	if proverKey.encryptWithNonce(proof.Z2, proof.WY).Cmp(
+		proverKey.add(proof.By, new(big.Int).Exp(statement.y, e, proverKey.nSquared))) != 0 {
+		return fmt.Errorf("%w: affine operation mask encryption", ErrThresholdProofFailed)
+	}
+
+	if setup.commit(proof.Z1, proof.Z3).Cmp(mulMod(proof.E, expMod(proof.S, e, setup.n), setup.n)) != 0 ||
+		setup.commit(proof.Z2, proof.Z4).Cmp(mulMod(proof.F, expMod(proof.T, e, setup.n), setup.n)) != 0 {
+		return fmt.Errorf("%w: affine operation commitments", ErrThresholdProofFailed)
+	}
+
+	return nil
+}
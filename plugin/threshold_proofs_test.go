@@ -0,0 +1,390 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+func generateProofTestKey(t *testing.T) (*paillierPrivateKey, *ringPedersenParams, *big.Int) {
+	key, err := generatePaillierKey()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate paillier key", err)
+	}
+
+	params, lambda, err := key.generateRingPedersenParams()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate ring-pedersen params", err)
+	}
+
+	return key, params, lambda
+}
+
+func TestPaillierKeyProofs(t *testing.T) {
+	key, params, lambda := generateProofTestKey(t)
+	proofCtx := &proofContext{sessionID: "keygen", proverID: 1}
+	otherCtx := &proofContext{sessionID: "keygen", proverID: 2}
+
+	modProof, err := provePaillierBlumModulus(proofCtx, key)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove paillier-blum modulus", err)
+	}
+
+	err = modProof.verify(proofCtx, key.n)
+	if err != nil {
+		t.Fatalf("%s: %e", "valid modulus proof must be accepted", err)
+	}
+
+	err = modProof.verify(otherCtx, key.n)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "modulus proof of other party must not be accepted")
+	}
+
+	err = modProof.verify(proofCtx, key.p)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "modulus proof of prime modulus must not be accepted")
+	}
+
+	modProof.A[0] = !modProof.A[0]
+	err = modProof.verify(proofCtx, key.n)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "modified modulus proof must not be accepted")
+	}
+
+	prmProof, err := proveRingPedersenParams(proofCtx, params, lambda, key.phi)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove ring-pedersen params", err)
+	}
+
+	err = prmProof.verify(proofCtx, params)
+	if err != nil {
+		t.Fatalf("%s: %e", "valid ring-pedersen params proof must be accepted", err)
+	}
+
+	err = prmProof.verify(otherCtx, params)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "ring-pedersen params proof of other party must not be accepted")
+	}
+
+	// s not in subgroup generated by t - lambda unknown
+	wrongS, err := randomUnit(key.n)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate random unit", err)
+	}
+
+	wrongParams, err := newRingPedersenParams(key.n, wrongS, params.t)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create ring-pedersen params", err)
+	}
+
+	wrongPrmProof, err := proveRingPedersenParams(proofCtx, wrongParams, lambda, key.phi)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove ring-pedersen params", err)
+	}
+
+	err = wrongPrmProof.verify(proofCtx, wrongParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "ring-pedersen params proof without lambda must not be accepted")
+	}
+
+	_, err = newRingPedersenParams(key.n, params.t, params.t)
+	if !errors.Is(err, ErrRingPedersenWrongParams) {
+		t.Fatalf("%s", "equal ring-pedersen params must not be accepted")
+	}
+}
+
+func TestNoSmallFactorProof(t *testing.T) {
+	key, _, _ := generateProofTestKey(t)
+	_, verifierParams, _ := generateProofTestKey(t)
+	proofCtx := &proofContext{sessionID: "keygen", proverID: 1, verifierID: 2}
+
+	proof, err := proveNoSmallFactor(proofCtx, key, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove no small factor", err)
+	}
+
+	err = proof.verify(proofCtx, key.n, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "valid no small factor proof must be accepted", err)
+	}
+
+	err = proof.verify(&proofContext{sessionID: "keygen", proverID: 1, verifierID: 3}, key.n, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "no small factor proof to other party must not be accepted")
+	}
+
+	// modulus of 2048 bits with 128-bit factor
+	smallP, err := rand.Prime(rand.Reader, 128)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate prime", err)
+	}
+
+	bigQ, err := rand.Prime(rand.Reader, 2*paillierPrimeBits-128)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate prime", err)
+	}
+
+	one := big.NewInt(1)
+	n := new(big.Int).Mul(smallP, bigQ)
+	wrongKey := &paillierPrivateKey{
+		paillierPublicKey: paillierPublicKey{n: n, nSquared: new(big.Int).Mul(n, n)},
+		p:                 smallP,
+		q:                 bigQ,
+		phi:               new(big.Int).Mul(new(big.Int).Sub(smallP, one), new(big.Int).Sub(bigQ, one)),
+	}
+
+	wrongProof, err := proveNoSmallFactor(proofCtx, wrongKey, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove no small factor", err)
+	}
+
+	err = wrongProof.verify(proofCtx, n, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "modulus with small factor must not be accepted")
+	}
+}
+
+func TestEncryptionAndAffineOperationProofs(t *testing.T) {
+	verifierKey, verifierParams, _ := generateProofTestKey(t)
+	proverKey, _, _ := generateProofTestKey(t)
+	proofCtx := &proofContext{sessionID: "sign", proverID: 1, verifierID: 2}
+	otherCtx := &proofContext{sessionID: "sign-other", proverID: 1, verifierID: 2}
+
+	k, err := randomScalar()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate scalar", err)
+	}
+
+	// nonce share encrypted by verifier key - verifier of affine operation is prover of encryption
+	kValue := scalarBigInt(k)
+	encK, encKNonce, err := verifierKey.encryptWithRandomNonce(kValue)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt", err)
+	}
+
+	encProof, err := proveEncryptionInRange(proofCtx, &verifierKey.paillierPublicKey, encK, kValue, encKNonce,
+		verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove encryption in range", err)
+	}
+
+	err = encProof.verify(proofCtx, &verifierKey.paillierPublicKey, encK, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "valid encryption proof must be accepted", err)
+	}
+
+	err = encProof.verify(otherCtx, &verifierKey.paillierPublicKey, encK, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "encryption proof of other session must not be accepted")
+	}
+
+	wideValue := new(big.Int).Lsh(big.NewInt(1), 1000)
+	wideEncK, wideNonce, err := verifierKey.encryptWithRandomNonce(wideValue)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt", err)
+	}
+
+	wideProof, err := proveEncryptionInRange(proofCtx, &verifierKey.paillierPublicKey, wideEncK, wideValue,
+		wideNonce, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove encryption in range", err)
+	}
+
+	err = wideProof.verify(proofCtx, &verifierKey.paillierPublicKey, wideEncK, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "encryption of value out of range must not be accepted")
+	}
+
+	gamma, err := randomScalar()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate scalar", err)
+	}
+
+	gammaPoint := scalarBaseMult(gamma)
+	mta, beta, err := mtaResponse(proofCtx, &verifierKey.paillierPublicKey, &proverKey.paillierPublicKey,
+		verifierParams, encK, gamma, gammaPoint)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mta response", err)
+	}
+
+	statement := &affineOperationStatement{
+		verifierKey: &verifierKey.paillierPublicKey,
+		proverKey:   &proverKey.paillierPublicKey,
+		c:           encK,
+		d:           new(big.Int).SetBytes(mta.Ciphertext),
+		y:           new(big.Int).SetBytes(mta.MaskCiphertext),
+		x:           gammaPoint,
+	}
+
+	err = mta.Proof.verify(proofCtx, statement, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "valid affine operation proof must be accepted", err)
+	}
+
+	// alpha + beta = k * gamma
+	alphaValue, err := verifierKey.decryptSigned(statement.d)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decrypt", err)
+	}
+
+	var expected btcec.ModNScalar
+	expected.Mul2(k, gamma)
+	if !scalarFromBigInt(alphaValue).Add(beta).Equals(&expected) {
+		t.Fatalf("%s", "mta shares sum not equal with product")
+	}
+
+	err = mta.Proof.verify(otherCtx, statement, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "affine operation proof of other session must not be accepted")
+	}
+
+	wrongStatement := *statement
+	wrongStatement.x = scalarBaseMult(k)
+	err = mta.Proof.verify(proofCtx, &wrongStatement, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "affine operation proof of other group commitment must not be accepted")
+	}
+
+	// mask out of range with slackness - y = 2^1900
+	wideMask := new(big.Int).Lsh(big.NewInt(1), 1900)
+	encMask, maskNonce, err := verifierKey.encryptWithRandomNonce(wideMask)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt", err)
+	}
+
+	maskCiphertext, maskCiphertextNonce, err := proverKey.encryptWithRandomNonce(wideMask)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt", err)
+	}
+
+	gammaValue := scalarBigInt(gamma)
+	wideStatement := &affineOperationStatement{
+		verifierKey: &verifierKey.paillierPublicKey,
+		proverKey:   &proverKey.paillierPublicKey,
+		c:           encK,
+		d:           verifierKey.add(verifierKey.mul(encK, gammaValue), encMask),
+		y:           maskCiphertext,
+		x:           gammaPoint,
+	}
+
+	wideAffineProof, err := proveAffineOperation(proofCtx, wideStatement, &affineOperationWitness{
+		x:    gammaValue,
+		y:    wideMask,
+		rho:  maskNonce,
+		rhoY: maskCiphertextNonce,
+	}, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove affine operation", err)
+	}
+
+	err = wideAffineProof.verify(proofCtx, wideStatement, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "affine operation with mask out of range must not be accepted")
+	}
+}
+
+func TestLogStarProof(t *testing.T) {
+	proverKey, _, _ := generateProofTestKey(t)
+	_, verifierParams, _ := generateProofTestKey(t)
+	proofCtx := &proofContext{sessionID: "sign", proverID: 1, verifierID: 2}
+	otherCtx := &proofContext{sessionID: "sign", proverID: 3, verifierID: 2}
+
+	k, err := randomScalar()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate scalar", err)
+	}
+
+	gamma, err := randomScalar()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate scalar", err)
+	}
+
+	kValue := scalarBigInt(k)
+	encK, encKNonce, err := proverKey.encryptWithRandomNonce(kValue)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to encrypt", err)
+	}
+
+	gammaPoint := scalarBaseMult(gamma)
+	statement := &logStarStatement{
+		key: &proverKey.paillierPublicKey,
+		c:   encK,
+		x:   pointMult(k, gammaPoint),
+		g:   gammaPoint,
+	}
+
+	proof, err := proveLogStar(proofCtx, statement, kValue, encKNonce, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove discrete logarithm", err)
+	}
+
+	err = proof.verify(proofCtx, statement, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "valid log proof must be accepted", err)
+	}
+
+	err = proof.verify(otherCtx, statement, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "log proof of other party must not be accepted")
+	}
+
+	wrongStatement := *statement
+	wrongStatement.x = pointMult(gamma, gammaPoint)
+	err = proof.verify(proofCtx, &wrongStatement, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "log proof of other point must not be accepted")
+	}
+
+	// point not consistent with encrypted value
+	anotherK, err := randomScalar()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to generate scalar", err)
+	}
+
+	wrongStatement = *statement
+	wrongStatement.x = pointMult(anotherK, gammaPoint)
+	wrongProof, err := proveLogStar(proofCtx, &wrongStatement, kValue, encKNonce, verifierParams)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to prove discrete logarithm", err)
+	}
+
+	err = wrongProof.verify(proofCtx, &wrongStatement, verifierParams)
+	if !errors.Is(err, ErrThresholdProofFailed) {
+		t.Fatalf("%s", "log proof of point not consistent with ciphertext must not be accepted")
+	}
+}
@@ -0,0 +1,566 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	thresholdSignRoundCommit = iota + 1
+	thresholdSignRoundMtA
+	thresholdSignRoundDelta
+	thresholdSignRoundSignature
+)
+
+// thresholdSigningParams - params of threshold signing session, JSON format
+type thresholdSigningParams struct {
+	// SessionID - unique signing session ID, same for all signing parties
+	SessionID string `json:"sessionId"`
+	// Parties - IDs of signing parties, count must be equal or greater than threshold
+	Parties []uint32 `json:"parties"`
+}
+
+type thresholdSignCommitMessage struct {
+	Commitment hexutil.Bytes `json:"commitment"`
+	// EncK - Paillier encryption of nonce share by sender key
+	EncK hexutil.Bytes `json:"encK"`
+	// EncKProofs - range proofs of EncK plaintext by ring-Pedersen params of every recipient
+	EncKProofs map[uint32]*encryptionInRangeProof `json:"encKProofs"`
+}
+
+// thresholdMtA - MtA response with affine operation range proof
+type thresholdMtA struct {
+	// Ciphertext - encK^x * Enc(mask) by recipient key
+	Ciphertext hexutil.Bytes `json:"ciphertext"`
+	// MaskCiphertext - Enc(mask) by sender key
+	MaskCiphertext hexutil.Bytes         `json:"maskCiphertext"`
+	Proof          *affineOperationProof `json:"proof"`
+}
+
+type thresholdSignMtAMessage struct {
+	// Gamma, Nonce - decommitment of sender gamma point
+	Gamma hexutil.Bytes `json:"gamma"`
+	Nonce hexutil.Bytes `json:"nonce"`
+	// MtAGamma - MtA of recipient nonce share and sender gamma share
+	MtAGamma *thresholdMtA `json:"mtaGamma"`
+	// MtAW - MtA of recipient nonce share and sender signing key share
+	MtAW *thresholdMtA `json:"mtaW"`
+}
+
+type thresholdSignDeltaMessage struct {
+	Delta hexutil.Bytes `json:"delta"`
+	// BigDelta - k_i * Gamma, Gamma - sum of gamma points of parties
+	BigDelta hexutil.Bytes `json:"bigDelta"`
+	// BigDeltaProofs - proofs of BigDelta discrete logarithm, encrypted by round 1 EncK,
+	// by ring-Pedersen params of every recipient
+	BigDeltaProofs map[uint32]*logStarProof `json:"bigDeltaProofs"`
+}
+
+type thresholdSignSignatureMessage struct {
+	S hexutil.Bytes `json:"s"`
+}
+
+// signCommitment - hash commitment of gamma point
+func signCommitment(sessionID string, partyID uint32, gamma []byte, nonce []byte) []byte {
+	return thresholdHash([]byte(sessionID), thresholdPartyBytes(partyID), gamma, nonce)
+}
+
+// mtaResponse - MtA responder part: c = encK^x * Enc(mask), mask in range [0, 2^ℓ'), with proof of x = log(xPoint)
+// by ring-Pedersen params of recipient. Returns MtA response and -mask mod q share
+func mtaResponse(proofCtx *proofContext,
+	recipientKey, senderKey *paillierPublicKey,
+	recipientRingPedersen *ringPedersenParams,
+	encK *big.Int,
+	x *btcec.ModNScalar,
+	xPoint *btcec.JacobianPoint,
+) (*thresholdMtA, *btcec.ModNScalar, error) {
+	mask, err := rand.Int(rand.Reader, proofBound(proofLPrime, nil))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer mask.SetInt64(0)
+
+	encMask, maskNonce, err := recipientKey.encryptWithRandomNonce(mask)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer maskNonce.SetInt64(0)
+
+	maskCiphertext, maskCiphertextNonce, err := senderKey.encryptWithRandomNonce(mask)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer maskCiphertextNonce.SetInt64(0)
+
+	xValue := scalarBigInt(x)
+	defer xValue.SetInt64(0)
+
+	statement := &affineOperationStatement{
+		verifierKey: recipientKey,
+		proverKey:   senderKey,
+		c:           encK,
+		d:           recipientKey.add(recipientKey.mul(encK, xValue), encMask),
+		y:           maskCiphertext,
+		x:           xPoint,
+	}
+
+	proof, err := proveAffineOperation(proofCtx, statement, &affineOperationWitness{
+		x:    xValue,
+		y:    mask,
+		rho:  maskNonce,
+		rhoY: maskCiphertextNonce,
+	}, recipientRingPedersen)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maskShare := scalarFromBigInt(mask)
+	maskShare.Negate()
+
+	return &thresholdMtA{
+		Ciphertext:     statement.d.Bytes(),
+		MaskCiphertext: maskCiphertext.Bytes(),
+		Proof:          proof,
+	}, maskShare, nil
+}
+
+// verifyMtA - verify MtA response of sender by affine operation proof and decrypt k*x + mask share
+func (u *thresholdWalletUnit) verifyMtA(proofCtx *proofContext,
+	mta *thresholdMtA,
+	encK *big.Int,
+	xPoint *btcec.JacobianPoint,
+) (*btcec.ModNScalar, error) {
+	if mta == nil {
+		return nil, fmt.Errorf("%w: empty mta", ErrThresholdWrongMessage)
+	}
+
+	ownKey := &u.paillierKey.paillierPublicKey
+	senderKey := u.paillierPublicKeys[proofCtx.proverID]
+
+	ciphertext := new(big.Int).SetBytes(mta.Ciphertext)
+	maskCiphertext := new(big.Int).SetBytes(mta.MaskCiphertext)
+	if ownKey.validateCiphertext(ciphertext) != nil || senderKey.validateCiphertext(maskCiphertext) != nil {
+		return nil, ErrPaillierWrongCiphertext
+	}
+
+	err := mta.Proof.verify(proofCtx, &affineOperationStatement{
+		verifierKey: ownKey,
+		proverKey:   senderKey,
+		c:           encK,
+		d:           ciphertext,
+		y:           maskCiphertext,
+		x:           xPoint,
+	}, u.ringPedersenParams[u.partyID])
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := u.paillierKey.decryptSigned(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	defer plaintext.SetInt64(0)
+
+	return scalarFromBigInt(plaintext), nil
+}
+
+// thresholdSign - GG18 threshold ECDSA signing of hash by signing parties with CGGMP range proofs of
+// nonce shares encryption and MtA. Delta shares checked by proofs of k_i * Gamma before nonce point computation,
+// signature share sent only after all checks passed. Returns 65 bytes [R || S || V] signature with low S and V in 0/1 format,
+// same as crypto.Sign
+func (u *thresholdWalletUnit) thresholdSign(ctx context.Context,
+	params *thresholdSigningParams,
+	hash []byte,
+) ([]byte, error) {
+	session := newThresholdSession(u.router, params.SessionID, u.partyID, params.Parties)
+	defer session.clear()
+
+	otherParties := session.otherParties()
+
+	var messageScalar btcec.ModNScalar
+	messageScalar.SetByteSlice(hash)
+
+	// w_i = lambda_i * x_i - additive share of signing key for signing parties set
+	var w btcec.ModNScalar
+	w.Mul2(lagrangeCoefficient(u.partyID, session.parties), u.share)
+	defer w.Zero()
+
+	k, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	defer k.Zero()
+
+	gamma, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	defer gamma.Zero()
+
+	gammaPoint := scalarBaseMult(gamma)
+	gammaPointBytes := pointToPublicKey(gammaPoint).SerializeCompressed()
+	wPoint := scalarBaseMult(&w)
+
+	nonce := make([]byte, thresholdNonceLength)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	kValue := scalarBigInt(k)
+	defer kValue.SetInt64(0)
+
+	ownKey := &u.paillierKey.paillierPublicKey
+	encK, encKNonce, err := ownKey.encryptWithRandomNonce(kValue)
+	if err != nil {
+		return nil, err
+	}
+	defer encKNonce.SetInt64(0)
+
+	encKProofs := make(map[uint32]*encryptionInRangeProof, len(otherParties))
+	for _, partyID := range otherParties {
+		encKProofs[partyID], err = proveEncryptionInRange(&proofContext{
+			sessionID:  params.SessionID,
+			proverID:   u.partyID,
+			verifierID: partyID,
+		}, ownKey, encK, kValue, encKNonce, u.ringPedersenParams[partyID])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// round 1 - commitment of gamma point and encrypted nonce share with range proofs
+	err = session.broadcast(ctx, thresholdSignRoundCommit, &thresholdSignCommitMessage{
+		Commitment: signCommitment(params.SessionID, u.partyID, gammaPointBytes, nonce),
+		EncK:       encK.Bytes(),
+		EncKProofs: encKProofs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	commitMessages, err := session.collect(ctx, thresholdSignRoundCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make(map[uint32]*thresholdSignCommitMessage, len(otherParties))
+	partiesEncK := make(map[uint32]*big.Int, len(otherParties))
+	for partyID, payload := range commitMessages {
+		commit := &thresholdSignCommitMessage{}
+		err = json.Unmarshal(payload, commit)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrThresholdWrongMessage, err.Error())
+		}
+
+		pubKey := u.paillierPublicKeys[partyID]
+		partyEncK := new(big.Int).SetBytes(commit.EncK)
+		err = pubKey.validateCiphertext(partyEncK)
+		if err != nil {
+			return nil, fmt.Errorf("%w: party %d", err, partyID)
+		}
+
+		err = commit.EncKProofs[u.partyID].verify(&proofContext{
+			sessionID:  params.SessionID,
+			proverID:   partyID,
+			verifierID: u.partyID,
+		}, pubKey, partyEncK, u.ringPedersenParams[u.partyID])
+		if err != nil {
+			return nil, fmt.Errorf("%w: party %d", err, partyID)
+		}
+
+		commits[partyID] = commit
+		partiesEncK[partyID] = partyEncK
+	}
+
+	// round 2 - decommitment of gamma point, MtA of other parties nonce shares with gamma share
+	// and signing key share
+	var delta, sigma btcec.ModNScalar
+	delta.Mul2(k, gamma)
+	sigma.Mul2(k, &w)
+	defer sigma.Zero()
+
+	for _, partyID := range otherParties {
+		proofCtx := &proofContext{
+			sessionID:  params.SessionID,
+			proverID:   u.partyID,
+			verifierID: partyID,
+		}
+		pubKey := u.paillierPublicKeys[partyID]
+		ringPedersen := u.ringPedersenParams[partyID]
+
+		mtaGamma, beta, loopErr := mtaResponse(proofCtx, pubKey, ownKey, ringPedersen, partiesEncK[partyID],
+			gamma, gammaPoint)
+		if loopErr != nil {
+			return nil, loopErr
+		}
+
+		mtaW, nu, loopErr := mtaResponse(proofCtx, pubKey, ownKey, ringPedersen, partiesEncK[partyID],
+			&w, wPoint)
+		if loopErr != nil {
+			return nil, loopErr
+		}
+
+		delta.Add(beta)
+		sigma.Add(nu)
+		beta.Zero()
+		nu.Zero()
+
+		loopErr = session.send(ctx, partyID, thresholdSignRoundMtA, &thresholdSignMtAMessage{
+			Gamma:    gammaPointBytes,
+			Nonce:    nonce,
+			MtAGamma: mtaGamma,
+			MtAW:     mtaW,
+		})
+		if loopErr != nil {
+			return nil, loopErr
+		}
+	}
+
+	mtaMessages, err := session.collect(ctx, thresholdSignRoundMtA)
+	if err != nil {
+		return nil, err
+	}
+
+	gammaSum := gammaPoint
+	for partyID, payload := range mtaMessages {
+		mta := &thresholdSignMtAMessage{}
+		err = json.Unmarshal(payload, mta)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrThresholdWrongMessage, err.Error())
+		}
+
+		commitment := signCommitment(params.SessionID, partyID, mta.Gamma, mta.Nonce)
+		if subtle.ConstantTimeCompare(commitment, commits[partyID].Commitment) != 1 {
+			return nil, fmt.Errorf("%w: party %d", ErrThresholdCommitmentFailed, partyID)
+		}
+
+		partyGamma, loopErr := parsePoint(mta.Gamma)
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong gamma of party %d", ErrThresholdProtocolAborted, partyID)
+		}
+
+		// W_j = lambda_j * X_j - public additive share of signing key of party
+		var partyPublicShare btcec.JacobianPoint
+		u.publicShares[partyID].AsJacobian(&partyPublicShare)
+		partyWPoint := pointMult(lagrangeCoefficient(partyID, session.parties), &partyPublicShare)
+
+		proofCtx := &proofContext{
+			sessionID:  params.SessionID,
+			proverID:   partyID,
+			verifierID: u.partyID,
+		}
+
+		alpha, loopErr := u.verifyMtA(proofCtx, mta.MtAGamma, encK, partyGamma)
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: party %d", loopErr, partyID)
+		}
+
+		mu, loopErr := u.verifyMtA(proofCtx, mta.MtAW, encK, partyWPoint)
+		if loopErr != nil {
+			alpha.Zero()
+
+			return nil, fmt.Errorf("%w: party %d", loopErr, partyID)
+		}
+
+		delta.Add(alpha)
+		sigma.Add(mu)
+		alpha.Zero()
+		mu.Zero()
+
+		gammaSum = pointAdd(gammaSum, partyGamma)
+	}
+
+	// round 3 - delta = k * gamma, Delta_i = k_i * Gamma with proof of k_i encrypted by EncK
+	bigDelta := pointMult(k, gammaSum)
+	bigDeltaSum := bigDelta
+
+	bigDeltaProofs := make(map[uint32]*logStarProof, len(otherParties))
+	for _, partyID := range otherParties {
+		bigDeltaProofs[partyID], err = proveLogStar(&proofContext{
+			sessionID:  params.SessionID,
+			proverID:   u.partyID,
+			verifierID: partyID,
+		}, &logStarStatement{
+			key: ownKey,
+			c:   encK,
+			x:   bigDelta,
+			g:   gammaSum,
+		}, kValue, encKNonce, u.ringPedersenParams[partyID])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = session.broadcast(ctx, thresholdSignRoundDelta, &thresholdSignDeltaMessage{
+		Delta:          scalarBytes(&delta),
+		BigDelta:       pointToPublicKey(bigDelta).SerializeCompressed(),
+		BigDeltaProofs: bigDeltaProofs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deltaMessages, err := session.collect(ctx, thresholdSignRoundDelta)
+	if err != nil {
+		return nil, err
+	}
+
+	for partyID, payload := range deltaMessages {
+		deltaMessage := &thresholdSignDeltaMessage{}
+		err = json.Unmarshal(payload, deltaMessage)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrThresholdWrongMessage, err.Error())
+		}
+
+		partyDelta, loopErr := scalarFromBytes(deltaMessage.Delta)
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong delta of party %d", ErrThresholdProtocolAborted, partyID)
+		}
+
+		partyBigDelta, loopErr := parsePoint(deltaMessage.BigDelta)
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong big delta of party %d", ErrThresholdProtocolAborted, partyID)
+		}
+
+		loopErr = deltaMessage.BigDeltaProofs[u.partyID].verify(&proofContext{
+			sessionID:  params.SessionID,
+			proverID:   partyID,
+			verifierID: u.partyID,
+		}, &logStarStatement{
+			key: u.paillierPublicKeys[partyID],
+			c:   partiesEncK[partyID],
+			x:   partyBigDelta,
+			g:   gammaSum,
+		}, u.ringPedersenParams[u.partyID])
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: party %d", loopErr, partyID)
+		}
+
+		delta.Add(partyDelta)
+		bigDeltaSum = pointAdd(bigDeltaSum, partyBigDelta)
+	}
+
+	if delta.IsZero() {
+		return nil, fmt.Errorf("%w: delta is zero", ErrThresholdProtocolAborted)
+	}
+
+	// delta * G = sum(k_i) * Gamma - delta shares consistent with nonce shares of round 1
+	if !pointEquals(scalarBaseMult(&delta), bigDeltaSum) {
+		return nil, fmt.Errorf("%w: delta not consistent with nonce shares", ErrThresholdProtocolAborted)
+	}
+
+	// R = delta^-1 * sum(Gamma_i) = k^-1 * G
+	delta.InverseNonConst()
+	noncePoint := pointMult(&delta, gammaSum)
+	noncePoint.ToAffine()
+
+	var r btcec.ModNScalar
+	rOverflow := r.SetBytes((*[32]byte)(noncePoint.X.Bytes()))
+	if r.IsZero() || rOverflow != 0 {
+		return nil, fmt.Errorf("%w: wrong nonce point", ErrThresholdProtocolAborted)
+	}
+
+	// round 4 - s_i = m * k_i + r * sigma_i
+	var s, rSigma btcec.ModNScalar
+	s.Mul2(&messageScalar, k)
+	rSigma.Mul2(&r, &sigma)
+	s.Add(&rSigma)
+	rSigma.Zero()
+
+	err = session.broadcast(ctx, thresholdSignRoundSignature, &thresholdSignSignatureMessage{S: scalarBytes(&s)})
+	if err != nil {
+		return nil, err
+	}
+
+	signatureMessages, err := session.collect(ctx, thresholdSignRoundSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	for partyID, payload := range signatureMessages {
+		signatureMessage := &thresholdSignSignatureMessage{}
+		err = json.Unmarshal(payload, signatureMessage)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrThresholdWrongMessage, err.Error())
+		}
+
+		partyS, loopErr := scalarFromBytes(signatureMessage.S)
+		if loopErr != nil {
+			return nil, fmt.Errorf("%w: wrong signature share of party %d", ErrThresholdProtocolAborted, partyID)
+		}
+
+		s.Add(partyS)
+	}
+
+	if s.IsZero() {
+		return nil, fmt.Errorf("%w: signature is zero", ErrThresholdProtocolAborted)
+	}
+
+	recoveryID := byte(0)
+	if noncePoint.Y.IsOdd() {
+		recoveryID = 1
+	}
+
+	if s.IsOverHalfOrder() {
+		s.Negate()
+		recoveryID ^= 1
+	}
+
+	signature := make([]byte, crypto.SignatureLength)
+	copy(signature[:32], scalarBytes(&r))
+	copy(signature[32:64], scalarBytes(&s))
+	signature[crypto.RecoveryIDOffset] = recoveryID
+
+	// signature of all parties must be valid signature of group key
+	recoveredPubKey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrThresholdProtocolAborted, err.Error())
+	}
+
+	if crypto.PubkeyToAddress(*recoveredPubKey).Hex() != u.address {
+		return nil, fmt.Errorf("%w: signature not match group public key", ErrThresholdProtocolAborted)
+	}
+
+	return signature, nil
+}
@@ -0,0 +1,231 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// thresholdPendingMessagesLimit - max count of buffered messages of not yet started rounds and sessions
+const thresholdPendingMessagesLimit = 4096
+
+var (
+	ErrThresholdWrongTransport    = errors.New("wrong threshold transport")
+	ErrThresholdWrongMessage      = errors.New("wrong threshold protocol message")
+	ErrThresholdPendingOverflow   = errors.New("too many pending threshold protocol messages")
+	ErrThresholdPartyNotInSession = errors.New("party is not member of threshold session")
+)
+
+// thresholdTransport - host-provided transport of threshold protocol messages between parties.
+// Transport must authenticate sender party ID and keep messages confidential -
+// key generation messages contain secret shares of recipient.
+// Local in-process implementation can be used for tests instead of network
+type thresholdTransport interface {
+	// Send - send message to party
+	Send(ctx context.Context, toPartyID uint32, message []byte) error
+	// Receive - wait for next message, addressed to current party
+	Receive(ctx context.Context) (fromPartyID uint32, message []byte, err error)
+}
+
+// thresholdMessage - envelope of threshold protocol message
+type thresholdMessage struct {
+	SessionID string          `json:"sessionId"`
+	Round     uint32          `json:"round"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type thresholdMessageKey struct {
+	sessionID string
+	round     uint32
+	from      uint32
+}
+
+// thresholdRouter - router of protocol messages by session and round.
+// Messages of not yet started rounds and sessions buffered until collected
+type thresholdRouter struct {
+	transport thresholdTransport
+	pending   map[thresholdMessageKey]json.RawMessage
+}
+
+func newThresholdRouter(transport interface{}) (*thresholdRouter, error) {
+	transportSvc, ok := transport.(thresholdTransport)
+	if !ok || transportSvc == nil {
+		return nil, ErrThresholdWrongTransport
+	}
+
+	return &thresholdRouter{
+		transport: transportSvc,
+		pending:   make(map[thresholdMessageKey]json.RawMessage),
+	}, nil
+}
+
+// thresholdSession - single run of threshold protocol between parties
+type thresholdSession struct {
+	id      string
+	partyID uint32
+	// parties - sorted list of session parties IDs, current party included
+	parties []uint32
+	router  *thresholdRouter
+}
+
+func newThresholdSession(router *thresholdRouter, sessionID string, partyID uint32, parties []uint32) *thresholdSession {
+	sortedParties := append([]uint32{}, parties...)
+	sort.Slice(sortedParties, func(i, j int) bool {
+		return sortedParties[i] < sortedParties[j]
+	})
+
+	return &thresholdSession{
+		id:      sessionID,
+		partyID: partyID,
+		parties: sortedParties,
+		router:  router,
+	}
+}
+
+// otherParties - session parties without current party
+func (s *thresholdSession) otherParties() []uint32 {
+	result := make([]uint32, 0, len(s.parties)-1)
+	for _, partyID := range s.parties {
+		if partyID != s.partyID {
+			result = append(result, partyID)
+		}
+	}
+
+	return result
+}
+
+func (s *thresholdSession) send(ctx context.Context, toPartyID uint32, round uint32, payload interface{}) error {
+	payloadData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	messageData, err := json.Marshal(&thresholdMessage{
+		SessionID: s.id,
+		Round:     round,
+		Payload:   payloadData,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.router.transport.Send(ctx, toPartyID, messageData)
+}
+
+// broadcast - send same message to every other party of session
+func (s *thresholdSession) broadcast(ctx context.Context, round uint32, payload interface{}) error {
+	for _, partyID := range s.otherParties() {
+		err := s.send(ctx, partyID, round, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collect - wait for messages of round from every other party of session
+func (s *thresholdSession) collect(ctx context.Context, round uint32) (map[uint32]json.RawMessage, error) {
+	result := make(map[uint32]json.RawMessage, len(s.parties)-1)
+
+	for _, partyID := range s.otherParties() {
+		key := thresholdMessageKey{sessionID: s.id, round: round, from: partyID}
+
+		payload, isExists := s.router.pending[key]
+		if isExists {
+			result[partyID] = payload
+			delete(s.router.pending, key)
+		}
+	}
+
+	for len(result) < len(s.parties)-1 {
+		fromPartyID, messageData, err := s.router.transport.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		message := &thresholdMessage{}
+		err = json.Unmarshal(messageData, message)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrThresholdWrongMessage, err.Error())
+		}
+
+		if message.SessionID == s.id && message.Round == round {
+			if !s.isMember(fromPartyID) || fromPartyID == s.partyID {
+				return nil, fmt.Errorf("%w: party %d", ErrThresholdPartyNotInSession, fromPartyID)
+			}
+
+			if _, isExists := result[fromPartyID]; isExists {
+				return nil, fmt.Errorf("%w: duplicated message of party %d in round %d",
+					ErrThresholdWrongMessage, fromPartyID, round)
+			}
+
+			result[fromPartyID] = message.Payload
+
+			continue
+		}
+
+		if len(s.router.pending) >= thresholdPendingMessagesLimit {
+			return nil, ErrThresholdPendingOverflow
+		}
+
+		s.router.pending[thresholdMessageKey{
+			sessionID: message.SessionID,
+			round:     message.Round,
+			from:      fromPartyID,
+		}] = message.Payload
+	}
+
+	return result, nil
+}
+
+// clear - drop buffered messages of finished session
+func (s *thresholdSession) clear() {
+	for key := range s.router.pending {
+		if key.sessionID == s.id {
+			delete(s.router.pending, key)
+		}
+	}
+}
+
+func (s *thresholdSession) isMember(partyID uint32) bool {
+	idx := sort.Search(len(s.parties), func(i int) bool {
+		return s.parties[i] >= partyID
+	})
+
+	return idx < len(s.parties) && s.parties[idx] == partyID
+}
@@ -0,0 +1,256 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	transferKindNative = "native"
+	transferKindERC20  = "erc20"
+)
+
+// erc20TransferMethodID - first 4 bytes of keccak256("transfer(address,uint256)")
+var erc20TransferMethodID = []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+var (
+	ErrTransferIntentUnknownKind      = errors.New("unknown kind of transfer intent")
+	ErrTransferIntentMissingRecipient = errors.New("missing recipient address in transfer intent")
+	ErrTransferIntentMissingAmount    = errors.New("missing or negative amount in transfer intent")
+	ErrTransferIntentMissingToken     = errors.New("missing token contract address in erc20 transfer intent")
+	ErrTransferIntentMissingGasLimit  = errors.New("missing gas limit in transfer intent")
+	ErrTransferIntentMissingFees      = errors.New("missing fee values in transfer intent")
+	ErrTransferIntentAmbiguousFees    = errors.New("gasPrice and maxFeePerGas values can't be used together")
+	ErrTransferIntentWrongFeeCaps     = errors.New("maxPriorityFeePerGas value is greater than maxFeePerGas")
+	ErrTransferIntentWrongChainID     = errors.New("chain id of transfer intent not equal with plugin chain id")
+)
+
+// transferIntent - structured description of native or ERC-20 transfer.
+// Numeric fields accept hex (0x-prefixed) or decimal strings
+//
+// Transaction type selected by fee fields:
+//   - gasPrice - legacy EIP-155 transaction
+//   - maxFeePerGas and maxPriorityFeePerGas - EIP-1559 dynamic fee transaction
+//   - maxFeePerGas, maxPriorityFeePerGas and feeCurrency - Celo CIP-64 transaction, only for Celo chain IDs
+type transferIntent struct {
+	Kind          string                `json:"kind"`
+	To            common.Address        `json:"to"`
+	Amount        *math.HexOrDecimal256 `json:"amount"`
+	TokenContract *common.Address       `json:"tokenContract,omitempty"`
+
+	Nonce math.HexOrDecimal64 `json:"nonce"`
+
+	txFeeParams
+	FeeCurrency *common.Address `json:"feeCurrency,omitempty"`
+
+	// ChainID - optional, if set must be equal with plugin chain ID
+	ChainID *math.HexOrDecimal256 `json:"chainId,omitempty"`
+}
+
+// signedTransfer - result of transfer intent signature. Contains raw signed transaction, hash
+// and decoded fields for logging
+type signedTransfer struct {
+	signedTxSummary
+
+	Kind          string          `json:"kind"`
+	Recipient     common.Address  `json:"recipient"`
+	Amount        *hexutil.Big    `json:"amount"`
+	TokenContract *common.Address `json:"tokenContract,omitempty"`
+}
+
+func (i *transferIntent) validate(pluginChainID *big.Int) error {
+	switch i.Kind {
+	case transferKindNative:
+	case transferKindERC20:
+		if i.TokenContract == nil || *i.TokenContract == (common.Address{}) {
+			return ErrTransferIntentMissingToken
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrTransferIntentUnknownKind, i.Kind)
+	}
+
+	if i.To == (common.Address{}) {
+		return ErrTransferIntentMissingRecipient
+	}
+
+	if i.Amount == nil || (*big.Int)(i.Amount).Sign() < 0 {
+		return ErrTransferIntentMissingAmount
+	}
+
+	err := i.txFeeParams.validate()
+	if err != nil {
+		return err
+	}
+
+	if i.FeeCurrency != nil && i.GasPrice != nil {
+		return fmt.Errorf("%w: feeCurrency supported only with dynamic fee values", ErrTransferIntentAmbiguousFees)
+	}
+
+	if i.FeeCurrency != nil && !isCeloChainID(pluginChainID) {
+		return fmt.Errorf("%w: %s", ErrCeloTxUnsupportedChain, pluginChainID)
+	}
+
+	if i.ChainID != nil && (*big.Int)(i.ChainID).Cmp(pluginChainID) != 0 {
+		return fmt.Errorf("%w: have %d want %d", ErrTransferIntentWrongChainID,
+			(*big.Int)(i.ChainID), pluginChainID)
+	}
+
+	return nil
+}
+
+// callParams - returns recipient of transaction, value and call data
+func (i *transferIntent) callParams() (*common.Address, *big.Int, []byte) {
+	amount := new(big.Int).Set((*big.Int)(i.Amount))
+
+	if i.Kind == transferKindERC20 {
+		tokenContract := *i.TokenContract
+
+		return &tokenContract, new(big.Int), makeERC20TransferData(i.To, amount)
+	}
+
+	to := i.To
+
+	return &to, amount, nil
+}
+
+func (i *transferIntent) makeTxData(chainID *big.Int) types.TxData {
+	to, value, data := i.callParams()
+
+	return i.txFeeParams.makeTxData(chainID, uint64(i.Nonce), to, value, data)
+}
+
+func (i *transferIntent) makeCeloTx(chainID *big.Int) *celoDynamicFeeTxV2 {
+	to, value, data := i.callParams()
+
+	return i.txFeeParams.makeCeloTx(chainID, uint64(i.Nonce), to, value, data, *i.FeeCurrency)
+}
+
+// makeERC20TransferData - ABI encoded call data of transfer(address,uint256) ERC-20 method
+func makeERC20TransferData(recipient common.Address, amount *big.Int) []byte {
+	data := make([]byte, 0, len(erc20TransferMethodID)+common.HashLength*2)
+	data = append(data, erc20TransferMethodID...)
+	data = append(data, common.LeftPadBytes(recipient.Bytes(), common.HashLength)...)
+	data = append(data, common.LeftPadBytes(amount.Bytes(), common.HashLength)...)
+
+	return data
+}
+
+func (i *transferIntent) describe() *signedTransfer {
+	amount := new(big.Int).Set((*big.Int)(i.Amount))
+
+	return &signedTransfer{
+		Kind:          i.Kind,
+		Recipient:     i.To,
+		Amount:        (*hexutil.Big)(amount),
+		TokenContract: i.TokenContract,
+	}
+}
+
+// makeSigningTx - validate transfer intent and assemble transaction, Celo CIP-64 transaction if fee currency set
+func (i *transferIntent) makeSigningTx(chainID *big.Int) (*signingTx, error) {
+	err := i.validate(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.FeeCurrency != nil {
+		return &signingTx{celoTx: i.makeCeloTx(chainID)}, nil
+	}
+
+	return &signingTx{tx: types.NewTx(i.makeTxData(chainID))}, nil
+}
+
+// SignTransfer - assemble transaction from transfer intent, sign it and return JSON-encoded signedTransfer.
+// accountParameters - sender derivation path, transferIntentData - JSON-encoded transferIntent
+func (u *mnemonicWalletUnit) SignTransfer(ctx context.Context,
+	accountParameters *anypb.Any,
+	transferIntentData []byte,
+) (*string, []byte, error) {
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	intent := &transferIntent{}
+	err = json.Unmarshal(transferIntentData, intent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to unmarshal transfer intent: %w", err)
+	}
+
+	tx, err := intent.makeSigningTx(u.dataSigner.ChainID())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = u.approveAccountTx(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, summary, err := u.signDecodedTx(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := intent.describe()
+	result.signedTxSummary = *summary
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	from := result.From.Hex()
+
+	return &from, resultData, nil
+}
@@ -0,0 +1,206 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMnemonicWalletUnit_SignTransfer(t *testing.T) {
+	type testCase struct {
+		Name   string
+		Intent string
+
+		ExpectedType  uint8
+		ExpectedTo    string
+		ExpectedValue string
+		ExpectedData  string
+	}
+
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	addressPath := &pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	}
+	expectedAddress := "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56"
+
+	testCases := []*testCase{
+		{
+			Name: "native dynamic fee transfer",
+			Intent: `{"kind":"native","to":"0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8",` +
+				`"amount":"1500000","nonce":"7","gasLimit":"21000",` +
+				`"maxFeePerGas":"30000000000","maxPriorityFeePerGas":"0x3b9aca00","chainId":"1"}`,
+			ExpectedType:  types.DynamicFeeTxType,
+			ExpectedTo:    "0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8",
+			ExpectedValue: "0x16e360",
+			ExpectedData:  "0x",
+		},
+		{
+			Name: "erc20 legacy transfer",
+			Intent: `{"kind":"erc20","to":"0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489",` +
+				`"amount":"1000000000000000000","tokenContract":"0xdAC17F958D2ee523a2206206994597C13D831ec7",` +
+				`"nonce":"0x0c","gasLimit":"65000","gasPrice":"10000000000"}`,
+			ExpectedType:  types.LegacyTxType,
+			ExpectedTo:    "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+			ExpectedValue: "0x0",
+			ExpectedData: "0xa9059cbb" +
+				"00000000000000000000000040b38765696e3d5d8d9d834d8aad4bb6e418e489" +
+				"0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+		},
+	}
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity := &anypb.Any{}
+	_ = accountIdentity.MarshalFrom(addressPath)
+
+	for _, tCase := range testCases {
+		addr, resultData, loopErr := poolUnit.SignTransfer(context.Background(), accountIdentity,
+			[]byte(tCase.Intent))
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to sign transfer", loopErr)
+		}
+
+		if addr == nil || expectedAddress != *addr {
+			t.Fatalf("%s: %s", tCase.Name, "address not equal with expected")
+		}
+
+		result := &signedTransfer{}
+		loopErr = json.Unmarshal(resultData, result)
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to unmarshal result", loopErr)
+		}
+
+		signedTx := &types.Transaction{}
+		loopErr = signedTx.UnmarshalBinary(result.RawTx)
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to unmarshal signed transaction", loopErr)
+		}
+
+		if signedTx.Hash() != result.TxHash {
+			t.Fatalf("%s: %s", tCase.Name, "transaction hash not equal with expected")
+		}
+
+		if signedTx.Type() != tCase.ExpectedType || uint64(result.Type) != uint64(tCase.ExpectedType) {
+			t.Fatalf("%s: %s", tCase.Name, "transaction type not equal with expected")
+		}
+
+		sender, loopErr := types.Sender(poolUnit.dataSigner, signedTx)
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to recover sender", loopErr)
+		}
+
+		if expectedAddress != sender.Hex() || expectedAddress != result.From.Hex() {
+			t.Fatalf("%s: %s", tCase.Name, "sender not equal with expected")
+		}
+
+		if signedTx.To() == nil || tCase.ExpectedTo != signedTx.To().Hex() {
+			t.Fatalf("%s: %s", tCase.Name, "transaction recipient not equal with expected")
+		}
+
+		if tCase.ExpectedValue != hexutil.EncodeBig(signedTx.Value()) {
+			t.Fatalf("%s: %s", tCase.Name, "transaction value not equal with expected")
+		}
+
+		if !bytes.Equal(hexutil.MustDecode(tCase.ExpectedData), signedTx.Data()) {
+			t.Fatalf("%s: %s", tCase.Name, "transaction data not equal with expected")
+		}
+	}
+}
+
+func TestMnemonicWalletUnit_SignTransfer_InvalidIntent(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity := &anypb.Any{}
+	_ = accountIdentity.MarshalFrom(&pbCommon.DerivationAddressIdentity{})
+
+	intents := []string{
+		// unknown kind
+		`{"kind":"erc721","to":"0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489","amount":"1",` +
+			`"gasLimit":"21000","gasPrice":"1"}`,
+		// erc20 without token contract
+		`{"kind":"erc20","to":"0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489","amount":"1",` +
+			`"gasLimit":"65000","gasPrice":"1"}`,
+		// both fee kinds
+		`{"kind":"native","to":"0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489","amount":"1",` +
+			`"gasLimit":"21000","gasPrice":"1","maxFeePerGas":"2","maxPriorityFeePerGas":"1"}`,
+		// tip greater than fee cap
+		`{"kind":"native","to":"0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489","amount":"1",` +
+			`"gasLimit":"21000","maxFeePerGas":"1","maxPriorityFeePerGas":"2"}`,
+		// foreign chain
+		`{"kind":"native","to":"0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489","amount":"1",` +
+			`"gasLimit":"21000","gasPrice":"1","chainId":"56"}`,
+		// fee currency on non-celo chain
+		`{"kind":"native","to":"0x40B38765696e3d5d8d9d834D8AaD4bB6e418E489","amount":"1",` +
+			`"gasLimit":"21000","maxFeePerGas":"2","maxPriorityFeePerGas":"1",` +
+			`"feeCurrency":"` + common.HexToAddress("0x765DE816845861e75A25fCA122bb6898B8B1282a").Hex() + `"}`,
+	}
+
+	for i, intent := range intents {
+		_, _, loopErr := poolUnit.SignTransfer(context.Background(), accountIdentity, []byte(intent))
+		if loopErr == nil {
+			t.Fatalf("%s: %d", "invalid transfer intent signed", i)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// celoDynamicFeeTxV2Type - CIP-64 transaction type. Same as EIP-1559 transaction, but with feeCurrency field.
+// See https://github.com/celo-org/celo-proposals/blob/master/CIPs/cip-0064.md
+const celoDynamicFeeTxV2Type = 0x7b
+
+const (
+	celoMainNetChainID   = 42220
+	celoAlfajoresChainID = 44787
+	celoBaklavaChainID   = 62320
+)
+
+var (
+	ErrCeloTxUnsupportedChain = errors.New("celo fee currency transactions are not supported by chain")
+	ErrCeloTxWrongType        = errors.New("wrong celo transaction type")
+)
+
+func isCeloChainID(chainID *big.Int) bool {
+	if chainID == nil || !chainID.IsInt64() {
+		return false
+	}
+
+	switch chainID.Int64() {
+	case celoMainNetChainID, celoAlfajoresChainID, celoBaklavaChainID:
+		return true
+	default:
+		return false
+	}
+}
+
+// isCeloDynamicFeeTxV2Data - checks type prefix of typed transaction envelope
+func isCeloDynamicFeeTxV2Data(data []byte) bool {
+	return len(data) > 0 && data[0] == celoDynamicFeeTxV2Type
+}
+
+// celoDynamicFeeTxV2 - CIP-64 transaction, gas can be paid in ERC-20 fee currency, e.g. cUSD
+type celoDynamicFeeTxV2 struct {
+	ChainID     *big.Int
+	Nonce       uint64
+	GasTipCap   *big.Int // a.k.a. maxPriorityFeePerGas
+	GasFeeCap   *big.Int // a.k.a. maxFeePerGas
+	Gas         uint64
+	To          *common.Address `rlp:"nil"` // nil means contract creation
+	Value       *big.Int
+	Data        []byte
+	AccessList  types.AccessList
+	FeeCurrency *common.Address `rlp:"nil"` // nil means native currency
+
+	// Signature values
+	V *big.Int
+	R *big.Int
+	S *big.Int
+}
+
+// celoDynamicFeeTxV2SigPayload - list of fields which used for signing hash calculation
+type celoDynamicFeeTxV2SigPayload struct {
+	ChainID     *big.Int
+	Nonce       uint64
+	GasTipCap   *big.Int
+	GasFeeCap   *big.Int
+	Gas         uint64
+	To          *common.Address `rlp:"nil"`
+	Value       *big.Int
+	Data        []byte
+	AccessList  types.AccessList
+	FeeCurrency *common.Address `rlp:"nil"`
+}
+
+func (tx *celoDynamicFeeTxV2) UnmarshalBinary(data []byte) error {
+	if !isCeloDynamicFeeTxV2Data(data) {
+		return ErrCeloTxWrongType
+	}
+
+	err := rlp.DecodeBytes(data[1:], tx)
+	if err != nil {
+		return fmt.Errorf("unable to decode celo transaction: %w", err)
+	}
+
+	if tx.V == nil {
+		tx.V = new(big.Int)
+	}
+
+	if tx.R == nil {
+		tx.R = new(big.Int)
+	}
+
+	if tx.S == nil {
+		tx.S = new(big.Int)
+	}
+
+	return nil
+}
+
+func (tx *celoDynamicFeeTxV2) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(celoDynamicFeeTxV2Type)
+
+	err := rlp.Encode(&buf, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SigHash - keccak256(0x7b || rlp([chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gasLimit,
+// to, value, data, accessList, feeCurrency]))
+func (tx *celoDynamicFeeTxV2) SigHash() (common.Hash, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(celoDynamicFeeTxV2Type)
+
+	err := rlp.Encode(&buf, &celoDynamicFeeTxV2SigPayload{
+		ChainID:     tx.ChainID,
+		Nonce:       tx.Nonce,
+		GasTipCap:   tx.GasTipCap,
+		GasFeeCap:   tx.GasFeeCap,
+		Gas:         tx.Gas,
+		To:          tx.To,
+		Value:       tx.Value,
+		Data:        tx.Data,
+		AccessList:  tx.AccessList,
+		FeeCurrency: tx.FeeCurrency,
+	})
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return crypto.Keccak256Hash(buf.Bytes()), nil
+}
+
+// Hash - transaction hash, keccak256 of signed binary data
+func (tx *celoDynamicFeeTxV2) Hash() (common.Hash, error) {
+	rawData, err := tx.MarshalBinary()
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return crypto.Keccak256Hash(rawData), nil
+}
+
+// Sender - recover transaction sender address from signature values
+func (tx *celoDynamicFeeTxV2) Sender() (common.Address, error) {
+	sigHash, err := tx.SigHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	// CIP-64 txs are defined to use 0 and 1 as their recovery
+	// id, add 27 to become equivalent to unprotected Homestead signatures.
+	V := new(big.Int).Add(tx.V, big.NewInt(27))
+
+	_, addr, err := recoverPlain(sigHash, tx.R, tx.S, V, true)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return addr, nil
+}
+
+// signCeloDynamicFeeTxV2 - sign CIP-64 transaction. Returns new signed copy of transaction
+func signCeloDynamicFeeTxV2(tx *celoDynamicFeeTxV2,
+	chainID *big.Int,
+	privateKey *ecdsa.PrivateKey,
+) (*celoDynamicFeeTxV2, error) {
+	if !isCeloChainID(chainID) {
+		return nil, fmt.Errorf("%w: %s", ErrCeloTxUnsupportedChain, chainID)
+	}
+
+	if tx.ChainID == nil || tx.ChainID.Cmp(chainID) != 0 {
+		return nil, fmt.Errorf("%w: have %d want %d", types.ErrInvalidChainId, tx.ChainID, chainID)
+	}
+
+	sigHash, err := tx.SigHash()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := crypto.Sign(sigHash[:], privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx := *tx
+	signedTx.R = new(big.Int).SetBytes(sig[:32])
+	signedTx.S = new(big.Int).SetBytes(sig[32:64])
+	signedTx.V = new(big.Int).SetBytes([]byte{sig[64]})
+
+	return &signedTx, nil
+}
@@ -0,0 +1,294 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// WARN: DO NOT USE THIS PRIVATE KEY IN MAINNET OR TESTNET. Usage only in unit-tests
+// Well-known development private key of first account of anvil/hardhat/ganache node.
+// Same key used in celo fixtures of viem serializer tests
+const celoTestPrivateKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+
+func TestCeloDynamicFeeTxV2_KnownAnswer(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Tx *celoDynamicFeeTxV2
+
+		ExpectedSigHash  string
+		ExpectedRawTx    string
+		ExpectedTxHash   string
+		ExpectedSenderTx string
+	}
+
+	addrPtr := func(a common.Address) *common.Address {
+		return &a
+	}
+
+	oneEther, _ := new(big.Int).SetString("1000000000000000000", 10)
+
+	testCases := []*testCase{
+		{
+			Name: "native transfer with cUSD fee currency on celo mainnet",
+			Tx: &celoDynamicFeeTxV2{
+				ChainID:     big.NewInt(celoMainNetChainID),
+				Nonce:       785,
+				GasTipCap:   big.NewInt(2_000_000_000),
+				GasFeeCap:   big.NewInt(2_000_000_000),
+				Gas:         21001,
+				To:          addrPtr(common.HexToAddress("0x90F79bf6EB2c4f870365E785982E1f101E93b906")),
+				Value:       oneEther,
+				FeeCurrency: addrPtr(common.HexToAddress("0x765DE816845861e75A25fCA122bb6898B8B1282a")),
+			},
+			ExpectedSigHash: "0x1835080987e32481abd23a9e5cc2075d50c4ae9dd06fc850b410fa1164c72d15",
+			ExpectedRawTx: "0x7bf88b82a4ec820311847735940084773594008252099490f79bf6eb2c4f870365e785982e1f101e93b906" +
+				"880de0b6b3a764000080c094765de816845861e75a25fca122bb6898b8b1282a80" +
+				"a079cc14d8698b5062ecdb0b7feace5a1e3c4ba569c59127973607c6f924e1fdc7" +
+				"a019f792d3d810b3364c76c1f2400dd686b79b7d0b23b77dbe9491cf987e69fdaa",
+			ExpectedTxHash:   "0xd1caa85ef60b0b5b652e3b1d6598a0c25bfcb28b31a5cf788897401ff3339160",
+			ExpectedSenderTx: "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		},
+		{
+			Name: "erc-20 transfer with access list on celo alfajores",
+			Tx: &celoDynamicFeeTxV2{
+				ChainID:   big.NewInt(celoAlfajoresChainID),
+				Nonce:     0,
+				GasTipCap: big.NewInt(1_000_000_000),
+				GasFeeCap: big.NewInt(25_000_000_000),
+				Gas:       65000,
+				To:        addrPtr(common.HexToAddress("0x874069Fa1Eb16D44d622F2e0Ca25eeA172369bC1")),
+				Value:     big.NewInt(0),
+				Data: hexutil.MustDecode("0xa9059cbb" +
+					"00000000000000000000000090f79bf6eb2c4f870365e785982e1f101e93b906" +
+					"0000000000000000000000000000000000000000000000000de0b6b3a7640000"),
+				AccessList: types.AccessList{{
+					Address:     common.HexToAddress("0x874069Fa1Eb16D44d622F2e0Ca25eeA172369bC1"),
+					StorageKeys: []common.Hash{common.HexToHash("0x01")},
+				}},
+				FeeCurrency: addrPtr(common.HexToAddress("0x874069Fa1Eb16D44d622F2e0Ca25eeA172369bC1")),
+			},
+			ExpectedSigHash: "0xa30d1992fa60a256d159061d10e04095c779cff826f7648b3694f0949e19e177",
+			ExpectedRawTx: "0x7bf9010082aef380843b9aca008505d21dba0082fde894874069fa1eb16d44d622f2e0ca25eea172369bc180" +
+				"b844a9059cbb00000000000000000000000090f79bf6eb2c4f870365e785982e1f101e93b906" +
+				"0000000000000000000000000000000000000000000000000de0b6b3a7640000" +
+				"f838f794874069fa1eb16d44d622f2e0ca25eea172369bc1" +
+				"e1a00000000000000000000000000000000000000000000000000000000000000001" +
+				"94874069fa1eb16d44d622f2e0ca25eea172369bc180" +
+				"a080ccc304035c03ba2e3ee828aefba70a961ea64514e7e37df1b6ac470b851e31" +
+				"a05f19ecb5d15dfb3f2447e688c4ae101bf127e8d5775febc20e3a4243653c23a2",
+			ExpectedTxHash:   "0xdecbbbbeb13f17d2809965c4b5606b9b654cd15f985804d1dd9af66dd6839e6b",
+			ExpectedSenderTx: "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		},
+	}
+
+	privKey, err := crypto.HexToECDSA(celoTestPrivateKey)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decode private key", err)
+	}
+
+	for _, tCase := range testCases {
+		sigHash, loopErr := tCase.Tx.SigHash()
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to calculate signing hash", loopErr)
+		}
+
+		if tCase.ExpectedSigHash != sigHash.Hex() {
+			t.Fatalf("%s: %s", tCase.Name, "signing hash not equal with expected")
+		}
+
+		signedTx, loopErr := signCeloDynamicFeeTxV2(tCase.Tx, tCase.Tx.ChainID, privKey)
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to sign transaction", loopErr)
+		}
+
+		rawTx, loopErr := signedTx.MarshalBinary()
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to marshal signed transaction", loopErr)
+		}
+
+		if tCase.ExpectedRawTx != hexutil.Encode(rawTx) {
+			t.Fatalf("%s: %s", tCase.Name, "signed transaction not equal with expected")
+		}
+
+		decodedTx := &celoDynamicFeeTxV2{}
+		loopErr = decodedTx.UnmarshalBinary(hexutil.MustDecode(tCase.ExpectedRawTx))
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to unmarshal signed transaction", loopErr)
+		}
+
+		reEncoded, loopErr := decodedTx.MarshalBinary()
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to marshal decoded transaction", loopErr)
+		}
+
+		if !bytes.Equal(rawTx, reEncoded) {
+			t.Fatalf("%s: %s", tCase.Name, "re-encoded transaction not equal with expected")
+		}
+
+		if *decodedTx.FeeCurrency != *tCase.Tx.FeeCurrency {
+			t.Fatalf("%s: %s", tCase.Name, "fee currency not equal with expected")
+		}
+
+		txHash, loopErr := decodedTx.Hash()
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to calculate transaction hash", loopErr)
+		}
+
+		if tCase.ExpectedTxHash != txHash.Hex() {
+			t.Fatalf("%s: %s", tCase.Name, "transaction hash not equal with expected")
+		}
+
+		sender, loopErr := decodedTx.Sender()
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", tCase.Name, "unable to recover sender", loopErr)
+		}
+
+		if tCase.ExpectedSenderTx != sender.Hex() {
+			t.Fatalf("%s: %s", tCase.Name, "sender not equal with expected")
+		}
+	}
+}
+
+func TestCeloDynamicFeeTxV2_WrongChainID(t *testing.T) {
+	privKey, err := crypto.HexToECDSA(celoTestPrivateKey)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to decode private key", err)
+	}
+
+	tx := &celoDynamicFeeTxV2{
+		ChainID:   big.NewInt(celoAlfajoresChainID),
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       21000,
+		Value:     big.NewInt(0),
+	}
+
+	_, err = signCeloDynamicFeeTxV2(tx, big.NewInt(celoMainNetChainID), privKey)
+	if err == nil {
+		t.Fatalf("%s", "transaction with foreign chain id must not be signed")
+	}
+
+	tx.ChainID = big.NewInt(ethereumMainNetChainID)
+	_, err = signCeloDynamicFeeTxV2(tx, big.NewInt(ethereumMainNetChainID), privKey)
+	if err == nil {
+		t.Fatalf("%s", "celo transaction must not be signed for non-celo chain")
+	}
+}
+
+func TestMnemonicWalletUnit_SignData_CeloDynamicFeeTxV2(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	addressPath := &pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	}
+	expectedAddress := "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	poolUnit.dataSigner = types.LatestSignerForChainID(big.NewInt(celoMainNetChainID))
+
+	to := common.HexToAddress("0x90F79bf6EB2c4f870365E785982E1f101E93b906")
+	feeCurrency := common.HexToAddress("0x765DE816845861e75A25fCA122bb6898B8B1282a")
+	unsignedTx := &celoDynamicFeeTxV2{
+		ChainID:     big.NewInt(celoMainNetChainID),
+		Nonce:       3,
+		GasTipCap:   big.NewInt(1_000_000_000),
+		GasFeeCap:   big.NewInt(30_000_000_000),
+		Gas:         71000,
+		To:          &to,
+		Value:       big.NewInt(1500000),
+		FeeCurrency: &feeCurrency,
+		V:           new(big.Int),
+		R:           new(big.Int),
+		S:           new(big.Int),
+	}
+
+	dataForSign, err := unsignedTx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal binary data", err)
+	}
+
+	accountIdentity := &anypb.Any{}
+	_ = accountIdentity.MarshalFrom(addressPath)
+
+	addr, signedData, err := poolUnit.SignData(context.Background(), accountIdentity, dataForSign)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data:", err)
+	}
+
+	if addr == nil || expectedAddress != *addr {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	signedTx := &celoDynamicFeeTxV2{}
+	err = signedTx.UnmarshalBinary(signedData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unmarshal signed transaction", err)
+	}
+
+	sender, err := signedTx.Sender()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to recover sender", err)
+	}
+
+	if expectedAddress != sender.Hex() {
+		t.Fatalf("%s", "sender not equal with expected")
+	}
+
+	if *signedTx.FeeCurrency != feeCurrency {
+		t.Fatalf("%s", "fee currency not equal with expected")
+	}
+}
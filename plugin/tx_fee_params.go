@@ -0,0 +1,136 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// txFeeParams - gas limit and fee values of transaction, supplied by host.
+// gasPrice - legacy transactions, maxFeePerGas and maxPriorityFeePerGas - EIP-1559 transactions.
+// Numeric fields accept hex (0x-prefixed) or decimal strings.
+// Single source of fee rules and transaction assembly for transfer intents, sweep batches and CREATE2 factory calls
+type txFeeParams struct {
+	GasLimit             math.HexOrDecimal64   `json:"gasLimit"`
+	GasPrice             *math.HexOrDecimal256 `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *math.HexOrDecimal256 `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *math.HexOrDecimal256 `json:"maxPriorityFeePerGas,omitempty"`
+}
+
+func (p *txFeeParams) validate() error {
+	if p.GasLimit == 0 {
+		return ErrTransferIntentMissingGasLimit
+	}
+
+	if p.GasPrice != nil && (p.MaxFeePerGas != nil || p.MaxPriorityFeePerGas != nil) {
+		return ErrTransferIntentAmbiguousFees
+	}
+
+	if p.GasPrice == nil && (p.MaxFeePerGas == nil || p.MaxPriorityFeePerGas == nil) {
+		return ErrTransferIntentMissingFees
+	}
+
+	if p.MaxFeePerGas != nil &&
+		(*big.Int)(p.MaxPriorityFeePerGas).Cmp((*big.Int)(p.MaxFeePerGas)) > 0 {
+		return ErrTransferIntentWrongFeeCaps
+	}
+
+	return nil
+}
+
+// maxFee - maximal fee which can be charged for transaction - gasLimit * gasPrice or gasLimit * maxFeePerGas
+func (p *txFeeParams) maxFee() *big.Int {
+	feePerGas := (*big.Int)(p.GasPrice)
+	if feePerGas == nil {
+		feePerGas = (*big.Int)(p.MaxFeePerGas)
+	}
+
+	return new(big.Int).Mul(feePerGas, new(big.Int).SetUint64(uint64(p.GasLimit)))
+}
+
+func (p *txFeeParams) makeTxData(chainID *big.Int,
+	nonce uint64,
+	to *common.Address,
+	value *big.Int,
+	data []byte,
+) types.TxData {
+	if p.GasPrice != nil {
+		return &types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: new(big.Int).Set((*big.Int)(p.GasPrice)),
+			Gas:      uint64(p.GasLimit),
+			To:       to,
+			Value:    value,
+			Data:     data,
+		}
+	}
+
+	return &types.DynamicFeeTx{
+		ChainID:   new(big.Int).Set(chainID),
+		Nonce:     nonce,
+		GasTipCap: new(big.Int).Set((*big.Int)(p.MaxPriorityFeePerGas)),
+		GasFeeCap: new(big.Int).Set((*big.Int)(p.MaxFeePerGas)),
+		Gas:       uint64(p.GasLimit),
+		To:        to,
+		Value:     value,
+		Data:      data,
+	}
+}
+
+// makeCeloTx - Celo CIP-64 transaction with fee paid in ERC-20 fee currency, dynamic fee values required
+func (p *txFeeParams) makeCeloTx(chainID *big.Int,
+	nonce uint64,
+	to *common.Address,
+	value *big.Int,
+	data []byte,
+	feeCurrency common.Address,
+) *celoDynamicFeeTxV2 {
+	return &celoDynamicFeeTxV2{
+		ChainID:     new(big.Int).Set(chainID),
+		Nonce:       nonce,
+		GasTipCap:   new(big.Int).Set((*big.Int)(p.MaxPriorityFeePerGas)),
+		GasFeeCap:   new(big.Int).Set((*big.Int)(p.MaxFeePerGas)),
+		Gas:         uint64(p.GasLimit),
+		To:          to,
+		Value:       value,
+		Data:        data,
+		FeeCurrency: &feeCurrency,
+		V:           new(big.Int),
+		R:           new(big.Int),
+		S:           new(big.Int),
+	}
+}
@@ -0,0 +1,403 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	replacementModeBump   = "bump"
+	replacementModeCancel = "cancel"
+
+	// minReplacementPriceBump - default minimal price bump of geth and erigon tx pools (--txpool.pricebump)
+	minReplacementPriceBump = 10
+)
+
+var (
+	ErrReplacementUnknownMode      = errors.New("unknown replacement mode")
+	ErrReplacementPriceBumpTooLow  = errors.New("replacement price bump is lower than minimal tx pool price bump")
+	ErrReplacementUnsignedTx       = errors.New("transaction for replacement is not signed")
+	ErrReplacementSignerMismatch   = errors.New("transaction for replacement signed by another account")
+	ErrReplacementTxTypeNotSupport = errors.New("replacement of transaction type is not supported")
+)
+
+// replacementParams - parameters of replacement transaction.
+// Numeric fields accept hex (0x-prefixed) or decimal strings
+type replacementParams struct {
+	// Mode - bump or cancel
+	//   - bump - same transaction with bumped fee values
+	//   - cancel - zero-value self-transfer with same nonce and bumped fee values
+	Mode string `json:"mode"`
+	// PriceBump - fee bump in percents, can't be lower than 10%
+	PriceBump uint64 `json:"priceBump"`
+
+	// Optional fee floors, e.g. current network fee values. Used if greater than bumped fee values
+	GasPrice             *math.HexOrDecimal256 `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *math.HexOrDecimal256 `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *math.HexOrDecimal256 `json:"maxPriorityFeePerGas,omitempty"`
+}
+
+// signedReplacement - result of replacement transaction signature
+type signedReplacement struct {
+	signedTxSummary
+
+	Mode             string      `json:"mode"`
+	ReplacedTxHash   common.Hash `json:"replacedTxHash"`
+	ReplacedTxSender string      `json:"replacedTxSender"`
+}
+
+func (p *replacementParams) validate() error {
+	switch p.Mode {
+	case replacementModeBump, replacementModeCancel:
+	default:
+		return fmt.Errorf("%w: %s", ErrReplacementUnknownMode, p.Mode)
+	}
+
+	if p.PriceBump == 0 {
+		p.PriceBump = minReplacementPriceBump
+	}
+
+	if p.PriceBump < minReplacementPriceBump {
+		return fmt.Errorf("%w: have %d want %d", ErrReplacementPriceBumpTooLow,
+			p.PriceBump, minReplacementPriceBump)
+	}
+
+	return nil
+}
+
+// bumpFee - returns fee value which satisfy tx pool replacement rules:
+// new value must be strictly greater than old value and not lower than old * (100 + priceBump) / 100
+func bumpFee(oldValue *big.Int, priceBump uint64, floor *math.HexOrDecimal256) *big.Int {
+	threshold := new(big.Int).Mul(oldValue, new(big.Int).SetUint64(100+priceBump))
+	threshold.Add(threshold, big.NewInt(99))
+	threshold.Div(threshold, big.NewInt(100))
+
+	if threshold.Cmp(oldValue) <= 0 {
+		threshold.Add(oldValue, common.Big1)
+	}
+
+	if floor != nil && (*big.Int)(floor).Cmp(threshold) > 0 {
+		return new(big.Int).Set((*big.Int)(floor))
+	}
+
+	return threshold
+}
+
+// bumpDynamicFees - bump priority fee and fee cap of EIP-1559 like transaction, fee cap can't be lower than tip
+func bumpDynamicFees(oldGasTipCap, oldGasFeeCap *big.Int,
+	replacement *replacementParams,
+) (gasTipCap *big.Int, gasFeeCap *big.Int) {
+	gasTipCap = bumpFee(oldGasTipCap, replacement.PriceBump, replacement.MaxPriorityFeePerGas)
+	gasFeeCap = bumpFee(oldGasFeeCap, replacement.PriceBump, replacement.MaxFeePerGas)
+	if gasTipCap.Cmp(gasFeeCap) > 0 {
+		gasFeeCap = new(big.Int).Set(gasTipCap)
+	}
+
+	return gasTipCap, gasFeeCap
+}
+
+// makeCeloReplacementTx - prepare Celo CIP-64 replacement transaction with same nonce and fee currency.
+// Fees bumped in fee currency units. Cancel transaction keeps gas limit of replaced transaction if fee paid
+// in ERC-20 fee currency - intrinsic gas of fee currency debit and credit is greater than plain transfer gas
+func makeCeloReplacementTx(oldTx *celoDynamicFeeTxV2,
+	sender common.Address,
+	replacement *replacementParams,
+) *celoDynamicFeeTxV2 {
+	gasTipCap, gasFeeCap := bumpDynamicFees(oldTx.GasTipCap, oldTx.GasFeeCap, replacement)
+
+	replacementTx := &celoDynamicFeeTxV2{
+		ChainID:     new(big.Int).Set(oldTx.ChainID),
+		Nonce:       oldTx.Nonce,
+		GasTipCap:   gasTipCap,
+		GasFeeCap:   gasFeeCap,
+		Gas:         oldTx.Gas,
+		To:          oldTx.To,
+		Value:       oldTx.Value,
+		Data:        oldTx.Data,
+		AccessList:  oldTx.AccessList,
+		FeeCurrency: oldTx.FeeCurrency,
+	}
+
+	if replacement.Mode == replacementModeCancel {
+		replacementTx.To, replacementTx.Value, replacementTx.Data = &sender, new(big.Int), nil
+		replacementTx.AccessList = nil
+
+		if replacementTx.FeeCurrency == nil {
+			replacementTx.Gas = params.TxGas
+		}
+	}
+
+	return replacementTx
+}
+
+// makeReplacementTxData - prepare replacement transaction data with same nonce
+func makeReplacementTxData(oldTx *types.Transaction,
+	sender common.Address,
+	replacement *replacementParams,
+) (types.TxData, error) {
+	to, value, data, gas := oldTx.To(), oldTx.Value(), oldTx.Data(), oldTx.Gas()
+	accessList := oldTx.AccessList()
+
+	if replacement.Mode == replacementModeCancel {
+		to, value, data, gas = &sender, new(big.Int), nil, params.TxGas
+		accessList = nil
+	}
+
+	switch oldTx.Type() {
+	case types.LegacyTxType:
+		return &types.LegacyTx{
+			Nonce:    oldTx.Nonce(),
+			GasPrice: bumpFee(oldTx.GasPrice(), replacement.PriceBump, replacement.GasPrice),
+			Gas:      gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		}, nil
+
+	case types.AccessListTxType:
+		return &types.AccessListTx{
+			ChainID:    oldTx.ChainId(),
+			Nonce:      oldTx.Nonce(),
+			GasPrice:   bumpFee(oldTx.GasPrice(), replacement.PriceBump, replacement.GasPrice),
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}, nil
+
+	case types.DynamicFeeTxType:
+		gasTipCap, gasFeeCap := bumpDynamicFees(oldTx.GasTipCap(), oldTx.GasFeeCap(), replacement)
+
+		return &types.DynamicFeeTx{
+			ChainID:    oldTx.ChainId(),
+			Nonce:      oldTx.Nonce(),
+			GasTipCap:  gasTipCap,
+			GasFeeCap:  gasFeeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrReplacementTxTypeNotSupport, oldTx.Type())
+	}
+}
+
+// SignReplacement - sign speed-up or cancel transaction for previously signed transaction.
+// accountParameters - derivation path of previously signed transaction sender, signedTxData - raw signed transaction,
+// replacementParamsData - JSON-encoded replacementParams. Returns JSON-encoded signedReplacement
+func (u *mnemonicWalletUnit) SignReplacement(ctx context.Context,
+	accountParameters *anypb.Any,
+	signedTxData []byte,
+	replacementParamsData []byte,
+) (*string, []byte, error) {
+	accIdentity, err := u.resolveAccountIdentity(ctx, accountParameters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replacement := &replacementParams{}
+	err = json.Unmarshal(replacementParamsData, replacement)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to unmarshal replacement params: %w", err)
+	}
+
+	var (
+		result *signedReplacement
+		tx     *signingTx
+	)
+
+	switch {
+	case isCeloDynamicFeeTxV2Data(signedTxData) && !isCeloChainID(u.dataSigner.ChainID()):
+		return nil, nil, fmt.Errorf("%w: %d", ErrReplacementTxTypeNotSupport, celoDynamicFeeTxV2Type)
+
+	case isCeloDynamicFeeTxV2Data(signedTxData):
+		oldTx := &celoDynamicFeeTxV2{}
+		err = oldTx.UnmarshalBinary(signedTxData)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result, tx, err = u.makeCeloReplacement(ctx, accIdentity.AccountIndex,
+			accIdentity.InternalIndex,
+			accIdentity.AddressIndex,
+			oldTx, replacement)
+
+	default:
+		oldTx := &types.Transaction{}
+		err = oldTx.UnmarshalBinary(signedTxData)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result, tx, err = u.makeReplacement(ctx, accIdentity.AccountIndex,
+			accIdentity.InternalIndex,
+			accIdentity.AddressIndex,
+			oldTx, replacement)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = u.approveAccountTx(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	_, summary, err := u.signDecodedTx(ctx, accIdentity.AccountIndex,
+		accIdentity.InternalIndex,
+		accIdentity.AddressIndex,
+		tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.signedTxSummary = *summary
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	from := result.From.Hex()
+
+	return &from, resultData, nil
+}
+
+// makeReplacement - check sender of previously signed transaction and assemble replacement transaction.
+// Returns replacement description without signed transaction summary
+func (u *mnemonicWalletUnit) makeReplacement(ctx context.Context,
+	account, change, index uint32,
+	oldTx *types.Transaction,
+	replacement *replacementParams,
+) (*signedReplacement, *signingTx, error) {
+	err := replacement.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	V, R, S := oldTx.RawSignatureValues()
+	if V.Sign() == 0 && R.Sign() == 0 && S.Sign() == 0 {
+		return nil, nil, ErrReplacementUnsignedTx
+	}
+
+	oldSender, err := types.Sender(u.dataSigner, oldTx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address, err := u.getAddressByPath(ctx, account, change, index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sender := common.HexToAddress(*address)
+	if sender != oldSender {
+		return nil, nil, fmt.Errorf("%w: have %s want %s", ErrReplacementSignerMismatch,
+			oldSender.Hex(), sender.Hex())
+	}
+
+	txData, err := makeReplacementTxData(oldTx, sender, replacement)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &signedReplacement{
+		Mode:             replacement.Mode,
+		ReplacedTxHash:   oldTx.Hash(),
+		ReplacedTxSender: oldSender.Hex(),
+	}, &signingTx{tx: types.NewTx(txData)}, nil
+}
+
+// makeCeloReplacement - same as makeReplacement, but for Celo CIP-64 transaction
+func (u *mnemonicWalletUnit) makeCeloReplacement(ctx context.Context,
+	account, change, index uint32,
+	oldTx *celoDynamicFeeTxV2,
+	replacement *replacementParams,
+) (*signedReplacement, *signingTx, error) {
+	err := replacement.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if oldTx.V.Sign() == 0 && oldTx.R.Sign() == 0 && oldTx.S.Sign() == 0 {
+		return nil, nil, ErrReplacementUnsignedTx
+	}
+
+	oldSender, err := oldTx.Sender()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldTxHash, err := oldTx.Hash()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	address, err := u.getAddressByPath(ctx, account, change, index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sender := common.HexToAddress(*address)
+	if sender != oldSender {
+		return nil, nil, fmt.Errorf("%w: have %s want %s", ErrReplacementSignerMismatch,
+			oldSender.Hex(), sender.Hex())
+	}
+
+	return &signedReplacement{
+		Mode:             replacement.Mode,
+		ReplacedTxHash:   oldTxHash,
+		ReplacedTxSender: oldSender.Hex(),
+	}, &signingTx{celoTx: makeCeloReplacementTx(oldTx, sender, replacement)}, nil
+}
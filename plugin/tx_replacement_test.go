@@ -0,0 +1,292 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestBumpFee(t *testing.T) {
+	type testCase struct {
+		OldValue  int64
+		PriceBump uint64
+		Expected  int64
+	}
+
+	testCases := []*testCase{
+		{OldValue: 1_000_000_000, PriceBump: 10, Expected: 1_100_000_000},
+		{OldValue: 1_000_000_001, PriceBump: 10, Expected: 1_100_000_002},
+		{OldValue: 7, PriceBump: 10, Expected: 8},
+		{OldValue: 0, PriceBump: 10, Expected: 1},
+		{OldValue: 200, PriceBump: 25, Expected: 250},
+	}
+
+	for _, tCase := range testCases {
+		result := bumpFee(big.NewInt(tCase.OldValue), tCase.PriceBump, nil)
+		if result.Int64() != tCase.Expected {
+			t.Fatalf("%s: have %d want %d", "bumped fee not equal with expected",
+				result.Int64(), tCase.Expected)
+		}
+	}
+}
+
+func TestMnemonicWalletUnit_SignReplacement(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	addressPath := &pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	}
+	expectedAddress := common.HexToAddress("0xf8A0F16782625B16260D0A4b0Ed107412bd95d56")
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity := &anypb.Any{}
+	_ = accountIdentity.MarshalFrom(addressPath)
+
+	recipient := common.HexToAddress("0xBE0eB53F46cd790Cd13851d5EFf43D12404d33E8")
+	stuckTxData, err := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   poolUnit.dataSigner.ChainID(),
+		Nonce:     42,
+		GasTipCap: big.NewInt(1_000_000_000),
+		GasFeeCap: big.NewInt(20_000_000_000),
+		Gas:       50000,
+		To:        &recipient,
+		Value:     big.NewInt(1500000),
+		Data:      []byte{0x1, 0x2},
+	}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal binary data", err)
+	}
+
+	_, signedStuckTxData, err := poolUnit.SignData(context.Background(), accountIdentity, stuckTxData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data:", err)
+	}
+
+	stuckTx := &types.Transaction{}
+	_ = stuckTx.UnmarshalBinary(signedStuckTxData)
+
+	for _, mode := range []string{replacementModeBump, replacementModeCancel} {
+		_, resultData, loopErr := poolUnit.SignReplacement(context.Background(), accountIdentity,
+			signedStuckTxData, []byte(`{"mode":"`+mode+`","priceBump":10}`))
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", mode, "unable to sign replacement", loopErr)
+		}
+
+		result := &signedReplacement{}
+		_ = json.Unmarshal(resultData, result)
+
+		replacementTx := &types.Transaction{}
+		loopErr = replacementTx.UnmarshalBinary(result.RawTx)
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", mode, "unable to unmarshal replacement", loopErr)
+		}
+
+		sender, loopErr := types.Sender(poolUnit.dataSigner, replacementTx)
+		if loopErr != nil || sender != expectedAddress {
+			t.Fatalf("%s: %s", mode, "replacement sender not equal with expected")
+		}
+
+		if replacementTx.Nonce() != stuckTx.Nonce() {
+			t.Fatalf("%s: %s", mode, "replacement nonce not equal with expected")
+		}
+
+		if result.ReplacedTxHash != stuckTx.Hash() {
+			t.Fatalf("%s: %s", mode, "replaced tx hash not equal with expected")
+		}
+
+		// geth legacypool rule: new fee caps >= old * (100 + priceBump) / 100
+		minTip := big.NewInt(1_100_000_000)
+		minFeeCap := big.NewInt(22_000_000_000)
+		if replacementTx.GasTipCap().Cmp(minTip) < 0 || replacementTx.GasFeeCap().Cmp(minFeeCap) < 0 {
+			t.Fatalf("%s: %s", mode, "replacement fees are not bumped")
+		}
+
+		switch mode {
+		case replacementModeBump:
+			if *replacementTx.To() != recipient || replacementTx.Value().Cmp(stuckTx.Value()) != 0 ||
+				replacementTx.Gas() != stuckTx.Gas() {
+				t.Fatalf("%s: %s", mode, "replacement payload not equal with original")
+			}
+		case replacementModeCancel:
+			if *replacementTx.To() != expectedAddress || replacementTx.Value().Sign() != 0 ||
+				len(replacementTx.Data()) != 0 || replacementTx.Gas() != 21000 {
+				t.Fatalf("%s: %s", mode, "replacement is not zero-value self-transfer")
+			}
+		}
+	}
+
+	_, _, err = poolUnit.SignReplacement(context.Background(), accountIdentity,
+		signedStuckTxData, []byte(`{"mode":"bump","priceBump":5}`))
+	if err == nil {
+		t.Fatalf("%s", "replacement with low price bump must be rejected")
+	}
+
+	anotherAccountIdentity := &anypb.Any{}
+	_ = anotherAccountIdentity.MarshalFrom(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  10,
+	})
+
+	_, _, err = poolUnit.SignReplacement(context.Background(), anotherAccountIdentity,
+		signedStuckTxData, []byte(`{"mode":"cancel"}`))
+	if err == nil {
+		t.Fatalf("%s", "replacement with another signer path must be rejected")
+	}
+}
+
+func TestMnemonicWalletUnit_SignReplacement_CeloDynamicFeeTxV2(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	addressPath := &pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	}
+	expectedAddress := common.HexToAddress("0xf8A0F16782625B16260D0A4b0Ed107412bd95d56")
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity := &anypb.Any{}
+	_ = accountIdentity.MarshalFrom(addressPath)
+
+	recipient := common.HexToAddress("0x90F79bf6EB2c4f870365E785982E1f101E93b906")
+	feeCurrency := common.HexToAddress("0x765DE816845861e75A25fCA122bb6898B8B1282a")
+	stuckTxData, err := (&celoDynamicFeeTxV2{
+		ChainID:     big.NewInt(celoMainNetChainID),
+		Nonce:       3,
+		GasTipCap:   big.NewInt(1_000_000_000),
+		GasFeeCap:   big.NewInt(30_000_000_000),
+		Gas:         71000,
+		To:          &recipient,
+		Value:       big.NewInt(1500000),
+		FeeCurrency: &feeCurrency,
+		V:           new(big.Int),
+		R:           new(big.Int),
+		S:           new(big.Int),
+	}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal binary data", err)
+	}
+
+	_, _, err = poolUnit.SignReplacement(context.Background(), accountIdentity,
+		stuckTxData, []byte(`{"mode":"bump"}`))
+	if !errors.Is(err, ErrReplacementTxTypeNotSupport) {
+		t.Fatalf("%s", "celo transaction replacement must be rejected for non-celo chain")
+	}
+
+	poolUnit.dataSigner = types.LatestSignerForChainID(big.NewInt(celoMainNetChainID))
+
+	_, signedStuckTxData, err := poolUnit.SignData(context.Background(), accountIdentity, stuckTxData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign data:", err)
+	}
+
+	stuckTx := &celoDynamicFeeTxV2{}
+	_ = stuckTx.UnmarshalBinary(signedStuckTxData)
+	stuckTxHash, _ := stuckTx.Hash()
+
+	for _, mode := range []string{replacementModeBump, replacementModeCancel} {
+		_, resultData, loopErr := poolUnit.SignReplacement(context.Background(), accountIdentity,
+			signedStuckTxData, []byte(`{"mode":"`+mode+`","priceBump":10}`))
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", mode, "unable to sign replacement", loopErr)
+		}
+
+		result := &signedReplacement{}
+		_ = json.Unmarshal(resultData, result)
+
+		replacementTx := &celoDynamicFeeTxV2{}
+		loopErr = replacementTx.UnmarshalBinary(result.RawTx)
+		if loopErr != nil {
+			t.Fatalf("%s: %s: %e", mode, "unable to unmarshal replacement", loopErr)
+		}
+
+		sender, loopErr := replacementTx.Sender()
+		if loopErr != nil || sender != expectedAddress {
+			t.Fatalf("%s: %s", mode, "replacement sender not equal with expected")
+		}
+
+		if replacementTx.Nonce != stuckTx.Nonce || result.ReplacedTxHash != stuckTxHash {
+			t.Fatalf("%s: %s", mode, "replacement not bound to replaced transaction")
+		}
+
+		if *replacementTx.FeeCurrency != feeCurrency {
+			t.Fatalf("%s: %s", mode, "fee currency of replacement not equal with original")
+		}
+
+		if replacementTx.GasTipCap.Cmp(big.NewInt(1_100_000_000)) < 0 ||
+			replacementTx.GasFeeCap.Cmp(big.NewInt(33_000_000_000)) < 0 {
+			t.Fatalf("%s: %s", mode, "replacement fees are not bumped")
+		}
+
+		// cancel transaction with fee currency keeps gas limit of original transaction
+		if replacementTx.Gas != stuckTx.Gas {
+			t.Fatalf("%s: %s", mode, "replacement gas limit not equal with original")
+		}
+
+		if mode == replacementModeCancel && (*replacementTx.To != expectedAddress ||
+			replacementTx.Value.Sign() != 0 || len(replacementTx.Data) != 0) {
+			t.Fatalf("%s: %s", mode, "replacement is not zero-value self-transfer")
+		}
+	}
+}
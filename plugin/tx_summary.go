@@ -0,0 +1,142 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// txSummary - decoded transaction fields
+type txSummary struct {
+	From common.Address `json:"from"`
+
+	Type                 hexutil.Uint64  `json:"type"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	To                   *common.Address `json:"to"`
+	Value                *hexutil.Big    `json:"value"`
+	Data                 hexutil.Bytes   `json:"input"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas,omitempty"`
+	FeeCurrency          *common.Address `json:"feeCurrency,omitempty"`
+
+	// ContractAddress - address of deployed contract, only for contract creation transactions
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+}
+
+func (r *txSummary) fillFromTx(from common.Address, tx *types.Transaction) {
+	r.From = from
+	r.Type = hexutil.Uint64(tx.Type())
+	r.ChainID = (*hexutil.Big)(tx.ChainId())
+	r.Nonce = hexutil.Uint64(tx.Nonce())
+	r.To = tx.To()
+	r.Value = (*hexutil.Big)(tx.Value())
+	r.Data = tx.Data()
+	r.Gas = hexutil.Uint64(tx.Gas())
+
+	switch tx.Type() {
+	case types.LegacyTxType, types.AccessListTxType:
+		r.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	default:
+		r.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		r.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	if tx.To() == nil {
+		contractAddress := crypto.CreateAddress(from, tx.Nonce())
+		r.ContractAddress = &contractAddress
+	}
+}
+
+func (r *txSummary) fillFromCeloTx(from common.Address, tx *celoDynamicFeeTxV2) {
+	r.From = from
+	r.Type = celoDynamicFeeTxV2Type
+	r.ChainID = (*hexutil.Big)(tx.ChainID)
+	r.Nonce = hexutil.Uint64(tx.Nonce)
+	r.To = tx.To
+	r.Value = (*hexutil.Big)(tx.Value)
+	r.Data = tx.Data
+	r.Gas = hexutil.Uint64(tx.Gas)
+	r.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap)
+	r.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap)
+	r.FeeCurrency = tx.FeeCurrency
+
+	if tx.To == nil {
+		contractAddress := crypto.CreateAddress(from, tx.Nonce)
+		r.ContractAddress = &contractAddress
+	}
+}
+
+// signedTxSummary - signed transaction with decoded fields for logging
+type signedTxSummary struct {
+	txSummary
+
+	RawTx  hexutil.Bytes `json:"rawTx"`
+	TxHash common.Hash   `json:"txHash"`
+}
+
+func (r *signedTxSummary) fillFromTx(from common.Address, signedTx *types.Transaction) error {
+	rawTx, err := signedTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	r.txSummary.fillFromTx(from, signedTx)
+	r.RawTx = rawTx
+	r.TxHash = signedTx.Hash()
+
+	return nil
+}
+
+func (r *signedTxSummary) fillFromCeloTx(from common.Address, signedTx *celoDynamicFeeTxV2) error {
+	rawTx, err := signedTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	txHash, err := signedTx.Hash()
+	if err != nil {
+		return err
+	}
+
+	r.txSummary.fillFromCeloTx(from, signedTx)
+	r.RawTx = rawTx
+	r.TxHash = txHash
+
+	return nil
+}
@@ -44,12 +44,18 @@ const (
 	Bip44Purpose = 44
 
 	ethereumCoinNumber = 60
+
+	walletSeedLength = 64
+	// walletSecretsLength - length of protected buffer with seed, master private key and master chain code
+	walletSecretsLength = walletSeedLength + masterKeyDataLength
 )
 
-// wallet contains the individual mnemonic and seed
+// wallet contains the individual seed. Mnemonic not stored - it is dropped after seed derivation
 type wallet struct {
-	mnemonic string
-	seed     []byte
+	// secrets - protected buffer with seed, master private key and master chain code
+	secrets *protectedBuffer
+	// seed - slice of secrets buffer
+	seed []byte
 
 	*keyBundle
 }
@@ -60,17 +66,28 @@ func restore(mnemonic string, network *chaincfg.Params) (*wallet, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer clear(seed)
+
+	secrets, err := newProtectedBuffer(walletSecretsLength)
+	if err != nil {
+		return nil, err
+	}
+
+	protectedSeed := secrets.Bytes()[:walletSeedLength:walletSeedLength]
+	copy(protectedSeed, seed)
 
-	bundle, err := newBundledKeyBySeed(seed)
+	bundle, err := newBundledKeyByProtectedSeed(protectedSeed, secrets.Bytes()[walletSeedLength:])
 	if err != nil {
+		secrets.Destroy()
+
 		return nil, err
 	}
 
 	bundle.ExtendedKey.SetNet(network)
 
 	return &wallet{
-		mnemonic:  mnemonic,
-		seed:      seed,
+		secrets:   secrets,
+		seed:      protectedSeed,
 		keyBundle: bundle,
 	}, nil
 }
@@ -85,25 +102,18 @@ func (w *wallet) GetSeed() string {
 	return hex.EncodeToString(w.Seed())
 }
 
-// GetMnemonic return mnemonic string
-func (w *wallet) GetMnemonic() string {
-	return w.mnemonic
+// MasterFingerprint return hex string of BIP-0032 master key fingerprint
+func (w *wallet) MasterFingerprint() string {
+	return hex.EncodeToString(w.keyBundle.Fingerprint())
 }
 
 // ClearSecrets is function clear sensitive secrets data
 func (w *wallet) ClearSecrets() {
-	w.mnemonic = "0"
-
-	pattern := []byte{0x1, 0x2, 0x3, 0x4}
-	// Copy the pattern into the start of the container
-	copy(w.seed, pattern)
-	// Incrementally duplicate the pattern throughout the container
-	for j := len(pattern); j < len(w.seed); j *= 2 {
-		copy(w.seed[j:], w.seed[:j])
-	}
 	w.seed = nil
 
+	// master extended key points to secrets buffer - it must be cleared before buffer release
 	w.keyBundle.ClearSecrets()
+	w.secrets.Destroy()
 }
 
 // NewWalletFromMnemonic new HD-wallet via entropy
@@ -123,6 +133,7 @@ func newFromString(mnemo string, network *chaincfg.Params) (*wallet, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer clear(entropy)
 
 	mnemonic, err := bip39.NewMnemonic(entropy)
 	if err != nil {
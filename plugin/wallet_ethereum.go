@@ -36,6 +36,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/hex"
 	"fmt"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/ethereum/go-ethereum/crypto"
 )
@@ -75,6 +76,16 @@ func (w *wallet) NewAccount(account, change, index uint32) (*ethereumWallet, err
 	}, err
 }
 
+// NewChangePublicNode create public extended key of change level via mnemonic wallet
+func (w *wallet) NewChangePublicNode(account, change uint32) (*hdkeychain.ExtendedKey, error) {
+	return w.GetChangePublicKey(Bip44Purpose, uint32(pluginCoinType), account, change)
+}
+
+// NewHardenedPrivateKey create private key of fully hardened path with custom purpose via mnemonic wallet
+func (w *wallet) NewHardenedPrivateKey(purpose, account, change, index uint32) (*ecdsa.PrivateKey, error) {
+	return w.GetHardenedPrivateKey(purpose, uint32(pluginCoinType), account, change, index)
+}
+
 // GetAddress get address with 0x
 func (e *ethereumWallet) GetAddress() (string, error) {
 	return crypto.PubkeyToAddress(*e.extendedKey.PublicECDSA).Hex(), nil
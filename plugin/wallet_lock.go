@@ -0,0 +1,190 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrWalletLocked          = errors.New("wallet is locked, unlock required")
+	ErrWalletUnloaded        = errors.New("wallet is unloaded")
+	ErrWalletUnlockWrongTTL  = errors.New("wallet unlock time-to-live must be greater than zero")
+	ErrWalletAlreadyUnlocked = errors.New("wallet already unlocked")
+)
+
+// LockWallet - wipe seed, master key and cached private keys, but keep public data - addresses index and public
+// change nodes. Addresses of accounts with cached change nodes can be derived in locked state,
+// signing requires UnlockWallet call
+func (u *mnemonicWalletUnit) LockWallet() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.isUnloaded() {
+		return ErrWalletUnloaded
+	}
+
+	if u.isLocked {
+		return nil
+	}
+
+	u.lockWallet()
+
+	return nil
+}
+
+// UnlockWallet - restore private material of locked wallet for time-to-live. Mnemonic must have same
+// fingerprint as mnemonic of pool unit. After time-to-live expiration wallet locked automatically.
+// Time-to-live of already unlocked by UnlockWallet wallet can be extended by same call
+func (u *mnemonicWalletUnit) UnlockWallet(mnemonic string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrWalletUnlockWrongTTL
+	}
+
+	hdWalletSvc, err := newWalletFromMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.isUnloaded() {
+		hdWalletSvc.ClearSecrets()
+
+		return ErrWalletUnloaded
+	}
+
+	fingerprint := hdWalletSvc.MasterFingerprint()
+	if fingerprint != u.mnemonicHash {
+		hdWalletSvc.ClearSecrets()
+
+		return fmt.Errorf("%w: have %s want %s", ErrMnemonicFingerprintMismatch, fingerprint, u.mnemonicHash)
+	}
+
+	switch {
+	case u.isLocked:
+		u.hdWalletSvc = hdWalletSvc
+		u.isLocked = false
+
+	case u.unlockTimer != nil:
+		// extension of time-to-live - restored wallet not required
+		hdWalletSvc.ClearSecrets()
+
+	default:
+		// wallet without time-to-live must be locked before unlock
+		hdWalletSvc.ClearSecrets()
+
+		return ErrWalletAlreadyUnlocked
+	}
+
+	u.resetUnlockTimer(ttl)
+
+	return nil
+}
+
+// IsWalletLocked - returns lock state of wallet
+func (u *mnemonicWalletUnit) IsWalletLocked() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.isLocked
+}
+
+func (u *mnemonicWalletUnit) lockWallet() {
+	// change nodes of derived addresses cached before wipe - they are used for derivation in locked state.
+	// Failed node derivation must not block wipe of private material
+	for _, path := range u.addrIndex.uncachedChangeNodes() {
+		node, err := u.hdWalletSvc.NewChangePublicNode(path.AccountIndex, path.InternalIndex)
+		if err != nil {
+			continue
+		}
+
+		u.addrIndex.putChangeNode(path.AccountIndex, path.InternalIndex, node)
+	}
+
+	u.stopUnlockTimer()
+	u.clearAddressPool()
+
+	u.addressPool = make(map[string]*addressData)
+	u.keyStore = newProtectedKeyStore()
+
+	u.hdWalletSvc.ClearSecrets()
+	u.hdWalletSvc = nil
+	u.isLocked = true
+}
+
+func (u *mnemonicWalletUnit) resetUnlockTimer(ttl time.Duration) {
+	u.stopUnlockTimer()
+
+	generation := u.unlockGeneration
+	u.unlockTimer = time.AfterFunc(ttl, func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		// timer was stopped or reset after firing
+		if generation != u.unlockGeneration || u.isLocked || u.isUnloaded() {
+			return
+		}
+
+		u.lockWallet()
+	})
+}
+
+func (u *mnemonicWalletUnit) stopUnlockTimer() {
+	if u.unlockTimer != nil {
+		u.unlockTimer.Stop()
+		u.unlockTimer = nil
+	}
+
+	u.unlockGeneration++
+}
+
+// unlockedWallet - returns wallet if it's not locked or unloaded. Must be called under unit mutex
+func (u *mnemonicWalletUnit) unlockedWallet() (*wallet, error) {
+	if u.hdWalletSvc != nil {
+		return u.hdWalletSvc, nil
+	}
+
+	if u.isLocked {
+		return nil, ErrWalletLocked
+	}
+
+	return nil, ErrWalletUnloaded
+}
+
+func (u *mnemonicWalletUnit) isUnloaded() bool {
+	return u.addressPool == nil
+}
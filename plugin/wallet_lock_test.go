@@ -0,0 +1,190 @@
+/*
+ *
+ *
+ * MIT NON-AI License
+ *
+ * Copyright (c) 2022-2024 Aleksei Kotelnikov(gudron2s@gmail.com)
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of the software and associated documentation files (the "Software"),
+ * to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+ * and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions.
+ *
+ * The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+ *
+ * In addition, the following restrictions apply:
+ *
+ * 1. The Software and any modifications made to it may not be used for the purpose of training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining. This condition applies to any derivatives,
+ * modifications, or updates based on the Software code. Any usage of the Software in an AI-training dataset is considered a breach of this License.
+ *
+ * 2. The Software may not be included in any dataset used for training or improving machine learning algorithms,
+ * including but not limited to artificial intelligence, natural language processing, or data mining.
+ *
+ * 3. Any person or organization found to be in violation of these restrictions will be subject to legal action and may be held liable
+ * for any damages resulting from such use.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+ * DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE
+ * OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pbCommon "github.com/crypto-bundle/bc-wallet-common-hdwallet-controller/pkg/grpc/common"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestMnemonicWalletUnit_LockUnlock(t *testing.T) {
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	mnemonic := "unknown valid carbon hat echo funny artist letter desk absorb unit fatigue foil skirt stay case path rescue hawk remember aware arch regular cry"
+	// WARN: DO NOT USE THIS MNEMONIC IN MAINNET OR TESTNET. Usage only in unit-tests
+	anotherMnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	poolUnitIntrf, err := NewPoolUnit(uuid.NewString(), mnemonic)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to create mnemonic wallet pool unit:", err)
+	}
+
+	poolUnit, ok := poolUnitIntrf.(*mnemonicWalletUnit)
+	if !ok {
+		t.Fatalf("%s", "unable to cast interface to pool unit worker")
+	}
+
+	accountIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  9,
+	})
+	neighbourIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  7,
+		InternalIndex: 8,
+		AddressIndex:  10,
+	})
+	unknownIdentity, _ := anypb.New(&pbCommon.DerivationAddressIdentity{
+		AccountIndex:  1,
+		InternalIndex: 0,
+		AddressIndex:  0,
+	})
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		nil, 21000, nil, nil)
+	txData, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to marshal transaction", err)
+	}
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign transaction", err)
+	}
+
+	neighbourAddr, err := poolUnit.GetAccountAddress(context.Background(), neighbourIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get account address", err)
+	}
+
+	err = poolUnit.UnlockWallet(mnemonic, time.Minute)
+	if !errors.Is(err, ErrWalletAlreadyUnlocked) {
+		t.Fatalf("%s", "wallet without time-to-live must not be unlocked")
+	}
+
+	err = poolUnit.LockWallet()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to lock wallet", err)
+	}
+
+	if !poolUnit.IsWalletLocked() || poolUnit.hdWalletSvc != nil || len(poolUnit.addressPool) != 0 {
+		t.Fatalf("%s", "private material not wiped after lock")
+	}
+
+	// address derivation by cached public change node
+	lockedNeighbourAddr, err := poolUnit.GetAccountAddress(context.Background(), neighbourIdentity)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to get account address in locked state", err)
+	}
+
+	if *lockedNeighbourAddr != *neighbourAddr {
+		t.Fatalf("%s", "address of locked wallet not equal with expected")
+	}
+
+	_, err = poolUnit.GetAccountAddress(context.Background(), unknownIdentity)
+	if !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("%s", "address without cached change node must not be derived in locked state")
+	}
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("%s", "locked wallet must not sign transaction")
+	}
+
+	err = poolUnit.UnlockWallet(mnemonic, 0)
+	if !errors.Is(err, ErrWalletUnlockWrongTTL) {
+		t.Fatalf("%s", "zero time-to-live must not be accepted")
+	}
+
+	err = poolUnit.UnlockWallet(anotherMnemonic, time.Minute)
+	if !errors.Is(err, ErrMnemonicFingerprintMismatch) {
+		t.Fatalf("%s", "wallet must not be unlocked by another mnemonic")
+	}
+
+	err = poolUnit.UnlockWallet(mnemonic, time.Minute)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unlock wallet", err)
+	}
+
+	addr, _, err := poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to sign transaction by unlocked wallet", err)
+	}
+
+	if *addr != "0xf8A0F16782625B16260D0A4b0Ed107412bd95d56" {
+		t.Fatalf("%s", "address not equal with expected")
+	}
+
+	// time-to-live extension
+	err = poolUnit.UnlockWallet(mnemonic, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to extend wallet unlock", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !poolUnit.IsWalletLocked() {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s", "wallet not locked after time-to-live expiration")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, _, err = poolUnit.SignData(context.Background(), accountIdentity, txData)
+	if !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("%s", "wallet must not sign transaction after time-to-live expiration")
+	}
+
+	err = poolUnit.UnloadWallet()
+	if err != nil {
+		t.Fatalf("%s: %e", "unable to unload wallet", err)
+	}
+
+	err = poolUnit.LockWallet()
+	if !errors.Is(err, ErrWalletUnloaded) {
+		t.Fatalf("%s", "unloaded wallet must not be locked")
+	}
+
+	err = poolUnit.UnlockWallet(mnemonic, time.Minute)
+	if !errors.Is(err, ErrWalletUnloaded) {
+		t.Fatalf("%s", "unloaded wallet must not be unlocked")
+	}
+}